@@ -0,0 +1,105 @@
+package logx
+
+import (
+	"net/http"
+	"strings"
+	"time"
+)
+
+// AccessLogConfig 访问日志配置
+type AccessLogConfig struct {
+	Headers      []string // 记录的请求头，字段名为 header_ 加小写下划线形式，例如 User-Agent -> header_user_agent
+	ExcludePaths []string // 不记录的路径，以 * 结尾时按前缀匹配，例如 /healthz、/static/*
+}
+
+// AccessLogMiddleware 返回记录访问日志的中间件，每个请求一条日志：
+// method、path、remote_addr、request_id(来自 X-Request-Id)以及 status、bytes、latency，
+// 状态码为 5xx 时为 ERROR，其余为 INFO。日志和其他日志一样经过异步队列
+func AccessLogMiddleware(l *Logger, cfg AccessLogConfig) func(http.Handler) http.Handler {
+	headerKeys := make([]string, len(cfg.Headers))
+	for i, h := range cfg.Headers {
+		headerKeys[i] = "header_" + strings.ReplaceAll(strings.ToLower(h), "-", "_")
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if cfg.excluded(r.URL.Path) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			start := time.Now()
+			rec := &responseRecorder{ResponseWriter: w}
+			next.ServeHTTP(rec, r)
+
+			status := rec.status
+			if status == 0 {
+				status = http.StatusOK
+			}
+			fields := append(requestFields(r),
+				Int("status", status),
+				Int64("bytes", rec.bytes),
+				Duration("latency", time.Since(start)),
+			)
+			for i, h := range cfg.Headers {
+				if v := r.Header.Get(h); v != "" {
+					fields = append(fields, String(headerKeys[i], v))
+				}
+			}
+			level := INFO
+			if status >= 500 {
+				level = ERROR
+			}
+			l.log(level, "http request", fields)
+		})
+	}
+}
+
+func (c *AccessLogConfig) excluded(path string) bool {
+	for _, p := range c.ExcludePaths {
+		if prefix, ok := strings.CutSuffix(p, "*"); ok {
+			if strings.HasPrefix(path, prefix) {
+				return true
+			}
+		} else if path == p {
+			return true
+		}
+	}
+	return false
+}
+
+// 记录状态码和响应字节数
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func (r *responseRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *responseRecorder) Write(p []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	n, err := r.ResponseWriter.Write(p)
+	r.bytes += int64(n)
+	return n, err
+}
+
+// Flush 支持流式响应，会发出响应头
+func (r *responseRecorder) Flush() {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	if f, ok := r.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap 供 http.ResponseController 访问底层的 ResponseWriter
+func (r *responseRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
@@ -0,0 +1,57 @@
+package logx
+
+import "sync/atomic"
+
+// 默认每块的字段数
+const defaultArenaChunk = 4096
+
+// WithArena 实验性功能：日志的字段切片(调用时复制的字段、加上静态字段后的字段)从按块分配的大数组中切出，
+// 每块 chunkFields 个字段(<=0 时为 4096)，一块中的日志都写完且不再被引用后整块由 GC 一起回收，
+// 把每条日志一次的分配变为每块一次，适合每分钟产生数百万条日志的突发场景。
+// 代价是一块中只要有一条日志仍被引用(例如 WithDedup 记住的上一条、订阅者和异步输出目标中未处理的日志)，
+// 整块都不能回收，内存占用会高于默认方式
+func WithArena(chunkFields int) Option {
+	return func(l *Logger) {
+		if chunkFields <= 0 {
+			chunkFields = defaultArenaChunk
+		}
+		l.arena = &fieldArena{chunkFields: chunkFields}
+	}
+}
+
+// fieldArena 多个协程可以同时分配，用完的块不复用，交给 GC 回收
+type fieldArena struct {
+	chunkFields int
+	chunk       atomic.Pointer[arenaChunk]
+}
+
+type arenaChunk struct {
+	fields []Field
+	used   atomic.Int64
+}
+
+// alloc 返回长度为0、容量为 n 的切片，追加超过 n 个字段时会重新分配，不会覆盖相邻的日志
+func (a *fieldArena) alloc(n int) []Field {
+	if n > a.chunkFields/8 {
+		// 字段很多的日志单独分配，避免浪费块中剩余的空间
+		return make([]Field, 0, n)
+	}
+	for {
+		c := a.chunk.Load()
+		if c != nil {
+			if end := int(c.used.Add(int64(n))); end <= len(c.fields) {
+				return c.fields[end-n : end-n : end]
+			}
+		}
+		// 失败说明其他协程已经换了新块，重新从新块分配
+		a.chunk.CompareAndSwap(c, &arenaChunk{fields: make([]Field, a.chunkFields)})
+	}
+}
+
+// 分配容量为 n 的字段切片，未开启 WithArena 时直接分配
+func (l *Logger) allocFields(n int) []Field {
+	if l.arena == nil {
+		return make([]Field, 0, n)
+	}
+	return l.arena.alloc(n)
+}
@@ -0,0 +1,152 @@
+package logx
+
+import (
+	"os"
+	"sync"
+	"time"
+)
+
+// AsyncSinkConfig 异步输出目标配置
+type AsyncSinkConfig struct {
+	Workers   int // 并行调用的协程数，默认1
+	QueueSize int // 等待发送的最大条数，默认1024，队列满时 Write 阻塞
+	// Ordered 保证按写入顺序调用 Write。每条日志带有序号，先完成 Prepare 的日志在重排缓冲区中
+	// 等待前面的日志，Write 不会并发调用；为 false 时各协程并发调用 Write，顺序不确定，吞吐更高
+	Ordered bool
+}
+
+// SinkPreparer 输出目标可以实现该接口，把转换格式、压缩等耗时的处理放在 Prepare 中，
+// AsyncSink 在多个协程中并行调用 Prepare，再把返回值作为 line 交给 Write。
+// Prepare 返回错误时这条日志不会写入
+type SinkPreparer interface {
+	Prepare(entry *Entry, line []byte) ([]byte, error)
+}
+
+// AsyncSink 在后台协程中调用被包装的输出目标，慢速的网络输出目标不再阻塞写入协程。
+// 日志和一行的内容会被复制，失败通过 AsyncErrorReporter 报告；带有 ExpiresKey 字段的日志过期后不再发送
+type AsyncSink struct {
+	sink    Sink
+	cfg     AsyncSinkConfig
+	queue   chan asyncItem
+	wg      sync.WaitGroup
+	onError func(error)
+	closeMu sync.RWMutex // Close 关闭队列时不能有正在进行的 Write
+
+	mu      sync.Mutex // 保护以下字段以及 Ordered 时对 sink.Write 的调用
+	seq     uint64     // 最后分配的序号
+	next    uint64     // 下一条要写入的序号
+	pending map[uint64]asyncItem
+}
+
+type asyncItem struct {
+	seq   uint64
+	entry Entry
+	line  []byte
+	err   error
+}
+
+// NewAsyncSink 包装 sink 并启动后台协程
+func NewAsyncSink(sink Sink, cfg AsyncSinkConfig) *AsyncSink {
+	if cfg.Workers < 1 {
+		cfg.Workers = 1
+	}
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = 1024
+	}
+	s := &AsyncSink{
+		sink:    sink,
+		cfg:     cfg,
+		queue:   make(chan asyncItem, cfg.QueueSize),
+		next:    1,
+		pending: make(map[uint64]asyncItem),
+	}
+	for i := 0; i < cfg.Workers; i++ {
+		s.wg.Add(1)
+		go s.run(s.queue)
+	}
+	return s
+}
+
+// SetErrorHandler 实现 AsyncErrorReporter
+func (s *AsyncSink) SetErrorHandler(handler func(error)) {
+	s.onError = handler
+}
+
+func (s *AsyncSink) report(err error) {
+	if s.onError != nil {
+		s.onError(err)
+		return
+	}
+	os.Stderr.WriteString("logx: async sink: " + err.Error() + "\n")
+}
+
+func (s *AsyncSink) Write(entry *Entry, line []byte) error {
+	s.closeMu.RLock()
+	defer s.closeMu.RUnlock()
+	if s.queue == nil {
+		return os.ErrClosed
+	}
+	item := asyncItem{entry: *entry, line: append([]byte(nil), line...)}
+	item.entry.Fields = append([]Field(nil), entry.Fields...)
+	s.mu.Lock()
+	s.seq++
+	item.seq = s.seq
+	s.mu.Unlock()
+	s.queue <- item
+	return nil
+}
+
+func (s *AsyncSink) run(queue <-chan asyncItem) {
+	defer s.wg.Done()
+	preparer, _ := s.sink.(SinkPreparer)
+	for item := range queue {
+		if preparer != nil {
+			item.line, item.err = preparer.Prepare(&item.entry, item.line)
+		}
+		if !s.cfg.Ordered {
+			s.write(item)
+			continue
+		}
+		s.mu.Lock()
+		s.pending[item.seq] = item
+		// 按序号写出重排缓冲区中已经连续的日志
+		for {
+			ready, ok := s.pending[s.next]
+			if !ok {
+				break
+			}
+			delete(s.pending, s.next)
+			s.next++
+			s.write(ready)
+		}
+		s.mu.Unlock()
+	}
+}
+
+func (s *AsyncSink) write(item asyncItem) {
+	if item.entry.Expired(time.Now()) {
+		// 在队列中等待时过期，不再发送
+		return
+	}
+	err := item.err
+	if err == nil {
+		err = s.sink.Write(&item.entry, item.line)
+	}
+	if err != nil {
+		s.report(err)
+	}
+}
+
+// Close 写完队列中的日志后关闭被包装的输出目标
+func (s *AsyncSink) Close() error {
+	s.closeMu.Lock()
+	if s.queue == nil {
+		s.closeMu.Unlock()
+		return nil
+	}
+	close(s.queue)
+	s.queue = nil
+	s.closeMu.Unlock()
+	s.wg.Wait()
+	return s.sink.Close()
+}
@@ -0,0 +1,229 @@
+package logx
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"strings"
+)
+
+// 审计模式追加的字段，hash 必须是每行的最后一个字段
+const (
+	auditPrevKey = "prev_hash"
+	auditHashKey = "hash"
+)
+
+// AuditGenesis 哈希链第一条日志的 prev_hash
+var AuditGenesis = strings.Repeat("0", sha256.Size*2)
+
+// WithAudit 开启审计模式：每条日志在末尾追加上一条日志的哈希 prev_hash 以及本行的哈希 hash，
+// 修改、插入或删除中间的记录都会使 VerifyAuditLog 失败。key 不为空时 hash 为 HMAC-SHA256，
+// 没有密钥无法重新计算整条链；为空时为 SHA-256。
+// 哈希链跨文件切割和进程重启延续(创建时从当前文件或最新的归档的最后一条日志继续)；
+// 审计日志不应同时开启采样、合并重复日志或多进程写入
+func WithAudit(key []byte) Option {
+	return func(l *Logger) {
+		l.audit = newAuditChain(key)
+	}
+}
+
+type auditChain struct {
+	mac  hash.Hash
+	prev [sha256.Size * 2]byte // 上一行的哈希(十六进制)
+	buf  []byte
+}
+
+func newAuditChain(key []byte) *auditChain {
+	c := &auditChain{mac: sha256.New()}
+	if len(key) > 0 {
+		c.mac = hmac.New(sha256.New, key)
+	}
+	copy(c.prev[:], AuditGenesis)
+	return c
+}
+
+// 从上次运行写入的最后一条日志继续哈希链：依次查找当前文件和从新到旧的归档，
+// 找到带哈希的一行为止；都没有时从 AuditGenesis 开始。在创建时切割之前调用
+func (l *Logger) resumeAuditChain() {
+	paths := []string{l.filePath}
+	archives, err := Archives(l.filePath)
+	if err != nil {
+		l.handleError(OpRotate, l.filePath, err)
+	}
+	for i := len(archives) - 1; i >= 0; i-- {
+		paths = append(paths, archives[i])
+	}
+	for _, path := range paths {
+		last, err := lastAuditHash(path, l.encryption)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			// 读不出上一条哈希时不跳到更早的文件，从起点开始，校验时会在这里断开
+			l.handleError(OpRotate, path, err)
+			return
+		}
+		if last != nil {
+			copy(l.audit.prev[:], last)
+			return
+		}
+	}
+}
+
+// 返回文件中最后一条带哈希的日志的 hash，没有时为 nil；压缩和加密的文件会先解开
+func lastAuditHash(path string, keys KeyProvider) ([]byte, error) {
+	reader, closeFile, err := openLogReader(path, keys)
+	if err != nil {
+		return nil, err
+	}
+	defer closeFile()
+	var last []byte
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(line) > 0 && line[len(line)-1] == '\n' {
+			if _, _, sum, ok := splitAuditLine(line); ok {
+				last = append(last[:0], sum...)
+			}
+		}
+		if err == io.EOF {
+			return last, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// 返回追加了哈希字段的一行，结果在下一次调用前有效，调用方需持有 l.mu
+func (c *auditChain) seal(line []byte) []byte {
+	json := len(line) > 0 && line[0] == '{'
+	buf := append(c.buf[:0], line[:len(line)-auditTrim(json)]...)
+	if json {
+		buf = append(buf, `,"`+auditPrevKey+`":"`...)
+		buf = append(buf, c.prev[:]...)
+		buf = append(buf, '"')
+	} else {
+		buf = append(buf, " "+auditPrevKey+"="...)
+		buf = append(buf, c.prev[:]...)
+	}
+	sum := auditSum(c.mac, buf)
+	if json {
+		buf = append(buf, `,"`+auditHashKey+`":"`...)
+		buf = append(buf, sum...)
+		buf = append(buf, "\"}\n"...)
+	} else {
+		buf = append(buf, " "+auditHashKey+"="...)
+		buf = append(buf, sum...)
+		buf = append(buf, '\n')
+	}
+	copy(c.prev[:], sum)
+	c.buf = buf
+	return buf
+}
+
+// JSON 行去掉结尾的 "}\n"，文本行去掉 "\n"
+func auditTrim(json bool) int {
+	if json {
+		return 2
+	}
+	return 1
+}
+
+func auditSum(mac hash.Hash, content []byte) []byte {
+	mac.Reset()
+	mac.Write(content)
+	return hex.AppendEncode(nil, mac.Sum(nil))
+}
+
+// AuditSummary 校验通过的审计日志概况
+type AuditSummary struct {
+	Entries   int
+	FirstPrev string // 第一条日志的 prev_hash，等于 AuditGenesis 时表示链的起点，否则应等于上一个文件的 LastHash
+	LastHash  string // 最后一条日志的哈希
+}
+
+// AuditError 审计日志校验失败的位置和原因
+type AuditError struct {
+	Path   string
+	Line   int
+	Reason string
+}
+
+func (e *AuditError) Error() string {
+	return fmt.Sprintf("logx: audit log %s line %d: %s", e.Path, e.Line, e.Reason)
+}
+
+// VerifyAuditLog 校验 WithAudit 写入的日志文件的哈希链，key 需与写入时一致(未使用 HMAC 时为 nil)。
+// 切割出的多个文件按时间顺序校验时，后一个文件的 FirstPrev 应等于前一个文件的 LastHash
+func VerifyAuditLog(path string, key []byte) (AuditSummary, error) {
+	var summary AuditSummary
+	file, err := os.Open(path)
+	if err != nil {
+		return summary, err
+	}
+	defer file.Close()
+
+	c := newAuditChain(key)
+	reader := bufio.NewReader(file)
+	for n := 1; ; n++ {
+		line, err := reader.ReadBytes('\n')
+		if len(line) == 0 && err != nil {
+			break
+		}
+		fail := func(reason string) (AuditSummary, error) {
+			return summary, &AuditError{Path: path, Line: n, Reason: reason}
+		}
+		if line[len(line)-1] != '\n' {
+			return fail("truncated entry")
+		}
+		content, prev, sum, ok := splitAuditLine(line)
+		if !ok {
+			return fail("missing audit fields")
+		}
+		if summary.Entries == 0 {
+			summary.FirstPrev = string(prev)
+		} else if !bytes.Equal(prev, c.prev[:]) {
+			return fail("prev_hash does not match previous entry (entry removed or reordered)")
+		}
+		if !hmac.Equal(auditSum(c.mac, content), sum) {
+			return fail("hash mismatch (entry modified or wrong key)")
+		}
+		copy(c.prev[:], sum)
+		summary.Entries++
+		summary.LastHash = string(sum)
+	}
+	return summary, nil
+}
+
+// 拆分出参与计算哈希的内容、prev_hash 和 hash
+func splitAuditLine(line []byte) (content, prev, sum []byte, ok bool) {
+	size := sha256.Size * 2
+	prevSep, hashSep, tail := " "+auditPrevKey+"=", " "+auditHashKey+"=", "\n"
+	if line[0] == '{' {
+		prevSep, hashSep, tail = `,"`+auditPrevKey+`":"`, `,"`+auditHashKey+`":"`, "\"}\n"
+	}
+	i := bytes.LastIndex(line, []byte(hashSep))
+	if i < 0 || len(line) != i+len(hashSep)+size+len(tail) || string(line[len(line)-len(tail):]) != tail {
+		return nil, nil, nil, false
+	}
+	content, sum = line[:i], line[i+len(hashSep):i+len(hashSep)+size]
+	j := bytes.LastIndex(content, []byte(prevSep))
+	if j < 0 {
+		return nil, nil, nil, false
+	}
+	prev = content[j+len(prevSep):]
+	if line[0] == '{' {
+		prev = bytes.TrimSuffix(prev, []byte(`"`))
+	}
+	if len(prev) != size {
+		return nil, nil, nil, false
+	}
+	return content, prev, sum, true
+}
@@ -0,0 +1,120 @@
+package logx
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// BackfillOptions 补录配置
+type BackfillOptions struct {
+	Since    time.Time // 只补录不早于该时间的日志，零值表示不限制
+	Until    time.Time // 只补录早于该时间的日志，零值表示不限制
+	MinLevel LogLevel  // 只补录不低于该等级的日志
+}
+
+// Archives 返回日志文件切割产生的归档(包括压缩后的)，按切割时间从早到晚排列
+func Archives(logPath string) ([]string, error) {
+	matches, err := filepath.Glob(logPath + ".*.log*")
+	if err != nil {
+		return nil, err
+	}
+	var archives []string
+	for _, m := range matches {
+		if _, ok := archiveStamp(logPath, m); ok {
+			archives = append(archives, m)
+		}
+	}
+	sort.Slice(archives, func(i, j int) bool {
+		a, _ := archiveStamp(logPath, archives[i])
+		b, _ := archiveStamp(logPath, archives[j])
+		return a < b
+	})
+	return archives, nil
+}
+
+// 归档文件名为 <logPath>.20060102_150405[_序号].log[.压缩编码]，同一秒内多次切割时从第二个开始带序号。
+// 返回可以按字符串排序的切割时间
+func archiveStamp(logPath, path string) (string, bool) {
+	rest := strings.TrimPrefix(path, logPath+".")
+	stamp, ext, ok := strings.Cut(rest, ".log")
+	if !ok || len(stamp) < len(archiveStampLayout) {
+		return "", false
+	}
+	seq := 0
+	if suffix := stamp[len(archiveStampLayout):]; suffix != "" {
+		n, err := strconv.Atoi(strings.TrimPrefix(suffix, "_"))
+		if err != nil || suffix[0] != '_' || n <= 0 {
+			return "", false
+		}
+		seq = n
+	}
+	stamp = fmt.Sprintf("%s_%06d", stamp[:len(archiveStampLayout)], seq)
+	if ext != "" {
+		if _, ok := LookupCompressor(strings.TrimPrefix(ext, ".")); !ok {
+			return "", false
+		}
+	}
+	return stamp, true
+}
+
+// Backfill 按顺序读取归档文件并写入输出目标，日志保持原始时间，用于采集端长时间故障后补录。
+// 压缩过的归档按扩展名找到对应的压缩编码解压；输出目标实现了 Flush() error 时在结束后调用。
+// 返回写入的条数，出错时返回已写入的条数和错误，可以据此从出错的文件继续
+func Backfill(ctx context.Context, paths []string, sink Sink, opts BackfillOptions) (int, error) {
+	total := 0
+	for _, path := range paths {
+		n, err := backfillFile(ctx, path, sink, opts)
+		total += n
+		if err != nil {
+			return total, fmt.Errorf("logx: backfill %s: %w", path, err)
+		}
+	}
+	if f, ok := sink.(interface{ Flush() error }); ok {
+		if err := f.Flush(); err != nil {
+			return total, err
+		}
+	}
+	return total, nil
+}
+
+func backfillFile(ctx context.Context, path string, sink Sink, opts BackfillOptions) (int, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	var r io.Reader = file
+	if ext := strings.TrimPrefix(filepath.Ext(path), "."); ext != "log" {
+		if c, ok := LookupCompressor(ext); ok {
+			zr, err := c.NewReader(file)
+			if err != nil {
+				return 0, err
+			}
+			defer zr.Close()
+			r = zr
+		}
+	}
+	written := 0
+	_, err = Replay(ctx, r, func(entry Entry) error {
+		if !opts.Since.IsZero() && entry.Time.Before(opts.Since) {
+			return nil
+		}
+		if !opts.Until.IsZero() && !entry.Time.Before(opts.Until) {
+			return nil
+		}
+		if err := sink.Write(&entry, encodeReplayLine(entry)); err != nil {
+			return err
+		}
+		written++
+		return nil
+	}, ReplayOptions{MinLevel: opts.MinLevel})
+	return written, err
+}
@@ -0,0 +1,179 @@
+package logx
+
+import (
+	"os"
+	"sync"
+	"time"
+)
+
+// BatchConfig 批量发送的参数，任意一个条件先满足就发送当前批次
+type BatchConfig struct {
+	MaxEntries int           // 每批最多条数，默认500
+	MaxBytes   int           // 每批编码后(压缩前)的最大字节数，默认1MB，单条超过时单独成批
+	MaxAge     time.Duration // 第一条日志进入批次后最多等待的时间，默认1秒
+	Compressor Compressor    // 每批发送前压缩，为空时不压缩
+	// Priority 命中任意一条规则的日志写入后立即发送当前批次，不等待攒批，
+	// 例如安全审计日志需要尽快送达，普通日志仍按批发送
+	Priority []PriorityRule
+}
+
+// PriorityRule 判断一条日志是否需要立即发送
+type PriorityRule func(entry *Entry) bool
+
+// PriorityLevel 不低于 level 的日志立即发送
+func PriorityLevel(level LogLevel) PriorityRule {
+	return func(entry *Entry) bool { return entry.Level >= level }
+}
+
+// PriorityTags 带有任意一个标签的日志立即发送，标签通过 Tags 字段设置
+func PriorityTags(tags ...string) PriorityRule {
+	return func(entry *Entry) bool {
+		for _, tag := range tags {
+			if entry.HasTag(tag) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+func (c *BatchConfig) urgent(entry *Entry) bool {
+	for _, rule := range c.Priority {
+		if rule(entry) {
+			return true
+		}
+	}
+	return false
+}
+
+// BatchFlusher 发送一批日志。body 为按行拼接的日志，经过压缩时 encoding 为压缩编码名称，否则为空。
+// body 在 Flush 返回后会被复用
+type BatchFlusher interface {
+	Flush(body []byte, count int, encoding string) error
+}
+
+// BatchSink 把日志攒成批次后交给 BatchFlusher 发送，适合按请求计费或限制请求大小的日志服务。
+// 定时发送失败时通过 AsyncErrorReporter 报告，没有设置回调时在下一次 Write 时返回
+type BatchSink struct {
+	flusher BatchFlusher
+	cfg     BatchConfig
+	onError func(error)
+
+	mu      sync.Mutex
+	buf     []byte
+	count   int
+	timer   *time.Timer
+	lastErr error // 没有 onError 时定时发送失败的错误，在下一次 Write 时返回
+	closed  bool
+}
+
+// NewBatchSink 创建批量输出目标
+func NewBatchSink(flusher BatchFlusher, cfg BatchConfig) *BatchSink {
+	if cfg.MaxEntries <= 0 {
+		cfg.MaxEntries = 500
+	}
+	if cfg.MaxBytes <= 0 {
+		cfg.MaxBytes = 1 << 20
+	}
+	if cfg.MaxAge <= 0 {
+		cfg.MaxAge = time.Second
+	}
+	return &BatchSink{flusher: flusher, cfg: cfg}
+}
+
+// SetErrorHandler 实现 AsyncErrorReporter
+func (s *BatchSink) SetErrorHandler(handler func(error)) {
+	s.onError = handler
+}
+
+func (s *BatchSink) Write(entry *Entry, line []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return os.ErrClosed
+	}
+	err := s.lastErr
+	s.lastErr = nil
+	if s.count > 0 && len(s.buf)+len(line) > s.cfg.MaxBytes {
+		if ferr := s.flushLocked(); ferr != nil {
+			err = ferr
+		}
+	}
+	s.buf = append(s.buf, line...)
+	s.count++
+	if s.count >= s.cfg.MaxEntries || len(s.buf) >= s.cfg.MaxBytes || s.cfg.urgent(entry) {
+		if ferr := s.flushLocked(); ferr != nil {
+			err = ferr
+		}
+	} else if s.count == 1 {
+		s.startTimer()
+	}
+	return err
+}
+
+func (s *BatchSink) startTimer() {
+	if s.timer == nil {
+		s.timer = time.AfterFunc(s.cfg.MaxAge, s.flushOnTimer)
+		return
+	}
+	s.timer.Reset(s.cfg.MaxAge)
+}
+
+func (s *BatchSink) flushOnTimer() {
+	s.mu.Lock()
+	err := s.flushLocked()
+	if err != nil && s.onError == nil {
+		s.lastErr = err
+	}
+	s.mu.Unlock()
+	if err != nil && s.onError != nil {
+		// 不持有锁，回调中可以再写入
+		s.onError(err)
+	}
+}
+
+// Flush 立即发送当前批次
+func (s *BatchSink) Flush() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.flushLocked()
+}
+
+// 失败时丢弃这一批，避免积压的日志无限增长
+func (s *BatchSink) flushLocked() error {
+	if s.count == 0 {
+		return nil
+	}
+	if s.timer != nil {
+		s.timer.Stop()
+	}
+	body, count := s.buf, s.count
+	s.buf, s.count = s.buf[:0], 0
+
+	encoding := ""
+	if s.cfg.Compressor != nil {
+		compressed, err := Compress(s.cfg.Compressor, body)
+		if err != nil {
+			return err
+		}
+		body, encoding = compressed, s.cfg.Compressor.Name()
+	}
+	return s.flusher.Flush(body, count, encoding)
+}
+
+// Close 发送剩余的日志，flusher 实现了 Close 时一并关闭
+func (s *BatchSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	err := s.flushLocked()
+	if c, ok := s.flusher.(interface{ Close() error }); ok {
+		if cerr := c.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
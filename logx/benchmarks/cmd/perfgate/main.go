@@ -0,0 +1,158 @@
+// perfgate 读取 go test -bench 的输出，按 budgets.json 检查 logx 相对 zap、zerolog 的耗时比例和内存分配，
+// 输出对比表格，超出预算时以状态码1退出，用于 CI：
+//
+//	go test -run '^$' -bench . -count 5 . | go run ./cmd/perfgate -budgets budgets.json
+//
+// 同一个基准运行多次(-count)时取中位数
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// 一个场景的预算
+type budget struct {
+	Ratio     map[string]float64 `json:"ratio"`      // logx 的 ns/op 最多是各库的多少倍
+	MaxAllocs *float64           `json:"max_allocs"` // logx 每次调用最多的分配次数
+}
+
+// 一个基准多次运行的结果，键为单位，例如 ns/op
+type samples map[string][]float64
+
+func main() {
+	budgetsPath := flag.String("budgets", "budgets.json", "预算文件")
+	flag.Parse()
+
+	data, err := os.ReadFile(*budgetsPath)
+	if err != nil {
+		fail(err)
+	}
+	var budgets map[string]budget
+	if err := json.Unmarshal(data, &budgets); err != nil {
+		fail(fmt.Errorf("%s: %w", *budgetsPath, err))
+	}
+	var in io.Reader = os.Stdin
+	if flag.NArg() > 0 {
+		file, err := os.Open(flag.Arg(0))
+		if err != nil {
+			fail(err)
+		}
+		defer file.Close()
+		in = file
+	}
+	results, err := parse(in)
+	if err != nil {
+		fail(err)
+	}
+	problems := check(os.Stdout, budgets, results)
+	if len(problems) > 0 {
+		fmt.Fprintln(os.Stderr, "perfgate: FAIL\n  "+strings.Join(problems, "\n  "))
+		os.Exit(1)
+	}
+	fmt.Println("perfgate: PASS")
+}
+
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, "perfgate:", err)
+	os.Exit(2)
+}
+
+// 解析 "BenchmarkTenFields/logx-8  200000  3367 ns/op  0 dropped/op  680 B/op  5 allocs/op"，
+// 返回 场景 -> 库 -> 结果
+func parse(r io.Reader) (map[string]map[string]samples, error) {
+	results := map[string]map[string]samples{}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 4 || !strings.HasPrefix(fields[0], "Benchmark") {
+			continue
+		}
+		name := strings.TrimPrefix(fields[0], "Benchmark")
+		if i := strings.LastIndexByte(name, '-'); i > 0 {
+			name = name[:i]
+		}
+		scenario, lib, ok := strings.Cut(name, "/")
+		if !ok {
+			continue
+		}
+		if results[scenario] == nil {
+			results[scenario] = map[string]samples{}
+		}
+		if results[scenario][lib] == nil {
+			results[scenario][lib] = samples{}
+		}
+		for i := 2; i+1 < len(fields); i += 2 {
+			v, err := strconv.ParseFloat(fields[i], 64)
+			if err != nil {
+				return nil, fmt.Errorf("bad benchmark line %q", scanner.Text())
+			}
+			results[scenario][lib][fields[i+1]] = append(results[scenario][lib][fields[i+1]], v)
+		}
+	}
+	return results, scanner.Err()
+}
+
+func median(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	return sorted[len(sorted)/2]
+}
+
+// 输出 markdown 表格并返回超出预算的项
+func check(w io.Writer, budgets map[string]budget, results map[string]map[string]samples) []string {
+	var problems []string
+	scenarios := make([]string, 0, len(budgets))
+	for name := range budgets {
+		scenarios = append(scenarios, name)
+	}
+	sort.Strings(scenarios)
+	fmt.Fprintln(w, "| scenario | library | ns/op | allocs/op | logx ratio | budget |")
+	fmt.Fprintln(w, "|---|---|---|---|---|---|")
+	for _, name := range scenarios {
+		b := budgets[name]
+		logx, ok := results[name]["logx"]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("%s: no logx result", name))
+			continue
+		}
+		logxNs := median(logx["ns/op"])
+		allocs := median(logx["allocs/op"])
+		fmt.Fprintf(w, "| %s | logx | %.1f | %.0f | | |\n", name, logxNs, allocs)
+		if dropped := median(logx["dropped/op"]); dropped > 0 {
+			problems = append(problems, fmt.Sprintf("%s: logx dropped %.3f entries per call", name, dropped))
+		}
+		if b.MaxAllocs != nil && allocs > *b.MaxAllocs {
+			problems = append(problems, fmt.Sprintf("%s: logx %.0f allocs/op exceeds %.0f", name, allocs, *b.MaxAllocs))
+		}
+		libs := make([]string, 0, len(b.Ratio))
+		for lib := range b.Ratio {
+			libs = append(libs, lib)
+		}
+		sort.Strings(libs)
+		for _, lib := range libs {
+			other, ok := results[name][lib]
+			if !ok {
+				problems = append(problems, fmt.Sprintf("%s: no %s result", name, lib))
+				continue
+			}
+			otherNs := median(other["ns/op"])
+			ratio := logxNs / otherNs
+			fmt.Fprintf(w, "| %s | %s | %.1f | %.0f | %.2f | %.2f |\n", name, lib, otherNs, median(other["allocs/op"]), ratio, b.Ratio[lib])
+			if ratio > b.Ratio[lib] {
+				problems = append(problems, fmt.Sprintf("%s: logx is %.2fx %s, budget %.2fx", name, ratio, lib, b.Ratio[lib]))
+			}
+		}
+	}
+	return problems
+}
@@ -0,0 +1,180 @@
+package benchmarks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/capyflow/opensource/logx"
+	"github.com/rs/zerolog"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// 三个库都写入临时目录中的文件，使用JSON格式。logx 的计时包含等待异步写入完成，
+// 队列满时丢弃的条数报告为 dropped/op，perfgate 要求为0
+
+var (
+	errExample = errors.New("upstream timeout")
+	timeField  = time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+)
+
+func newLogx(b *testing.B, level logx.LogLevel) *logx.Logger {
+	b.Helper()
+	l, err := logx.NewLogger(filepath.Join(b.TempDir(), "logx.log"), level, 1024, false, logx.WithFormat(logx.FormatJSON))
+	if err != nil {
+		b.Fatal(err)
+	}
+	l.StartWorker()
+	b.Cleanup(l.Close)
+	return l
+}
+
+func newZap(b *testing.B, level zapcore.Level) *zap.Logger {
+	b.Helper()
+	file := tempFile(b, "zap.log")
+	enc := zapcore.NewJSONEncoder(zap.NewProductionEncoderConfig())
+	return zap.New(zapcore.NewCore(enc, zapcore.AddSync(file), level))
+}
+
+func newZerolog(b *testing.B, level zerolog.Level) zerolog.Logger {
+	b.Helper()
+	return zerolog.New(tempFile(b, "zerolog.log")).Level(level).With().Timestamp().Logger()
+}
+
+func tempFile(b *testing.B, name string) *os.File {
+	file, err := os.Create(filepath.Join(b.TempDir(), name))
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Cleanup(func() { file.Close() })
+	return file
+}
+
+// 等待 logx 写完，使计时与同步写入的库可比
+func drain(b *testing.B, l *logx.Logger) {
+	l.Drain(context.Background())
+	b.StopTimer()
+	b.ReportMetric(float64(l.Stats().Dropped)/float64(b.N), "dropped/op")
+}
+
+func BenchmarkDisabled(b *testing.B) {
+	b.Run("logx", func(b *testing.B) {
+		l := newLogx(b, logx.ERROR)
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			l.Info("request handled", logx.String("path", "/api/orders"), logx.Int("status", 200))
+		}
+		drain(b, l)
+	})
+	b.Run("zap", func(b *testing.B) {
+		l := newZap(b, zapcore.ErrorLevel)
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			l.Info("request handled", zap.String("path", "/api/orders"), zap.Int("status", 200))
+		}
+	})
+	b.Run("zerolog", func(b *testing.B) {
+		l := newZerolog(b, zerolog.ErrorLevel)
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			l.Info().Str("path", "/api/orders").Int("status", 200).Msg("request handled")
+		}
+	})
+}
+
+func BenchmarkTenFields(b *testing.B) {
+	b.Run("logx", func(b *testing.B) {
+		l := newLogx(b, logx.INFO)
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			l.Info("request handled",
+				logx.String("method", "GET"),
+				logx.String("path", "/api/v1/orders"),
+				logx.Int("status", 200),
+				logx.Int64("bytes", 5120),
+				logx.Duration("latency", 1500*time.Microsecond),
+				logx.Bool("cached", true),
+				logx.String("user_id", "u-42"),
+				logx.Any("ratio", 0.75),
+				logx.Any("at", timeField),
+				logx.Err(errExample),
+			)
+		}
+		drain(b, l)
+	})
+	b.Run("zap", func(b *testing.B) {
+		l := newZap(b, zapcore.InfoLevel)
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			l.Info("request handled",
+				zap.String("method", "GET"),
+				zap.String("path", "/api/v1/orders"),
+				zap.Int("status", 200),
+				zap.Int64("bytes", 5120),
+				zap.Duration("latency", 1500*time.Microsecond),
+				zap.Bool("cached", true),
+				zap.String("user_id", "u-42"),
+				zap.Float64("ratio", 0.75),
+				zap.Time("at", timeField),
+				zap.Error(errExample),
+			)
+		}
+	})
+	b.Run("zerolog", func(b *testing.B) {
+		l := newZerolog(b, zerolog.InfoLevel)
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			l.Info().
+				Str("method", "GET").
+				Str("path", "/api/v1/orders").
+				Int("status", 200).
+				Int64("bytes", 5120).
+				Dur("latency", 1500*time.Microsecond).
+				Bool("cached", true).
+				Str("user_id", "u-42").
+				Float64("ratio", 0.75).
+				Time("at", timeField).
+				Err(errExample).
+				Msg("request handled")
+		}
+	})
+}
+
+func BenchmarkFormatted(b *testing.B) {
+	b.Run("logx", func(b *testing.B) {
+		l := newLogx(b, logx.INFO)
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			l.Info(fmt.Sprintf("request %s %s took %v", "GET", "/api/v1/orders", 1500*time.Microsecond))
+		}
+		drain(b, l)
+	})
+	b.Run("zap", func(b *testing.B) {
+		l := newZap(b, zapcore.InfoLevel).Sugar()
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			l.Infof("request %s %s took %v", "GET", "/api/v1/orders", 1500*time.Microsecond)
+		}
+	})
+	b.Run("zerolog", func(b *testing.B) {
+		l := newZerolog(b, zerolog.InfoLevel)
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			l.Info().Msgf("request %s %s took %v", "GET", "/api/v1/orders", 1500*time.Microsecond)
+		}
+	})
+}
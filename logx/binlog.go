@@ -0,0 +1,484 @@
+package logx
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"time"
+)
+
+// FormatBinary 的文件以 binaryMagic 开头(长度为 0 的记录不会出现，因此可以与记录区分)，
+// 之后每条日志为一条记录：uvarint 长度 + MessagePack 数组 [时间, 等级, 消息, 文件, 行号, 字段]。
+// 时间使用 MessagePack 的 timestamp 扩展类型，字段为保持顺序的 map，无法直接表示的值以 JSON 扩展类型保存
+const (
+	binaryMagic     = "\x00LOGXBIN1"
+	binaryJSONExt   = 1
+	maxBinaryRecord = 64 << 20
+)
+
+// ErrNotBinary 读取的内容不是 FormatBinary 写入的日志
+var ErrNotBinary = errors.New("logx: not a binary log file")
+
+func appendBinary(buf []byte, entry Entry) []byte {
+	// 先预留最长的 uvarint 长度，编码完成后再移动到实际位置
+	start := len(buf)
+	buf = append(buf, make([]byte, binary.MaxVarintLen64)...)
+	body := len(buf)
+	buf = append(buf, 0x96)
+	buf = appendMsgpackTime(buf, entry.Time)
+	buf = appendMsgpackInt(buf, int64(entry.Level))
+	buf = appendMsgpackString(buf, entry.Message)
+	buf = appendMsgpackString(buf, entry.File)
+	buf = appendMsgpackInt(buf, int64(entry.Line))
+	buf = appendMsgpackHeader(buf, len(entry.Fields), 0x80, 0xde)
+	for _, f := range entry.Fields {
+		buf = appendMsgpackString(buf, f.Key)
+		buf = appendMsgpackValue(buf, f.Value)
+	}
+	var size [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(size[:], uint64(len(buf)-body))
+	copy(buf[start:], size[:n])
+	copy(buf[start+n:], buf[body:])
+	return buf[:len(buf)-(body-start-n)]
+}
+
+// 与 appendJSONValue 的类型处理保持一致
+func appendMsgpackValue(buf []byte, v interface{}) []byte {
+	switch val := v.(type) {
+	case nil:
+		return append(buf, 0xc0)
+	case string:
+		return appendMsgpackString(buf, val)
+	case bool:
+		if val {
+			return append(buf, 0xc3)
+		}
+		return append(buf, 0xc2)
+	case int:
+		return appendMsgpackInt(buf, int64(val))
+	case int32:
+		return appendMsgpackInt(buf, int64(val))
+	case int64:
+		return appendMsgpackInt(buf, val)
+	case uint:
+		return appendMsgpackUint(buf, uint64(val))
+	case uint32:
+		return appendMsgpackUint(buf, uint64(val))
+	case uint64:
+		return appendMsgpackUint(buf, val)
+	case float64:
+		return binary.BigEndian.AppendUint64(append(buf, 0xcb), math.Float64bits(val))
+	case float32:
+		return binary.BigEndian.AppendUint32(append(buf, 0xca), math.Float32bits(val))
+	case time.Time:
+		return appendMsgpackTime(buf, val)
+	case time.Duration:
+		return appendMsgpackString(buf, val.String())
+	case error:
+		return appendMsgpackString(buf, val.Error())
+	case json.Marshaler:
+		data, err := val.MarshalJSON()
+		if err != nil {
+			return appendMsgpackString(buf, err.Error())
+		}
+		return appendMsgpackExt(buf, binaryJSONExt, data)
+	case fmt.Stringer:
+		return appendMsgpackString(buf, val.String())
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return appendMsgpackString(buf, fmt.Sprintf("%+v", v))
+	}
+	return appendMsgpackExt(buf, binaryJSONExt, data)
+}
+
+func appendMsgpackInt(buf []byte, n int64) []byte {
+	switch {
+	case n >= 0:
+		return appendMsgpackUint(buf, uint64(n))
+	case n >= -32:
+		return append(buf, byte(n))
+	case n >= math.MinInt8:
+		return append(buf, 0xd0, byte(n))
+	case n >= math.MinInt16:
+		return binary.BigEndian.AppendUint16(append(buf, 0xd1), uint16(n))
+	case n >= math.MinInt32:
+		return binary.BigEndian.AppendUint32(append(buf, 0xd2), uint32(n))
+	}
+	return binary.BigEndian.AppendUint64(append(buf, 0xd3), uint64(n))
+}
+
+func appendMsgpackUint(buf []byte, n uint64) []byte {
+	switch {
+	case n <= 0x7f:
+		return append(buf, byte(n))
+	case n <= math.MaxUint8:
+		return append(buf, 0xcc, byte(n))
+	case n <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16(append(buf, 0xcd), uint16(n))
+	case n <= math.MaxUint32:
+		return binary.BigEndian.AppendUint32(append(buf, 0xce), uint32(n))
+	}
+	return binary.BigEndian.AppendUint64(append(buf, 0xcf), n)
+}
+
+func appendMsgpackString(buf []byte, s string) []byte {
+	switch n := len(s); {
+	case n < 32:
+		buf = append(buf, 0xa0|byte(n))
+	case n <= math.MaxUint8:
+		buf = append(buf, 0xd9, byte(n))
+	case n <= math.MaxUint16:
+		buf = binary.BigEndian.AppendUint16(append(buf, 0xda), uint16(n))
+	default:
+		buf = binary.BigEndian.AppendUint32(append(buf, 0xdb), uint32(n))
+	}
+	return append(buf, s...)
+}
+
+// 数组或 map 的头部，fix 为 fixarray/fixmap 的前缀，code16 为 16 位长度的类型码(32 位为 code16+1)
+func appendMsgpackHeader(buf []byte, n int, fix, code16 byte) []byte {
+	switch {
+	case n < 16:
+		return append(buf, fix|byte(n))
+	case n <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16(append(buf, code16), uint16(n))
+	}
+	return binary.BigEndian.AppendUint32(append(buf, code16+1), uint32(n))
+}
+
+func appendMsgpackExt(buf []byte, typ int8, data []byte) []byte {
+	switch n := len(data); {
+	case n <= math.MaxUint8:
+		buf = append(buf, 0xc7, byte(n))
+	case n <= math.MaxUint16:
+		buf = binary.BigEndian.AppendUint16(append(buf, 0xc8), uint16(n))
+	default:
+		buf = binary.BigEndian.AppendUint32(append(buf, 0xc9), uint32(n))
+	}
+	return append(append(buf, byte(typ)), data...)
+}
+
+// MessagePack timestamp 扩展类型(-1)，能用 64 位表示时使用 fixext8
+func appendMsgpackTime(buf []byte, t time.Time) []byte {
+	sec, nsec := t.Unix(), uint64(t.Nanosecond())
+	if sec >= 0 && sec < 1<<34 {
+		return binary.BigEndian.AppendUint64(append(buf, 0xd7, 0xff), nsec<<34|uint64(sec))
+	}
+	buf = binary.BigEndian.AppendUint32(append(buf, 0xc7, 12, 0xff), uint32(nsec))
+	return binary.BigEndian.AppendUint64(buf, uint64(sec))
+}
+
+// ReaderOptions 读取时的过滤条件
+type ReaderOptions struct {
+	Since    time.Time // 只返回不早于该时间的日志，零值表示不限制
+	Until    time.Time // 只返回早于该时间的日志，零值表示不限制
+	MinLevel LogLevel  // 只返回不低于该等级的日志
+	// MaxRecordSize 单条记录最大字节数，更长的记录跳过而不是读入内存。默认 64MB，
+	// 超过 64MB 的长度视为文件损坏
+	MaxRecordSize int
+}
+
+// Reader 逐条读取 FormatBinary 写入的日志，用法与 bufio.Scanner 相同：
+//
+//	for r.Next() {
+//		entry := r.Entry()
+//	}
+//	if err := r.Err(); err != nil { ... }
+//
+// 整数字段读出为 int64(超出范围的无符号数为 uint64)，以 JSON 保存的值读出为 json.RawMessage
+type Reader struct {
+	r      *bufio.Reader
+	closer io.Closer
+	opts   ReaderOptions
+	entry  Entry
+	record []byte
+	err    error
+}
+
+// NewReader 从 r 读取二进制日志，开头不是二进制日志文件头时返回 ErrNotBinary
+func NewReader(r io.Reader, opts ReaderOptions) (*Reader, error) {
+	br := bufio.NewReader(r)
+	head, _ := br.Peek(len(binaryMagic))
+	if string(head) != binaryMagic {
+		return nil, ErrNotBinary
+	}
+	return &Reader{r: br, opts: opts}, nil
+}
+
+// OpenReader 打开二进制日志文件，读取完后需要调用 Close
+func OpenReader(path string, opts ReaderOptions) (*Reader, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	r, err := NewReader(file, opts)
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	r.closer = file
+	return r, nil
+}
+
+// Next 读取下一条符合过滤条件的日志，读完或出错时返回 false
+func (r *Reader) Next() bool {
+	for r.err == nil {
+		entry, err := r.read()
+		if err == errSkippedRecord {
+			continue
+		}
+		if err != nil {
+			if err != io.EOF {
+				r.err = err
+			}
+			return false
+		}
+		if r.match(&entry) {
+			r.entry = entry
+			return true
+		}
+	}
+	return false
+}
+
+// Entry 返回 Next 读取的日志
+func (r *Reader) Entry() Entry {
+	return r.entry
+}
+
+// Err 返回读取过程中的错误，正常读完时为 nil
+func (r *Reader) Err() error {
+	return r.err
+}
+
+func (r *Reader) Close() error {
+	if r.closer == nil {
+		return nil
+	}
+	return r.closer.Close()
+}
+
+func (r *Reader) match(entry *Entry) bool {
+	if entry.Level < r.opts.MinLevel {
+		return false
+	}
+	if !r.opts.Since.IsZero() && entry.Time.Before(r.opts.Since) {
+		return false
+	}
+	if !r.opts.Until.IsZero() && !entry.Time.Before(r.opts.Until) {
+		return false
+	}
+	return true
+}
+
+func (r *Reader) read() (Entry, error) {
+	var size uint64
+	for {
+		n, err := binary.ReadUvarint(r.r)
+		if err != nil {
+			if err == io.ErrUnexpectedEOF {
+				return Entry{}, errors.New("logx: truncated binary record")
+			}
+			return Entry{}, err
+		}
+		if n != 0 {
+			size = n
+			break
+		}
+		// 文件头，多个进程同时创建文件时可能重复出现
+		if _, err := r.r.Discard(len(binaryMagic) - 1); err != nil {
+			return Entry{}, errors.New("logx: truncated binary header")
+		}
+	}
+	if size > maxBinaryRecord {
+		return Entry{}, fmt.Errorf("logx: invalid binary record length %d", size)
+	}
+	if max := r.opts.MaxRecordSize; max > 0 && size > uint64(max) {
+		if _, err := r.r.Discard(int(size)); err != nil {
+			return Entry{}, errors.New("logx: truncated binary record")
+		}
+		return Entry{}, errSkippedRecord
+	}
+	if uint64(cap(r.record)) < size {
+		r.record = make([]byte, size)
+	}
+	record := r.record[:size]
+	if _, err := io.ReadFull(r.r, record); err != nil {
+		return Entry{}, errors.New("logx: truncated binary record")
+	}
+	d := msgpackDecoder{buf: record}
+	entry := d.entry()
+	if d.err != nil {
+		return Entry{}, d.err
+	}
+	return entry, nil
+}
+
+type msgpackDecoder struct {
+	buf []byte
+	err error
+}
+
+var (
+	errInvalidBinary = errors.New("logx: invalid binary record")
+	errSkippedRecord = errors.New("logx: binary record too large")
+)
+
+func (d *msgpackDecoder) entry() Entry {
+	if d.next(1)[0] != 0x96 {
+		d.fail()
+		return Entry{}
+	}
+	var entry Entry
+	entry.Time, _ = d.value().(time.Time)
+	level, _ := d.value().(int64)
+	entry.Level = LogLevel(level)
+	entry.Message, _ = d.value().(string)
+	entry.File, _ = d.value().(string)
+	line, _ := d.value().(int64)
+	entry.Line = int(line)
+	n, ok := d.header(d.next(1)[0], 0x80, 0xde)
+	if !ok {
+		d.fail()
+	}
+	if n > 0 && d.err == nil {
+		entry.Fields = make([]Field, 0, min(n, len(d.buf)))
+	}
+	for i := 0; i < n && d.err == nil; i++ {
+		key, ok := d.value().(string)
+		if !ok {
+			d.fail()
+			break
+		}
+		entry.Fields = append(entry.Fields, Field{Key: key, Value: d.value()})
+	}
+	return entry
+}
+
+func (d *msgpackDecoder) fail() {
+	if d.err == nil {
+		d.err = errInvalidBinary
+	}
+	d.buf = nil
+}
+
+// 取出 n 个字节，长度不足时返回全零并记录错误
+func (d *msgpackDecoder) next(n int) []byte {
+	if d.err != nil || n > len(d.buf) {
+		d.fail()
+		return make([]byte, n)
+	}
+	b := d.buf[:n]
+	d.buf = d.buf[n:]
+	return b
+}
+
+// 读取数组或 map 的长度，c 为已读出的类型码
+func (d *msgpackDecoder) header(c, fix, code16 byte) (int, bool) {
+	switch {
+	case c&0xf0 == fix:
+		return int(c & 0x0f), true
+	case c == code16:
+		return int(binary.BigEndian.Uint16(d.next(2))), true
+	case c == code16+1:
+		return int(binary.BigEndian.Uint32(d.next(4))), true
+	}
+	return 0, false
+}
+
+func (d *msgpackDecoder) value() interface{} {
+	c := d.next(1)[0]
+	switch {
+	case c <= 0x7f:
+		return int64(c)
+	case c >= 0xe0:
+		return int64(int8(c))
+	case c&0xe0 == 0xa0:
+		return string(d.next(int(c & 0x1f)))
+	case c&0xf0 == 0x90 || c == 0xdc || c == 0xdd:
+		n, _ := d.header(c, 0x90, 0xdc)
+		values := make([]interface{}, 0, min(n, len(d.buf)))
+		for i := 0; i < n && d.err == nil; i++ {
+			values = append(values, d.value())
+		}
+		return values
+	case c&0xf0 == 0x80 || c == 0xde || c == 0xdf:
+		n, _ := d.header(c, 0x80, 0xde)
+		values := make(map[string]interface{}, min(n, len(d.buf)))
+		for i := 0; i < n && d.err == nil; i++ {
+			key := fmt.Sprint(d.value())
+			values[key] = d.value()
+		}
+		return values
+	}
+	switch c {
+	case 0xc0:
+		return nil
+	case 0xc2:
+		return false
+	case 0xc3:
+		return true
+	case 0xcc:
+		return int64(d.next(1)[0])
+	case 0xcd:
+		return int64(binary.BigEndian.Uint16(d.next(2)))
+	case 0xce:
+		return int64(binary.BigEndian.Uint32(d.next(4)))
+	case 0xcf:
+		n := binary.BigEndian.Uint64(d.next(8))
+		if n > math.MaxInt64 {
+			return n
+		}
+		return int64(n)
+	case 0xd0:
+		return int64(int8(d.next(1)[0]))
+	case 0xd1:
+		return int64(int16(binary.BigEndian.Uint16(d.next(2))))
+	case 0xd2:
+		return int64(int32(binary.BigEndian.Uint32(d.next(4))))
+	case 0xd3:
+		return int64(binary.BigEndian.Uint64(d.next(8)))
+	case 0xca:
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(d.next(4))))
+	case 0xcb:
+		return math.Float64frombits(binary.BigEndian.Uint64(d.next(8)))
+	case 0xd9:
+		return string(d.next(int(d.next(1)[0])))
+	case 0xda:
+		return string(d.next(int(binary.BigEndian.Uint16(d.next(2)))))
+	case 0xdb:
+		return string(d.next(int(binary.BigEndian.Uint32(d.next(4)))))
+	case 0xd7:
+		return d.ext(int8(d.next(1)[0]), d.next(8))
+	case 0xc7:
+		n := int(d.next(1)[0])
+		return d.ext(int8(d.next(1)[0]), d.next(n))
+	case 0xc8:
+		n := int(binary.BigEndian.Uint16(d.next(2)))
+		return d.ext(int8(d.next(1)[0]), d.next(n))
+	case 0xc9:
+		n := int(binary.BigEndian.Uint32(d.next(4)))
+		return d.ext(int8(d.next(1)[0]), d.next(n))
+	}
+	d.fail()
+	return nil
+}
+
+func (d *msgpackDecoder) ext(typ int8, data []byte) interface{} {
+	switch {
+	case typ == -1 && len(data) == 8:
+		v := binary.BigEndian.Uint64(data)
+		return time.Unix(int64(v&(1<<34-1)), int64(v>>34))
+	case typ == -1 && len(data) == 12:
+		return time.Unix(int64(binary.BigEndian.Uint64(data[4:])), int64(binary.BigEndian.Uint32(data)))
+	case typ == binaryJSONExt:
+		return json.RawMessage(append([]byte(nil), data...))
+	}
+	return append([]byte(nil), data...)
+}
@@ -0,0 +1,99 @@
+package logx
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// BlobStore 保存超大附件的存储，例如本地目录或对象存储
+type BlobStore interface {
+	// Put 保存数据并返回可用于查找的引用
+	Put(id string, data []byte) (ref string, err error)
+}
+
+// BlobRef 日志中代替超大附件的引用
+type BlobRef struct {
+	Ref  string `json:"blob_ref"`
+	Size int    `json:"size"`
+}
+
+func (r BlobRef) String() string {
+	return fmt.Sprintf("blob:%s(%d bytes)", r.Ref, r.Size)
+}
+
+// DirBlobStore 把附件保存为目录下的单独文件
+type DirBlobStore struct {
+	Dir string
+}
+
+func NewDirBlobStore(dir string) *DirBlobStore {
+	return &DirBlobStore{Dir: dir}
+}
+
+func (s *DirBlobStore) Put(id string, data []byte) (string, error) {
+	if err := os.MkdirAll(s.Dir, 0755); err != nil {
+		return "", err
+	}
+	path := filepath.Join(s.Dir, id+".blob")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// WithBlobStore 超过 threshold 字节的 string/[]byte 字段写入 store，日志中只保留 BlobRef
+func WithBlobStore(store BlobStore, threshold int) Option {
+	return func(l *Logger) {
+		l.blobs = store
+		l.blobThreshold = threshold
+	}
+}
+
+// Attachment 附件字段，超过阈值时会被写入 BlobStore
+func Attachment(key string, data []byte) Field {
+	return Field{Key: key, Value: data}
+}
+
+// 把超大字段转存到 BlobStore
+func (l *Logger) offloadBlobs(entry Entry) Entry {
+	var copied bool
+	for i, f := range entry.Fields {
+		var data []byte
+		switch v := f.Value.(type) {
+		case string:
+			if len(v) > l.blobThreshold {
+				data = []byte(v)
+			}
+		case []byte:
+			if len(v) > l.blobThreshold {
+				data = v
+			}
+		}
+		if data == nil {
+			continue
+		}
+
+		ref, err := l.blobs.Put(newBlobID(entry.Time), data)
+		if err != nil {
+			l.handleError(OpBlob, f.Key, err)
+			continue
+		}
+		// 调用方可能仍持有字段切片，修改前先复制
+		if !copied {
+			entry.Fields = append([]Field(nil), entry.Fields...)
+			copied = true
+		}
+		entry.Fields[i].Value = BlobRef{Ref: ref, Size: len(data)}
+	}
+	return entry
+}
+
+func newBlobID(t time.Time) string {
+	var b [6]byte
+	rand.Read(b[:])
+	return t.Format("20060102T150405") + "-" + hex.EncodeToString(b[:])
+}
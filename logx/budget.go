@@ -0,0 +1,104 @@
+package logx
+
+import (
+	"math"
+	"sync/atomic"
+	"time"
+)
+
+// ErrorBudgetConfig 错误预算配置
+type ErrorBudgetConfig struct {
+	Interval time.Duration // 报告间隔，默认1分钟
+	Target   float64       // 允许的错误比例，例如 0.001 表示 99.9% 成功率
+}
+
+// WithErrorBudget 统计 ERROR 日志与 RecordRequests 上报的请求数之比，并定期输出 "error budget" 日志。
+// 燃烧速率(窗口内错误比例/Target)大于1时以 WARN 输出，否则为 INFO；
+// 窗口内有错误但没有上报请求时(可能是全部中断)比例为 null、燃烧速率为 +Inf，以 ERROR 输出
+func WithErrorBudget(cfg ErrorBudgetConfig) Option {
+	return func(l *Logger) {
+		if cfg.Interval <= 0 {
+			cfg.Interval = time.Minute
+		}
+		l.budget = &errorBudget{cfg: cfg}
+	}
+}
+
+type errorBudget struct {
+	cfg           ErrorBudgetConfig
+	requests      atomic.Int64 // 当前窗口
+	errors        atomic.Int64
+	totalRequests int64 // 只在报告协程中访问
+	totalErrors   int64
+}
+
+// RecordRequests 上报处理的请求数，用于计算错误预算；未开启 WithErrorBudget 时忽略
+func (l *Logger) RecordRequests(n int) {
+	if l.budget != nil {
+		l.budget.requests.Add(int64(n))
+	}
+}
+
+func (l *Logger) runErrorBudget() {
+	defer l.bgWg.Done()
+	ticker := time.NewTicker(l.budget.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			l.emitErrorBudget()
+		case <-l.done:
+			return
+		}
+	}
+}
+
+func (l *Logger) emitErrorBudget() {
+	b := l.budget
+	requests := b.requests.Swap(0)
+	errors := b.errors.Swap(0)
+	b.totalRequests += requests
+	b.totalErrors += errors
+
+	// 没有请求却有错误(例如全部请求失败、没能上报)时比例没有意义，按预算耗尽处理
+	var ratio interface{}
+	burnRate := 0.0
+	switch {
+	case requests > 0:
+		r := float64(errors) / float64(requests)
+		ratio = r
+		if b.cfg.Target > 0 {
+			burnRate = r / b.cfg.Target
+		}
+	case errors > 0:
+		burnRate = math.Inf(1)
+	default:
+		ratio = 0.0
+	}
+	remaining := 1.0
+	switch {
+	case b.cfg.Target > 0 && b.totalRequests > 0:
+		remaining = 1 - float64(b.totalErrors)/(b.cfg.Target*float64(b.totalRequests))
+	case b.totalErrors > 0:
+		remaining = 0
+	}
+
+	level := INFO
+	switch {
+	case requests == 0 && errors > 0:
+		level = ERROR
+	case burnRate > 1:
+		level = WARN
+	}
+	l.enqueue(Entry{Level: level, Time: l.now(), Message: "error budget", Fields: []Field{
+		Duration("window", b.cfg.Interval),
+		Int64("requests", requests),
+		Int64("errors", errors),
+		Any("error_ratio", ratio),
+		Any("target", b.cfg.Target),
+		Any("burn_rate", burnRate),
+		Int64("total_requests", b.totalRequests),
+		Int64("total_errors", b.totalErrors),
+		Any("budget_remaining", remaining),
+	}})
+}
@@ -0,0 +1,119 @@
+package logx
+
+import "time"
+
+type SyncPolicy int
+
+const (
+	SyncNever    SyncPolicy = iota // 不主动 fsync，由操作系统决定落盘时间
+	SyncInterval                   // 每次定时刷新缓冲区时 fsync，未开启 WithBufferedWrites 时每隔100ms fsync
+	SyncOnError                    // 写入 ERROR 及以上等级的日志后立即刷新并 fsync
+)
+
+// WithBufferedWrites 日志文件写入经过 size 字节的缓冲区，每隔 flushInterval(默认100ms)刷新一次，
+// 缓冲区满、切割、重新打开和 Close 时也会刷新，用于降低高吞吐时的系统调用开销
+func WithBufferedWrites(size int, flushInterval time.Duration) Option {
+	return func(l *Logger) {
+		if size <= 0 {
+			size = 64 * 1024
+		}
+		if flushInterval <= 0 {
+			flushInterval = defaultFlushInterval
+		}
+		l.bufferSize = size
+		l.flushInterval = flushInterval
+	}
+}
+
+// WithSyncPolicy 设置 fsync 策略，默认 SyncNever
+func WithSyncPolicy(policy SyncPolicy) Option {
+	return func(l *Logger) {
+		l.syncPolicy = policy
+	}
+}
+
+// Sync 把缓冲区中的日志写入文件并 fsync，只保证已经被写入协程处理的日志；Close 之后不做处理
+func (l *Logger) Sync() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if err := l.flushBuffer(); err != nil {
+		return err
+	}
+	if l.file == nil {
+		return nil
+	}
+	return l.file.Sync()
+}
+
+// 调用方需持有 l.mu
+func (l *Logger) flushBuffer() error {
+	if l.buffer == nil {
+		return nil
+	}
+	err := l.buffer.Flush()
+	if err != nil {
+		// bufio.Writer 出错后不再写入，丢弃未写出的内容，磁盘空间恢复后下一批日志可以继续写入
+		l.buffer.Reset(l.bufferDst)
+	}
+	return err
+}
+
+// 写入文件尾、刷新缓冲区(SyncNever 以外的策略还会 fsync)后关闭文件，调用方需持有 l.mu
+func (l *Logger) closeFile() {
+	if l.file == nil {
+		return
+	}
+	l.writeFooter()
+	if err := l.flushBuffer(); err != nil {
+		l.handleError(OpWrite, l.filePath, err)
+	} else if l.syncPolicy != SyncNever {
+		if err := l.file.Sync(); err != nil {
+			l.handleError(OpWrite, l.filePath, err)
+		}
+	}
+	l.file.Close()
+}
+
+// 写入一条后按 fsync 策略处理，调用方需持有 l.mu
+func (l *Logger) syncAfter(level LogLevel) {
+	if l.syncPolicy != SyncOnError || level < ERROR || l.file == nil {
+		return
+	}
+	if err := l.flushBuffer(); err != nil {
+		l.handleError(OpWrite, l.filePath, err)
+		return
+	}
+	if err := l.file.Sync(); err != nil {
+		l.handleError(OpWrite, l.filePath, err)
+	}
+}
+
+// 默认的刷新间隔
+const defaultFlushInterval = 100 * time.Millisecond
+
+// 定时刷新缓冲区，SyncInterval 时同时 fsync；只开启 SyncInterval 时也会启动
+func (l *Logger) runFlusher() {
+	defer l.bgWg.Done()
+	interval := l.flushInterval
+	if interval <= 0 {
+		interval = defaultFlushInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			l.mu.Lock()
+			if err := l.flushBuffer(); err != nil {
+				l.handleError(OpWrite, l.filePath, err)
+			} else if l.syncPolicy == SyncInterval && l.file != nil {
+				if err := l.file.Sync(); err != nil {
+					l.handleError(OpWrite, l.filePath, err)
+				}
+			}
+			l.mu.Unlock()
+		case <-l.done:
+			return
+		}
+	}
+}
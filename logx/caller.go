@@ -0,0 +1,80 @@
+package logx
+
+import (
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// 常用编辑器的超链接模板，{file} 为绝对路径，{line} 为行号
+const (
+	VSCodeLinkTemplate  = "vscode://file/{file}:{line}"
+	GoLandLinkTemplate  = "goland://open?file={file}&line={line}"
+	SublimeLinkTemplate = "subl://open?url=file://{file}&line={line}"
+	FileLinkTemplate    = "file://{file}"
+)
+
+// captureCaller -> logScoped -> log -> Info 等公共方法(包括 Child 的) -> 调用方
+const callerSkipFromLogFunc = 4
+
+// WithCaller 记录调用日志方法的文件和行号
+func WithCaller(enabled bool) Option {
+	return func(l *Logger) {
+		l.withCaller = enabled
+	}
+}
+
+// WithCallerHyperlink 在控制台输出中把调用位置渲染为终端超链接(OSC 8)，点击即可跳转到源码
+// template 中的 {file}、{line} 会被替换，例如 VSCodeLinkTemplate；会同时开启 WithCaller，
+// 控制台不使用颜色(见 WithColor)时输出普通文本
+func WithCallerHyperlink(template string) Option {
+	return func(l *Logger) {
+		l.withCaller = true
+		l.callerLink = template
+	}
+}
+
+func captureCaller(skip int) (string, int) {
+	_, file, line, ok := runtime.Caller(skip)
+	if !ok {
+		return "", 0
+	}
+	return file, line
+}
+
+func callerFromPC(pc uintptr) (string, int) {
+	frame, _ := runtime.CallersFrames([]uintptr{pc}).Next()
+	return frame.File, frame.Line
+}
+
+// 短路径形式：所在目录/文件名:行号
+func shortCaller(file string, line int) string {
+	if file == "" {
+		return ""
+	}
+	return string(appendShortCaller(nil, file, line))
+}
+
+// runtime.Caller 返回的路径在所有平台上都以 / 分隔
+func appendShortCaller(buf []byte, file string, line int) []byte {
+	short := file
+	if i := strings.LastIndexByte(file, '/'); i >= 0 {
+		if j := strings.LastIndexByte(file[:i], '/'); j >= 0 {
+			short = file[j+1:]
+		}
+	}
+	buf = append(buf, short...)
+	buf = append(buf, ':')
+	return strconv.AppendInt(buf, int64(line), 10)
+}
+
+// 生成 OSC 8 超链接
+func callerHyperlink(template, file string, line int) string {
+	text := shortCaller(file, line)
+	if template == "" || file == "" {
+		return text
+	}
+	url := strings.NewReplacer("{file}", filepath.ToSlash(file), "{line}", strconv.Itoa(line)).Replace(template)
+	return "\033]8;;" + url + "\033\\" + text + "\033]8;;\033\\"
+}
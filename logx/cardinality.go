@@ -0,0 +1,112 @@
+package logx
+
+import (
+	"fmt"
+	"hash/fnv"
+	"strconv"
+	"time"
+)
+
+type CardinalityAction int
+
+const (
+	CardinalityWarn CardinalityAction = iota // 只输出一条告警
+	CardinalityHash                          // 告警并把超出限制后的新值替换为哈希
+)
+
+// CardinalityConfig 高基数字段保护配置
+type CardinalityConfig struct {
+	Keys   []string          // 需要检查的字段，为空时检查所有标记为 HintIndexed 的字段
+	Limit  int               // 每个窗口内每个字段允许的不同值数量
+	Window time.Duration     // 统计窗口，默认1分钟
+	Action CardinalityAction // 超出限制后的处理方式
+}
+
+// WithCardinalityGuard 统计窗口内每个字段的不同值数量，超出限制时告警(或对值做哈希)，
+// 避免把原始 UUID 之类的值当作标签字段，导致下游索引成本暴涨
+func WithCardinalityGuard(cfg CardinalityConfig) Option {
+	return func(l *Logger) {
+		if cfg.Window <= 0 {
+			cfg.Window = time.Minute
+		}
+		g := &cardinalityGuard{cfg: cfg, values: make(map[string]map[string]struct{}), warned: make(map[string]bool)}
+		if len(cfg.Keys) > 0 {
+			g.keys = make(map[string]bool, len(cfg.Keys))
+			for _, k := range cfg.Keys {
+				g.keys[k] = true
+			}
+		}
+		l.cardinality = g
+	}
+}
+
+// 只在写入协程中使用
+type cardinalityGuard struct {
+	cfg         CardinalityConfig
+	keys        map[string]bool
+	windowStart time.Time
+	values      map[string]map[string]struct{}
+	warned      map[string]bool
+}
+
+func (g *cardinalityGuard) watched(f Field) bool {
+	if g.keys != nil {
+		return g.keys[f.Key]
+	}
+	return f.Hint == HintIndexed
+}
+
+// 检查字段，返回处理后的日志和需要额外输出的告警
+func (g *cardinalityGuard) check(entry Entry) (Entry, []Entry) {
+	if entry.Time.Sub(g.windowStart) > g.cfg.Window {
+		g.windowStart = entry.Time
+		g.values = make(map[string]map[string]struct{}, len(g.values))
+		g.warned = make(map[string]bool, len(g.warned))
+	}
+
+	var warnings []Entry
+	var copied bool
+	for i, f := range entry.Fields {
+		if !g.watched(f) {
+			continue
+		}
+		value := textValue(f.Value)
+		seen := g.values[f.Key]
+		if seen == nil {
+			seen = make(map[string]struct{})
+			g.values[f.Key] = seen
+		}
+		if _, ok := seen[value]; ok {
+			continue
+		}
+		if len(seen) < g.cfg.Limit {
+			seen[value] = struct{}{}
+			continue
+		}
+
+		// 超出限制：不再记录新值，避免统计本身占用过多内存
+		if !g.warned[f.Key] {
+			g.warned[f.Key] = true
+			warnings = append(warnings, Entry{
+				Level:   WARN,
+				Time:    entry.Time,
+				Message: fmt.Sprintf("logx: high cardinality field %q exceeded %d distinct values within %s", f.Key, g.cfg.Limit, g.cfg.Window),
+				Fields:  []Field{String("field", f.Key), Int("limit", g.cfg.Limit)},
+			})
+		}
+		if g.cfg.Action == CardinalityHash {
+			if !copied {
+				entry.Fields = append([]Field(nil), entry.Fields...)
+				copied = true
+			}
+			entry.Fields[i].Value = hashValue(value)
+		}
+	}
+	return entry, warnings
+}
+
+func hashValue(s string) string {
+	h := fnv.New64a()
+	h.Write([]byte(s))
+	return "h:" + strconv.FormatUint(h.Sum64(), 16)
+}
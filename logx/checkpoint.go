@@ -0,0 +1,121 @@
+package logx
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strings"
+	"time"
+)
+
+// 默认的检查点间隔
+const defaultCheckpointInterval = time.Minute
+
+// WithStatsCheckpoint 每隔 interval(<=0 时为1分钟)把 Stats 的累计计数(各等级条数、字节数、丢弃、错误等)写入 path，
+// Close 时再写一次；创建日志记录器时读取 path 中的计数作为起始值，重启后 Stats 和 Collector 导出的计数不会归零。
+// 队列深度、降级报告、各环节和试运行的统计不保存。path 读取失败时从0开始，错误通过 WithErrorHandler 报告(OpState)，
+// 每个检查点文件只能由一个日志记录器使用
+func WithStatsCheckpoint(path string, interval time.Duration) Option {
+	return func(l *Logger) {
+		if interval <= 0 {
+			interval = defaultCheckpointInterval
+		}
+		l.checkpointPath, l.checkpointInterval = path, interval
+	}
+}
+
+// 检查点文件的内容
+type statsCheckpoint struct {
+	Saved       time.Time         `json:"saved"`
+	Entries     map[string]uint64 `json:"entries"` // 键为小写的等级名称，other 为自定义等级
+	Bytes       uint64            `json:"bytes"`
+	Dropped     uint64            `json:"dropped"`
+	Coalesced   uint64            `json:"coalesced"`
+	Rotations   uint64            `json:"rotations"`
+	WriteErrors uint64            `json:"write_errors"`
+	Truncated   uint64            `json:"truncated"`
+	Filtered    uint64            `json:"filtered"`
+	AfterClose  uint64            `json:"after_close"`
+}
+
+// 读取检查点并加到当前计数上，文件不存在时不做处理
+func (l *Logger) loadStatsCheckpoint() {
+	data, err := os.ReadFile(l.checkpointPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return
+	}
+	if err != nil {
+		l.handleError(OpState, l.checkpointPath, err)
+		return
+	}
+	var cp statsCheckpoint
+	if err := json.Unmarshal(data, &cp); err != nil {
+		l.handleError(OpState, l.checkpointPath, fmt.Errorf("logx: invalid stats checkpoint: %w", err))
+		return
+	}
+	m := &l.metrics
+	for level := range m.entries {
+		m.entries[level].Add(cp.Entries[strings.ToLower(levelString(LogLevel(level)))])
+	}
+	m.otherLevels.Add(cp.Entries["other"])
+	m.bytes.Add(cp.Bytes)
+	m.dropped.Add(cp.Dropped)
+	m.coalesced.Add(cp.Coalesced)
+	m.rotations.Add(cp.Rotations)
+	m.writeErrors.Add(cp.WriteErrors)
+	m.truncated.Add(cp.Truncated)
+	m.filtered.Add(cp.Filtered)
+	m.afterClose.Add(cp.AfterClose)
+}
+
+// SaveStatsCheckpoint 立即写入 WithStatsCheckpoint 的检查点文件，未开启时不做处理
+func (l *Logger) SaveStatsCheckpoint() error {
+	if l.checkpointPath == "" {
+		return nil
+	}
+	m := &l.metrics
+	cp := statsCheckpoint{
+		Saved:       l.now(),
+		Entries:     make(map[string]uint64, len(m.entries)+1),
+		Bytes:       m.bytes.Load(),
+		Dropped:     m.dropped.Load(),
+		Coalesced:   m.coalesced.Load(),
+		Rotations:   m.rotations.Load(),
+		WriteErrors: m.writeErrors.Load(),
+		Truncated:   m.truncated.Load(),
+		Filtered:    m.filtered.Load(),
+		AfterClose:  m.afterClose.Load(),
+	}
+	for level := range m.entries {
+		cp.Entries[strings.ToLower(levelString(LogLevel(level)))] = m.entries[level].Load()
+	}
+	if n := m.otherLevels.Load(); n > 0 {
+		cp.Entries["other"] = n
+	}
+	data, err := json.Marshal(cp)
+	if err != nil {
+		return err
+	}
+	// 定时写入与 Close 时的写入可能同时进行
+	l.checkpointMu.Lock()
+	defer l.checkpointMu.Unlock()
+	return writeFileAtomic(l.checkpointPath, data)
+}
+
+func (l *Logger) runStatsCheckpoint() {
+	defer l.bgWg.Done()
+	ticker := time.NewTicker(l.checkpointInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := l.SaveStatsCheckpoint(); err != nil {
+				l.handleError(OpState, l.checkpointPath, err)
+			}
+		case <-l.done:
+			return
+		}
+	}
+}
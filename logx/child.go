@@ -0,0 +1,169 @@
+package logx
+
+import (
+	"fmt"
+	"time"
+)
+
+// Child 带有一组字段的子日志记录器，与创建它的 Logger 共用文件、队列和全部配置，可以被多个协程同时使用。
+// 每条日志的字段依次为 WithStaticFields 的字段、子日志记录器的字段、调用时传入的字段
+type Child struct {
+	l         *Logger
+	fields    []Field
+	hidden    []string     // 不输出的静态字段的键
+	sinks     []string     // To、Also 指定的输出目标
+	sinksOnly bool         // 只发送到 sinks
+	groups    []childGroup // WithGroup 打开的分组
+	callerPC  uintptr      // 不为0时作为调用位置，slog 适配器使用
+	time      time.Time    // 不为零时作为日志时间，slog 适配器使用
+}
+
+// With 创建带有 fields 的子日志记录器，例如 log.With(String("tenant", id))
+func (l *Logger) With(fields ...Field) *Child {
+	return (&Child{l: l}).With(fields...)
+}
+
+// With 在继承的字段基础上加上 fields 创建新的子日志记录器，与继承的字段(包括 WithStaticFields 的)
+// 同名时替换继承的值，例如覆盖服务名 c.With(Service("billing-worker"))；在 WithGroup 之后调用时放入分组。c 本身不变
+func (c *Child) With(fields ...Field) *Child {
+	if len(c.groups) > 0 {
+		return c.withGrouped(fields)
+	}
+	child := c.clone()
+	child.fields = make([]Field, 0, len(c.fields)+len(fields))
+	for _, f := range c.fields {
+		if !hasFieldKey(fields, f.Key) {
+			child.fields = append(child.fields, f)
+		}
+	}
+	child.fields = append(child.fields, fields...)
+	for _, f := range fields {
+		child.hide(f.Key)
+	}
+	return child
+}
+
+// Without 去掉继承的 keys 字段(包括 WithStaticFields 的)创建新的子日志记录器，
+// 例如多租户的包装层去掉 c.Without("user_id")。c 本身不变
+func (c *Child) Without(keys ...string) *Child {
+	child := c.clone()
+	child.fields = nil
+	for _, f := range c.fields {
+		if !containsString(keys, f.Key) {
+			child.fields = append(child.fields, f)
+		}
+	}
+	for _, key := range keys {
+		child.hide(key)
+	}
+	return child
+}
+
+// To 返回只把日志发送到名为 sinks 的输出目标(WithSink 的 name)的子日志记录器，不受其最低等级和 WithRoutes 的
+// To 规则限制，日志文件和控制台不受影响，用于少量需要单独投递的日志，例如 log.To("audit").Info("role changed")。
+// 不存在的名称通过 WithErrorHandler 报告
+func (l *Logger) To(sinks ...string) *Child {
+	return (&Child{l: l}).To(sinks...)
+}
+
+// Also 与 To 相同，但除了 sinks 之外仍按最低等级和路由规则发送到其他输出目标
+func (l *Logger) Also(sinks ...string) *Child {
+	return (&Child{l: l}).Also(sinks...)
+}
+
+// To 见 Logger.To，替换 c 上已经指定的输出目标
+func (c *Child) To(sinks ...string) *Child {
+	child := c.clone()
+	child.sinks, child.sinksOnly = c.l.knownSinks(sinks), true
+	return child
+}
+
+// Also 见 Logger.Also，替换 c 上已经指定的输出目标
+func (c *Child) Also(sinks ...string) *Child {
+	child := c.clone()
+	child.sinks, child.sinksOnly = c.l.knownSinks(sinks), false
+	return child
+}
+
+func (c *Child) clone() *Child {
+	child := *c
+	return &child
+}
+
+// 报告不存在的输出目标
+func (l *Logger) knownSinks(names []string) []string {
+	for _, name := range names {
+		found := false
+		for _, route := range l.sinks {
+			if route.name == name {
+				found = true
+				break
+			}
+		}
+		if !found {
+			l.handleError(OpSink, name, fmt.Errorf("logx: unknown sink %q", name))
+		}
+	}
+	return names
+}
+
+// 静态字段中有 key 时不再输出
+func (c *Child) hide(key string) {
+	if !hasFieldKey(c.l.staticFields, key) || containsString(c.hidden, key) {
+		return
+	}
+	// hidden 可能与父日志记录器共用底层数组
+	c.hidden = append(c.hidden[:len(c.hidden):len(c.hidden)], key)
+}
+
+// Fields 返回子日志记录器自身的字段，不包括静态字段，分组中的字段以 Group 字段返回
+func (c *Child) Fields() []Field {
+	return append(append([]Field(nil), c.fields...), c.nest(nil)...)
+}
+
+// Logger 返回创建子日志记录器的 Logger
+func (c *Child) Logger() *Logger {
+	return c.l
+}
+
+func (c *Child) Debug(msg string, fields ...Field) { c.log(DEBUG, msg, fields) }
+func (c *Child) Info(msg string, fields ...Field)  { c.log(INFO, msg, fields) }
+func (c *Child) Warn(msg string, fields ...Field)  { c.log(WARN, msg, fields) }
+func (c *Child) Error(msg string, fields ...Field) { c.log(ERROR, msg, fields) }
+
+// TryLog 见 Logger.TryLog
+func (c *Child) TryLog(level LogLevel, msg string, fields ...Field) error {
+	return c.tryLog(level, msg, fields)
+}
+
+func (c *Child) TryDebug(msg string, fields ...Field) error { return c.tryLog(DEBUG, msg, fields) }
+func (c *Child) TryInfo(msg string, fields ...Field) error  { return c.tryLog(INFO, msg, fields) }
+func (c *Child) TryWarn(msg string, fields ...Field) error  { return c.tryLog(WARN, msg, fields) }
+func (c *Child) TryError(msg string, fields ...Field) error { return c.tryLog(ERROR, msg, fields) }
+
+func (c *Child) tryLog(level LogLevel, msg string, fields []Field) error {
+	return c.l.logScoped(level, msg, c, fields, true)
+}
+
+// 与 Logger.log 的调用层数相同，见 callerSkipFromLogFunc
+func (c *Child) log(level LogLevel, msg string, fields []Field) {
+	c.l.logScoped(level, msg, c, fields, false)
+}
+
+func hasFieldKey(fields []Field, key string) bool {
+	for _, f := range fields {
+		if f.Key == key {
+			return true
+		}
+	}
+	return false
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,73 @@
+package logx
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// CLIMode 命令行工具的日志输出模式，对应 --log-format 参数
+type CLIMode string
+
+const (
+	CLIHuman CLIMode = "human" // 文本格式，终端中带颜色
+	CLIJSON  CLIMode = "json"  // 每行一个JSON对象，便于其他程序解析
+	CLIQuiet CLIMode = "quiet" // 控制台只输出错误，文件照常写入
+)
+
+// ParseCLIMode 解析输出模式名称，支持 human、json、quiet
+func ParseCLIMode(s string) (CLIMode, error) {
+	switch mode := CLIMode(strings.ToLower(strings.TrimSpace(s))); mode {
+	case CLIHuman, CLIJSON, CLIQuiet:
+		return mode, nil
+	case "":
+		return CLIHuman, nil
+	}
+	return CLIHuman, fmt.Errorf("logx: unknown log format %q (want human, json or quiet)", s)
+}
+
+func (m *CLIMode) String() string {
+	return string(*m)
+}
+
+// Set 实现 flag.Value
+func (m *CLIMode) Set(s string) error {
+	mode, err := ParseCLIMode(s)
+	if err != nil {
+		return err
+	}
+	*m = mode
+	return nil
+}
+
+// LogFormatFlag 在 fs 上注册 --log-format 参数，默认 human；fs 为空时使用 flag.CommandLine。
+// 解析参数后把结果传给 WithCLIMode，所有命令行工具的日志参数保持一致
+func LogFormatFlag(fs *flag.FlagSet) *CLIMode {
+	if fs == nil {
+		fs = flag.CommandLine
+	}
+	mode := CLIHuman
+	fs.Var(&mode, "log-format", "log output: human, json or quiet")
+	return &mode
+}
+
+// WithCLIMode 按命令行工具的惯例配置控制台输出：日志写到 stderr，stdout 留给命令本身的输出。
+// human 为文本格式，json 为JSON格式且不使用颜色，quiet 时控制台只输出 ERROR 及以上
+func WithCLIMode(mode CLIMode) Option {
+	return func(l *Logger) {
+		l.consoleOut = true
+		l.consoleWriter = os.Stderr
+		switch mode {
+		case CLIJSON:
+			l.format = FormatJSON
+			l.colorMode = ColorNever
+		case CLIQuiet:
+			l.format = FormatText
+			l.console.levelSet = true
+			l.console.level = ERROR
+		default:
+			l.format = FormatText
+		}
+	}
+}
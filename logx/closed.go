@@ -0,0 +1,49 @@
+package logx
+
+import (
+	"errors"
+	"sync"
+)
+
+// ErrClosed 日志记录器已经 Close
+var ErrClosed = errors.New("logx: logger closed")
+
+// ClosedPolicy Close 之后仍然收到日志时的处理方式，停机时其他协程晚于 Close 写日志很常见
+type ClosedPolicy int
+
+const (
+	ClosedDrop   ClosedPolicy = iota // 丢弃，计入 Stats.AfterClose，默认
+	ClosedStderr                     // 同步以文本格式写到错误控制台(默认 os.Stderr，见 WithErrorConsoleWriter)，不会丢失停机时的最后几条日志
+)
+
+// WithClosedPolicy 设置 Close 之后收到日志时的处理方式，无论哪种方式都计入 Stats.AfterClose
+func WithClosedPolicy(p ClosedPolicy) Option {
+	return func(l *Logger) {
+		l.closedPolicy = p
+	}
+}
+
+// Close 之后写到错误控制台时串行写入
+var closedWriteMu sync.Mutex
+
+// 处理 Close 之后收到的日志
+func (l *Logger) afterClose(entry Entry) {
+	l.metrics.afterClose.Add(1)
+	if l.closedPolicy != ClosedStderr || entry.belowLevel {
+		return
+	}
+	if len(l.staticFields) > 0 {
+		entry = l.applyStaticFields(entry)
+	}
+	if l.redactor != nil {
+		entry = l.redactor.apply(entry)
+	}
+	bufp := getBuffer()
+	line := l.appendTime((*bufp)[:0], entry.Time, defaultTextTimeLayout, false)
+	line = append(appendText(append(line, ' '), entry), '\n')
+	closedWriteMu.Lock()
+	l.errConsoleWriter.Write(line)
+	closedWriteMu.Unlock()
+	*bufp = line
+	putBuffer(bufp)
+}
@@ -0,0 +1,350 @@
+// logx-soaktest 长时间写入日志，检查切割、压缩、归档保留和磁盘写满时的行为，发布前运行：
+//
+//	go run ./cmd/logx-soaktest -dir /mnt/soak -duration 4h -rate 20000 -compress gzip -max-backups 20
+//
+// 每条日志带递增的 seq 字段。运行期间定期检查文件大小和归档数量，结束后读出所有保留的文件，
+// 检查 seq 从最早保留的一条到最后一条连续(磁盘写满期间前后写入失败的日志除外)。
+// -fill-every 会周期性地写满 -dir 所在的文件系统来模拟磁盘写满，只能用于专门挂载的小分区或 tmpfs。
+// 发现问题时以状态码1退出
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/capyflow/opensource/logx"
+)
+
+type options struct {
+	dir        string
+	duration   time.Duration
+	rate       int
+	entrySize  int
+	maxSizeMB  int64
+	compress   string
+	maxBackups int
+	check      time.Duration
+	fillEvery  time.Duration
+	fillHold   time.Duration
+}
+
+func main() {
+	var opts options
+	flag.StringVar(&opts.dir, "dir", "soak-logs", "日志目录，会先清空其中的 soak.log*")
+	flag.DurationVar(&opts.duration, "duration", time.Hour, "运行时长")
+	flag.IntVar(&opts.rate, "rate", 10000, "每秒写入的条数，0 表示不限速")
+	flag.IntVar(&opts.entrySize, "entry-size", 200, "每条日志填充的字节数")
+	flag.Int64Var(&opts.maxSizeMB, "max-size-mb", 1, "单个日志文件最大大小(MB)")
+	flag.StringVar(&opts.compress, "compress", "gzip", "归档压缩编码，为空时不压缩")
+	flag.IntVar(&opts.maxBackups, "max-backups", 10, "保留的归档数量，0 表示不删除")
+	flag.DurationVar(&opts.check, "check-interval", 10*time.Second, "运行期间检查的间隔")
+	flag.DurationVar(&opts.fillEvery, "fill-every", 0, "每隔多久写满一次磁盘，0 表示不模拟")
+	flag.DurationVar(&opts.fillHold, "fill-hold", 30*time.Second, "磁盘保持写满的时长")
+	flag.Parse()
+
+	if err := run(opts); err != nil {
+		fmt.Fprintln(os.Stderr, "soaktest: FAIL:", err)
+		os.Exit(1)
+	}
+	fmt.Println("soaktest: PASS")
+}
+
+func run(opts options) error {
+	if err := os.MkdirAll(opts.dir, 0755); err != nil {
+		return err
+	}
+	path := filepath.Join(opts.dir, "soak.log")
+	old, _ := filepath.Glob(path + "*")
+	for _, f := range old {
+		os.Remove(f)
+	}
+
+	var writeErrors atomic.Uint64
+	logOpts := []logx.Option{
+		logx.WithFormat(logx.FormatJSON),
+		logx.WithMaxBackups(opts.maxBackups),
+		logx.WithErrorHandler(func(err error) { writeErrors.Add(1) }),
+	}
+	if opts.compress != "" {
+		c, ok := logx.LookupCompressor(opts.compress)
+		if !ok {
+			return fmt.Errorf("unknown compression %q", opts.compress)
+		}
+		logOpts = append(logOpts, logx.WithArchiveCompression(c))
+	}
+	log, err := logx.NewLogger(path, logx.INFO, opts.maxSizeMB, false, logOpts...)
+	if err != nil {
+		return err
+	}
+	log.StartWorker()
+
+	stop := time.After(opts.duration)
+	checks := time.NewTicker(opts.check)
+	defer checks.Stop()
+	var fills <-chan time.Time
+	if opts.fillEvery > 0 {
+		ticker := time.NewTicker(opts.fillEvery)
+		defer ticker.Stop()
+		fills = ticker.C
+	}
+	filling := make(chan fillResult, 1)
+	fillsRunning := 0
+	var windows []window
+
+	pad := strings.Repeat("x", opts.entrySize)
+	maxSize := opts.maxSizeMB * 1024 * 1024
+	var seq int64
+	var written atomic.Int64 // 供写满磁盘的协程读取
+	var problems []string
+	start := time.Now()
+	pace := time.NewTicker(10 * time.Millisecond)
+	defer pace.Stop()
+	perTick := opts.rate / 100
+	if perTick == 0 && opts.rate > 0 {
+		perTick = 1
+	}
+
+loop:
+	for {
+		select {
+		case <-stop:
+			break loop
+		case <-checks.C:
+			// 压缩完成前新旧归档同时存在，允许多出两个
+			problems = append(problems, checkFiles(path, maxSize, opts.maxBackups+2)...)
+			s := log.Stats()
+			fmt.Printf("soaktest: %s seq=%d rotations=%d write_errors=%d queue=%d/%d\n",
+				time.Since(start).Round(time.Second), seq, s.Rotations, writeErrors.Load(), s.QueueDepth, s.QueueCap)
+		case <-fills:
+			fillsRunning++
+			go func() { filling <- fillDisk(opts.dir, opts.fillHold, &written) }()
+		case r := <-filling:
+			fillsRunning--
+			windows = append(windows, r.window)
+			if r.err != nil {
+				problems = append(problems, "fill disk: "+r.err.Error())
+			}
+		case <-pace.C:
+			n := perTick
+			if opts.rate == 0 {
+				n = 1000
+			}
+			for i := 0; i < n; i++ {
+				log.Info("soak", logx.Int64("seq", seq), logx.String("pad", pad))
+				seq++
+			}
+			written.Store(seq)
+		}
+	}
+	// 等磁盘空间释放后再写入最后一批，这些日志应当全部写入
+	for ; fillsRunning > 0; fillsRunning-- {
+		r := <-filling
+		windows = append(windows, r.window)
+		if r.err != nil {
+			problems = append(problems, "fill disk: "+r.err.Error())
+		}
+	}
+	for i := 0; i < 1000; i++ {
+		log.Info("soak", logx.Int64("seq", seq), logx.String("pad", pad))
+		seq++
+	}
+	log.Close()
+
+	problems = append(problems, checkFiles(path, maxSize, opts.maxBackups)...)
+	// 写满期间队列中的日志和缓冲区中未写出的日志都可能丢失
+	margin := int64(log.Stats().QueueCap + 1024)
+	for i := range windows {
+		windows[i].from -= margin
+		windows[i].to += margin
+	}
+	problems = append(problems, checkSequence(path, seq, windows)...)
+	s := log.Stats()
+	fmt.Printf("soaktest: wrote %d entries in %s, %d rotations, %d write errors\n",
+		seq, time.Since(start).Round(time.Second), s.Rotations, writeErrors.Load())
+	if len(problems) > 0 {
+		return errors.New(strings.Join(problems, "\n  "))
+	}
+	return nil
+}
+
+// 检查每个文件解压后不超过 maxSize，归档数量不超过 maxArchives
+func checkFiles(path string, maxSize int64, maxArchives int) []string {
+	var problems []string
+	archives, err := logx.Archives(path)
+	if err != nil {
+		return []string{err.Error()}
+	}
+	stamps := map[string]bool{}
+	for _, a := range archives {
+		stamps[strings.TrimSuffix(strings.TrimSuffix(a, filepath.Ext(a)), ".log")] = true
+	}
+	if maxArchives > 2 && len(stamps) > maxArchives {
+		problems = append(problems, fmt.Sprintf("%d archives retained, want at most %d", len(stamps), maxArchives))
+	}
+	for _, f := range append(archives, path) {
+		size, err := uncompressedSize(f)
+		if errors.Is(err, os.ErrNotExist) {
+			// 期间被压缩或删除
+			continue
+		}
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", f, err))
+			continue
+		}
+		if size > maxSize {
+			problems = append(problems, fmt.Sprintf("%s has %d bytes, exceeds %d", f, size, maxSize))
+		}
+	}
+	return problems
+}
+
+func openFile(path string) (io.ReadCloser, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	ext := strings.TrimPrefix(filepath.Ext(path), ".")
+	c, ok := logx.LookupCompressor(ext)
+	if ext == "log" || !ok {
+		return file, nil
+	}
+	r, err := c.NewReader(file)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{r, file}, nil
+}
+
+func uncompressedSize(path string) (int64, error) {
+	r, err := openFile(path)
+	if err != nil {
+		return 0, err
+	}
+	defer r.Close()
+	return io.Copy(io.Discard, r)
+}
+
+// seq 的范围 [from, to]
+type window struct{ from, to int64 }
+
+func (w window) contains(seq int64) bool { return seq >= w.from && seq <= w.to }
+
+// 检查保留的日志从最早的一条到最后一条(seq-1)连续，只允许在 windows 内缺失或有损坏的行
+func checkSequence(path string, total int64, windows []window) []string {
+	archives, err := logx.Archives(path)
+	if err != nil {
+		return []string{err.Error()}
+	}
+	var problems []string
+	var seqs []int64
+	broken := 0
+	for _, f := range append(archives, path) {
+		r, err := openFile(f)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", f, err))
+			continue
+		}
+		scanner := bufio.NewScanner(r)
+		scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+		for scanner.Scan() {
+			var line struct {
+				Seq *int64 `json:"seq"`
+			}
+			if err := json.Unmarshal(scanner.Bytes(), &line); err != nil || line.Seq == nil {
+				broken++
+				continue
+			}
+			seqs = append(seqs, *line.Seq)
+		}
+		if err := scanner.Err(); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", f, err))
+		}
+		r.Close()
+	}
+	if len(seqs) == 0 {
+		return append(problems, "no entries retained")
+	}
+	if !sort.SliceIsSorted(seqs, func(i, j int) bool { return seqs[i] < seqs[j] }) {
+		problems = append(problems, "entries are out of order across files")
+		sort.Slice(seqs, func(i, j int) bool { return seqs[i] < seqs[j] })
+	}
+	missing := 0
+	for i := 1; i < len(seqs); i++ {
+		if seqs[i] <= seqs[i-1] {
+			problems = append(problems, fmt.Sprintf("duplicate seq %d", seqs[i]))
+			continue
+		}
+		for s := seqs[i-1] + 1; s < seqs[i]; s++ {
+			missing++
+			if !inWindows(windows, s) {
+				problems = append(problems, fmt.Sprintf("seq %d..%d missing outside disk full windows", s, seqs[i]-1))
+				break
+			}
+		}
+	}
+	if last := seqs[len(seqs)-1]; last != total-1 {
+		problems = append(problems, fmt.Sprintf("last retained seq is %d, want %d", last, total-1))
+	}
+	if broken > 0 && len(windows) == 0 {
+		problems = append(problems, fmt.Sprintf("%d broken lines without disk full", broken))
+	}
+	fmt.Printf("soaktest: retained seq %d..%d, %d missing, %d broken lines\n", seqs[0], seqs[len(seqs)-1], missing, broken)
+	return problems
+}
+
+func inWindows(windows []window, seq int64) bool {
+	for _, w := range windows {
+		if w.contains(seq) {
+			return true
+		}
+	}
+	return false
+}
+
+func ballastPath(dir string) string {
+	return filepath.Join(dir, ".soak-ballast")
+}
+
+type fillResult struct {
+	window window // 写满期间写入的 seq
+	err    error
+}
+
+// 写满 dir 所在的文件系统，保持 hold 后删除
+func fillDisk(dir string, hold time.Duration, written *atomic.Int64) fillResult {
+	r := fillResult{window: window{from: written.Load()}}
+	path := ballastPath(dir)
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		r.window.to = written.Load()
+		r.err = err
+		return r
+	}
+	chunk := make([]byte, 1024*1024)
+	for {
+		if _, err := file.Write(chunk); err != nil {
+			break
+		}
+	}
+	file.Close()
+	fmt.Println("soaktest: disk full")
+	time.Sleep(hold)
+	r.err = os.Remove(path)
+	r.window.to = written.Load()
+	fmt.Println("soaktest: disk space released")
+	return r
+}
@@ -0,0 +1,106 @@
+package logx
+
+import (
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+type ColorMode int
+
+const (
+	ColorAuto   ColorMode = iota // 输出到终端时才使用颜色
+	ColorAlways                  // 总是输出ANSI颜色
+	ColorNever                   // 不使用颜色
+)
+
+// WithColor 设置控制台颜色模式，默认 ColorAuto；
+// ColorAuto 会遵循 NO_COLOR 环境变量和 TERM=dumb
+func WithColor(mode ColorMode) Option {
+	return func(l *Logger) {
+		l.colorMode = mode
+	}
+}
+
+// WithLevelColor 覆盖某个等级(包括自定义等级)的控制台样式，style 为ANSI转义序列，例如 Style(1, 31)
+func WithLevelColor(level LogLevel, style string) Option {
+	return func(l *Logger) {
+		if l.levelColors == nil {
+			l.levelColors = make(map[LogLevel]string, len(levelColors))
+			for k, v := range levelColors {
+				l.levelColors[k] = v
+			}
+		}
+		l.levelColors[level] = style
+	}
+}
+
+// Style 根据SGR参数生成样式，例如 Style(1, 31) 为粗体红色
+func Style(codes ...int) string {
+	parts := make([]string, len(codes))
+	for i, c := range codes {
+		parts[i] = strconv.Itoa(c)
+	}
+	return "\033[" + strings.Join(parts, ";") + "m"
+}
+
+// 返回等级对应的颜色和重置序列，不使用颜色时都为空，调用方需持有 l.mu
+func (l *Logger) colorFor(w io.Writer, level LogLevel) (string, string) {
+	if !l.useColor(w) {
+		return "", ""
+	}
+	colors := l.levelColors
+	if colors == nil {
+		colors = levelColors
+	}
+	color, ok := colors[level]
+	if !ok || color == "" {
+		return "", ""
+	}
+	return color, resetColor
+}
+
+func (l *Logger) useColor(w io.Writer) bool {
+	switch l.colorMode {
+	case ColorAlways:
+		return true
+	case ColorNever:
+		return false
+	}
+	if l.ttyCache == nil {
+		l.ttyCache = make(map[io.Writer]bool)
+	}
+	tty, ok := l.ttyCache[w]
+	if !ok {
+		tty = colorTerminal(w)
+		l.ttyCache[w] = tty
+	}
+	return tty
+}
+
+func colorTerminal(w io.Writer) bool {
+	if _, ok := os.LookupEnv("NO_COLOR"); ok {
+		return false
+	}
+	if os.Getenv("TERM") == "dumb" {
+		return false
+	}
+	if !isTerminal(w) {
+		return false
+	}
+	return enableANSI(w.(*os.File))
+}
+
+// 判断是否为字符设备(终端)
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	stat, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return stat.Mode()&os.ModeCharDevice != 0
+}
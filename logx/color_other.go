@@ -0,0 +1,7 @@
+//go:build !windows
+
+package logx
+
+import "os"
+
+func enableANSI(f *os.File) bool { return true }
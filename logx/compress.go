@@ -0,0 +1,160 @@
+package logx
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// Compressor 压缩编码，网络/HTTP 输出目标和归档文件都通过它压缩，
+// 可以自行实现 snappy、lz4、zstd 等编码并通过 RegisterCompressor 注册
+type Compressor interface {
+	// Name 编码名称，用作 HTTP Content-Encoding 和归档文件扩展名，例如 gzip
+	Name() string
+	NewWriter(w io.Writer) (io.WriteCloser, error)
+	NewReader(r io.Reader) (io.ReadCloser, error)
+}
+
+// 内置的压缩编码
+var (
+	Gzip    Compressor = gzipCompressor{level: gzip.DefaultCompression}
+	Deflate Compressor = zlibCompressor{}
+)
+
+var (
+	compressorsMu sync.RWMutex
+	compressors   = map[string]Compressor{"gzip": Gzip, "deflate": Deflate}
+)
+
+// RegisterCompressor 注册压缩编码，同名的会被覆盖
+func RegisterCompressor(c Compressor) {
+	compressorsMu.Lock()
+	defer compressorsMu.Unlock()
+	compressors[c.Name()] = c
+}
+
+// LookupCompressor 按名称查找已注册的压缩编码
+func LookupCompressor(name string) (Compressor, bool) {
+	compressorsMu.RLock()
+	defer compressorsMu.RUnlock()
+	c, ok := compressors[name]
+	return c, ok
+}
+
+// NewGzipCompressor 指定压缩等级的 gzip
+func NewGzipCompressor(level int) Compressor {
+	return gzipCompressor{level: level}
+}
+
+// Compress 一次性压缩数据，c 为 nil 时原样返回
+func Compress(c Compressor, data []byte) ([]byte, error) {
+	if c == nil {
+		return data, nil
+	}
+	var buf bytes.Buffer
+	w, err := c.NewWriter(&buf)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(data); err != nil {
+		w.Close()
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+type gzipCompressor struct {
+	level int
+}
+
+func (gzipCompressor) Name() string { return "gzip" }
+
+func (g gzipCompressor) NewWriter(w io.Writer) (io.WriteCloser, error) {
+	return gzip.NewWriterLevel(w, g.level)
+}
+
+func (gzipCompressor) NewReader(r io.Reader) (io.ReadCloser, error) {
+	return gzip.NewReader(r)
+}
+
+type zlibCompressor struct{}
+
+func (zlibCompressor) Name() string { return "deflate" }
+
+func (zlibCompressor) NewWriter(w io.Writer) (io.WriteCloser, error) {
+	return zlib.NewWriter(w), nil
+}
+
+func (zlibCompressor) NewReader(r io.Reader) (io.ReadCloser, error) {
+	return zlib.NewReader(r)
+}
+
+// WithArchiveCompression 切割后在后台用 c 压缩旧日志文件，压缩后的文件名追加 .<c.Name()>
+func WithArchiveCompression(c Compressor) Option {
+	return func(l *Logger) {
+		l.archiveCompressor = c
+	}
+}
+
+// 在后台压缩切割出的文件
+func (l *Logger) compressArchive(path string) {
+	l.archiveWg.Add(1)
+	go func() {
+		defer l.archiveWg.Done()
+		l.pruneMu.Lock()
+		_, err := CompressFile(l.archiveCompressor, path)
+		l.pruneMu.Unlock()
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			// 已经被 DeleteArchive 或 CompressArchive 处理的不再报告
+			l.handleError(OpRotate, path, err)
+		}
+		if l.maxBackups > 0 {
+			l.pruneArchives()
+		}
+	}()
+}
+
+// CompressFile 把 path 压缩为 path.<c.Name()> 并删除原文件，返回压缩后的路径
+func CompressFile(c Compressor, path string) (string, error) {
+	src, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer src.Close()
+
+	dstPath := fmt.Sprintf("%s.%s", path, c.Name())
+	dst, err := os.OpenFile(dstPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return "", err
+	}
+	if stat, serr := src.Stat(); serr == nil {
+		err = copyOwner(dst, stat)
+	}
+	var w io.WriteCloser
+	if err == nil {
+		w, err = c.NewWriter(dst)
+	}
+	if err == nil {
+		_, err = io.Copy(w, src)
+		if cerr := w.Close(); err == nil {
+			err = cerr
+		}
+	}
+	if cerr := dst.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(dstPath)
+		return "", err
+	}
+	src.Close()
+	return dstPath, os.Remove(path)
+}
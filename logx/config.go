@@ -0,0 +1,369 @@
+package logx
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// ConfigDuration 以 "100ms"、"1.5s"、"2m" 这样的字符串序列化的时长
+type ConfigDuration time.Duration
+
+func (d ConfigDuration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *ConfigDuration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("logx: duration must be a string like \"1s\": %s", data)
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = ConfigDuration(v)
+	return nil
+}
+
+// Config 可序列化的日志配置，可以从 JSON 读取后用 NewLoggerFromConfig 创建日志记录器，
+// 或用 ApplyConfig 热更新。ConfigSchema 生成对应的 JSON Schema，用于在下发前校验配置。
+// 输出目标、脱敏正则、按等级的采样、审计和加密的密钥等无法序列化或不应写在配置中的内容仍通过 Option 传入
+type Config struct {
+	File                    string         `json:"file" required:"true" desc:"日志文件路径"`
+	Level                   string         `json:"level,omitempty" enum:"debug,info,warn,error" default:"info" reload:"true" desc:"最低输出等级"`
+	MaxSizeMB               int64          `json:"max_size_mb,omitempty" minimum:"0" default:"10" reload:"true" desc:"单个日志文件最大大小(MB)"`
+	Console                 bool           `json:"console,omitempty" desc:"是否同时输出到控制台"`
+	Format                  string         `json:"format,omitempty" enum:"text,json,binary" default:"text" desc:"输出格式"`
+	Color                   string         `json:"color,omitempty" enum:"auto,always,never" default:"auto" desc:"控制台颜色模式"`
+	Caller                  bool           `json:"caller,omitempty" desc:"记录调用位置"`
+	TimeFormat              string         `json:"time_format,omitempty" desc:"time 包的布局或 epoch_seconds、epoch_millis、epoch_nanos"`
+	UTC                     bool           `json:"utc,omitempty" desc:"以UTC时间输出"`
+	WriteTime               bool           `json:"write_time,omitempty" desc:"输出写入时间而不是调用日志方法的时间"`
+	TimeSkewField           string         `json:"time_skew_field,omitempty" desc:"记录写入时间与调用时间之差的字段名"`
+	SchemaVersion           *int           `json:"schema_version,omitempty" minimum:"0" maximum:"1" desc:"JSON输出的结构版本，默认当前版本"`
+	BufferSize              int            `json:"buffer_size,omitempty" minimum:"0" desc:"写缓冲区大小(字节)，大于0时开启缓冲写入"`
+	FlushInterval           ConfigDuration `json:"flush_interval,omitempty" desc:"缓冲写入的定时刷新间隔"`
+	Workers                 int            `json:"workers,omitempty" minimum:"0" desc:"并行编码的协程数"`
+	MaxEntrySize            int            `json:"max_entry_size,omitempty" minimum:"0" desc:"单条日志最大字节数，超过时截断"`
+	Dedup                   ConfigDuration `json:"dedup,omitempty" desc:"合并该时间窗口内重复的日志"`
+	RedactKeys              []string       `json:"redact_keys,omitempty" desc:"需要屏蔽值的字段名"`
+	Filter                  string         `json:"filter,omitempty" desc:"过滤规则，见 ParseFilter，例如 Deny(level<INFO, module=\"http\")"`
+	Routes                  string         `json:"routes,omitempty" reload:"true" desc:"路由规则，见 ParseRoutes，例如 level>=ERROR, tag=security -> pagerduty"`
+	DryRunFilter            string         `json:"dry_run_filter,omitempty" reload:"true" desc:"试运行的过滤规则，只统计不生效"`
+	DryRunRoutes            string         `json:"dry_run_routes,omitempty" reload:"true" desc:"试运行的路由规则，只统计不生效"`
+	VersionHeader           bool           `json:"version_header,omitempty" desc:"每个日志文件开头写入版本文件头，见 ReadFileHeader"`
+	FloatPrecision          int            `json:"float_precision,omitempty" minimum:"0" desc:"JSON输出中浮点数固定保留的小数位数"`
+	FloatNoExponent         bool           `json:"float_no_exponent,omitempty" desc:"JSON输出中浮点数不使用科学计数法"`
+	BigIntAsString          bool           `json:"big_int_as_string,omitempty" desc:"JSON输出中超出JavaScript安全范围的整数输出为字符串"`
+	Compression             string         `json:"compression,omitempty" desc:"切割出的归档使用的压缩编码，例如 gzip"`
+	MaxBackups              int            `json:"max_backups,omitempty" minimum:"0" desc:"只保留最近的若干个归档，0 表示全部保留"`
+	SamplingTick            ConfigDuration `json:"sampling_tick,omitempty" desc:"采样的统计周期，默认1秒；所有等级使用相同的采样配置"`
+	SamplingFirst           int            `json:"sampling_first,omitempty" minimum:"0" desc:"每个周期内同一等级和消息全部输出的条数，与 sampling_thereafter 都为0时不采样"`
+	SamplingThereafter      int            `json:"sampling_thereafter,omitempty" minimum:"0" desc:"超出 sampling_first 后每多少条输出1条，0 表示全部丢弃"`
+	ErrorStreamLevel        string         `json:"error_stream_level,omitempty" enum:"debug,info,warn,error" desc:"不低于该等级的日志额外写入错误日志文件，为空时不开启"`
+	ErrorStreamFile         string         `json:"error_stream_file,omitempty" desc:"错误日志文件路径，默认根据日志文件名生成，例如 app.err.log"`
+	StatsCheckpoint         string         `json:"stats_checkpoint,omitempty" desc:"运行指标检查点文件，重启后继续累计"`
+	StatsCheckpointInterval ConfigDuration `json:"stats_checkpoint_interval,omitempty" desc:"写入检查点的间隔"`
+	WALDir                  string         `json:"wal_dir,omitempty" desc:"预写队列目录，为空时不开启"`
+	WALSync                 bool           `json:"wal_sync,omitempty" desc:"预写队列每条日志写入后 fsync"`
+	Audit                   bool           `json:"audit,omitempty" desc:"审计模式(SHA-256 哈希链)，使用 HMAC 密钥时通过 WithAudit 传入"`
+	MultiProcess            bool           `json:"multi_process,omitempty" desc:"多个进程写同一个文件"`
+	ExternalRotation        bool           `json:"external_rotation,omitempty" desc:"由 logrotate 等外部工具切割"`
+	StrictPaths             bool           `json:"strict_paths,omitempty" desc:"严格检查日志路径(符号链接、属主和权限)"`
+}
+
+// LoadConfig 解析并校验 JSON 配置，未知的字段会返回错误
+func LoadConfig(data []byte) (Config, error) {
+	var cfg Config
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&cfg); err != nil {
+		return Config{}, fmt.Errorf("logx: invalid config: %w", err)
+	}
+	return cfg, cfg.Validate()
+}
+
+// Validate 检查配置取值，与 ConfigSchema 的约束一致
+func (c Config) Validate() error {
+	if c.File == "" {
+		return fmt.Errorf("logx: config: file is required")
+	}
+	if _, err := c.level(); err != nil {
+		return fmt.Errorf("logx: config: %w", err)
+	}
+	if _, err := c.format(); err != nil {
+		return fmt.Errorf("logx: config: %w", err)
+	}
+	if _, err := c.color(); err != nil {
+		return err
+	}
+	if c.Compression != "" {
+		if _, ok := LookupCompressor(c.Compression); !ok {
+			return fmt.Errorf("logx: config: unknown compression %q", c.Compression)
+		}
+	}
+	if _, err := ParseFilter(c.Filter); err != nil {
+		return err
+	}
+	for _, rules := range []string{c.Routes, c.DryRunRoutes} {
+		if _, err := ParseRoutes(rules); err != nil {
+			return err
+		}
+	}
+	if _, err := ParseFilter(c.DryRunFilter); err != nil {
+		return err
+	}
+	if c.SchemaVersion != nil && (*c.SchemaVersion < SchemaLegacy || *c.SchemaVersion > CurrentSchemaVersion) {
+		return fmt.Errorf("logx: config: unsupported schema_version %d", *c.SchemaVersion)
+	}
+	if c.ErrorStreamLevel != "" {
+		if _, err := ParseLevel(c.ErrorStreamLevel); err != nil {
+			return fmt.Errorf("logx: config: error_stream_level: %w", err)
+		}
+	} else if c.ErrorStreamFile != "" {
+		return fmt.Errorf("logx: config: error_stream_file requires error_stream_level")
+	}
+	if c.WALSync && c.WALDir == "" {
+		return fmt.Errorf("logx: config: wal_sync requires wal_dir")
+	}
+	for name, v := range map[string]int64{
+		"max_size_mb":               c.MaxSizeMB,
+		"buffer_size":               int64(c.BufferSize),
+		"workers":                   int64(c.Workers),
+		"max_entry_size":            int64(c.MaxEntrySize),
+		"flush_interval":            int64(c.FlushInterval),
+		"dedup":                     int64(c.Dedup),
+		"float_precision":           int64(c.FloatPrecision),
+		"max_backups":               int64(c.MaxBackups),
+		"sampling_tick":             int64(c.SamplingTick),
+		"sampling_first":            int64(c.SamplingFirst),
+		"sampling_thereafter":       int64(c.SamplingThereafter),
+		"stats_checkpoint_interval": int64(c.StatsCheckpointInterval),
+	} {
+		if v < 0 {
+			return fmt.Errorf("logx: config: %s must not be negative", name)
+		}
+	}
+	return nil
+}
+
+func (c Config) level() (LogLevel, error) {
+	if c.Level == "" {
+		return INFO, nil
+	}
+	return ParseLevel(c.Level)
+}
+
+func (c Config) format() (Format, error) {
+	if c.Format == "" {
+		return FormatText, nil
+	}
+	return ParseFormat(c.Format)
+}
+
+func (c Config) color() (ColorMode, error) {
+	switch strings.ToLower(c.Color) {
+	case "", "auto":
+		return ColorAuto, nil
+	case "always":
+		return ColorAlways, nil
+	case "never":
+		return ColorNever, nil
+	}
+	return ColorAuto, fmt.Errorf("logx: config: unknown color %q", c.Color)
+}
+
+func (c Config) dryRun() DryRunConfig {
+	filter, _ := ParseFilter(c.DryRunFilter)
+	routes, _ := ParseRoutes(c.DryRunRoutes)
+	return DryRunConfig{Filter: filter, Routes: routes}
+}
+
+func (c Config) maxSizeMB() int64 {
+	if c.MaxSizeMB == 0 {
+		return 10
+	}
+	return c.MaxSizeMB
+}
+
+// Options 把配置转换为 Option，调用方需先调用 Validate
+func (c Config) Options() []Option {
+	format, _ := c.format()
+	color, _ := c.color()
+	opts := []Option{WithFormat(format), WithColor(color)}
+	if c.Caller {
+		opts = append(opts, WithCaller(true))
+	}
+	if c.TimeFormat != "" {
+		opts = append(opts, WithTimeFormat(c.TimeFormat))
+	}
+	if c.UTC {
+		opts = append(opts, WithUTC())
+	}
+	if c.WriteTime {
+		opts = append(opts, WithWriteTime())
+	}
+	if c.TimeSkewField != "" {
+		opts = append(opts, WithTimeSkewField(c.TimeSkewField))
+	}
+	if c.SchemaVersion != nil {
+		opts = append(opts, WithSchemaVersion(*c.SchemaVersion))
+	}
+	if c.VersionHeader {
+		opts = append(opts, WithVersionHeader())
+	}
+	if c.FloatPrecision > 0 || c.FloatNoExponent || c.BigIntAsString {
+		opts = append(opts, WithNumberFormat(NumberFormat{FloatPrecision: c.FloatPrecision, NoExponent: c.FloatNoExponent, BigIntAsString: c.BigIntAsString}))
+	}
+	if c.BufferSize > 0 || c.FlushInterval > 0 {
+		opts = append(opts, WithBufferedWrites(c.BufferSize, time.Duration(c.FlushInterval)))
+	}
+	if c.Workers > 0 {
+		opts = append(opts, WithWorkers(c.Workers))
+	}
+	if c.MaxEntrySize > 0 {
+		opts = append(opts, WithMaxEntrySize(c.MaxEntrySize))
+	}
+	if c.Dedup > 0 {
+		opts = append(opts, WithDedup(time.Duration(c.Dedup)))
+	}
+	if len(c.RedactKeys) > 0 {
+		opts = append(opts, WithRedaction(RedactionConfig{Keys: c.RedactKeys}))
+	}
+	if c.Filter != "" {
+		rules, _ := ParseFilter(c.Filter)
+		opts = append(opts, WithFilter(rules...))
+	}
+	// 总是开启路由和试运行，之后可以通过 ApplyConfig 热更新规则
+	routes, _ := ParseRoutes(c.Routes)
+	opts = append(opts, WithRoutes(routes...), WithDryRun(c.dryRun()))
+	if c.Compression != "" {
+		compressor, _ := LookupCompressor(c.Compression)
+		opts = append(opts, WithArchiveCompression(compressor))
+	}
+	if c.MaxBackups > 0 {
+		opts = append(opts, WithMaxBackups(c.MaxBackups))
+	}
+	if c.SamplingFirst > 0 || c.SamplingThereafter > 0 {
+		opts = append(opts, WithSampling(SamplingConfig{Tick: time.Duration(c.SamplingTick), First: c.SamplingFirst, Thereafter: c.SamplingThereafter}))
+	}
+	if c.ErrorStreamLevel != "" {
+		level, _ := ParseLevel(c.ErrorStreamLevel)
+		opts = append(opts, WithErrorStream(c.ErrorStreamFile, level))
+	}
+	if c.StatsCheckpoint != "" {
+		opts = append(opts, WithStatsCheckpoint(c.StatsCheckpoint, time.Duration(c.StatsCheckpointInterval)))
+	}
+	if c.WALDir != "" {
+		opts = append(opts, WithWAL(SpoolConfig{Dir: c.WALDir, Sync: c.WALSync}))
+	}
+	if c.Audit {
+		opts = append(opts, WithAudit(nil))
+	}
+	if c.MultiProcess {
+		opts = append(opts, WithMultiProcess())
+	}
+	if c.ExternalRotation {
+		opts = append(opts, WithExternalRotation())
+	}
+	if c.StrictPaths {
+		opts = append(opts, WithStrictPaths())
+	}
+	return opts
+}
+
+// NewLoggerFromConfig 校验配置后创建日志记录器，opts 在配置之后应用
+func NewLoggerFromConfig(cfg Config, opts ...Option) (*Logger, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	level, _ := cfg.level()
+	opts = append(cfg.Options(), opts...)
+	l, err := NewLogger(cfg.File, level, cfg.maxSizeMB(), cfg.Console, opts...)
+	if err != nil {
+		return nil, err
+	}
+	l.config = &cfg
+	return l, nil
+}
+
+// ApplyConfig 热更新配置。只有标记为可热更新的字段(level、max_size_mb、routes 和试运行规则)会生效；
+// 日志记录器由 NewLoggerFromConfig 创建时，其他字段与创建时不同会返回错误且不做任何修改
+func (l *Logger) ApplyConfig(cfg Config) error {
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+	if l.config != nil {
+		if changed := restartFields(*l.config, cfg); len(changed) > 0 {
+			return fmt.Errorf("logx: config fields %s cannot be changed without recreating the logger", strings.Join(changed, ", "))
+		}
+	}
+	routes, _ := ParseRoutes(cfg.Routes)
+	dryRun := cfg.dryRun()
+	// 先检查再修改，避免只生效一部分
+	setRoutes := l.router != nil || len(routes) > 0
+	if setRoutes {
+		if l.router == nil {
+			return errRoutingDisabled
+		}
+		if err := l.checkRoutes(routes); err != nil {
+			return err
+		}
+	}
+	// 试运行规则没有变化时保留统计
+	setDryRun := l.config == nil || cfg.DryRunFilter != l.config.DryRunFilter || cfg.DryRunRoutes != l.config.DryRunRoutes
+	if setDryRun && (l.dryRun != nil || dryRun.Filter != nil || dryRun.Routes != nil) {
+		if l.dryRun == nil {
+			return errDryRunDisabled
+		}
+		if err := l.checkRoutes(dryRun.Routes); err != nil {
+			return err
+		}
+	} else {
+		setDryRun = false
+	}
+	if setRoutes {
+		l.SetRoutes(routes...)
+	}
+	if setDryRun {
+		l.SetDryRun(dryRun)
+	}
+	level, _ := cfg.level()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.setLevelLocked(level)
+	l.maxSize = cfg.maxSizeMB() * 1024 * 1024
+	if l.config != nil {
+		l.config = &cfg
+	}
+	return nil
+}
+
+// 不能热更新且取值不同的字段
+func restartFields(old, cfg Config) []string {
+	var changed []string
+	ov, nv := reflect.ValueOf(old), reflect.ValueOf(cfg)
+	t := ov.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.Tag.Get("reload") == "true" {
+			continue
+		}
+		if !reflect.DeepEqual(ov.Field(i).Interface(), nv.Field(i).Interface()) {
+			changed = append(changed, jsonName(field))
+		}
+	}
+	return changed
+}
+
+func jsonName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	if name == "" {
+		return field.Name
+	}
+	return name
+}
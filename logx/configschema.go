@@ -0,0 +1,100 @@
+package logx
+
+import (
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// ConfigSchemaID 生成的 JSON Schema 的 $id
+const ConfigSchemaID = "https://github.com/capyflow/opensource/logx/config.schema.json"
+
+var durationType = reflect.TypeOf(ConfigDuration(0))
+
+// ConfigSchema 根据 Config 的字段生成 JSON Schema(draft 2020-12)，
+// 平台可以用它在下发前校验租户提供的日志配置
+func ConfigSchema() []byte {
+	schema := structSchema(reflect.TypeOf(Config{}))
+	schema["$schema"] = "https://json-schema.org/draft/2020-12/schema"
+	schema["$id"] = ConfigSchemaID
+	schema["title"] = "logx.Config"
+	data, _ := json.MarshalIndent(schema, "", "  ")
+	return data
+}
+
+func structSchema(t reflect.Type) map[string]interface{} {
+	properties := map[string]interface{}{}
+	var required []string
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name := jsonName(field)
+		if name == "-" {
+			continue
+		}
+		prop := typeSchema(field.Type)
+		if desc := field.Tag.Get("desc"); desc != "" {
+			prop["description"] = desc
+		}
+		if enum := field.Tag.Get("enum"); enum != "" {
+			prop["enum"] = strings.Split(enum, ",")
+		}
+		for _, key := range []string{"minimum", "maximum"} {
+			if v, err := strconv.ParseInt(field.Tag.Get(key), 10, 64); err == nil {
+				prop[key] = v
+			}
+		}
+		if def := field.Tag.Get("default"); def != "" {
+			if v, err := strconv.ParseInt(def, 10, 64); err == nil && prop["type"] == "integer" {
+				prop["default"] = v
+			} else {
+				prop["default"] = def
+			}
+		}
+		if field.Tag.Get("reload") == "true" {
+			prop["x-logx-reloadable"] = true
+		}
+		if field.Tag.Get("required") == "true" {
+			required = append(required, name)
+			prop["minLength"] = 1
+		}
+		properties[name] = prop
+	}
+	schema := map[string]interface{}{
+		"type":                 "object",
+		"properties":           properties,
+		"additionalProperties": false,
+	}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+func typeSchema(t reflect.Type) map[string]interface{} {
+	if t == durationType {
+		// 与 time.ParseDuration 接受的格式一致
+		return map[string]interface{}{"type": "string", "pattern": `^([0-9]+(\.[0-9]+)?(ns|us|µs|ms|s|m|h))+$|^0$`}
+	}
+	switch t.Kind() {
+	case reflect.Ptr:
+		return typeSchema(t.Elem())
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": typeSchema(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": typeSchema(t.Elem())}
+	case reflect.Struct:
+		return structSchema(t)
+	}
+	return map[string]interface{}{"type": "string"}
+}
@@ -0,0 +1,108 @@
+package logx
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// Credentials 为发往日志服务的请求添加认证信息。令牌需要轮换时由实现自行刷新，不需要重建日志记录器
+type Credentials interface {
+	Authorize(req *http.Request) error
+}
+
+// Refresher 可以丢弃缓存令牌的认证信息，服务端返回 401 时会调用 Invalidate 后重试一次
+type Refresher interface {
+	Invalidate()
+}
+
+// TokenSource 获取一个新令牌和它的过期时间，expiry 为零值表示不过期
+type TokenSource func(ctx context.Context) (token string, expiry time.Time, err error)
+
+// BearerToken 使用 Authorization: Bearer 头的认证信息，令牌过期前 margin 时间内会提前刷新
+type BearerToken struct {
+	source  TokenSource
+	margin  time.Duration
+	timeout time.Duration
+
+	mu     sync.Mutex
+	token  string
+	expiry time.Time
+	valid  bool
+}
+
+// NewBearerToken 创建按需刷新的令牌，margin <= 0 时默认提前1分钟刷新
+func NewBearerToken(source TokenSource, margin time.Duration) *BearerToken {
+	if margin <= 0 {
+		margin = time.Minute
+	}
+	return &BearerToken{source: source, margin: margin, timeout: 10 * time.Second}
+}
+
+// StaticToken 固定不变的令牌
+func StaticToken(token string) *BearerToken {
+	return NewBearerToken(func(context.Context) (string, time.Time, error) {
+		return token, time.Time{}, nil
+	}, 0)
+}
+
+// TokenFile 每次刷新时从文件读取令牌，适合 Kubernetes 投射的服务账号令牌等由外部轮换的文件。
+// 文件内容没有过期时间，每隔 refresh(默认1分钟)重新读取一次
+func TokenFile(path string, refresh time.Duration) *BearerToken {
+	if refresh <= 0 {
+		refresh = time.Minute
+	}
+	b := NewBearerToken(func(context.Context) (string, time.Time, error) {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", time.Time{}, err
+		}
+		return string(bytes.TrimSpace(data)), time.Now().Add(refresh), nil
+	}, 0)
+	b.margin = 0
+	return b
+}
+
+// Token 返回当前有效的令牌，需要时刷新
+func (b *BearerToken) Token(ctx context.Context) (string, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.valid && (b.expiry.IsZero() || time.Now().Add(b.margin).Before(b.expiry)) {
+		return b.token, nil
+	}
+	token, expiry, err := b.source(ctx)
+	if err != nil {
+		if b.valid && (b.expiry.IsZero() || time.Now().Before(b.expiry)) {
+			// 刷新失败但旧令牌还没有真正过期，继续使用
+			return b.token, nil
+		}
+		return "", err
+	}
+	b.token, b.expiry, b.valid = token, expiry, true
+	return token, nil
+}
+
+func (b *BearerToken) Invalidate() {
+	b.mu.Lock()
+	b.valid = false
+	b.mu.Unlock()
+}
+
+func (b *BearerToken) Authorize(req *http.Request) error {
+	ctx, cancel := context.WithTimeout(req.Context(), b.timeout)
+	defer cancel()
+	token, err := b.Token(ctx)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	return nil
+}
+
+// CredentialsFunc 把函数作为 Credentials，例如为请求计算签名
+type CredentialsFunc func(req *http.Request) error
+
+func (f CredentialsFunc) Authorize(req *http.Request) error { return f(req) }
@@ -0,0 +1,67 @@
+package logx
+
+import (
+	"fmt"
+	"time"
+)
+
+// WithDedup 合并时间窗口内连续出现的相同日志(等级和消息都相同)，
+// 只输出第一条，随后输出 "last message repeated N times"
+func WithDedup(window time.Duration) Option {
+	return func(l *Logger) {
+		if window <= 0 {
+			l.dedup = nil
+			return
+		}
+		l.dedup = &dedupState{window: window}
+	}
+}
+
+// 由 l.mu 保护
+type dedupState struct {
+	window  time.Duration
+	last    Entry // 最近一条实际输出的日志
+	has     bool
+	repeats int // last 之后被合并的条数
+}
+
+// 与上一条相同且在窗口内则计数并返回 true
+func (d *dedupState) absorb(entry Entry) bool {
+	if !d.has || entry.Level != d.last.Level || entry.Message != d.last.Message || !sameFields(entry.Fields, d.last.Fields) {
+		return false
+	}
+	if entry.Time.Sub(d.last.Time) > d.window {
+		return false
+	}
+	d.repeats++
+	return true
+}
+
+func (d *dedupState) remember(entry Entry) {
+	d.last = entry
+	d.has = true
+	d.repeats = 0
+}
+
+// 输出重复计数，调用方需持有 l.mu
+func (l *Logger) flushRepeats() {
+	d := l.dedup
+	if d.repeats == 0 {
+		return
+	}
+	msg := fmt.Sprintf("last message repeated %d times", d.repeats)
+	repeats := d.last
+	repeats.Message = msg
+	repeats.Fields = nil
+	repeats.Time = l.now()
+	d.has = false
+	d.repeats = 0
+	l.output(repeats)
+}
+
+// 窗口到期后即使没有新日志也输出重复计数，调用方需持有 l.mu
+func (l *Logger) expireRepeats(now time.Time) {
+	if l.dedup.has && now.Sub(l.dedup.last.Time) > l.dedup.window {
+		l.flushRepeats()
+	}
+}
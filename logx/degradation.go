@@ -0,0 +1,119 @@
+package logx
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// 保留的降级报告数量
+const maxDegradationReports = 16
+
+// DegradationReport 一段日志不可信的时间范围：期间发生了丢弃、写入失败等情况
+type DegradationReport struct {
+	Start  time.Time         `json:"start"`
+	End    time.Time         `json:"end"`
+	Counts map[string]uint64 `json:"counts"` // 按类型统计，例如 dropped、write_error
+}
+
+// WithDegradationReport 记录丢弃、输出失败等降级事件，连续 quiet 时间内没有新事件时认为事件结束，
+// 输出一条汇总的 "logx degradation report" 日志，并通过 Stats().Degradations 暴露
+func WithDegradationReport(quiet time.Duration) Option {
+	return func(l *Logger) {
+		if quiet <= 0 {
+			quiet = 30 * time.Second
+		}
+		l.degradation = &degradationTracker{quiet: quiet}
+	}
+}
+
+type degradationTracker struct {
+	mu      sync.Mutex
+	quiet   time.Duration
+	current *DegradationReport
+	reports []DegradationReport
+}
+
+// 记录一次降级事件
+func (l *Logger) noteDegradation(kind string) {
+	d := l.degradation
+	if d == nil {
+		return
+	}
+	now := l.now()
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.current == nil {
+		d.current = &DegradationReport{Start: now, Counts: make(map[string]uint64)}
+	}
+	d.current.End = now
+	d.current.Counts[kind]++
+}
+
+// 结束已经平静下来的事件并返回
+func (d *degradationTracker) finish(now time.Time, force bool) *DegradationReport {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.current == nil || (!force && now.Sub(d.current.End) < d.quiet) {
+		return nil
+	}
+	report := *d.current
+	d.current = nil
+	d.reports = append(d.reports, report)
+	if len(d.reports) > maxDegradationReports {
+		d.reports = d.reports[len(d.reports)-maxDegradationReports:]
+	}
+	return &report
+}
+
+func (d *degradationTracker) snapshot() []DegradationReport {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	out := make([]DegradationReport, 0, len(d.reports)+1)
+	out = append(out, d.reports...)
+	if d.current != nil {
+		ongoing := *d.current
+		ongoing.Counts = make(map[string]uint64, len(d.current.Counts))
+		for k, v := range d.current.Counts {
+			ongoing.Counts[k] = v
+		}
+		out = append(out, ongoing)
+	}
+	return out
+}
+
+func (l *Logger) runDegradationReport() {
+	defer l.bgWg.Done()
+	ticker := time.NewTicker(l.degradation.quiet / 4)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			l.emitDegradationReport(false)
+		case <-l.done:
+			l.emitDegradationReport(true)
+			return
+		}
+	}
+}
+
+func (l *Logger) emitDegradationReport(force bool) {
+	report := l.degradation.finish(l.now(), force)
+	if report == nil {
+		return
+	}
+	kinds := make([]string, 0, len(report.Counts))
+	for kind := range report.Counts {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	fields := []Field{
+		Any("start", report.Start),
+		Any("end", report.End),
+		Duration("duration", report.End.Sub(report.Start)),
+	}
+	for _, kind := range kinds {
+		fields = append(fields, Any(kind, report.Counts[kind]))
+	}
+	l.enqueue(Entry{Level: WARN, Time: l.now(), Message: "logx degradation report", Fields: fields})
+}
@@ -0,0 +1,165 @@
+package logx
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Change 一个字段的变化，Path 形如 "db.hosts[1]"
+type Change struct {
+	Path string      `json:"path"`
+	Old  interface{} `json:"old"`
+	New  interface{} `json:"new"`
+}
+
+func (c Change) String() string {
+	return fmt.Sprintf("%s: %v -> %v", c.Path, c.Old, c.New)
+}
+
+// Diff 计算 oldVal 与 newVal 的字段级差异，以 INFO 等级记录，差异放在 "changes" 字段中；
+// 没有差异时不输出。结构体按 json 标签(没有则按字段名)命名，json:"-" 的字段会被忽略
+func (l *Logger) Diff(msg string, oldVal, newVal interface{}, fields ...Field) {
+	if INFO < l.minLevel() && !l.filterMayAllow(INFO) {
+		return
+	}
+	changes := DiffValues(oldVal, newVal)
+	if len(changes) == 0 {
+		return
+	}
+	l.log(INFO, msg, append([]Field{Any("changes", changes)}, fields...))
+}
+
+// DiffValues 返回两个值之间的字段级差异
+func DiffValues(oldVal, newVal interface{}) []Change {
+	var changes []Change
+	diffValue(&changes, "", reflect.ValueOf(oldVal), reflect.ValueOf(newVal))
+	return changes
+}
+
+func diffValue(changes *[]Change, path string, a, b reflect.Value) {
+	// 解开指针和接口
+	for a.IsValid() && (a.Kind() == reflect.Ptr || a.Kind() == reflect.Interface) {
+		if a.IsNil() {
+			a = reflect.Value{}
+			break
+		}
+		a = a.Elem()
+	}
+	for b.IsValid() && (b.Kind() == reflect.Ptr || b.Kind() == reflect.Interface) {
+		if b.IsNil() {
+			b = reflect.Value{}
+			break
+		}
+		b = b.Elem()
+	}
+
+	if !a.IsValid() || !b.IsValid() || a.Type() != b.Type() {
+		if a.IsValid() != b.IsValid() || (a.IsValid() && !reflect.DeepEqual(a.Interface(), b.Interface())) {
+			*changes = append(*changes, Change{Path: rootPath(path), Old: valueOrNil(a), New: valueOrNil(b)})
+		}
+		return
+	}
+
+	switch a.Kind() {
+	case reflect.Struct:
+		t := a.Type()
+		// 没有导出字段的结构体(如 time.Time)整体比较
+		if !hasExportedFields(t) {
+			break
+		}
+		for i := 0; i < t.NumField(); i++ {
+			sf := t.Field(i)
+			if !sf.IsExported() {
+				continue
+			}
+			name := fieldName(sf)
+			if name == "" {
+				continue
+			}
+			diffValue(changes, joinPath(path, name), a.Field(i), b.Field(i))
+		}
+		return
+	case reflect.Map:
+		keys := make(map[string]reflect.Value)
+		for _, k := range a.MapKeys() {
+			keys[fmt.Sprint(k.Interface())] = k
+		}
+		for _, k := range b.MapKeys() {
+			keys[fmt.Sprint(k.Interface())] = k
+		}
+		names := make([]string, 0, len(keys))
+		for name := range keys {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			k := keys[name]
+			diffValue(changes, joinPath(path, name), a.MapIndex(k), b.MapIndex(k))
+		}
+		return
+	case reflect.Slice, reflect.Array:
+		n := a.Len()
+		if b.Len() > n {
+			n = b.Len()
+		}
+		for i := 0; i < n; i++ {
+			var ai, bi reflect.Value
+			if i < a.Len() {
+				ai = a.Index(i)
+			}
+			if i < b.Len() {
+				bi = b.Index(i)
+			}
+			diffValue(changes, path+"["+strconv.Itoa(i)+"]", ai, bi)
+		}
+		return
+	}
+
+	if !reflect.DeepEqual(a.Interface(), b.Interface()) {
+		*changes = append(*changes, Change{Path: rootPath(path), Old: a.Interface(), New: b.Interface()})
+	}
+}
+
+func hasExportedFields(t reflect.Type) bool {
+	for i := 0; i < t.NumField(); i++ {
+		if t.Field(i).IsExported() {
+			return true
+		}
+	}
+	return false
+}
+
+func fieldName(sf reflect.StructField) string {
+	tag := sf.Tag.Get("json")
+	if tag == "-" {
+		return ""
+	}
+	if name, _, _ := strings.Cut(tag, ","); name != "" {
+		return name
+	}
+	return sf.Name
+}
+
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+func rootPath(path string) string {
+	if path == "" {
+		return "."
+	}
+	return path
+}
+
+func valueOrNil(v reflect.Value) interface{} {
+	if !v.IsValid() {
+		return nil
+	}
+	return v.Interface()
+}
@@ -0,0 +1,167 @@
+package logx
+
+import (
+	"errors"
+	"sync/atomic"
+)
+
+// DryRunConfig 试运行的过滤和路由规则，为 nil 的一项沿用当前生效的规则
+type DryRunConfig struct {
+	Filter []FilterRule
+	Routes []RouteRule
+}
+
+// DryRunStats 试运行规则从设置以来的统计，更换规则后重新计数
+type DryRunStats struct {
+	Evaluated  uint64            // 评估的条数
+	Dropped    uint64            // 会被过滤或路由规则丢弃的条数
+	Downgraded uint64            // 会被降低等级的条数
+	Routed     map[string]uint64 // 会发送到各输出目标的条数
+	FilterHits []uint64          // 试运行的每条过滤规则匹配的次数
+	RouteHits  []uint64          // 试运行的每条路由规则匹配的次数
+}
+
+type dryRun struct {
+	rules   atomic.Pointer[dryRunRules]
+	initial DryRunConfig // 创建日志记录器时所有输出目标都添加后才能生效
+}
+
+type dryRunRules struct {
+	filter    *filter // 为 nil 时沿用 l.filter
+	routes    []RouteRule
+	hasRoutes bool
+
+	evaluated  atomic.Uint64
+	dropped    atomic.Uint64
+	downgraded atomic.Uint64
+	routed     []atomic.Uint64 // 与 l.sinks 一一对应
+	filterHits []atomic.Uint64
+	routeHits  []atomic.Uint64
+}
+
+// WithDryRun 试运行新的过滤和路由规则：规则会在过滤之前对每条日志评估，但不影响输出，
+// 通过 DryRunStats 查看替换当前规则后会丢弃、降级和发送到各输出目标的条数，确认无误后再用
+// WithFilter、SetRoutes 正式生效。低于最低等级且当前规则不会放行的日志不会进入写入协程，
+// 试运行的 Allow 规则看不到这些日志。之后可以用 SetDryRun 或 ApplyConfig 更换
+func WithDryRun(cfg DryRunConfig) Option {
+	return func(l *Logger) {
+		l.dryRun = &dryRun{initial: cfg}
+	}
+}
+
+var errDryRunDisabled = errors.New("logx: dry run is not enabled, create the logger with WithDryRun")
+
+// SetDryRun 更换试运行的规则并清零统计，cfg 为空时停止试运行
+func (l *Logger) SetDryRun(cfg DryRunConfig) error {
+	if l.dryRun == nil {
+		return errDryRunDisabled
+	}
+	if err := l.checkRoutes(cfg.Routes); err != nil {
+		return err
+	}
+	l.storeDryRun(cfg)
+	return nil
+}
+
+func (l *Logger) storeDryRun(cfg DryRunConfig) {
+	if cfg.Filter == nil && cfg.Routes == nil {
+		l.dryRun.rules.Store(nil)
+		return
+	}
+	d := &dryRunRules{
+		routes:     cfg.Routes,
+		hasRoutes:  cfg.Routes != nil,
+		routed:     make([]atomic.Uint64, len(l.sinks)),
+		filterHits: make([]atomic.Uint64, len(cfg.Filter)),
+		routeHits:  make([]atomic.Uint64, len(cfg.Routes)),
+	}
+	if cfg.Filter != nil {
+		d.filter = newFilter(cfg.Filter)
+	}
+	l.dryRun.rules.Store(d)
+}
+
+// DryRunStats 返回试运行的统计，没有试运行时返回 nil
+func (l *Logger) DryRunStats() *DryRunStats {
+	if l.dryRun == nil {
+		return nil
+	}
+	d := l.dryRun.rules.Load()
+	if d == nil {
+		return nil
+	}
+	s := &DryRunStats{
+		Evaluated:  d.evaluated.Load(),
+		Dropped:    d.dropped.Load(),
+		Downgraded: d.downgraded.Load(),
+		Routed:     make(map[string]uint64, len(l.sinks)),
+		FilterHits: loadCounters(d.filterHits),
+		RouteHits:  loadCounters(d.routeHits),
+	}
+	for i, route := range l.sinks {
+		s.Routed[route.name] += d.routed[i].Load()
+	}
+	return s
+}
+
+func loadCounters(counters []atomic.Uint64) []uint64 {
+	values := make([]uint64, len(counters))
+	for i := range counters {
+		values[i] = counters[i].Load()
+	}
+	return values
+}
+
+// 按试运行规则评估，不修改 e
+func (l *Logger) evaluateDryRun(e *Entry) {
+	d := l.dryRun.rules.Load()
+	if d == nil {
+		return
+	}
+	d.evaluated.Add(1)
+	f := d.filter
+	if f == nil {
+		f = l.filter
+	}
+	if f != nil {
+		keep := !e.belowLevel
+		if i := f.match(e); i >= 0 {
+			keep = f.rules[i].allow
+			if f == d.filter {
+				d.filterHits[i].Add(1)
+			}
+		}
+		if !keep {
+			d.dropped.Add(1)
+			return
+		}
+	}
+
+	routes := d.routes
+	if !d.hasRoutes && l.router != nil {
+		routes = *l.router.rules.Load()
+	}
+	decided := Entry{Level: e.Level, routed: e.routed, routes: e.routes, targeted: e.targeted, alsoSinks: e.alsoSinks}
+	if i := matchRoute(routes, e); i >= 0 {
+		if d.hasRoutes {
+			d.routeHits[i].Add(1)
+		}
+		switch r := routes[i]; r.action {
+		case routeTo:
+			if !decided.targeted {
+				decided.routed, decided.routes = true, r.sinks
+			}
+		case routeDrop:
+			d.dropped.Add(1)
+			return
+		case routeDowngrade:
+			decided.Level = r.level
+			d.downgraded.Add(1)
+		}
+	}
+	for i, route := range l.sinks {
+		if decided.routedTo(route) {
+			d.routed[i].Add(1)
+		}
+	}
+}
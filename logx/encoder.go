@@ -0,0 +1,256 @@
+package logx
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+type Format int
+
+const (
+	FormatText   Format = iota // 文本格式
+	FormatJSON                 // 每行一个JSON对象
+	FormatBinary               // 长度前缀的 MessagePack 记录，用 Reader 读取，控制台仍输出文本
+)
+
+func (f Format) String() string {
+	switch f {
+	case FormatText:
+		return "text"
+	case FormatJSON:
+		return "json"
+	case FormatBinary:
+		return "binary"
+	default:
+		return "unknown"
+	}
+}
+
+// ParseFormat 解析格式名称，支持 text、json、binary
+func ParseFormat(s string) (Format, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "text", "":
+		return FormatText, nil
+	case "json":
+		return FormatJSON, nil
+	case "binary":
+		return FormatBinary, nil
+	default:
+		return FormatText, fmt.Errorf("logx: unknown format %q", s)
+	}
+}
+
+// 预先格式化的等级文本，避免每条日志拼接字符串
+var (
+	levelTags  = [...]string{DEBUG: "[DEBUG] ", INFO: "[INFO] ", WARN: "[WARN] ", ERROR: "[ERROR] "}
+	jsonLevels = [...]string{DEBUG: `,"level":"DEBUG"`, INFO: `,"level":"INFO"`, WARN: `,"level":"WARN"`, ERROR: `,"level":"ERROR"`}
+)
+
+func levelTag(level LogLevel) string {
+	if level >= DEBUG && int(level) < len(levelTags) {
+		return levelTags[level]
+	}
+	return "[" + levelString(level) + "] "
+}
+
+// 编码为JSON行
+func (l *Logger) encodeJSON(entry Entry) []byte {
+	return l.appendJSON(make([]byte, 0, 64+len(entry.Message)), entry)
+}
+
+func (l *Logger) appendJSON(buf []byte, entry Entry) []byte {
+	buf = append(buf, '{')
+	buf = l.appendSchemaVersion(buf)
+	buf = append(buf, `"time":`...)
+	buf = l.appendTime(buf, entry.Time, time.RFC3339Nano, true)
+	if entry.Level >= DEBUG && int(entry.Level) < len(jsonLevels) {
+		buf = append(buf, jsonLevels[entry.Level]...)
+	} else {
+		buf = append(buf, `,"level":`...)
+		buf = appendJSONString(buf, levelString(entry.Level))
+	}
+	if entry.File != "" {
+		buf = append(buf, `,"caller":"`...)
+		buf = appendShortCaller(buf, entry.File, entry.Line)
+		buf = append(buf, '"')
+	}
+	buf = append(buf, `,"msg":`...)
+	buf = appendJSONString(buf, entry.Message)
+	for _, f := range entry.Fields {
+		buf = append(buf, ',')
+		buf = appendJSONString(buf, l.jsonFieldKey(f.Key))
+		buf = append(buf, ':')
+		buf = l.appendJSONValue(buf, f.Value)
+	}
+	buf = append(buf, '}', '\n')
+	return buf
+}
+
+// 编码为文本(不含时间和换行)：[LEVEL] caller msg k=v
+func appendText(buf []byte, entry Entry) []byte {
+	buf = append(buf, levelTag(entry.Level)...)
+	if entry.File != "" {
+		buf = appendShortCaller(buf, entry.File, entry.Line)
+		buf = append(buf, ' ')
+	}
+	buf = append(buf, entry.Message...)
+	return appendTextFields(buf, entry.Fields)
+}
+
+// 文本格式的字段，形如 " k=v k2=\"a b\""
+func textFields(fields []Field) string {
+	return string(appendTextFields(nil, fields))
+}
+
+func appendTextFields(buf []byte, fields []Field) []byte {
+	for _, f := range fields {
+		if g, ok := f.Value.(FieldGroup); ok {
+			buf = appendTextGroup(buf, f.Key+".", g)
+			continue
+		}
+		buf = append(buf, ' ')
+		buf = append(buf, f.Key...)
+		buf = append(buf, '=')
+		buf = appendTextValue(buf, f.Value)
+	}
+	return buf
+}
+
+func textValue(v interface{}) string {
+	return string(appendTextValue(nil, v))
+}
+
+func appendTextValue(buf []byte, v interface{}) []byte {
+	var s string
+	switch val := v.(type) {
+	case string:
+		s = val
+	case int:
+		return strconv.AppendInt(buf, int64(val), 10)
+	case int64:
+		return strconv.AppendInt(buf, val, 10)
+	case int32:
+		return strconv.AppendInt(buf, int64(val), 10)
+	case uint64:
+		return strconv.AppendUint(buf, val, 10)
+	case bool:
+		return strconv.AppendBool(buf, val)
+	case float64:
+		return strconv.AppendFloat(buf, val, 'g', -1, 64)
+	case time.Duration:
+		s = val.String()
+	case error:
+		s = val.Error()
+	case fmt.Stringer:
+		s = val.String()
+	case int8, int16, uint, uint8, uint16, uint32, float32:
+		return fmt.Append(buf, val)
+	default:
+		data, err := json.Marshal(val)
+		if err != nil {
+			s = fmt.Sprintf("%+v", val)
+		} else {
+			return append(buf, data...)
+		}
+	}
+	if s == "" || strings.ContainsAny(s, " \t\n\r\"=") {
+		return strconv.AppendQuote(buf, s)
+	}
+	return append(buf, s...)
+}
+
+// 编码任意字段值为JSON
+func appendJSONValue(buf []byte, v interface{}) []byte {
+	switch val := v.(type) {
+	case nil:
+		return append(buf, "null"...)
+	case string:
+		return appendJSONString(buf, val)
+	case bool:
+		return strconv.AppendBool(buf, val)
+	case int:
+		return strconv.AppendInt(buf, int64(val), 10)
+	case int32:
+		return strconv.AppendInt(buf, int64(val), 10)
+	case int64:
+		return strconv.AppendInt(buf, val, 10)
+	case uint:
+		return strconv.AppendUint(buf, uint64(val), 10)
+	case uint32:
+		return strconv.AppendUint(buf, uint64(val), 10)
+	case uint64:
+		return strconv.AppendUint(buf, val, 10)
+	case float64:
+		return appendJSONFloat(buf, val, 64)
+	case float32:
+		return appendJSONFloat(buf, float64(val), 32)
+	case time.Time:
+		return appendJSONString(buf, val.Format(time.RFC3339Nano))
+	case time.Duration:
+		return appendJSONString(buf, val.String())
+	case error:
+		return appendJSONString(buf, val.Error())
+	case json.Marshaler:
+		data, err := val.MarshalJSON()
+		if err != nil {
+			return appendJSONString(buf, err.Error())
+		}
+		return append(buf, data...)
+	case fmt.Stringer:
+		return appendJSONString(buf, val.String())
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return appendJSONString(buf, fmt.Sprintf("%+v", v))
+	}
+	return append(buf, data...)
+}
+
+// NaN/Inf 不是合法的JSON数字，输出为字符串
+func appendJSONFloat(buf []byte, f float64, bits int) []byte {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return appendJSONString(buf, strconv.FormatFloat(f, 'g', -1, bits))
+	}
+	return strconv.AppendFloat(buf, f, 'g', -1, bits)
+}
+
+const hexDigits = "0123456789abcdef"
+
+// 按JSON规则转义字符串
+func appendJSONString(buf []byte, s string) []byte {
+	buf = append(buf, '"')
+	for i := 0; i < len(s); {
+		c := s[i]
+		if c < utf8.RuneSelf {
+			switch {
+			case c == '"' || c == '\\':
+				buf = append(buf, '\\', c)
+			case c == '\n':
+				buf = append(buf, '\\', 'n')
+			case c == '\r':
+				buf = append(buf, '\\', 'r')
+			case c == '\t':
+				buf = append(buf, '\\', 't')
+			case c < 0x20:
+				buf = append(buf, '\\', 'u', '0', '0', hexDigits[c>>4], hexDigits[c&0xf])
+			default:
+				buf = append(buf, c)
+			}
+			i++
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if r == utf8.RuneError && size == 1 {
+			buf = append(buf, `\ufffd`...)
+		} else {
+			buf = append(buf, s[i:i+size]...)
+		}
+		i += size
+	}
+	return append(buf, '"')
+}
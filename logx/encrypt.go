@@ -0,0 +1,235 @@
+package logx
+
+import (
+	"bufio"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// 加密文件以 encryptMagic 开头，之后每次写入(一批日志或缓冲区刷新)为一条记录：
+// 4 字节大端长度 + 1 字节密钥 ID 长度 + 密钥 ID + 12 字节随机数 + AES-GCM 密文，密钥 ID 作为附加数据参与认证
+const (
+	encryptMagic     = "LOGXENC1"
+	maxEncryptRecord = 64 << 20
+)
+
+// ErrNotEncrypted 读取的文件不是 WithEncryption 写入的加密日志
+var ErrNotEncrypted = errors.New("logx: not an encrypted log file")
+
+// KeyProvider 提供加密日志文件使用的 AES 密钥(16、24 或 32 字节)
+type KeyProvider interface {
+	// CurrentKey 返回加密新文件所用的密钥及其 ID，每次打开或切割出新文件时调用，可以借此轮换密钥
+	CurrentKey() (id string, key []byte, err error)
+	// Key 按 ID 返回密钥，用于解密旧文件
+	Key(id string) ([]byte, error)
+}
+
+// StaticKeys 固定的密钥集合，Current 为加密使用的密钥 ID，其余密钥只用于解密
+type StaticKeys struct {
+	Current string
+	Keys    map[string][]byte
+}
+
+func (k StaticKeys) CurrentKey() (string, []byte, error) {
+	key, err := k.Key(k.Current)
+	return k.Current, key, err
+}
+
+func (k StaticKeys) Key(id string) ([]byte, error) {
+	key, ok := k.Keys[id]
+	if !ok {
+		return nil, fmt.Errorf("logx: unknown encryption key %q", id)
+	}
+	return key, nil
+}
+
+// WithEncryption 使用 AES-GCM 加密写入日志文件的内容，控制台和输出目标不受影响。
+// 每个文件打开时向 keys 获取当前密钥，切割后的新文件可以使用新密钥；用 NewDecryptReader 或 OpenEncrypted 读取
+func WithEncryption(keys KeyProvider) Option {
+	return func(l *Logger) {
+		l.encryption = keys
+	}
+}
+
+type encryptWriter struct {
+	file  *os.File
+	aead  cipher.AEAD
+	id    string
+	err   error // 获取密钥失败时每次写入都返回该错误，不会写入明文
+	magic bool  // 是否已写入文件头
+	buf   []byte
+}
+
+func newEncryptWriter(file *os.File, keys KeyProvider) *encryptWriter {
+	w := &encryptWriter{file: file}
+	id, key, err := keys.CurrentKey()
+	if err == nil && len(id) > 255 {
+		err = fmt.Errorf("logx: encryption key id %q is too long", id)
+	}
+	if err == nil {
+		w.aead, err = newGCM(key)
+	}
+	w.id, w.err = id, err
+	if stat, err := file.Stat(); err == nil && stat.Size() > 0 {
+		w.magic = true
+	}
+	return w
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// Write 把 p 加密为一条记录并一次写入文件
+func (w *encryptWriter) Write(p []byte) (int, error) {
+	if w.err != nil {
+		return 0, w.err
+	}
+	buf := w.buf[:0]
+	if !w.magic {
+		buf = append(buf, encryptMagic...)
+	}
+	buf, err := appendEncrypted(buf, w.aead, w.id, p)
+	if err != nil {
+		return 0, err
+	}
+	w.buf = buf
+	if _, err := w.file.Write(buf); err != nil {
+		return 0, err
+	}
+	w.magic = true
+	return len(p), nil
+}
+
+// 把 p 加密为一条记录追加到 buf 后
+func appendEncrypted(buf []byte, aead cipher.AEAD, id string, p []byte) ([]byte, error) {
+	start := len(buf)
+	buf = append(buf, 0, 0, 0, 0, byte(len(id)))
+	buf = append(buf, id...)
+	nonce := len(buf)
+	buf = append(buf, make([]byte, aead.NonceSize())...)
+	if _, err := rand.Read(buf[nonce:]); err != nil {
+		return buf[:start], err
+	}
+	buf = aead.Seal(buf, buf[nonce:], p, []byte(id))
+	binary.BigEndian.PutUint32(buf[start:], uint32(len(buf)-start-4))
+	return buf, nil
+}
+
+// NewDecryptReader 返回解密 WithEncryption 写入的日志内容的 Reader，读出的是原始的日志行
+func NewDecryptReader(r io.Reader, keys KeyProvider) io.Reader {
+	return &decryptReader{r: bufio.NewReader(r), keys: keys, aeads: make(map[string]cipher.AEAD)}
+}
+
+// OpenEncrypted 打开加密的日志文件，可以用于切割后的旧文件，例如 bufio.Scanner 逐行读取
+func OpenEncrypted(path string, keys KeyProvider) (io.ReadCloser, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{NewDecryptReader(file, keys), file}, nil
+}
+
+type decryptReader struct {
+	r       *bufio.Reader
+	keys    KeyProvider
+	aeads   map[string]cipher.AEAD
+	started bool
+	plain   []byte
+	record  []byte
+}
+
+func (d *decryptReader) Read(p []byte) (int, error) {
+	for len(d.plain) == 0 {
+		if err := d.next(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, d.plain)
+	d.plain = d.plain[n:]
+	return n, nil
+}
+
+func (d *decryptReader) next() error {
+	// 多个进程同时创建文件时文件头可能重复出现
+	for {
+		head, err := d.r.Peek(len(encryptMagic))
+		if string(head) != encryptMagic {
+			if !d.started {
+				if err == io.EOF && len(head) == 0 {
+					return io.EOF
+				}
+				return ErrNotEncrypted
+			}
+			break
+		}
+		d.r.Discard(len(encryptMagic))
+		d.started = true
+	}
+	var size [4]byte
+	if _, err := io.ReadFull(d.r, size[:]); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return fmt.Errorf("logx: truncated encrypted record")
+		}
+		return err
+	}
+	n := binary.BigEndian.Uint32(size[:])
+	if n == 0 || n > maxEncryptRecord {
+		return fmt.Errorf("logx: invalid encrypted record length %d", n)
+	}
+	if cap(d.record) < int(n) {
+		d.record = make([]byte, n)
+	}
+	record := d.record[:n]
+	if _, err := io.ReadFull(d.r, record); err != nil {
+		return fmt.Errorf("logx: truncated encrypted record")
+	}
+	idLen := int(record[0])
+	if len(record) < 1+idLen {
+		return fmt.Errorf("logx: invalid encrypted record")
+	}
+	id := string(record[1 : 1+idLen])
+	aead, err := d.aead(id)
+	if err != nil {
+		return err
+	}
+	rest := record[1+idLen:]
+	if len(rest) < aead.NonceSize() {
+		return fmt.Errorf("logx: invalid encrypted record")
+	}
+	plain, err := aead.Open(d.plain[:0], rest[:aead.NonceSize()], rest[aead.NonceSize():], []byte(id))
+	if err != nil {
+		return fmt.Errorf("logx: decrypt record with key %q: %w", id, err)
+	}
+	d.plain = plain
+	return nil
+}
+
+func (d *decryptReader) aead(id string) (cipher.AEAD, error) {
+	if aead, ok := d.aeads[id]; ok {
+		return aead, nil
+	}
+	key, err := d.keys.Key(id)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	d.aeads[id] = aead
+	return aead, nil
+}
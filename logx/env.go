@@ -0,0 +1,88 @@
+package logx
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// 环境变量名称
+const (
+	EnvLevel     = "LOGX_LEVEL"       // debug|info|warn|error，默认 info
+	EnvFormat    = "LOGX_FORMAT"      // text|json，默认 text
+	EnvFile      = "LOGX_FILE"        // 日志文件路径，默认 logs/app.log
+	EnvApp       = "LOGX_APP"         // 应用名称，未设置 LOGX_FILE 时日志写入 DefaultLogPath(应用名称)
+	EnvMaxSizeMB = "LOGX_MAX_SIZE_MB" // 单个日志文件最大大小(MB)，默认 10
+	EnvConsole   = "LOGX_CONSOLE"     // 是否输出到控制台，默认 true
+)
+
+// NewFromEnv 完全通过环境变量创建日志记录器，opts 会覆盖环境变量中的配置
+// 与 NewLogger 一样，需要调用 StartWorker 启动写入协程
+func NewFromEnv(opts ...Option) (*Logger, error) {
+	level := INFO
+	if v, ok := lookupEnv(EnvLevel); ok {
+		parsed, err := ParseLevel(v)
+		if err != nil {
+			return nil, fmt.Errorf("logx: invalid %s: %w", EnvLevel, err)
+		}
+		level = parsed
+	}
+
+	format := FormatText
+	if v, ok := lookupEnv(EnvFormat); ok {
+		parsed, err := ParseFormat(v)
+		if err != nil {
+			return nil, fmt.Errorf("logx: invalid %s: %w", EnvFormat, err)
+		}
+		format = parsed
+	}
+
+	file := filepath.Join("logs", "app.log")
+	if v, ok := lookupEnv(EnvFile); ok {
+		file = v
+	} else if v, ok := lookupEnv(EnvApp); ok {
+		file = DefaultLogPath(v)
+	}
+
+	maxSizeMB := int64(10)
+	if v, ok := lookupEnv(EnvMaxSizeMB); ok {
+		parsed, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || parsed <= 0 {
+			return nil, fmt.Errorf("logx: invalid %s %q", EnvMaxSizeMB, v)
+		}
+		maxSizeMB = parsed
+	}
+
+	console := true
+	if v, ok := lookupEnv(EnvConsole); ok {
+		parsed, err := parseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("logx: invalid %s %q", EnvConsole, v)
+		}
+		console = parsed
+	}
+
+	opts = append([]Option{WithFormat(format)}, opts...)
+	return NewLogger(file, level, maxSizeMB, console, opts...)
+}
+
+// 读取非空的环境变量
+func lookupEnv(key string) (string, bool) {
+	v, ok := os.LookupEnv(key)
+	if !ok || strings.TrimSpace(v) == "" {
+		return "", false
+	}
+	return strings.TrimSpace(v), true
+}
+
+func parseBool(s string) (bool, error) {
+	switch strings.ToLower(s) {
+	case "on", "yes":
+		return true, nil
+	case "off", "no":
+		return false, nil
+	}
+	return strconv.ParseBool(s)
+}
@@ -0,0 +1,47 @@
+package logx
+
+import (
+	"fmt"
+	"os"
+)
+
+// 内部错误的来源
+const (
+	OpWrite  = "write"  // 写入日志文件
+	OpRotate = "rotate" // 切割日志文件
+	OpSink   = "sink"   // 写入或关闭输出目标
+	OpBlob   = "blob"   // 写入附件存储
+	OpState  = "state"  // 读写运行指标的检查点文件，见 WithStatsCheckpoint
+)
+
+// WriteError 日志记录器在后台写入时发生的错误，可以用 errors.Is(err, syscall.ENOSPC) 判断具体原因
+type WriteError struct {
+	Op   string // OpWrite、OpRotate 等
+	Name string // 文件路径或输出目标名称
+	Err  error
+}
+
+func (e *WriteError) Error() string {
+	return fmt.Sprintf("logx: %s %s: %v", e.Op, e.Name, e.Err)
+}
+
+func (e *WriteError) Unwrap() error { return e.Err }
+
+// WithErrorHandler 设置后台写入失败时的回调(参数为 *WriteError)，用于计数、告警或切换到其他输出目标。
+// 回调在写入协程中同步执行(异步输出目标的错误在其发送协程中执行)，不要在其中调用同一个日志记录器。默认输出到 stderr
+func WithErrorHandler(handler func(error)) Option {
+	return func(l *Logger) {
+		l.errorHandler = handler
+	}
+}
+
+func (l *Logger) handleError(op, name string, err error) {
+	l.metrics.writeErrors.Add(1)
+	l.noteDegradation(op + "_error")
+	werr := &WriteError{Op: op, Name: name, Err: err}
+	if l.errorHandler != nil {
+		l.errorHandler(werr)
+		return
+	}
+	fmt.Fprintln(os.Stderr, werr)
+}
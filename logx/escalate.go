@@ -0,0 +1,147 @@
+package logx
+
+import (
+	"sync"
+	"time"
+)
+
+// 临时提升的日志等级
+type escalation struct {
+	module string
+	level  LogLevel
+	prev   LogLevel // 全局提升结束后恢复的等级
+	stop   chan struct{}
+}
+
+// Escalate 在 d 时间内把最低等级临时降到 level(例如排查线上问题时打开 DEBUG)，到期后自动恢复，
+// 开始和结束时各写一条 WARN 日志。返回的函数可以提前结束；再次调用 Escalate 会替换之前的提升。
+// 提升期间调用 SetLevel 修改的是结束后恢复的等级
+func (l *Logger) Escalate(level LogLevel, d time.Duration) func() {
+	return l.escalate("", level, d)
+}
+
+// EscalateModule 与 Escalate 相同，但只对模块字段(见 Module)为 module 的日志生效
+func (l *Logger) EscalateModule(module string, level LogLevel, d time.Duration) func() {
+	return l.escalate(module, level, d)
+}
+
+func (l *Logger) escalate(module string, level LogLevel, d time.Duration) func() {
+	e := &escalation{module: module, level: level, stop: make(chan struct{})}
+	l.mu.Lock()
+	var replaced *escalation
+	if module == "" {
+		replaced = l.escalation
+		e.prev = l.minLevel()
+		if replaced != nil {
+			e.prev = replaced.prev
+		}
+		l.escalation = e
+		l.level.Store(int64(level))
+	} else {
+		modules := make(map[string]*escalation, len(l.moduleEscalations)+1)
+		for k, v := range l.moduleEscalations {
+			modules[k] = v
+		}
+		replaced = modules[module]
+		modules[module] = e
+		l.moduleEscalations = modules
+		l.storeModuleLevels()
+	}
+	l.mu.Unlock()
+	if replaced != nil {
+		close(replaced.stop)
+	}
+
+	fields := []Field{{Key: "level", Value: levelString(level)}, {Key: "duration", Value: d}}
+	if module != "" {
+		fields = append(fields, Module(module))
+	}
+	l.enqueue(Entry{Level: WARN, Time: l.now(), Message: "level escalation started", Fields: fields})
+
+	var once sync.Once
+	end := func(reason string) {
+		once.Do(func() { l.endEscalation(e, reason) })
+	}
+	go func() {
+		timer := time.NewTimer(d)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+			end("expired")
+		case <-e.stop:
+		case <-l.done:
+		}
+	}()
+	return func() {
+		end("cancelled")
+	}
+}
+
+func (l *Logger) endEscalation(e *escalation, reason string) {
+	l.mu.Lock()
+	restored := l.minLevel()
+	if e.module == "" {
+		if l.escalation != e {
+			l.mu.Unlock()
+			return
+		}
+		l.escalation = nil
+		l.level.Store(int64(e.prev))
+		restored = e.prev
+	} else {
+		if l.moduleEscalations[e.module] != e {
+			l.mu.Unlock()
+			return
+		}
+		modules := make(map[string]*escalation, len(l.moduleEscalations))
+		for k, v := range l.moduleEscalations {
+			if k != e.module {
+				modules[k] = v
+			}
+		}
+		l.moduleEscalations = modules
+		l.storeModuleLevels()
+	}
+	l.mu.Unlock()
+	close(e.stop)
+
+	fields := []Field{{Key: "level", Value: levelString(restored)}, {Key: "reason", Value: reason}}
+	if e.module != "" {
+		fields = append(fields, Module(e.module))
+	}
+	l.enqueue(Entry{Level: WARN, Time: l.now(), Message: "level escalation ended", Fields: fields})
+}
+
+// 发布模块等级供 log 无锁读取，调用方需持有 l.mu
+func (l *Logger) storeModuleLevels() {
+	if len(l.moduleEscalations) == 0 {
+		l.moduleLevels.Store(nil)
+		return
+	}
+	levels := make(map[string]LogLevel, len(l.moduleEscalations))
+	for k, v := range l.moduleEscalations {
+		levels[k] = v.level
+	}
+	l.moduleLevels.Store(&levels)
+}
+
+// 日志所属模块是否临时提升到了 level
+func (l *Logger) moduleEscalated(level LogLevel, fields []Field) bool {
+	levels := l.moduleLevels.Load()
+	if levels == nil {
+		return false
+	}
+	for _, group := range [][]Field{fields, l.staticFields} {
+		for _, f := range group {
+			if f.Key != ModuleKey {
+				continue
+			}
+			if name, ok := f.Value.(string); ok {
+				if min, ok := (*levels)[name]; ok {
+					return level >= min
+				}
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,71 @@
+package logx
+
+import (
+	"errors"
+	"sync"
+)
+
+// ErrEventLogUnsupported 非 Windows 平台创建事件日志输出目标时返回该错误
+var ErrEventLogUnsupported = errors.New("logx: windows event log is only supported on windows")
+
+// Windows 事件类型
+const (
+	eventTypeError       = 0x0001
+	eventTypeWarning     = 0x0002
+	eventTypeInformation = 0x0004
+)
+
+// EventLogConfig Windows 事件日志输出目标配置
+type EventLogConfig struct {
+	// Source 事件来源名称。没有在注册表中登记消息文件时事件查看器会提示找不到描述，但消息内容仍完整可见，
+	// 登记方式见 New-EventLog -LogName Application -Source <Source>
+	Source  string
+	EventID uint32 // 所有事件使用的事件 ID，默认1
+}
+
+// EventLogSink 写入 Windows 事件日志(Application)的输出目标：ERROR 为错误事件，WARN 为警告事件，
+// 其他等级为信息事件，事件内容为不含时间前缀的文本格式
+type EventLogSink struct {
+	cfg EventLogConfig
+	mu  sync.Mutex
+	log eventLogHandle
+	buf []byte
+}
+
+// NewEventLogSink 注册事件来源
+func NewEventLogSink(cfg EventLogConfig) (*EventLogSink, error) {
+	if cfg.Source == "" {
+		return nil, errors.New("logx: event log source is required")
+	}
+	if cfg.EventID == 0 {
+		cfg.EventID = 1
+	}
+	h, err := openEventLog(cfg.Source)
+	if err != nil {
+		return nil, err
+	}
+	return &EventLogSink{cfg: cfg, log: h}, nil
+}
+
+func eventType(level LogLevel) uint16 {
+	switch {
+	case level >= ERROR:
+		return eventTypeError
+	case level == WARN:
+		return eventTypeWarning
+	}
+	return eventTypeInformation
+}
+
+func (s *EventLogSink) Write(entry *Entry, line []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.buf = appendText(s.buf[:0], *entry)
+	return s.log.report(eventType(entry.Level), s.cfg.EventID, string(s.buf))
+}
+
+func (s *EventLogSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.log.close()
+}
@@ -0,0 +1,13 @@
+//go:build !windows
+
+package logx
+
+type eventLogHandle struct{}
+
+func openEventLog(source string) (eventLogHandle, error) {
+	return eventLogHandle{}, ErrEventLogUnsupported
+}
+
+func (eventLogHandle) report(typ uint16, id uint32, msg string) error { return ErrEventLogUnsupported }
+
+func (eventLogHandle) close() error { return nil }
@@ -0,0 +1,54 @@
+//go:build windows
+
+package logx
+
+import (
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+var (
+	advapi32                  = syscall.NewLazyDLL("advapi32.dll")
+	procRegisterEventSource   = advapi32.NewProc("RegisterEventSourceW")
+	procDeregisterEventSource = advapi32.NewProc("DeregisterEventSource")
+	procReportEvent           = advapi32.NewProc("ReportEventW")
+)
+
+type eventLogHandle struct {
+	h uintptr
+}
+
+func openEventLog(source string) (eventLogHandle, error) {
+	name, err := syscall.UTF16PtrFromString(source)
+	if err != nil {
+		return eventLogHandle{}, err
+	}
+	h, _, err := procRegisterEventSource.Call(0, uintptr(unsafe.Pointer(name)))
+	if h == 0 {
+		return eventLogHandle{}, err
+	}
+	return eventLogHandle{h: h}, nil
+}
+
+func (e eventLogHandle) report(typ uint16, id uint32, msg string) error {
+	// 消息中不能有 NUL
+	text, err := syscall.UTF16PtrFromString(strings.ReplaceAll(msg, "\x00", ""))
+	if err != nil {
+		return err
+	}
+	strs := [1]*uint16{text}
+	ok, _, err := procReportEvent.Call(e.h, uintptr(typ), 0, uintptr(id), 0, 1, 0,
+		uintptr(unsafe.Pointer(&strs[0])), 0)
+	if ok == 0 {
+		return err
+	}
+	return nil
+}
+
+func (e eventLogHandle) close() error {
+	if ok, _, err := procDeregisterEventSource.Call(e.h); ok == 0 {
+		return err
+	}
+	return nil
+}
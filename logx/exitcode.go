@@ -0,0 +1,76 @@
+package logx
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// ExitPolicy 记录运行期间日志的最高等级，给出建议的进程退出码，
+// 方便基于 logx 的命令行工具在记录过 ERROR 时统一以非零状态退出
+type ExitPolicy struct {
+	max   atomic.Int32 // 最高等级+1，0 表示还没有记录
+	mu    sync.Mutex
+	codes map[LogLevel]int
+}
+
+// NewExitPolicy 默认 ERROR 对应退出码 1，其余为 0
+func NewExitPolicy() *ExitPolicy {
+	return &ExitPolicy{codes: map[LogLevel]int{ERROR: 1}}
+}
+
+// SetCode 设置最高等级为 level 时的退出码
+func (p *ExitPolicy) SetCode(level LogLevel, code int) *ExitPolicy {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.codes[level] = code
+	return p
+}
+
+// Observe 记录一次日志等级，通常由 Logger 自动调用
+func (p *ExitPolicy) Observe(level LogLevel) {
+	v := int32(level) + 1
+	for {
+		cur := p.max.Load()
+		if cur >= v || p.max.CompareAndSwap(cur, v) {
+			return
+		}
+	}
+}
+
+// MaxLevel 返回已记录的最高等级
+func (p *ExitPolicy) MaxLevel() (LogLevel, bool) {
+	v := p.max.Load()
+	if v == 0 {
+		return DEBUG, false
+	}
+	return LogLevel(v - 1), true
+}
+
+// SuggestedExitCode 按已记录的最高等级返回退出码，
+// 该等级没有配置时向下查找最近的已配置等级
+func (p *ExitPolicy) SuggestedExitCode() int {
+	max, ok := p.MaxLevel()
+	if !ok {
+		return 0
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for level := max; level >= DEBUG; level-- {
+		if code, ok := p.codes[level]; ok {
+			return code
+		}
+	}
+	return 0
+}
+
+// Reset 清除已记录的等级
+func (p *ExitPolicy) Reset() {
+	p.max.Store(0)
+}
+
+// WithExitPolicy 让日志记录器把每条通过等级过滤的日志报告给 p
+func WithExitPolicy(p *ExitPolicy) Option {
+	return func(l *Logger) {
+		l.exitPolicy = p
+	}
+}
@@ -0,0 +1,42 @@
+package logx
+
+import "time"
+
+// ExpiresKey 过期时间字段的键，值为 RFC3339 格式的时间
+const ExpiresKey = "expires_at"
+
+// ExpiresAt 标记日志在 t 之后不再有意义，例如告警。
+// 排队的输出目标(SpoolSink、AsyncSink)发送前会丢弃已经过期的日志，避免故障恢复后补发几小时前的告警；
+// 日志文件仍会完整记录
+func ExpiresAt(t time.Time) Field {
+	return Field{Key: ExpiresKey, Value: t.UTC().Format(time.RFC3339Nano)}
+}
+
+// ExpiresIn 标记日志在 d 之后过期
+func ExpiresIn(d time.Duration) Field {
+	return ExpiresAt(time.Now().Add(d))
+}
+
+// ExpiresAt 返回日志的过期时间，没有 ExpiresKey 字段时 ok 为 false
+func (e *Entry) ExpiresAt() (t time.Time, ok bool) {
+	for _, f := range e.Fields {
+		if f.Key != ExpiresKey {
+			continue
+		}
+		switch v := f.Value.(type) {
+		case time.Time:
+			return v, true
+		case string:
+			if t, err := time.Parse(time.RFC3339Nano, v); err == nil {
+				return t, true
+			}
+		}
+	}
+	return time.Time{}, false
+}
+
+// Expired 日志在 now 时是否已经过期
+func (e *Entry) Expired(now time.Time) bool {
+	t, ok := e.ExpiresAt()
+	return ok && !now.Before(t)
+}
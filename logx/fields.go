@@ -0,0 +1,155 @@
+package logx
+
+import (
+	"reflect"
+	"time"
+)
+
+// Field 结构化字段
+type Field struct {
+	Key   string
+	Value interface{}
+	Hint  FieldHint // 给输出目标的索引提示，不影响编码结果
+}
+
+// FieldHint 告诉 Loki、Elasticsearch 等输出目标字段应该作为标签/索引字段还是放在消息体中
+type FieldHint uint8
+
+const (
+	HintDefault FieldHint = iota // 由输出目标自行决定
+	HintIndexed                  // 作为标签或索引字段，应保持低基数
+	HintPayload                  // 只放在消息体中，不建立索引
+)
+
+// Indexed 创建一个带索引提示的字段
+func Indexed(key string, value interface{}) Field {
+	return Field{Key: key, Value: value, Hint: HintIndexed}
+}
+
+// Payload 创建一个不建立索引的字段
+func Payload(key string, value interface{}) Field {
+	return Field{Key: key, Value: value, Hint: HintPayload}
+}
+
+// WithHint 返回设置了索引提示的字段副本
+func (f Field) WithHint(hint FieldHint) Field {
+	f.Hint = hint
+	return f
+}
+
+// WithIndexedKeys 没有设置提示的字段中，键在 keys 中的会被标记为 HintIndexed
+func WithIndexedKeys(keys ...string) Option {
+	return func(l *Logger) {
+		if l.indexedKeys == nil {
+			l.indexedKeys = make(map[string]bool, len(keys))
+		}
+		for _, k := range keys {
+			l.indexedKeys[k] = true
+		}
+	}
+}
+
+// IndexedFields 返回标记为 HintIndexed 的字段
+func (e *Entry) IndexedFields() []Field {
+	return e.fieldsWithHint(HintIndexed)
+}
+
+// PayloadFields 返回没有标记为 HintIndexed 的字段
+func (e *Entry) PayloadFields() []Field {
+	var out []Field
+	for _, f := range e.Fields {
+		if f.Hint != HintIndexed {
+			out = append(out, f)
+		}
+	}
+	return out
+}
+
+func (e *Entry) fieldsWithHint(hint FieldHint) []Field {
+	var out []Field
+	for _, f := range e.Fields {
+		if f.Hint == hint {
+			out = append(out, f)
+		}
+	}
+	return out
+}
+
+// 按 WithIndexedKeys 给字段加上提示
+func (l *Logger) applyIndexHints(entry Entry) Entry {
+	var copied bool
+	for i, f := range entry.Fields {
+		if f.Hint != HintDefault || !l.indexedKeys[f.Key] {
+			continue
+		}
+		if !copied {
+			entry.Fields = append([]Field(nil), entry.Fields...)
+			copied = true
+		}
+		entry.Fields[i].Hint = HintIndexed
+	}
+	return entry
+}
+
+func Any(key string, value interface{}) Field { return Field{Key: key, Value: value} }
+
+func String(key, value string) Field { return Field{Key: key, Value: value} }
+
+func Int(key string, value int) Field { return Field{Key: key, Value: value} }
+
+func Int64(key string, value int64) Field { return Field{Key: key, Value: value} }
+
+func Bool(key string, value bool) Field { return Field{Key: key, Value: value} }
+
+func Duration(key string, value time.Duration) Field { return Field{Key: key, Value: value} }
+
+// Err 以 error 为键记录错误，err 为 nil 时记录 null
+func Err(err error) Field {
+	if err == nil {
+		return Field{Key: "error"}
+	}
+	return Field{Key: "error", Value: err}
+}
+
+// TagsKey 标签字段的键
+const TagsKey = "tags"
+
+// Tags 给日志打标签，例如 Tags("security", "audit")，输出目标可以据此路由或优先发送
+func Tags(tags ...string) Field {
+	return Field{Key: TagsKey, Value: tags, Hint: HintIndexed}
+}
+
+// HasTag 日志是否带有标签 tag
+func (e *Entry) HasTag(tag string) bool {
+	for _, f := range e.Fields {
+		if f.Key != TagsKey {
+			continue
+		}
+		switch v := f.Value.(type) {
+		case []string:
+			for _, t := range v {
+				if t == tag {
+					return true
+				}
+			}
+		case string:
+			if v == tag {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// 判断两组字段是否相同
+func sameFields(a, b []Field) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].Key != b[i].Key || !reflect.DeepEqual(a[i].Value, b[i].Value) {
+			return false
+		}
+	}
+	return true
+}
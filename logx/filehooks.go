@@ -0,0 +1,53 @@
+package logx
+
+// FileHook 返回写在日志文件开头或结尾的内容(通常以换行结尾)，path 为日志文件路径，返回 nil 表示不写
+type FileHook func(path string) []byte
+
+// WithFileHeader 每个新建的日志文件(包括切割后的)开头写入 header 返回的内容，例如结构版本或应用信息。
+// 文件头计入文件大小；Query、Replay 等读取时会跳过无法识别的行，审计模式和二进制格式不写文件头
+func WithFileHeader(header FileHook) Option {
+	return func(l *Logger) {
+		l.fileHeader = header
+	}
+}
+
+// WithFileFooter 每个日志文件在切割或 Close 关闭前写入 footer 返回的内容，例如汇总信息或结束标记
+func WithFileFooter(footer FileHook) Option {
+	return func(l *Logger) {
+		l.fileFooter = footer
+	}
+}
+
+// 调用方需持有 l.mu
+func (l *Logger) writeHeader() {
+	if l.audit != nil || l.format == FormatBinary {
+		return
+	}
+	var data []byte
+	if l.versionHeader {
+		data = l.encodeVersionHeader()
+	}
+	if l.fileHeader != nil {
+		data = append(data, l.fileHeader(l.filePath)...)
+	}
+	if len(data) == 0 {
+		return
+	}
+	if _, err := l.out.Write(data); err != nil {
+		l.handleError(OpWrite, l.filePath, err)
+		return
+	}
+	l.headerSize = int64(len(data))
+}
+
+// 调用方需持有 l.mu
+func (l *Logger) writeFooter() {
+	if l.fileFooter == nil || l.audit != nil || l.format == FormatBinary {
+		return
+	}
+	if data := l.fileFooter(l.filePath); len(data) > 0 {
+		if _, err := l.out.Write(data); err != nil {
+			l.handleError(OpWrite, l.filePath, err)
+		}
+	}
+}
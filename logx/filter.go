@@ -0,0 +1,343 @@
+package logx
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ModuleKey 模块字段的键，过滤规则中的 module 条件匹配该字段
+const ModuleKey = "module"
+
+// Module 标记日志来自哪个模块，例如 Module("http")，可以放在 WithStaticFields 或单条日志中
+func Module(name string) Field { return Field{Key: ModuleKey, Value: name} }
+
+// Cond 过滤规则中的一个条件
+type Cond struct {
+	match  func(entry *Entry) bool
+	min    LogLevel // 条件只可能匹配不低于 min 的等级
+	hasMin bool
+}
+
+// CondFunc 自定义条件
+func CondFunc(match func(entry *Entry) bool) Cond {
+	return Cond{match: match}
+}
+
+// LevelBelow 等级低于 level
+func LevelBelow(level LogLevel) Cond {
+	return Cond{match: func(e *Entry) bool { return e.Level < level }}
+}
+
+// LevelAtLeast 等级不低于 level
+func LevelAtLeast(level LogLevel) Cond {
+	return Cond{match: func(e *Entry) bool { return e.Level >= level }, min: level, hasMin: true}
+}
+
+// FieldEquals 存在键为 key 的字段且值格式化后等于 value
+func FieldEquals(key, value string) Cond {
+	return Cond{match: func(e *Entry) bool {
+		v, ok := fieldValue(e, key)
+		return ok && fmt.Sprint(v) == value
+	}}
+}
+
+// ModuleIs 模块字段(ModuleKey)等于 name
+func ModuleIs(name string) Cond {
+	return FieldEquals(ModuleKey, name)
+}
+
+// TagIs 带有标签 tag，见 Tags
+func TagIs(tag string) Cond {
+	return Cond{match: func(e *Entry) bool { return e.HasTag(tag) }}
+}
+
+// MessageMatches 消息匹配正则
+func MessageMatches(re *regexp.Regexp) Cond {
+	return Cond{match: func(e *Entry) bool { return re.MatchString(e.Message) }}
+}
+
+func fieldValue(e *Entry, key string) (interface{}, bool) {
+	for _, f := range e.Fields {
+		if f.Key == key {
+			return f.Value, true
+		}
+	}
+	return nil, false
+}
+
+// FilterRule 过滤规则，所有条件同时满足时生效
+type FilterRule struct {
+	allow bool
+	conds []Cond
+}
+
+// Allow 满足条件的日志总是输出，不受 NewLogger 中最低等级的限制
+func Allow(conds ...Cond) FilterRule {
+	return FilterRule{allow: true, conds: conds}
+}
+
+// Deny 满足条件的日志不输出
+func Deny(conds ...Cond) FilterRule {
+	return FilterRule{conds: conds}
+}
+
+func (r FilterRule) matches(e *Entry) bool {
+	return matchAll(r.conds, e)
+}
+
+func matchAll(conds []Cond, e *Entry) bool {
+	for _, c := range conds {
+		if !c.match(e) {
+			return false
+		}
+	}
+	return true
+}
+
+// 规则可能放行的最低等级
+func (r FilterRule) floor() LogLevel {
+	floor := LogLevel(math.MinInt)
+	for _, c := range r.conds {
+		if c.hasMin && c.min > floor {
+			floor = c.min
+		}
+	}
+	return floor
+}
+
+type filter struct {
+	rules []FilterRule
+	// 低于最低等级但可能被 Allow 放行的日志要送到写入协程判断
+	floor LogLevel
+}
+
+// WithFilter 设置过滤规则，在写入协程中按顺序匹配，第一条满足的规则决定是否输出，
+// 都不满足时按最低等级过滤，例如：
+//
+//	WithFilter(Deny(LevelBelow(WARN), ModuleIs("http")), Allow(MessageMatches(regexp.MustCompile("slow query"))))
+//
+// 条件看到的是已添加 WithStaticFields、已脱敏的日志。存在 Allow 规则时，
+// 低于最低等级的日志也会进入队列，由写入协程丢弃
+func WithFilter(rules ...FilterRule) Option {
+	return func(l *Logger) {
+		if len(rules) == 0 {
+			l.filter = nil
+			return
+		}
+		l.filter = newFilter(rules)
+	}
+}
+
+func newFilter(rules []FilterRule) *filter {
+	f := &filter{rules: rules, floor: LogLevel(math.MaxInt)}
+	for _, r := range rules {
+		if r.allow && r.floor() < f.floor {
+			f.floor = r.floor()
+		}
+	}
+	return f
+}
+
+// 返回 false 表示该条日志被过滤
+func (f *filter) keep(e *Entry) bool {
+	if i := f.match(e); i >= 0 {
+		return f.rules[i].allow
+	}
+	return !e.belowLevel
+}
+
+// 第一条满足的规则的下标，都不满足时返回 -1
+func (f *filter) match(e *Entry) int {
+	for i, r := range f.rules {
+		if r.matches(e) {
+			return i
+		}
+	}
+	return -1
+}
+
+// 低于最低等级的日志是否可能被 Allow 规则放行
+func (l *Logger) filterMayAllow(level LogLevel) bool {
+	return l.filter != nil && level >= l.filter.floor
+}
+
+// ParseFilter 解析文本形式的过滤规则，可以用在配置文件中，例如：
+//
+//	Deny(level<INFO, module="http"), Allow(messageRegex="slow query")
+//
+// 条件支持 level(<、<=、>、>=、=、!=)、messageRegex(=)、tag(=、!=)、module 和任意字段名(=、!=)，
+// 值可以带双引号
+func ParseFilter(s string) ([]FilterRule, error) {
+	p := &filterParser{s: s}
+	var rules []FilterRule
+	for {
+		p.skipSpace()
+		if p.done() {
+			return rules, nil
+		}
+		if len(rules) > 0 && !p.consume(",") {
+			return nil, p.errorf("expected ','")
+		}
+		rule, err := p.rule()
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+}
+
+type filterParser struct {
+	s   string
+	pos int
+}
+
+func (p *filterParser) done() bool { return p.pos >= len(p.s) }
+
+func (p *filterParser) skipSpace() {
+	for !p.done() && (p.s[p.pos] == ' ' || p.s[p.pos] == '\t' || p.s[p.pos] == '\n') {
+		p.pos++
+	}
+}
+
+func (p *filterParser) consume(tok string) bool {
+	p.skipSpace()
+	if strings.HasPrefix(p.s[p.pos:], tok) {
+		p.pos += len(tok)
+		return true
+	}
+	return false
+}
+
+func (p *filterParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("logx: filter at offset %d: %s", p.pos, fmt.Sprintf(format, args...))
+}
+
+func (p *filterParser) ident() string {
+	p.skipSpace()
+	start := p.pos
+	for !p.done() {
+		c := p.s[p.pos]
+		if c != '_' && c != '.' && c != '-' && !('a' <= c && c <= 'z') && !('A' <= c && c <= 'Z') && !('0' <= c && c <= '9') {
+			break
+		}
+		p.pos++
+	}
+	return p.s[start:p.pos]
+}
+
+func (p *filterParser) rule() (FilterRule, error) {
+	var rule FilterRule
+	switch name := p.ident(); strings.ToLower(name) {
+	case "allow":
+		rule.allow = true
+	case "deny":
+	default:
+		return rule, p.errorf("expected Allow or Deny, got %q", name)
+	}
+	if !p.consume("(") {
+		return rule, p.errorf("expected '('")
+	}
+	for !p.consume(")") {
+		if len(rule.conds) > 0 && !p.consume(",") {
+			return rule, p.errorf("expected ',' or ')'")
+		}
+		cond, err := p.cond()
+		if err != nil {
+			return rule, err
+		}
+		rule.conds = append(rule.conds, cond)
+	}
+	return rule, nil
+}
+
+func (p *filterParser) cond() (Cond, error) {
+	key := p.ident()
+	if key == "" {
+		return Cond{}, p.errorf("expected condition")
+	}
+	var op string
+	for _, candidate := range []string{"<=", ">=", "!=", "<", ">", "="} {
+		if p.consume(candidate) {
+			op = candidate
+			break
+		}
+	}
+	if op == "" {
+		return Cond{}, p.errorf("expected operator after %q", key)
+	}
+	value, err := p.value()
+	if err != nil {
+		return Cond{}, err
+	}
+
+	switch key {
+	case "level":
+		level, err := ParseLevel(value)
+		if err != nil {
+			return Cond{}, err
+		}
+		switch op {
+		case "<":
+			return LevelBelow(level), nil
+		case "<=":
+			return LevelBelow(level + 1), nil
+		case ">":
+			return LevelAtLeast(level + 1), nil
+		case ">=":
+			return LevelAtLeast(level), nil
+		case "=":
+			return Cond{match: func(e *Entry) bool { return e.Level == level }, min: level, hasMin: true}, nil
+		}
+		return CondFunc(func(e *Entry) bool { return e.Level != level }), nil
+	case "messageRegex", "message_regex":
+		if op != "=" {
+			return Cond{}, p.errorf("messageRegex only supports '='")
+		}
+		re, err := regexp.Compile(value)
+		if err != nil {
+			return Cond{}, err
+		}
+		return MessageMatches(re), nil
+	case "tag":
+		tag := TagIs(value)
+		switch op {
+		case "=":
+			return tag, nil
+		case "!=":
+			return CondFunc(func(e *Entry) bool { return !tag.match(e) }), nil
+		}
+		return Cond{}, p.errorf("tag only supports '=' and '!='")
+	}
+	switch op {
+	case "=":
+		return FieldEquals(key, value), nil
+	case "!=":
+		eq := FieldEquals(key, value)
+		return CondFunc(func(e *Entry) bool { return !eq.match(e) }), nil
+	}
+	return Cond{}, p.errorf("field %q only supports '=' and '!='", key)
+}
+
+func (p *filterParser) value() (string, error) {
+	p.skipSpace()
+	if !p.done() && p.s[p.pos] == '"' {
+		quoted, err := strconv.QuotedPrefix(p.s[p.pos:])
+		if err != nil {
+			return "", p.errorf("unterminated string")
+		}
+		p.pos += len(quoted)
+		return strconv.Unquote(quoted)
+	}
+	start := p.pos
+	for !p.done() && !strings.ContainsRune(",);\n", rune(p.s[p.pos])) && !strings.HasPrefix(p.s[p.pos:], "->") {
+		p.pos++
+	}
+	value := strings.TrimSpace(p.s[start:p.pos])
+	if value == "" {
+		return "", p.errorf("expected value")
+	}
+	return value, nil
+}
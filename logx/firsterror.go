@@ -0,0 +1,28 @@
+package logx
+
+// FirstError 返回启动(或上次 ResetFirstError)以来记录的第一条 ERROR 日志，
+// 字段是脱敏之后的；没有时第二个返回值为 false。
+// 日志是异步写入的，需要在 Close 之后调用才能保证看到所有日志
+func (l *Logger) FirstError() (Entry, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.firstError == nil {
+		return Entry{}, false
+	}
+	return *l.firstError, true
+}
+
+// ResetFirstError 清除已记录的第一条错误
+func (l *Logger) ResetFirstError() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.firstError = nil
+}
+
+// 调用方需持有 l.mu
+func (l *Logger) recordFirstError(entry Entry) {
+	if entry.Level >= ERROR && l.firstError == nil {
+		first := entry
+		l.firstError = &first
+	}
+}
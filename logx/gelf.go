@@ -0,0 +1,183 @@
+package logx
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"math/rand/v2"
+	"net"
+	"os"
+	"strings"
+	"sync"
+)
+
+// GELFConfig GELF 输出目标配置
+type GELFConfig struct {
+	Network    string        // udp(默认) 或 tcp
+	Host       string        // host 字段，默认为本机主机名
+	ChunkSize  int           // UDP 单个数据报的最大字节数，默认1420，超过时按 GELF 分片发送
+	Compressor Compressor    // UDP 消息的压缩编码，例如 Gzip、Deflate(zlib)，Graylog 会自动识别；为空时不压缩，TCP 不支持压缩
+	Net        NetSinkConfig // TCP 连接的超时、TLS、代理等配置
+}
+
+// GELFSink 以 GELF 1.1 格式直接发送到 Graylog 的输出目标。等级映射为 syslog 等级，
+// 结构化字段写为 _key，多行消息的第一行作为 short_message，完整内容写入 full_message
+type GELFSink struct {
+	cfg GELFConfig
+	tcp *TCPSink // TCP 时复用 TCPSink 的重连、TLS 和代理
+
+	mu  sync.Mutex
+	udp net.Conn
+	buf []byte
+}
+
+const (
+	gelfMaxChunks   = 128
+	gelfChunkHeader = 12 // 0x1e 0x0f + 消息ID(8) + 序号 + 总数
+)
+
+var errGELFTooLarge = errors.New("logx: gelf message exceeds 128 chunks")
+
+// NewGELFSink 创建 GELF 输出目标，addr 为 Graylog GELF 输入的地址
+func NewGELFSink(addr string, cfg GELFConfig) (*GELFSink, error) {
+	if cfg.Host == "" {
+		cfg.Host, _ = os.Hostname()
+	}
+	if cfg.ChunkSize <= gelfChunkHeader {
+		cfg.ChunkSize = 1420
+	}
+	s := &GELFSink{cfg: cfg}
+	switch cfg.Network {
+	case "", "udp":
+		ctx, cancel := context.WithTimeout(context.Background(), cfg.Net.timeout())
+		defer cancel()
+		conn, err := cfg.Net.dialer().DialContext(ctx, "udp", addr)
+		if err != nil {
+			return nil, err
+		}
+		s.udp = conn
+	case "tcp":
+		if cfg.Compressor != nil {
+			return nil, errors.New("logx: gelf over tcp does not support compression")
+		}
+		tcp, err := NewTCPSink(addr, cfg.Net)
+		if err != nil {
+			return nil, err
+		}
+		s.tcp = tcp
+	default:
+		return nil, errors.New("logx: unsupported gelf network " + cfg.Network)
+	}
+	return s, nil
+}
+
+// syslog 等级：3 error、4 warning、6 informational、7 debug
+func gelfLevel(level LogLevel) int {
+	return int(journaldPriority(level) - '0')
+}
+
+func (s *GELFSink) encode(buf []byte, entry *Entry) []byte {
+	short, _, multiline := strings.Cut(entry.Message, "\n")
+	buf = append(buf, `{"version":"1.1","host":`...)
+	buf = appendJSONString(buf, s.cfg.Host)
+	buf = append(buf, `,"short_message":`...)
+	buf = appendJSONString(buf, short)
+	if multiline {
+		buf = append(buf, `,"full_message":`...)
+		buf = appendJSONString(buf, entry.Message)
+	}
+	buf = append(buf, `,"timestamp":`...)
+	buf = appendJSONFloat(buf, float64(entry.Time.UnixMicro())/1e6, 64)
+	buf = append(buf, `,"level":`...)
+	buf = appendJSONValue(buf, gelfLevel(entry.Level))
+	if entry.File != "" {
+		buf = append(buf, `,"_file":`...)
+		buf = appendJSONString(buf, entry.File)
+		buf = append(buf, `,"_line":`...)
+		buf = appendJSONValue(buf, entry.Line)
+	}
+	for _, f := range entry.Fields {
+		buf = append(buf, ',')
+		buf = appendJSONString(buf, gelfFieldName(f.Key))
+		buf = append(buf, ':')
+		switch v := f.Value.(type) {
+		case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+			buf = appendJSONValue(buf, v)
+		default:
+			// GELF 的附加字段只支持字符串和数字
+			buf = appendJSONString(buf, rawTextValue(v))
+		}
+	}
+	return append(buf, '}')
+}
+
+// 附加字段名只能包含字母、数字、下划线、点和横线，且不能是 _id
+func gelfFieldName(key string) string {
+	name := make([]byte, 0, len(key)+1)
+	name = append(name, '_')
+	for _, c := range []byte(key) {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '_', c == '.', c == '-':
+			name = append(name, c)
+		default:
+			name = append(name, '_')
+		}
+	}
+	if string(name) == "_id" {
+		return "__id"
+	}
+	return string(name)
+}
+
+func (s *GELFSink) Write(entry *Entry, line []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.buf = s.encode(s.buf[:0], entry)
+	if s.tcp != nil {
+		// GELF TCP 以空字节分隔消息
+		return s.tcp.Write(entry, append(s.buf, 0))
+	}
+	payload := s.buf
+	if s.cfg.Compressor != nil {
+		compressed, err := Compress(s.cfg.Compressor, payload)
+		if err != nil {
+			return err
+		}
+		payload = compressed
+	}
+	return s.sendUDP(payload)
+}
+
+func (s *GELFSink) sendUDP(payload []byte) error {
+	if len(payload) <= s.cfg.ChunkSize {
+		_, err := s.udp.Write(payload)
+		return err
+	}
+	size := s.cfg.ChunkSize - gelfChunkHeader
+	count := (len(payload) + size - 1) / size
+	if count > gelfMaxChunks {
+		return errGELFTooLarge
+	}
+	chunk := make([]byte, 0, s.cfg.ChunkSize)
+	id := rand.Uint64()
+	for i := 0; i < count; i++ {
+		chunk = append(chunk[:0], 0x1e, 0x0f)
+		chunk = binary.BigEndian.AppendUint64(chunk, id)
+		chunk = append(chunk, byte(i), byte(count))
+		end := min((i+1)*size, len(payload))
+		chunk = append(chunk, payload[i*size:end]...)
+		if _, err := s.udp.Write(chunk); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *GELFSink) Close() error {
+	if s.tcp != nil {
+		return s.tcp.Close()
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.udp.Close()
+}
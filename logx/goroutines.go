@@ -0,0 +1,41 @@
+package logx
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"runtime"
+)
+
+// 调用栈缓冲区的上限，超过时截断
+const maxGoroutineDump = 64 << 20
+
+// DumpGoroutines 以一条日志记录所有 goroutine 的调用栈(与 SIGQUIT 输出的内容相同)，返回 goroutine 数量。
+// 调用栈放在 HintPayload 的 dump 字段中，整体编码为一行；配置了 WithBlobStore 时超过阈值的调用栈写入附件存储。
+// 用于在线上排查死锁，不会中断进程
+func (l *Logger) DumpGoroutines(level LogLevel) int {
+	buf := make([]byte, 64<<10)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) || len(buf) >= maxGoroutineDump {
+			buf = buf[:n]
+			break
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+	count := bytes.Count(buf, []byte("\n\ngoroutine ")) + 1
+	l.log(level, "goroutine dump", []Field{Int("goroutines", count), Payload("dump", string(buf))})
+	return count
+}
+
+// GoroutineDumpHandler 返回管理接口使用的 http.Handler，收到 POST 请求时调用 DumpGoroutines
+func (l *Logger) GoroutineDumpHandler(level LogLevel) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+		fmt.Fprintf(w, "logged %d goroutines\n", l.DumpGoroutines(level))
+	})
+}
@@ -0,0 +1,107 @@
+package logx
+
+// FieldGroup 分组字段的值，JSON 格式输出为嵌套对象 {"http":{"status":200}}，
+// 文本格式展开为 http.status=200
+type FieldGroup []Field
+
+// Group 创建分组字段，例如 Group("http", Int("status", 200))
+func Group(key string, fields ...Field) Field {
+	return Field{Key: key, Value: FieldGroup(fields)}
+}
+
+// MarshalJSON 供 encoding/json 和二进制格式使用
+func (g FieldGroup) MarshalJSON() ([]byte, error) {
+	buf := append([]byte(nil), '{')
+	for i, f := range g {
+		if i > 0 {
+			buf = append(buf, ',')
+		}
+		buf = appendJSONString(buf, f.Key)
+		buf = append(buf, ':')
+		buf = appendJSONValue(buf, f.Value)
+	}
+	return append(buf, '}'), nil
+}
+
+// 打开的分组及其中 With 添加的字段
+type childGroup struct {
+	name   string
+	fields []Field
+}
+
+// WithGroup 见 Child.WithGroup
+func (l *Logger) WithGroup(name string) *Child {
+	return (&Child{l: l}).WithGroup(name)
+}
+
+// WithGroup 返回之后的字段(With 添加的和调用时传入的)都放在名为 name 的分组中的子日志记录器，
+// 例如 log.WithGroup("http").With(Int("status", 200)) 输出 {"http":{"status":200}}，与 slog 的语义一致：
+// 之前的字段不受影响，没有字段的分组不输出，name 为空时返回 c。Without 只作用于分组之外的字段
+func (c *Child) WithGroup(name string) *Child {
+	if name == "" {
+		return c
+	}
+	child := c.clone()
+	child.groups = append(c.groups[:len(c.groups):len(c.groups)], childGroup{name: name})
+	return child
+}
+
+// 在最内层的分组中加上 fields，同名时替换
+func (c *Child) withGrouped(fields []Field) *Child {
+	child := c.clone()
+	child.groups = append([]childGroup(nil), c.groups...)
+	last := &child.groups[len(child.groups)-1]
+	merged := make([]Field, 0, len(last.fields)+len(fields))
+	for _, f := range last.fields {
+		if !hasFieldKey(fields, f.Key) {
+			merged = append(merged, f)
+		}
+	}
+	last.fields = append(merged, fields...)
+	return child
+}
+
+// 把 fields 和各分组的字段按分组嵌套，没有字段的分组不输出
+func (c *Child) nest(fields []Field) []Field {
+	inner := fields
+	for i := len(c.groups) - 1; i >= 0; i-- {
+		g := c.groups[i]
+		if len(g.fields)+len(inner) == 0 {
+			continue
+		}
+		content := make([]Field, 0, len(g.fields)+len(inner))
+		content = append(append(content, g.fields...), inner...)
+		inner = []Field{Group(g.name, content...)}
+	}
+	return inner
+}
+
+// 编码分组为JSON对象，数字按 WithNumberFormat 输出
+func (l *Logger) appendJSONGroup(buf []byte, g FieldGroup) []byte {
+	buf = append(buf, '{')
+	for i, f := range g {
+		if i > 0 {
+			buf = append(buf, ',')
+		}
+		buf = appendJSONString(buf, f.Key)
+		buf = append(buf, ':')
+		buf = l.appendJSONValue(buf, f.Value)
+	}
+	return append(buf, '}')
+}
+
+// 文本格式的分组字段，键加上 prefix 展开
+func appendTextGroup(buf []byte, prefix string, g FieldGroup) []byte {
+	for _, f := range g {
+		if sub, ok := f.Value.(FieldGroup); ok {
+			buf = appendTextGroup(buf, prefix+f.Key+".", sub)
+			continue
+		}
+		buf = append(buf, ' ')
+		buf = append(buf, prefix...)
+		buf = append(buf, f.Key...)
+		buf = append(buf, '=')
+		buf = appendTextValue(buf, f.Value)
+	}
+	return buf
+}
@@ -0,0 +1,140 @@
+package logx
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultJournaldSocket systemd-journald 原生协议的套接字
+const DefaultJournaldSocket = "/run/systemd/journal/socket"
+
+// ErrJournaldUnsupported 非 Linux 平台创建 journald 输出目标时返回该错误
+var ErrJournaldUnsupported = errors.New("logx: journald is only supported on linux")
+
+// JournaldConfig journald 输出目标配置
+type JournaldConfig struct {
+	Identifier  string // SYSLOG_IDENTIFIER，为空时由 journald 按进程名填充
+	SocketPath  string // 默认 DefaultJournaldSocket
+	FieldPrefix string // 结构化字段名的前缀，例如 APP_，避免与 journald 的标准字段冲突
+}
+
+// JournaldSink 通过原生协议写入 systemd journal 的输出目标：消息写入 MESSAGE，等级映射为 PRIORITY，
+// 结构化字段转为大写的 FIELD=value，调用位置写入 CODE_FILE/CODE_LINE，便于 journalctl 按字段过滤
+type JournaldSink struct {
+	cfg  JournaldConfig
+	mu   sync.Mutex
+	conn journaldConn
+	buf  bytes.Buffer
+}
+
+// NewJournaldSink 连接 journald 的套接字
+func NewJournaldSink(cfg JournaldConfig) (*JournaldSink, error) {
+	if cfg.SocketPath == "" {
+		cfg.SocketPath = DefaultJournaldSocket
+	}
+	conn, err := dialJournald(cfg.SocketPath)
+	if err != nil {
+		return nil, err
+	}
+	return &JournaldSink{cfg: cfg, conn: conn}, nil
+}
+
+// syslog 优先级：3 err、4 warning、6 info、7 debug
+func journaldPriority(level LogLevel) byte {
+	switch {
+	case level <= DEBUG:
+		return '7'
+	case level == INFO:
+		return '6'
+	case level == WARN:
+		return '4'
+	case level == ERROR:
+		return '3'
+	}
+	return '2'
+}
+
+func (s *JournaldSink) Write(entry *Entry, line []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.buf.Reset()
+	appendJournaldField(&s.buf, "MESSAGE", entry.Message)
+	s.buf.WriteString("PRIORITY=")
+	s.buf.WriteByte(journaldPriority(entry.Level))
+	s.buf.WriteByte('\n')
+	if s.cfg.Identifier != "" {
+		appendJournaldField(&s.buf, "SYSLOG_IDENTIFIER", s.cfg.Identifier)
+	}
+	if entry.File != "" {
+		appendJournaldField(&s.buf, "CODE_FILE", entry.File)
+		appendJournaldField(&s.buf, "CODE_LINE", strconv.Itoa(entry.Line))
+	}
+	for _, f := range entry.Fields {
+		appendJournaldField(&s.buf, journaldFieldName(s.cfg.FieldPrefix, f.Key), rawTextValue(f.Value))
+	}
+	return s.conn.send(s.buf.Bytes())
+}
+
+func (s *JournaldSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.conn.close()
+}
+
+// 不加引号和转义的文本值，用于 journald、GELF 等自带字段结构的协议
+func rawTextValue(v interface{}) string {
+	switch val := v.(type) {
+	case string:
+		return val
+	case error:
+		return val.Error()
+	case fmt.Stringer:
+		return val.String()
+	}
+	return textValue(v)
+}
+
+// 值中含有换行时使用二进制格式：名称、换行、64位小端长度、值、换行
+func appendJournaldField(buf *bytes.Buffer, name, value string) {
+	buf.WriteString(name)
+	if strings.IndexByte(value, '\n') < 0 {
+		buf.WriteByte('=')
+		buf.WriteString(value)
+		buf.WriteByte('\n')
+		return
+	}
+	buf.WriteByte('\n')
+	var size [8]byte
+	binary.LittleEndian.PutUint64(size[:], uint64(len(value)))
+	buf.Write(size[:])
+	buf.WriteString(value)
+	buf.WriteByte('\n')
+}
+
+// journald 的字段名只能包含大写字母、数字和下划线，不能以数字或下划线开头，最长64字节
+func journaldFieldName(prefix, key string) string {
+	name := make([]byte, 0, len(prefix)+len(key))
+	for _, c := range []byte(prefix + key) {
+		switch {
+		case c >= 'a' && c <= 'z':
+			name = append(name, c-'a'+'A')
+		case c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+			name = append(name, c)
+		default:
+			name = append(name, '_')
+		}
+	}
+	name = bytes.TrimLeft(name, "_")
+	if len(name) == 0 || name[0] >= '0' && name[0] <= '9' {
+		name = append([]byte("F_"), name...)
+	}
+	if len(name) > 64 {
+		name = name[:64]
+	}
+	return string(name)
+}
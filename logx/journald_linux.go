@@ -0,0 +1,48 @@
+//go:build linux
+
+package logx
+
+import (
+	"errors"
+	"net"
+	"os"
+	"syscall"
+)
+
+type journaldConn struct {
+	conn *net.UnixConn
+}
+
+func dialJournald(path string) (journaldConn, error) {
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		return journaldConn{}, err
+	}
+	return journaldConn{conn: conn}, nil
+}
+
+// 超过数据报大小限制时，与 sd_journal_sendv 一样把内容写入已删除的临时文件，通过 SCM_RIGHTS 传递文件描述符
+func (c journaldConn) send(payload []byte) error {
+	_, err := c.conn.Write(payload)
+	if err == nil || !(errors.Is(err, syscall.EMSGSIZE) || errors.Is(err, syscall.ENOBUFS)) {
+		return err
+	}
+	file, err := os.CreateTemp("/dev/shm", "logx-journal-")
+	if err != nil {
+		file, err = os.CreateTemp("", "logx-journal-")
+		if err != nil {
+			return err
+		}
+	}
+	defer file.Close()
+	os.Remove(file.Name())
+	if _, err := file.Write(payload); err != nil {
+		return err
+	}
+	_, _, err = c.conn.WriteMsgUnix(nil, syscall.UnixRights(int(file.Fd())), nil)
+	return err
+}
+
+func (c journaldConn) close() error {
+	return c.conn.Close()
+}
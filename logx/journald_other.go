@@ -0,0 +1,13 @@
+//go:build !linux
+
+package logx
+
+type journaldConn struct{}
+
+func dialJournald(path string) (journaldConn, error) {
+	return journaldConn{}, ErrJournaldUnsupported
+}
+
+func (journaldConn) send(payload []byte) error { return ErrJournaldUnsupported }
+
+func (journaldConn) close() error { return nil }
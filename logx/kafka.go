@@ -0,0 +1,92 @@
+package logx
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// KafkaMessage 发往 Kafka 的一条消息
+type KafkaMessage struct {
+	Topic     string
+	Key       []byte // 相同 key 的消息写入同一个分区，为空时由生产者选择分区
+	Value     []byte // 编码好的一行日志
+	Timestamp time.Time
+}
+
+// KafkaProducer Kafka 生产者，logx 不依赖具体的客户端库，
+// 可以用 sarama 的 AsyncProducer、franz-go 的 kgo.Client 等适配，批量发送和重试由生产者负责
+type KafkaProducer interface {
+	// Produce 异步发送消息，发送完成(或失败)后调用 done，done 可能在其他协程中执行
+	Produce(msg *KafkaMessage, done func(error))
+	// Close 发送剩余的消息后关闭
+	Close() error
+}
+
+// KafkaConfig Kafka 输出目标配置
+type KafkaConfig struct {
+	Topic string
+	Key   func(entry *Entry) []byte // 提取分区 key，例如 KeyByField("request_id")
+}
+
+// KeyByField 使用第一个存在的字段值作为分区 key，同一个服务或请求的日志保持顺序
+func KeyByField(keys ...string) func(entry *Entry) []byte {
+	return func(entry *Entry) []byte {
+		for _, key := range keys {
+			for _, f := range entry.Fields {
+				if f.Key == key && f.Value != nil {
+					return []byte(rawTextValue(f.Value))
+				}
+			}
+		}
+		return nil
+	}
+}
+
+// KafkaSink 把编码好的日志发送到 Kafka 主题的输出目标，发送失败通过 WithErrorHandler 报告
+type KafkaSink struct {
+	producer KafkaProducer
+	cfg      KafkaConfig
+	onError  func(error)
+}
+
+// NewKafkaSink 创建 Kafka 输出目标
+func NewKafkaSink(producer KafkaProducer, cfg KafkaConfig) (*KafkaSink, error) {
+	if cfg.Topic == "" {
+		return nil, errors.New("logx: kafka topic is required")
+	}
+	return &KafkaSink{producer: producer, cfg: cfg}, nil
+}
+
+func (s *KafkaSink) SetErrorHandler(handler func(error)) {
+	s.onError = handler
+}
+
+func (s *KafkaSink) Write(entry *Entry, line []byte) error {
+	msg := &KafkaMessage{
+		Topic:     s.cfg.Topic,
+		Value:     append([]byte(nil), line...), // 生产者异步发送，line 会被复用
+		Timestamp: entry.Time,
+	}
+	if s.cfg.Key != nil {
+		msg.Key = s.cfg.Key(entry)
+	}
+	s.producer.Produce(msg, s.report)
+	return nil
+}
+
+func (s *KafkaSink) report(err error) {
+	if err == nil {
+		return
+	}
+	if s.onError != nil {
+		s.onError(err)
+		return
+	}
+	fmt.Fprintln(os.Stderr, "logx: kafka:", err)
+}
+
+func (s *KafkaSink) Close() error {
+	return s.producer.Close()
+}
@@ -0,0 +1,79 @@
+package logx
+
+import (
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// OpenLogger 创建后还没有 Close 的日志记录器，见 OpenLoggers
+type OpenLogger struct {
+	ID        uint64    // 进程内唯一的编号，按创建顺序递增
+	Path      string    // 日志文件路径
+	CreatedAt string    // 创建它的代码位置，file:line
+	Created   time.Time // 创建时间
+	Worker    bool      // 是否已经调用 StartWorker
+}
+
+var openLoggers struct {
+	sync.Mutex
+	m      map[*Logger]*OpenLogger
+	nextID uint64
+}
+
+// OpenLoggers 返回当前进程中所有还没有 Close 的日志记录器，按创建顺序排列，
+// 用于在测试中发现没有关闭的日志记录器(以及它们的写入协程和文件句柄)，见 logtest.VerifyNoLeaks
+func OpenLoggers() []OpenLogger {
+	openLoggers.Lock()
+	defer openLoggers.Unlock()
+	list := make([]OpenLogger, 0, len(openLoggers.m))
+	for _, info := range openLoggers.m {
+		list = append(list, *info)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+	return list
+}
+
+func trackOpen(l *Logger) {
+	info := &OpenLogger{Path: l.filePath, CreatedAt: creationSite(), Created: time.Now()}
+	openLoggers.Lock()
+	defer openLoggers.Unlock()
+	if openLoggers.m == nil {
+		openLoggers.m = make(map[*Logger]*OpenLogger)
+	}
+	openLoggers.nextID++
+	info.ID = openLoggers.nextID
+	openLoggers.m[l] = info
+}
+
+func trackWorker(l *Logger) {
+	openLoggers.Lock()
+	defer openLoggers.Unlock()
+	if info := openLoggers.m[l]; info != nil {
+		info.Worker = true
+	}
+}
+
+func trackClose(l *Logger) {
+	openLoggers.Lock()
+	defer openLoggers.Unlock()
+	delete(openLoggers.m, l)
+}
+
+// 调用 logx(包括 logtest 等子包)的第一个外部代码位置，logx 自身的测试中为测试文件的位置
+func creationSite() string {
+	pcs := make([]uintptr, 16)
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(3, pcs)])
+	for {
+		frame, more := frames.Next()
+		if !strings.HasPrefix(frame.Function, modulePath) || strings.HasSuffix(frame.File, "_test.go") {
+			return frame.File + ":" + strconv.Itoa(frame.Line)
+		}
+		if !more {
+			return "unknown"
+		}
+	}
+}
@@ -0,0 +1,10 @@
+//go:build !unix && !windows
+
+package logx
+
+import "os"
+
+// 不支持文件锁的平台上只能依赖进程内的互斥锁
+func lockFile(f *os.File) error { return nil }
+
+func unlockFile(f *os.File) error { return nil }
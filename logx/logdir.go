@@ -0,0 +1,57 @@
+package logx
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+// DefaultLogDir 返回应用日志的默认目录，不会创建目录：
+//   - Linux 等类 Unix 系统：root 用户为 /var/log/<app>，否则为 $XDG_STATE_HOME/<app>(默认 ~/.local/state/<app>)
+//   - macOS：~/Library/Logs/<app>，root 用户为 /Library/Logs/<app>
+//   - Windows：%ProgramData%\<app>\logs
+//
+// 无法确定用户目录时退回到当前目录下的 logs
+func DefaultLogDir(appName string) string {
+	home, _ := os.UserHomeDir()
+	return logDirFor(runtime.GOOS, appName, home, os.Geteuid() == 0, os.Getenv)
+}
+
+func logDirFor(goos, appName, home string, root bool, getenv func(string) string) string {
+	switch goos {
+	case "windows":
+		base := getenv("ProgramData")
+		if base == "" {
+			base = `C:\ProgramData`
+		}
+		return filepath.Join(base, appName, "logs")
+	case "darwin", "ios":
+		if root {
+			return filepath.Join("/Library/Logs", appName)
+		}
+		if home != "" {
+			return filepath.Join(home, "Library", "Logs", appName)
+		}
+	default:
+		if root {
+			return filepath.Join("/var/log", appName)
+		}
+		if state := getenv("XDG_STATE_HOME"); filepath.IsAbs(state) {
+			return filepath.Join(state, appName)
+		}
+		if home != "" {
+			return filepath.Join(home, ".local", "state", appName)
+		}
+	}
+	return "logs"
+}
+
+// DefaultLogPath 返回 DefaultLogDir 下的 <app>.log
+func DefaultLogPath(appName string) string {
+	return filepath.Join(DefaultLogDir(appName), appName+".log")
+}
+
+// NewAppLogger 与 NewLogger 相同，日志文件位于 DefaultLogPath(appName)，适合桌面应用和命令行工具
+func NewAppLogger(appName string, level LogLevel, maxSizeMB int64, consoleOut bool, opts ...Option) (*Logger, error) {
+	return NewLogger(DefaultLogPath(appName), level, maxSizeMB, consoleOut, opts...)
+}
@@ -0,0 +1,75 @@
+package logtest
+
+import (
+	"bytes"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/capyflow/opensource/logx"
+)
+
+// 测试结束后等待 logx 协程退出的最长时间，部分输出目标在 Close 返回后才退出
+const leakWait = time.Second
+
+// VerifyNoLeaks 在测试开始时调用，测试结束时(在之后注册的 Cleanup 之后，因此 New 创建的记录器已经关闭)
+// 检查测试期间创建的日志记录器都已 Close，logx 启动的协程都已退出，否则测试失败并列出创建位置和协程的调用栈。
+// 与 t.Parallel 同时使用时可能把其他测试的记录器算进来
+func VerifyNoLeaks(t testing.TB) {
+	t.Helper()
+	loggers := map[uint64]bool{}
+	for _, l := range logx.OpenLoggers() {
+		loggers[l.ID] = true
+	}
+	goroutines := logxGoroutines()
+	t.Cleanup(func() {
+		for _, l := range logx.OpenLoggers() {
+			if !loggers[l.ID] {
+				t.Errorf("logtest: logger %s created at %s was not closed (worker started: %v)", l.Path, l.CreatedAt, l.Worker)
+			}
+		}
+		var leaked []string
+		for deadline := time.Now().Add(leakWait); ; time.Sleep(10 * time.Millisecond) {
+			leaked = leaked[:0]
+			for id, stack := range logxGoroutines() {
+				if _, ok := goroutines[id]; !ok {
+					leaked = append(leaked, stack)
+				}
+			}
+			if len(leaked) == 0 || time.Now().After(deadline) {
+				break
+			}
+		}
+		for _, stack := range leaked {
+			t.Errorf("logtest: leaked goroutine:\n%s", stack)
+		}
+	})
+}
+
+// 调用栈中有 logx 代码的协程(不包括当前协程和 logtest 自身)，按协程编号索引
+func logxGoroutines() map[string]string {
+	buf := make([]byte, 1<<20)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			buf = buf[:n]
+			break
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+	stacks := map[string]string{}
+	for i, stack := range bytes.Split(buf, []byte("\n\n")) {
+		if i == 0 {
+			// 第一个是当前协程
+			continue
+		}
+		header, _, _ := strings.Cut(string(stack), "\n")
+		id := strings.Fields(header)
+		if len(id) < 2 || !strings.Contains(string(stack), "github.com/capyflow/opensource/logx.") || strings.Contains(string(stack), "testing.tRunner") {
+			continue
+		}
+		stacks[id[1]] = string(stack)
+	}
+	return stacks
+}
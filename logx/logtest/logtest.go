@@ -0,0 +1,56 @@
+// Package logtest 提供测试中使用 logx 的辅助工具
+package logtest
+
+import (
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/capyflow/opensource/logx"
+)
+
+// New 创建测试用的日志记录器：所有日志缓冲在内存中，测试成功时不输出，
+// 失败时在测试结束后通过 t.Log 按顺序输出，保持成功测试的输出干净。
+// 日志文件写在 t.TempDir() 中，记录器会在测试结束时自动关闭
+func New(t testing.TB, level logx.LogLevel, opts ...logx.Option) *logx.Logger {
+	t.Helper()
+	buf := &bufferSink{}
+	opts = append([]logx.Option{logx.WithSink("logtest", buf, logx.DEBUG)}, opts...)
+	log, err := logx.NewLogger(filepath.Join(t.TempDir(), "test.log"), level, 10, false, opts...)
+	if err != nil {
+		t.Fatalf("logtest: create logger: %v", err)
+	}
+	log.StartWorker()
+	t.Cleanup(func() {
+		log.Close()
+		if !t.Failed() {
+			return
+		}
+		for _, line := range buf.lines() {
+			t.Log(line)
+		}
+	})
+	return log
+}
+
+// 在内存中缓冲日志行
+type bufferSink struct {
+	mu  sync.Mutex
+	buf []string
+}
+
+func (s *bufferSink) Write(entry *logx.Entry, line []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.buf = append(s.buf, strings.TrimRight(string(line), "\n"))
+	return nil
+}
+
+func (s *bufferSink) Close() error { return nil }
+
+func (s *bufferSink) lines() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.buf...)
+}
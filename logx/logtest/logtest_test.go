@@ -0,0 +1,158 @@
+package logtest
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/capyflow/opensource/logx"
+)
+
+func TestObservedLogs(t *testing.T) {
+	log := NewTestLogger(t)
+	log.Info("request handled", logx.Int("status", 200))
+	log.Error("request failed", logx.Int("status", 502))
+	log.Error("upstream timeout", logx.Int("status", 504))
+
+	errs := log.ObservedLogs().FilterLevel(logx.ERROR)
+	if errs.Len() != 2 {
+		t.Fatalf("expected 2 errors, got %v", errs.Messages())
+	}
+	if got := errs.FilterMessageContains("timeout").FilterField("status", 504).Len(); got != 1 {
+		t.Fatalf("expected the timeout entry, got %d", got)
+	}
+}
+
+func TestSinkTranscripts(t *testing.T) {
+	rec := NewRecorder()
+	cfg := logx.NetSinkConfig{Dialer: rec}
+	tcp, err := logx.NewTCPSink("127.0.0.1:5170", cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	httpSink, err := logx.NewHTTPSink("http://127.0.0.1:8080/ingest", cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gelf, err := logx.NewGELFSink("127.0.0.1:12201", logx.GELFConfig{Host: "web-1", Net: cfg})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sinks := []logx.Sink{tcp, httpSink, gelf}
+	entries := []logx.Entry{
+		{Level: logx.INFO, Time: time.Unix(1700000000, 0).UTC(), Message: "request handled", Fields: []logx.Field{logx.Int("status", 200)}},
+		{Level: logx.ERROR, Time: time.Unix(1700000001, 0).UTC(), Message: "upstream timeout", Fields: []logx.Field{logx.Int("status", 504)}},
+	}
+	for _, e := range entries {
+		line := []byte(`{"level":"` + map[logx.LogLevel]string{logx.INFO: "info", logx.ERROR: "error"}[e.Level] + `","msg":"` + e.Message + `"}` + "\n")
+		for _, s := range sinks {
+			if err := s.Write(&e, line); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	for _, s := range sinks {
+		s.Close()
+	}
+	got := rec.Transcript()
+	VerifyGolden(t, filepath.Join("testdata", "sinks.golden"), got)
+
+	// 重放得到相同的字节
+	replayed := NewRecorder()
+	if err := got.Replay(context.Background(), replayed); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(replayed.Transcript().Encode(), got.Encode()) {
+		t.Fatalf("replay differs:\n%s", replayed.Transcript().Encode())
+	}
+}
+
+// 记录失败而不中止测试
+type fakeTB struct {
+	testing.TB
+	errs     []string
+	cleanups []func()
+}
+
+func (f *fakeTB) Helper()           {}
+func (f *fakeTB) Cleanup(fn func()) { f.cleanups = append(f.cleanups, fn) }
+func (f *fakeTB) Errorf(format string, args ...interface{}) {
+	f.errs = append(f.errs, fmt.Sprintf(format, args...))
+}
+
+func (f *fakeTB) finish() {
+	for i := len(f.cleanups) - 1; i >= 0; i-- {
+		f.cleanups[i]()
+	}
+}
+
+func TestVerifyNoLeaks(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	clean := &fakeTB{TB: t}
+	VerifyNoLeaks(clean)
+	log, err := logx.NewLogger(path, logx.INFO, 1, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	log.StartWorker()
+	log.Info("closed")
+	log.Close()
+	clean.finish()
+	if len(clean.errs) != 0 {
+		t.Fatalf("unexpected leaks: %v", clean.errs)
+	}
+
+	leaky := &fakeTB{TB: t}
+	VerifyNoLeaks(leaky)
+	log, err = logx.NewLogger(path, logx.INFO, 1, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	log.StartWorker()
+	leaky.finish()
+	log.Close()
+	report := strings.Join(leaky.errs, "\n")
+	if !strings.Contains(report, "logtest_test.go") || !strings.Contains(report, "worker started: true") || !strings.Contains(report, "runWorker") {
+		t.Fatalf("expected the open logger and its worker to be reported, got:\n%s", report)
+	}
+}
+
+// 记录 t.Log 的输出，Failed 返回 failed
+type logTB struct {
+	fakeTB
+	failed bool
+	logs   []string
+}
+
+func (f *logTB) Failed() bool { return f.failed }
+func (f *logTB) Log(args ...interface{}) {
+	f.logs = append(f.logs, fmt.Sprint(args...))
+}
+
+func TestNew(t *testing.T) {
+	passed := &logTB{fakeTB: fakeTB{TB: t}}
+	log := New(passed, logx.INFO)
+	log.Debug("below level")
+	log.Info("quiet")
+	passed.finish()
+	if len(passed.logs) != 0 {
+		t.Fatalf("passing test printed logs: %q", passed.logs)
+	}
+
+	failed := &logTB{fakeTB: fakeTB{TB: t}, failed: true}
+	log = New(failed, logx.INFO)
+	log.Info("first", logx.Int("n", 1))
+	log.Warn("second")
+	failed.finish()
+	// 失败时在结束后按顺序输出，记录器已经关闭
+	if len(failed.logs) != 2 || !strings.Contains(failed.logs[0], "first n=1") || !strings.Contains(failed.logs[1], "second") {
+		t.Fatalf("failing test logs = %q", failed.logs)
+	}
+	if err := log.TryInfo("late"); err == nil {
+		t.Fatal("logger still open after cleanup")
+	}
+}
@@ -0,0 +1,147 @@
+package logtest
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/capyflow/opensource/logx"
+)
+
+// TestLogger 写入 t.Log 并在内存中记录每条日志的日志记录器，用于断言代码输出了哪些日志
+type TestLogger struct {
+	*logx.Logger
+	t        testing.TB
+	recorder *recorder
+}
+
+// NewTestLogger 创建记录所有等级日志的 TestLogger，每条日志同时通过 t.Log 输出，
+// 记录器会在测试结束时自动关闭
+func NewTestLogger(t testing.TB, opts ...logx.Option) *TestLogger {
+	t.Helper()
+	rec := &recorder{t: t}
+	opts = append([]logx.Option{logx.WithSink("logtest", rec, logx.DEBUG)}, opts...)
+	log, err := logx.NewLogger(filepath.Join(t.TempDir(), "test.log"), logx.DEBUG, 10, false, opts...)
+	if err != nil {
+		t.Fatalf("logtest: create logger: %v", err)
+	}
+	log.StartWorker()
+	t.Cleanup(log.Close)
+	return &TestLogger{Logger: log, t: t, recorder: rec}
+}
+
+// ObservedLogs 等待已经写入的日志处理完后，返回目前为止记录的所有日志
+func (l *TestLogger) ObservedLogs() *ObservedLogs {
+	l.t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := l.Drain(ctx); err != nil {
+		l.t.Fatalf("logtest: wait for log entries: %v", err)
+	}
+	return &ObservedLogs{entries: l.recorder.snapshot()}
+}
+
+// 记录日志并输出到 t.Log 的输出目标
+type recorder struct {
+	t       testing.TB
+	mu      sync.Mutex
+	entries []logx.Entry
+}
+
+func (r *recorder) Write(entry *logx.Entry, line []byte) error {
+	r.t.Log(strings.TrimRight(string(line), "\n"))
+	e := *entry
+	e.Fields = append([]logx.Field(nil), entry.Fields...)
+	r.mu.Lock()
+	r.entries = append(r.entries, e)
+	r.mu.Unlock()
+	return nil
+}
+
+func (r *recorder) Close() error { return nil }
+
+func (r *recorder) snapshot() []logx.Entry {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]logx.Entry(nil), r.entries...)
+}
+
+// ObservedLogs 记录下来的一组日志，过滤方法返回新的集合，不修改原集合
+type ObservedLogs struct {
+	entries []logx.Entry
+}
+
+func (o *ObservedLogs) Len() int { return len(o.entries) }
+
+// All 返回所有日志
+func (o *ObservedLogs) All() []logx.Entry {
+	return append([]logx.Entry(nil), o.entries...)
+}
+
+// Messages 返回所有日志的消息，便于整体比较
+func (o *ObservedLogs) Messages() []string {
+	msgs := make([]string, len(o.entries))
+	for i, e := range o.entries {
+		msgs[i] = e.Message
+	}
+	return msgs
+}
+
+// Filter 返回满足 keep 的日志
+func (o *ObservedLogs) Filter(keep func(logx.Entry) bool) *ObservedLogs {
+	var out []logx.Entry
+	for _, e := range o.entries {
+		if keep(e) {
+			out = append(out, e)
+		}
+	}
+	return &ObservedLogs{entries: out}
+}
+
+// FilterLevel 只保留等级等于 level 的日志
+func (o *ObservedLogs) FilterLevel(level logx.LogLevel) *ObservedLogs {
+	return o.Filter(func(e logx.Entry) bool { return e.Level == level })
+}
+
+// FilterMinLevel 只保留不低于 level 的日志
+func (o *ObservedLogs) FilterMinLevel(level logx.LogLevel) *ObservedLogs {
+	return o.Filter(func(e logx.Entry) bool { return e.Level >= level })
+}
+
+// FilterMessage 只保留消息等于 msg 的日志
+func (o *ObservedLogs) FilterMessage(msg string) *ObservedLogs {
+	return o.Filter(func(e logx.Entry) bool { return e.Message == msg })
+}
+
+// FilterMessageContains 只保留消息包含 sub 的日志
+func (o *ObservedLogs) FilterMessageContains(sub string) *ObservedLogs {
+	return o.Filter(func(e logx.Entry) bool { return strings.Contains(e.Message, sub) })
+}
+
+// FilterField 只保留包含字段 key 且值与 value 深度相等的日志
+func (o *ObservedLogs) FilterField(key string, value interface{}) *ObservedLogs {
+	return o.Filter(func(e logx.Entry) bool {
+		for _, f := range e.Fields {
+			if f.Key == key && reflect.DeepEqual(f.Value, value) {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// FilterFieldKey 只保留包含字段 key 的日志
+func (o *ObservedLogs) FilterFieldKey(key string) *ObservedLogs {
+	return o.Filter(func(e logx.Entry) bool {
+		for _, f := range e.Fields {
+			if f.Key == key {
+				return true
+			}
+		}
+		return false
+	})
+}
@@ -0,0 +1,281 @@
+package logtest
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/capyflow/opensource/logx"
+)
+
+// Record 一个 TCP 连接发出的全部字节，或一个 UDP 数据报
+type Record struct {
+	Conn    int // 第几个连接，从1开始
+	Network string
+	Addr    string
+	Data    []byte
+}
+
+// Transcript 按发生顺序排列的记录，可以与 golden 文件比较，也可以重放到真实的后端
+type Transcript []Record
+
+// Recorder 记录输出目标发出的字节的 logx.ContextDialer。设置为 NetSinkConfig.Dialer 后，
+// TCPSink、HTTPSink、GELFSink 等输出目标不需要真实的后端即可测试：HTTP 请求收到 Status 响应，
+// 其他连接只记录不回复。记录的是未加密的字节，不要同时配置 TLS
+type Recorder struct {
+	Status int // HTTP 响应的状态码，默认200
+
+	mu      sync.Mutex
+	records Transcript
+	conns   int
+}
+
+// NewRecorder 创建 Recorder
+func NewRecorder() *Recorder {
+	return &Recorder{Status: http.StatusOK}
+}
+
+func (r *Recorder) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.conns++
+	c := &recordConn{r: r, id: r.conns, network: network, addr: addr, index: -1}
+	c.cond = sync.NewCond(&c.mu)
+	if !isPacket(network) {
+		c.index = len(r.records)
+		r.records = append(r.records, Record{Conn: c.id, Network: network, Addr: addr})
+	}
+	return c, nil
+}
+
+// Transcript 返回目前为止的记录
+func (r *Recorder) Transcript() Transcript {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	t := make(Transcript, len(r.records))
+	for i, rec := range r.records {
+		rec.Data = append([]byte(nil), rec.Data...)
+		t[i] = rec
+	}
+	return t
+}
+
+func isPacket(network string) bool {
+	switch network {
+	case "udp", "udp4", "udp6", "unixgram":
+		return true
+	}
+	return false
+}
+
+// 记录写入的内存连接
+type recordConn struct {
+	r       *Recorder
+	id      int
+	network string
+	addr    string
+	index   int // TCP 连接在 records 中的位置
+
+	mu      sync.Mutex
+	cond    *sync.Cond
+	pending []byte // 未读完的 HTTP 请求
+	mode    int    // 0 未确定，1 HTTP，2 其他协议
+	resp    bytes.Buffer
+	closed  bool
+}
+
+func (c *recordConn) Write(p []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return 0, net.ErrClosed
+	}
+	c.r.mu.Lock()
+	if c.index < 0 {
+		c.r.records = append(c.r.records, Record{Conn: c.id, Network: c.network, Addr: c.addr, Data: append([]byte(nil), p...)})
+	} else {
+		c.r.records[c.index].Data = append(c.r.records[c.index].Data, p...)
+	}
+	c.r.mu.Unlock()
+	if c.index >= 0 && c.mode != 2 {
+		c.pending = append(c.pending, p...)
+		c.answer()
+	}
+	return len(p), nil
+}
+
+// 对读完的 HTTP 请求写入响应
+func (c *recordConn) answer() {
+	if c.mode == 0 {
+		line, _, complete := bytes.Cut(c.pending, []byte("\r\n"))
+		if !complete {
+			return
+		}
+		if !bytes.Contains(line, []byte(" HTTP/1.")) {
+			c.mode, c.pending = 2, nil
+			return
+		}
+		c.mode = 1
+	}
+	for len(c.pending) > 0 {
+		src := bytes.NewReader(c.pending)
+		br := bufio.NewReader(src)
+		req, err := http.ReadRequest(br)
+		if err != nil {
+			return
+		}
+		if _, err := io.Copy(io.Discard, req.Body); err != nil {
+			return
+		}
+		c.pending = c.pending[len(c.pending)-src.Len()-br.Buffered():]
+		fmt.Fprintf(&c.resp, "HTTP/1.1 %d %s\r\nContent-Length: 0\r\n\r\n", c.r.Status, http.StatusText(c.r.Status))
+		c.cond.Broadcast()
+	}
+}
+
+func (c *recordConn) Read(p []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for c.resp.Len() == 0 && !c.closed {
+		c.cond.Wait()
+	}
+	if c.resp.Len() == 0 {
+		return 0, io.EOF
+	}
+	return c.resp.Read(p)
+}
+
+func (c *recordConn) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closed = true
+	c.cond.Broadcast()
+	return nil
+}
+
+func (c *recordConn) LocalAddr() net.Addr                { return recordAddr{c.network, "recorder"} }
+func (c *recordConn) RemoteAddr() net.Addr               { return recordAddr{c.network, c.addr} }
+func (c *recordConn) SetDeadline(t time.Time) error      { return nil }
+func (c *recordConn) SetReadDeadline(t time.Time) error  { return nil }
+func (c *recordConn) SetWriteDeadline(t time.Time) error { return nil }
+
+type recordAddr struct{ network, addr string }
+
+func (a recordAddr) Network() string { return a.network }
+func (a recordAddr) String() string  { return a.addr }
+
+// Encode 编码为文本，每条记录为一行 "conn <序号> <network> <addr> <字节数>"，接着是原始字节和换行符
+func (t Transcript) Encode() []byte {
+	var buf bytes.Buffer
+	for _, rec := range t {
+		fmt.Fprintf(&buf, "conn %d %s %s %d\n", rec.Conn, rec.Network, rec.Addr, len(rec.Data))
+		buf.Write(rec.Data)
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes()
+}
+
+// ParseTranscript 解析 Encode 的输出
+func ParseTranscript(data []byte) (Transcript, error) {
+	var t Transcript
+	for len(data) > 0 {
+		header, rest, ok := bytes.Cut(data, []byte("\n"))
+		if !ok {
+			return nil, fmt.Errorf("logtest: transcript: missing record header")
+		}
+		var rec Record
+		var size int
+		if _, err := fmt.Sscanf(string(header), "conn %d %s %s %d", &rec.Conn, &rec.Network, &rec.Addr, &size); err != nil {
+			return nil, fmt.Errorf("logtest: transcript: bad header %q: %w", header, err)
+		}
+		if size < 0 || len(rest) < size+1 || rest[size] != '\n' {
+			return nil, fmt.Errorf("logtest: transcript: record %q is truncated", header)
+		}
+		rec.Data = append([]byte(nil), rest[:size]...)
+		t = append(t, rec)
+		data = rest[size+1:]
+	}
+	return t, nil
+}
+
+// Replay 通过 dialer 把记录的字节原样发送一遍，每个连接重新建立一次，可以用来在真实的后端上复现问题。
+// 不读取响应
+func (t Transcript) Replay(ctx context.Context, dialer logx.ContextDialer) error {
+	conns := map[int]net.Conn{}
+	defer func() {
+		for _, conn := range conns {
+			conn.Close()
+		}
+	}()
+	// 按原来的顺序建立连接，再按记录的顺序写入
+	first := map[int]Record{}
+	var ids []int
+	for _, rec := range t {
+		if _, ok := first[rec.Conn]; !ok {
+			first[rec.Conn] = rec
+			ids = append(ids, rec.Conn)
+		}
+	}
+	sort.Ints(ids)
+	for _, id := range ids {
+		conn, err := dialer.DialContext(ctx, first[id].Network, first[id].Addr)
+		if err != nil {
+			return err
+		}
+		conns[id] = conn
+	}
+	for _, rec := range t {
+		if len(rec.Data) == 0 {
+			continue
+		}
+		if _, err := conns[rec.Conn].Write(rec.Data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// VerifyGolden 比较 got 与 golden 文件，不一致时测试失败。
+// 设置环境变量 LOGTEST_UPDATE=1 运行测试会用 got 重写 golden 文件
+func VerifyGolden(t testing.TB, path string, got Transcript) {
+	t.Helper()
+	data := got.Encode()
+	if update, _ := strconv.ParseBool(os.Getenv("LOGTEST_UPDATE")); update {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("logtest: %v", err)
+		}
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			t.Fatalf("logtest: %v", err)
+		}
+		return
+	}
+	golden, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("logtest: read golden transcript (run with LOGTEST_UPDATE=1 to create it): %v", err)
+	}
+	want, err := ParseTranscript(golden)
+	if err != nil {
+		t.Fatalf("logtest: %s: %v", path, err)
+	}
+	for i := 0; i < len(want) || i < len(got); i++ {
+		switch {
+		case i >= len(got):
+			t.Fatalf("logtest: %s: missing record %d:\n%s", path, i+1, Transcript{want[i]}.Encode())
+		case i >= len(want):
+			t.Fatalf("logtest: %s: unexpected record %d:\n%s", path, i+1, Transcript{got[i]}.Encode())
+		case !bytes.Equal(Transcript{got[i]}.Encode(), Transcript{want[i]}.Encode()):
+			t.Fatalf("logtest: %s: record %d differs\ngot:\n%swant:\n%s", path, i+1, Transcript{got[i]}.Encode(), Transcript{want[i]}.Encode())
+		}
+	}
+}
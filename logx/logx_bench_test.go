@@ -0,0 +1,152 @@
+package logx
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// 只测量编码和写入路径，文件输出替换为 io.Discard
+func newBenchLogger(b *testing.B, opts ...Option) *Logger {
+	b.Helper()
+	l, err := NewLogger(filepath.Join(b.TempDir(), "bench.log"), DEBUG, 100, false, opts...)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Cleanup(l.Close)
+	l.out = io.Discard
+	return l
+}
+
+func benchEntry(fields ...Field) Entry {
+	return Entry{Level: INFO, Time: time.Now(), Message: "request handled", Fields: fields}
+}
+
+func BenchmarkWriteText(b *testing.B) {
+	l := newBenchLogger(b)
+	entry := benchEntry()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		l.write(entry)
+	}
+}
+
+func BenchmarkWriteJSON(b *testing.B) {
+	l := newBenchLogger(b, WithFormat(FormatJSON))
+	entry := benchEntry()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		l.write(entry)
+	}
+}
+
+func BenchmarkWriteJSONFields(b *testing.B) {
+	l := newBenchLogger(b, WithFormat(FormatJSON))
+	entry := benchEntry(
+		String("method", "GET"),
+		String("path", "/api/v1/users"),
+		Int("status", 200),
+		Duration("latency", 1500*time.Microsecond),
+		Bool("cached", true),
+		Err(errors.New("upstream timeout")),
+	)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		l.write(entry)
+	}
+}
+
+func BenchmarkInfoDisabledLevel(b *testing.B) {
+	l := newBenchLogger(b)
+	l.SetLevel(ERROR)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		l.Info("request handled", Int("status", 200))
+	}
+}
+
+func BenchmarkInfoAsync(b *testing.B) {
+	l := newBenchLogger(b)
+	l.StartWorker()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		l.Info("request handled")
+	}
+}
+
+// 多个协程同时写日志时入队的吞吐
+func BenchmarkInfoParallel(b *testing.B) {
+	l := newBenchLogger(b)
+	l.StartWorker()
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			l.Info("request handled")
+		}
+	})
+}
+
+// 写入真实文件，计时包含 Close 等待队列写完，衡量端到端的吞吐
+func BenchmarkInfoParallelFile(b *testing.B) {
+	l, err := NewLogger(filepath.Join(b.TempDir(), "bench.log"), DEBUG, 1024, false)
+	if err != nil {
+		b.Fatal(err)
+	}
+	l.StartWorker()
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			l.Info("request handled")
+		}
+	})
+	l.Close()
+}
+
+// 一批 JSON 日志的编码和写入，对比单协程与多个编码协程
+func BenchmarkWriteBatchJSON(b *testing.B) {
+	for _, workers := range []int{1, 4} {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			l := newBenchLogger(b, WithFormat(FormatJSON), WithWorkers(workers))
+			l.startEncoders()
+			defer l.stopEncoders()
+			batch := make([]Entry, workerBatchSize)
+			for j := range batch {
+				batch[j] = benchEntry(String("method", "GET"), Int("status", 200), Duration("latency", 3*time.Millisecond))
+			}
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				l.writeBatch(batch)
+			}
+		})
+	}
+}
+
+// 带字段的日志入队，对比 WithArena 按块分配字段切片
+func BenchmarkInfoFieldsArena(b *testing.B) {
+	for _, arena := range []bool{false, true} {
+		b.Run(fmt.Sprintf("arena=%v", arena), func(b *testing.B) {
+			var opts []Option
+			if arena {
+				opts = append(opts, WithArena(0))
+			}
+			l := newBenchLogger(b, append(opts, WithStaticFields(Service("billing")))...)
+			l.StartWorker()
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				l.Info("request handled", String("method", "GET"), String("path", "/api/v1/orders"))
+			}
+		})
+	}
+}
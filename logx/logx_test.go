@@ -1,8 +1,43 @@
 package logx
 
 import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/binary"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"flag"
 	"fmt"
+	"io"
+	stdlog "log"
+	"log/slog"
+	"math"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"runtime"
+	"runtime/debug"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
 	"testing"
+	"time"
+	"unicode/utf8"
 )
 
 func TestLogxV2(t *testing.T) {
@@ -22,3 +57,3811 @@ func TestLogxV2(t *testing.T) {
 		log.Debug(fmt.Sprintf("Log line %d", i))
 	}
 }
+
+func TestNewFromEnv(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "env.log")
+	t.Setenv(EnvLevel, "warn")
+	t.Setenv(EnvFormat, "json")
+	t.Setenv(EnvFile, file)
+	t.Setenv(EnvConsole, "false")
+
+	log, err := NewFromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	log.StartWorker()
+	log.Info("dropped")
+	log.Error("kept")
+	log.Close()
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "dropped") || !strings.Contains(string(data), `"level":"ERROR","msg":"kept"`) {
+		t.Fatalf("unexpected output: %s", data)
+	}
+
+	t.Setenv(EnvLevel, "verbose")
+	if _, err := NewFromEnv(); err == nil {
+		t.Fatal("expected error for invalid level")
+	}
+}
+
+func TestSampling(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "sample.log")
+	log, err := NewLogger(file, DEBUG, 1, false, WithSampling(SamplingConfig{Tick: time.Hour, First: 3, Thereafter: 10}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	log.StartWorker()
+	for i := 0; i < 100; i++ {
+		log.Error("hot loop")
+	}
+	log.Close()
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 前3条 + (97/10)=9条
+	if n := strings.Count(string(data), "] hot loop"); n != 12 {
+		t.Fatalf("expected 12 sampled lines, got %d", n)
+	}
+	if !strings.Contains(string(data), "suppressed 88 similar messages: hot loop") {
+		t.Fatalf("missing summary: %s", data)
+	}
+}
+
+func TestDedup(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "dedup.log")
+	log, err := NewLogger(file, DEBUG, 1, false, WithDedup(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	log.StartWorker()
+	for i := 0; i < 5; i++ {
+		log.Warn("retrying")
+	}
+	log.Info("done")
+	log.Close()
+
+	data, _ := os.ReadFile(file)
+	out := string(data)
+	if strings.Count(out, "retrying") != 1 || !strings.Contains(out, "[WARN] last message repeated 4 times") {
+		t.Fatalf("unexpected output: %s", out)
+	}
+}
+
+func TestDiffValues(t *testing.T) {
+	type db struct {
+		Hosts []string `json:"hosts"`
+		Pass  string   `json:"-"`
+	}
+	type config struct {
+		Name  string            `json:"name"`
+		DB    *db               `json:"db"`
+		Extra map[string]int    `json:"extra"`
+		Tags  map[string]string `json:"tags,omitempty"`
+	}
+	oldCfg := config{Name: "a", DB: &db{Hosts: []string{"h1"}, Pass: "x"}, Extra: map[string]int{"k": 1}}
+	newCfg := config{Name: "a", DB: &db{Hosts: []string{"h1", "h2"}, Pass: "y"}, Extra: map[string]int{"k": 2}}
+
+	changes := DiffValues(oldCfg, newCfg)
+	got := fmt.Sprint(changes)
+	want := "[db.hosts[1]: <nil> -> h2 extra.k: 1 -> 2]"
+	if got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+}
+
+func TestRedaction(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "redact.log")
+	log, err := NewLogger(file, DEBUG, 1, false, WithFormat(FormatJSON), WithRedaction(RedactionConfig{
+		Keys:     DefaultRedactKeys,
+		Patterns: []*regexp.Regexp{EmailPattern},
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	log.StartWorker()
+	log.Info("login from bob@example.com", String("password", "hunter2"), String("user", "bob"))
+	log.Diff("config changed", map[string]string{"token": "a"}, map[string]string{"token": "b"})
+	log.Close()
+
+	data, _ := os.ReadFile(file)
+	out := string(data)
+	for _, secret := range []string{"hunter2", "bob@example.com", `"old":"a"`} {
+		if strings.Contains(out, secret) {
+			t.Fatalf("secret %q leaked: %s", secret, out)
+		}
+	}
+	if !strings.Contains(out, `"user":"bob"`) {
+		t.Fatalf("unexpected output: %s", out)
+	}
+}
+
+func TestErrorStream(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "app.log")
+	log, err := NewLogger(file, DEBUG, 1, false, WithErrorStream("", WARN))
+	if err != nil {
+		t.Fatal(err)
+	}
+	log.StartWorker()
+	log.Info("normal")
+	log.Error("broken")
+	log.Close()
+
+	data, err := os.ReadFile(filepath.Join(filepath.Dir(file), "app.err.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "normal") || !strings.Contains(string(data), "[ERROR] broken") {
+		t.Fatalf("unexpected error stream: %s", data)
+	}
+}
+
+func TestConsoleWriter(t *testing.T) {
+	var buf bytes.Buffer
+	log, err := NewLogger(filepath.Join(t.TempDir(), "console.log"), DEBUG, 1, false, WithConsoleWriter(&buf))
+	if err != nil {
+		t.Fatal(err)
+	}
+	log.StartWorker()
+	log.Info("to buffer", Int("n", 1))
+	log.Close()
+
+	// 缓冲区不是终端，不应包含颜色
+	if got := buf.String(); got != "[INFO] to buffer n=1\n" {
+		t.Fatalf("unexpected console output: %q", got)
+	}
+}
+
+func TestClockAndTimeFormat(t *testing.T) {
+	clock := func() time.Time { return time.Date(2024, 1, 2, 3, 4, 5, 0, time.FixedZone("CST", 8*3600)) }
+	var buf bytes.Buffer
+	log, err := NewLogger(filepath.Join(t.TempDir(), "clock.log"), DEBUG, 1, false,
+		WithConsoleWriter(&buf), WithFormat(FormatJSON), WithClock(clock), WithUTC())
+	if err != nil {
+		t.Fatal(err)
+	}
+	log.StartWorker()
+	log.Info("tick")
+	log.Close()
+
+	if got, want := buf.String(), `{"schema_version":1,"time":"2024-01-01T19:04:05Z","level":"INFO","msg":"tick"}`+"\n"; got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
+
+func TestParseLine(t *testing.T) {
+	entry, err := ParseLine([]byte(`2025/05/27 17:13:15 [WARN] logx/logx_test.go:12 slow query took=1.5s sql="select 1"`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry.Level != WARN || entry.Message != "slow query" || entry.File != "logx/logx_test.go" || entry.Line != 12 {
+		t.Fatalf("unexpected entry: %+v", entry)
+	}
+	if got := textFields(entry.Fields); got != ` took=1.5s sql="select 1"` {
+		t.Fatalf("unexpected fields: %s", got)
+	}
+
+	entry, err = ParseLine([]byte(`{"time":"2024-01-01T19:04:05Z","level":"ERROR","msg":"boom","code":7}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry.Level != ERROR || entry.Message != "boom" || len(entry.Fields) != 1 || entry.Fields[0].Key != "code" {
+		t.Fatalf("unexpected entry: %+v", entry)
+	}
+}
+
+func TestReplay(t *testing.T) {
+	input := strings.Join([]string{
+		`2025/05/27 17:13:15 [INFO] first`,
+		`garbage`,
+		`2025/05/27 17:13:16 [ERROR] second`,
+	}, "\n")
+	var got []string
+	n, err := Replay(context.Background(), strings.NewReader(input), func(e Entry) error {
+		got = append(got, e.Message)
+		return nil
+	}, ReplayOptions{})
+	if err != nil || n != 2 || strings.Join(got, ",") != "first,second" {
+		t.Fatalf("n=%d err=%v got=%v", n, err, got)
+	}
+}
+
+type failingSink struct{}
+
+func (failingSink) Write(*Entry, []byte) error { return syscall.ENOSPC }
+func (failingSink) Close() error               { return nil }
+
+// 在写入协程中回调每条日志
+type captureSink func(e *Entry)
+
+func (f captureSink) Write(e *Entry, _ []byte) error { f(e); return nil }
+func (captureSink) Close() error                     { return nil }
+
+// 返回已启动的日志记录器和获取已写入条目的函数
+func newCaptureLogger(t *testing.T, opts ...Option) (*Logger, func() []Entry) {
+	t.Helper()
+	var mu sync.Mutex
+	var entries []Entry
+	sink := captureSink(func(e *Entry) {
+		mu.Lock()
+		entries = append(entries, *e)
+		mu.Unlock()
+	})
+	log, err := NewLogger(filepath.Join(t.TempDir(), "app.log"), DEBUG, 1, false, append(opts, WithSink("capture", sink, DEBUG))...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	log.StartWorker()
+	return log, func() []Entry {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]Entry(nil), entries...)
+	}
+}
+
+func TestErrorHandler(t *testing.T) {
+	var got []error
+	log, err := NewLogger(filepath.Join(t.TempDir(), "app.log"), DEBUG, 1, false,
+		WithSink("full", failingSink{}, DEBUG),
+		WithErrorHandler(func(err error) { got = append(got, err) }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	log.StartWorker()
+	log.Info("hello")
+	log.Close()
+
+	var werr *WriteError
+	if len(got) != 1 || !errors.As(got[0], &werr) || werr.Op != OpSink || !errors.Is(got[0], syscall.ENOSPC) {
+		t.Fatalf("unexpected errors: %v", got)
+	}
+	if n := log.Stats().WriteErrors; n != 1 {
+		t.Fatalf("expected 1 write error, got %d", n)
+	}
+}
+
+// 生成 CA 以及由它签发的证书，写入 dir 下的 name.crt 和 name.key
+func writeTestCert(t *testing.T, dir, name string, ca *x509.Certificate, caKey *ecdsa.PrivateKey, tmpl *x509.Certificate) (*x509.Certificate, *ecdsa.PrivateKey) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parent, parentKey := tmpl, key
+	if ca != nil {
+		parent, parentKey = ca, caKey
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(filepath.Join(dir, name+".crt"), pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600)
+	os.WriteFile(filepath.Join(dir, name+".key"), pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600)
+	cert, _ := x509.ParseCertificate(der)
+	return cert, key
+}
+
+func TestTCPSinkMutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	notAfter := time.Now().Add(time.Hour)
+	ca, caKey := writeTestCert(t, dir, "ca", nil, nil, &x509.Certificate{
+		SerialNumber: big.NewInt(1), Subject: pkix.Name{CommonName: "test ca"}, NotAfter: notAfter,
+		IsCA: true, BasicConstraintsValid: true, KeyUsage: x509.KeyUsageCertSign,
+	})
+	writeTestCert(t, dir, "server", ca, caKey, &x509.Certificate{
+		SerialNumber: big.NewInt(2), DNSNames: []string{"collector.internal"}, NotAfter: notAfter,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	})
+	writeTestCert(t, dir, "client", ca, caKey, &x509.Certificate{
+		SerialNumber: big.NewInt(3), Subject: pkix.Name{CommonName: "app"}, NotAfter: notAfter,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	})
+
+	serverCert, err := tls.LoadX509KeyPair(filepath.Join(dir, "server.crt"), filepath.Join(dir, "server.key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(ca)
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+		Certificates: []tls.Certificate{serverCert},
+		ClientCAs:    pool,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	received := make(chan string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		line, _ := bufio.NewReader(conn).ReadString('\n')
+		received <- line
+	}()
+
+	sink, err := NewTCPSink(ln.Addr().String(), NetSinkConfig{TLS: &TLSConfig{
+		CAFile:     filepath.Join(dir, "ca.crt"),
+		CertFile:   filepath.Join(dir, "client.crt"),
+		KeyFile:    filepath.Join(dir, "client.key"),
+		ServerName: "collector.internal",
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	log, err := NewLogger(filepath.Join(dir, "app.log"), DEBUG, 1, false, WithSink("collector", sink, DEBUG))
+	if err != nil {
+		t.Fatal(err)
+	}
+	log.StartWorker()
+	log.Info("shipped over mtls")
+	log.Close()
+
+	select {
+	case line := <-received:
+		if !strings.Contains(line, "shipped over mtls") {
+			t.Fatalf("unexpected line: %q", line)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("collector did not receive the log line")
+	}
+}
+
+func TestWorkersPreserveOrder(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	log, err := NewLogger(path, DEBUG, 100, false, WithFormat(FormatJSON), WithWorkers(4))
+	if err != nil {
+		t.Fatal(err)
+	}
+	log.StartWorker()
+	const n = 5000
+	for i := 0; i < n; i++ {
+		log.Info("seq", Int("i", i))
+	}
+	log.Close()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != n {
+		t.Fatalf("expected %d lines, got %d", n, len(lines))
+	}
+	for i, line := range lines {
+		if !strings.Contains(line, fmt.Sprintf(`"i":%d}`, i)) {
+			t.Fatalf("line %d out of order: %s", i, line)
+		}
+	}
+}
+
+// 只支持无认证 CONNECT 的 SOCKS5 代理，记录收到的目标地址
+func startSOCKS5Proxy(t *testing.T, targets chan<- string) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				buf := make([]byte, 262)
+				io.ReadFull(conn, buf[:2])
+				io.ReadFull(conn, buf[:buf[1]])
+				conn.Write([]byte{0x05, 0x00})
+				io.ReadFull(conn, buf[:5]) // VER CMD RSV ATYP=3 LEN
+				n := int(buf[4])
+				io.ReadFull(conn, buf[:n+2])
+				host := string(buf[:n])
+				port := binary.BigEndian.Uint16(buf[n:])
+				addr := net.JoinHostPort(host, strconv.Itoa(int(port)))
+				targets <- addr
+				upstream, err := net.Dial("tcp", addr)
+				if err != nil {
+					conn.Write([]byte{0x05, 0x05, 0x00, 0x01, 0, 0, 0, 0, 0, 0})
+					return
+				}
+				defer upstream.Close()
+				conn.Write([]byte{0x05, 0x00, 0x00, 0x01, 0, 0, 0, 0, 0, 0})
+				go io.Copy(upstream, conn)
+				io.Copy(conn, upstream)
+			}()
+		}
+	}()
+	return ln.Addr().String()
+}
+
+func TestTCPSinkSOCKS5Proxy(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	received := make(chan string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		line, _ := bufio.NewReader(conn).ReadString('\n')
+		received <- line
+	}()
+
+	targets := make(chan string, 1)
+	proxy := startSOCKS5Proxy(t, targets)
+	_, port, _ := net.SplitHostPort(ln.Addr().String())
+	target := net.JoinHostPort("localhost", port)
+	sink, err := NewTCPSink(target, NetSinkConfig{Proxy: "socks5://" + proxy})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sink.Close()
+	if err := sink.Write(&Entry{}, []byte("via proxy\n")); err != nil {
+		t.Fatal(err)
+	}
+	if got := <-targets; got != target {
+		t.Fatalf("proxy asked to connect to %q, want %q", got, target)
+	}
+	select {
+	case line := <-received:
+		if line != "via proxy\n" {
+			t.Fatalf("unexpected line: %q", line)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("server did not receive the line")
+	}
+}
+
+func TestHTTPSinkTokenRefresh(t *testing.T) {
+	var mu sync.Mutex
+	accepted := "token-1"
+	var got []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if r.Header.Get("Authorization") != "Bearer "+accepted {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		got = append(got, string(body))
+	}))
+	defer srv.Close()
+
+	issued := 0
+	creds := NewBearerToken(func(context.Context) (string, time.Time, error) {
+		issued++
+		return fmt.Sprintf("token-%d", issued), time.Now().Add(time.Hour), nil
+	}, time.Minute)
+	sink, err := NewHTTPSink(srv.URL, NetSinkConfig{Credentials: creds})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sink.Close()
+
+	if err := sink.Write(&Entry{}, []byte("first\n")); err != nil {
+		t.Fatal(err)
+	}
+	// 服务端轮换令牌后，旧令牌被拒绝，sink 应刷新后重试
+	mu.Lock()
+	accepted = "token-2"
+	mu.Unlock()
+	if err := sink.Write(&Entry{}, []byte("second\n")); err != nil {
+		t.Fatal(err)
+	}
+	if issued != 2 || len(got) != 2 || got[1] != "second\n" {
+		t.Fatalf("issued %d tokens, server got %q", issued, got)
+	}
+}
+
+func TestMaxEntrySize(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	log, err := NewLogger(path, DEBUG, 1, false, WithFormat(FormatJSON), WithMaxEntrySize(200))
+	if err != nil {
+		t.Fatal(err)
+	}
+	log.StartWorker()
+	log.Info("dump", String("payload", strings.Repeat("数据", 1000)), Int("status", 500))
+	log.Info("small", String("k", "v"))
+	log.Close()
+
+	data, _ := os.ReadFile(path)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 lines, got %d", len(lines))
+	}
+	if len(lines[0]) > 400 || !strings.Contains(lines[0], "...[truncated, 6000 bytes]") || !strings.Contains(lines[0], `"status":500`) {
+		t.Fatalf("unexpected truncated line (%d bytes): %s", len(lines[0]), lines[0])
+	}
+	if !utf8.ValidString(lines[0]) {
+		t.Fatal("truncation split a multi-byte character")
+	}
+	if !strings.Contains(lines[1], `"k":"v"`) || log.Stats().Truncated != 1 {
+		t.Fatalf("unexpected: %s, truncated=%d", lines[1], log.Stats().Truncated)
+	}
+}
+
+type batchRecorder struct {
+	mu      sync.Mutex
+	batches []string
+	counts  []int
+}
+
+func (r *batchRecorder) Flush(body []byte, count int, encoding string) error {
+	if encoding != "" {
+		c, _ := LookupCompressor(encoding)
+		zr, err := c.NewReader(bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		body, _ = io.ReadAll(zr)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.batches = append(r.batches, string(body))
+	r.counts = append(r.counts, count)
+	return nil
+}
+
+func TestBatchSink(t *testing.T) {
+	rec := &batchRecorder{}
+	sink := NewBatchSink(rec, BatchConfig{MaxEntries: 3, MaxBytes: 10, MaxAge: 20 * time.Millisecond, Compressor: Gzip})
+	for _, line := range []string{"a\n", "b\n", "c\n", "dddddddd\n", "e\n"} {
+		sink.Write(&Entry{}, []byte(line))
+	}
+	time.Sleep(100 * time.Millisecond) // "e" 由 MaxAge 触发发送
+	sink.Write(&Entry{}, []byte("f\n"))
+	sink.Close()
+
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	want := []string{"a\nb\nc\n", "dddddddd\n", "e\n", "f\n"}
+	if fmt.Sprint(rec.batches) != fmt.Sprint(want) || fmt.Sprint(rec.counts) != "[3 1 1 1]" {
+		t.Fatalf("unexpected batches %q counts %v", rec.batches, rec.counts)
+	}
+}
+
+type failingFlusher struct{}
+
+func (failingFlusher) Flush([]byte, int, string) error { return errors.New("collector unavailable") }
+
+func TestBatchSinkTimerError(t *testing.T) {
+	errs := make(chan error, 4)
+	log, err := NewLogger(filepath.Join(t.TempDir(), "app.log"), INFO, 1, false,
+		WithSink("batch", NewBatchSink(failingFlusher{}, BatchConfig{MaxAge: 10 * time.Millisecond}), INFO),
+		WithErrorHandler(func(err error) { errs <- err }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	log.StartWorker()
+	defer log.Close()
+	// 之后没有新的日志，定时发送的失败也要立即报告
+	log.Info("last entry")
+	select {
+	case err := <-errs:
+		var werr *WriteError
+		if !errors.As(err, &werr) || werr.Op != OpSink || werr.Name != "batch" {
+			t.Fatalf("unexpected error %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("timer flush error not reported")
+	}
+
+	// 没有设置回调时仍在下一次 Write 返回
+	sink := NewBatchSink(failingFlusher{}, BatchConfig{MaxAge: 10 * time.Millisecond})
+	sink.Write(&Entry{}, []byte("a\n"))
+	time.Sleep(50 * time.Millisecond)
+	if err := sink.Write(&Entry{}, []byte("b\n")); err == nil {
+		t.Fatal("expected the timer flush error on the next Write")
+	}
+	sink.Close()
+}
+
+func TestWriter(t *testing.T) {
+	var lines []string
+	log, err := NewLogger(filepath.Join(t.TempDir(), "app.log"), DEBUG, 1, false,
+		WithSink("capture", captureSink(func(e *Entry) { lines = append(lines, levelString(e.Level)+" "+e.Message) }), DEBUG))
+	if err != nil {
+		t.Fatal(err)
+	}
+	log.StartWorker()
+	std := stdlog.New(log.Writer(ERROR), "http: ", 0)
+	std.Printf("TLS handshake error from %s", "10.0.0.1:5555")
+	w := log.Writer(WARN)
+	fmt.Fprint(w, "partial ")
+	fmt.Fprint(w, "line\r\nsecond\n\nthird")
+	w.(io.Closer).Close()
+	log.Close()
+
+	want := []string{"ERROR http: TLS handshake error from 10.0.0.1:5555", "WARN partial line", "WARN second", "WARN third"}
+	if fmt.Sprint(lines) != fmt.Sprint(want) {
+		t.Fatalf("got %q, want %q", lines, want)
+	}
+}
+
+func TestBackfill(t *testing.T) {
+	dir := t.TempDir()
+	logPath := filepath.Join(dir, "app.log")
+	os.WriteFile(logPath+".20240101_000000.log", []byte(
+		`{"time":"2024-01-01T00:00:01Z","level":"INFO","msg":"one"}`+"\n"+
+			`{"time":"2024-01-01T00:00:02Z","level":"DEBUG","msg":"skipped"}`+"\n"), 0644)
+	archived := logPath + ".20240102_000000.log"
+	os.WriteFile(archived, []byte(`{"time":"2024-01-02T00:00:01Z","level":"ERROR","msg":"two"}`+"\n"), 0644)
+	if _, err := CompressFile(Gzip, archived); err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(logPath, []byte(`{"time":"2024-01-03T00:00:01Z","level":"ERROR","msg":"active"}`+"\n"), 0644)
+
+	paths, err := Archives(logPath)
+	if err != nil || len(paths) != 2 || !strings.HasSuffix(paths[1], ".log.gzip") {
+		t.Fatalf("unexpected archives %v: %v", paths, err)
+	}
+	var got []string
+	n, err := Backfill(context.Background(), paths, captureSink(func(e *Entry) {
+		got = append(got, e.Time.UTC().Format(time.RFC3339)+" "+e.Message)
+	}), BackfillOptions{MinLevel: INFO})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"2024-01-01T00:00:01Z one", "2024-01-02T00:00:01Z two"}
+	if n != 2 || fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Fatalf("backfilled %d: %q", n, got)
+	}
+}
+
+type flakyDeliverer struct {
+	mu       sync.Mutex
+	failures int // 前几次投递失败
+	seen     map[string]bool
+	lines    []string
+}
+
+func (d *flakyDeliverer) Deliver(records []SpoolRecord) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.failures > 0 {
+		d.failures--
+		return errors.New("collector unavailable")
+	}
+	for _, r := range records {
+		if !d.seen[r.ID] {
+			d.seen[r.ID] = true
+			d.lines = append(d.lines, string(r.Line))
+		}
+	}
+	return nil
+}
+
+func waitAcked(t *testing.T, s *SpoolSink, seq uint64) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for s.Stats().Acked < seq {
+		if time.Now().After(deadline) {
+			t.Fatalf("spool not acknowledged: %+v", s.Stats())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestSpoolSinkAtLeastOnce(t *testing.T) {
+	dir := t.TempDir()
+	cfg := SpoolConfig{Dir: dir, SegmentSize: 64, BatchSize: 4, RetryMin: time.Millisecond, RetryMax: 5 * time.Millisecond}
+	d := &flakyDeliverer{failures: 1 << 30, seen: map[string]bool{}}
+	s, err := NewSpoolSink(d, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i <= 6; i++ {
+		s.Write(&Entry{}, []byte(fmt.Sprintf("line %d\n", i)))
+	}
+	// 采集端一直不可用时关闭，日志留在磁盘上
+	s.Close()
+	if st := s.Stats(); st.Pending != 6 || st.LastError == nil {
+		t.Fatalf("unexpected stats before restart: %+v", st)
+	}
+
+	d.failures = 2
+	s, err = NewSpoolSink(d, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	for i := 7; i <= 10; i++ {
+		s.Write(&Entry{}, []byte(fmt.Sprintf("line %d\n", i)))
+	}
+	waitAcked(t, s, 10)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for i, line := range d.lines {
+		if line != fmt.Sprintf("line %d\n", i+1) {
+			t.Fatalf("unexpected delivery order: %q", d.lines)
+		}
+	}
+	if len(d.lines) != 10 {
+		t.Fatalf("expected 10 delivered lines, got %q", d.lines)
+	}
+	if segs, _ := filepath.Glob(filepath.Join(dir, "spool-*.seg")); len(segs) > 2 {
+		t.Fatalf("acknowledged segments not removed: %v", segs)
+	}
+}
+
+// 只写入一半后返回错误的段文件，truncateErr 不为空时截断也失败
+type tornFile struct {
+	spoolFile
+	failures    int
+	truncateErr error
+}
+
+func (f *tornFile) Write(p []byte) (int, error) {
+	if f.failures > 0 {
+		f.failures--
+		n, _ := f.spoolFile.Write(p[:len(p)/2])
+		return n, syscall.EIO
+	}
+	return f.spoolFile.Write(p)
+}
+
+func (f *tornFile) Truncate(size int64) error {
+	if f.truncateErr != nil {
+		return f.truncateErr
+	}
+	return f.spoolFile.Truncate(size)
+}
+
+func TestSpoolSinkTornWrite(t *testing.T) {
+	for _, truncateErr := range []error{nil, syscall.EROFS} {
+		dir := t.TempDir()
+		cfg := SpoolConfig{Dir: dir, RetryMin: time.Millisecond, RetryMax: 5 * time.Millisecond}
+		d := &flakyDeliverer{seen: map[string]bool{}}
+		s, err := NewSpoolSink(d, cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s.Write(&Entry{}, []byte("line 1\n"))
+		s.mu.Lock()
+		s.seg = &tornFile{spoolFile: s.seg, failures: 1, truncateErr: truncateErr}
+		s.mu.Unlock()
+		if err := s.Write(&Entry{}, []byte("torn line\n")); err == nil {
+			t.Fatal("expected the failed write to be reported")
+		}
+		for i := 2; i <= 3; i++ {
+			if err := s.Write(&Entry{}, []byte(fmt.Sprintf("line %d\n", i))); err != nil {
+				t.Fatal(err)
+			}
+		}
+		waitAcked(t, s, 3)
+		s.Close()
+		d.mu.Lock()
+		if fmt.Sprint(d.lines) != fmt.Sprint([]string{"line 1\n", "line 2\n", "line 3\n"}) {
+			t.Fatalf("truncate error %v: delivered %q", truncateErr, d.lines)
+		}
+		d.mu.Unlock()
+
+		// 重启后不会因为损坏的记录丢掉之后的日志
+		s, err = NewSpoolSink(d, cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s.Write(&Entry{}, []byte("line 4\n"))
+		waitAcked(t, s, 4)
+		s.Close()
+		if st := s.Stats(); st.Written != 4 || st.LastError != nil {
+			t.Fatalf("truncate error %v: stats after restart %+v", truncateErr, st)
+		}
+	}
+}
+
+func TestSubscribe(t *testing.T) {
+	log, err := NewLogger(filepath.Join(t.TempDir(), "app.log"), DEBUG, 1, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	log.StartWorker()
+	warnings, cancelWarnings := log.Subscribe(WARN)
+	all, _ := log.Subscribe(DEBUG)
+	log.Info("started")
+	log.Warn("disk almost full", Int("percent", 91))
+	log.Drain(context.Background())
+	cancelWarnings()
+	cancelWarnings()
+	log.Error("after cancel")
+	log.Close()
+
+	var got []string
+	for e := range warnings {
+		got = append(got, e.Message)
+	}
+	if fmt.Sprint(got) != "[disk almost full]" {
+		t.Fatalf("unexpected warnings: %q", got)
+	}
+	got = nil
+	for e := range all {
+		got = append(got, e.Message)
+	}
+	if fmt.Sprint(got) != "[started disk almost full after cancel]" {
+		t.Fatalf("unexpected entries: %q", got)
+	}
+}
+
+func TestBatchSinkPriority(t *testing.T) {
+	rec := &batchRecorder{}
+	sink := NewBatchSink(rec, BatchConfig{MaxAge: time.Hour, Priority: []PriorityRule{PriorityTags("security"), PriorityLevel(ERROR)}})
+	write := func(e Entry) { sink.Write(&e, []byte(e.Message+"\n")) }
+	write(Entry{Level: INFO, Message: "a"})
+	write(Entry{Level: INFO, Message: "login failed", Fields: []Field{Tags("security")}})
+	write(Entry{Level: INFO, Message: "b"})
+	write(Entry{Level: ERROR, Message: "c"})
+	write(Entry{Level: INFO, Message: "d"})
+
+	rec.mu.Lock()
+	got := fmt.Sprint(rec.batches)
+	rec.mu.Unlock()
+	if got != fmt.Sprint([]string{"a\nlogin failed\n", "b\nc\n"}) {
+		t.Fatalf("unexpected batches before close: %q", got)
+	}
+	sink.Close()
+	if n := len(rec.batches); n != 3 {
+		t.Fatalf("expected remaining entry flushed on close, got %d batches", n)
+	}
+}
+
+func TestJournaldSink(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("journald is only supported on linux")
+	}
+	path := filepath.Join(t.TempDir(), "journal.sock")
+	ln, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		t.Skipf("unixgram not available: %v", err)
+	}
+	defer ln.Close()
+
+	sink, err := NewJournaldSink(JournaldConfig{SocketPath: path, Identifier: "billing", FieldPrefix: "app_"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sink.Close()
+	entry := Entry{Level: WARN, Message: "slow query", Fields: []Field{String("db.table", "orders"), String("sql", "SELECT *\nFROM orders")}}
+	if err := sink.Write(&entry, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	buf := make([]byte, 4096)
+	n, err := ln.Read(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "MESSAGE=slow query\nPRIORITY=4\nSYSLOG_IDENTIFIER=billing\nAPP_DB_TABLE=orders\nAPP_SQL\n" +
+		"\x14\x00\x00\x00\x00\x00\x00\x00SELECT *\nFROM orders\n"
+	if got := string(buf[:n]); got != want {
+		t.Fatalf("unexpected datagram:\n%q\nwant\n%q", got, want)
+	}
+}
+
+func TestProgressAware(t *testing.T) {
+	var buf bytes.Buffer
+	log, err := NewLogger(filepath.Join(t.TempDir(), "console.log"), DEBUG, 1, false, WithConsoleWriter(&buf), WithProgressAware(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	log.StartWorker()
+	bar := log.ProgressWriter()
+	fmt.Fprint(bar, "\r[==    ] 30%")
+	log.Info("downloaded part 1")
+	log.Drain(context.Background())
+	fmt.Fprint(bar, "\r[======] 100%\n")
+	log.Info("done")
+	log.Close()
+
+	want := "\r[==    ] 30%" + clearLine + "[INFO] downloaded part 1\n[==    ] 30%" + "\r[======] 100%\n" + "[INFO] done\n"
+	if got := buf.String(); got != want {
+		t.Fatalf("unexpected console output:\n%q\nwant\n%q", got, want)
+	}
+}
+
+func TestGELFSinkChunking(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	sink, err := NewGELFSink(conn.LocalAddr().String(), GELFConfig{Host: "web-1", ChunkSize: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sink.Close()
+	entry := Entry{Level: ERROR, Time: time.Unix(1700000000, 500000000), Message: "panic: boom\ngoroutine 1",
+		Fields: []Field{Int("status", 500), String("path", strings.Repeat("/a", 60)), Bool("retry", false)}}
+	if err := sink.Write(&entry, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// 按序号重组分片
+	var chunks [][]byte
+	buf := make([]byte, 2048)
+	for received := 0; chunks == nil || received < len(chunks); received++ {
+		conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n > 100 || buf[0] != 0x1e || buf[1] != 0x0f {
+			t.Fatalf("invalid chunk of %d bytes", n)
+		}
+		if chunks == nil {
+			chunks = make([][]byte, buf[11])
+		}
+		chunks[buf[10]] = append([]byte(nil), buf[12:n]...)
+	}
+	var msg map[string]interface{}
+	if err := json.Unmarshal(bytes.Join(chunks, nil), &msg); err != nil {
+		t.Fatal(err)
+	}
+	if msg["short_message"] != "panic: boom" || msg["level"] != 3.0 || msg["_status"] != 500.0 || msg["_retry"] != "false" || msg["host"] != "web-1" || msg["timestamp"] != 1700000000.5 {
+		t.Fatalf("unexpected gelf message: %v", msg)
+	}
+}
+
+func TestGELFSinkCompression(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	for _, c := range []Compressor{Gzip, Deflate} {
+		sink, err := NewGELFSink(conn.LocalAddr().String(), GELFConfig{Host: "web-1", Compressor: c})
+		if err != nil {
+			t.Fatal(err)
+		}
+		entry := Entry{Level: INFO, Time: time.Unix(1700000000, 0), Message: "compressed " + c.Name()}
+		if err := sink.Write(&entry, nil); err != nil {
+			t.Fatal(err)
+		}
+		sink.Close()
+
+		buf := make([]byte, 2048)
+		conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r, err := c.NewReader(bytes.NewReader(buf[:n]))
+		if err != nil {
+			t.Fatalf("%s: %v", c.Name(), err)
+		}
+		var msg map[string]interface{}
+		if err := json.NewDecoder(r).Decode(&msg); err != nil || msg["short_message"] != "compressed "+c.Name() {
+			t.Fatalf("%s: unexpected message %v, %v", c.Name(), msg, err)
+		}
+	}
+	if _, err := NewGELFSink("127.0.0.1:12201", GELFConfig{Network: "tcp", Compressor: Gzip}); err == nil {
+		t.Fatal("expected compression over tcp to be rejected")
+	}
+}
+
+// 模拟异步生产者，key 为 bad 的消息投递失败，Close 时才回调
+type fakeProducer struct {
+	mu      sync.Mutex
+	msgs    []*KafkaMessage
+	pending []func(error)
+}
+
+func (p *fakeProducer) Produce(msg *KafkaMessage, done func(error)) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.msgs = append(p.msgs, msg)
+	var err error
+	if string(msg.Key) == "bad" {
+		err = errors.New("kafka: leader not available")
+	}
+	p.pending = append(p.pending, func(error) { done(err) })
+}
+
+func (p *fakeProducer) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, done := range p.pending {
+		done(nil)
+	}
+	return nil
+}
+
+func TestKafkaSink(t *testing.T) {
+	producer := &fakeProducer{}
+	sink, err := NewKafkaSink(producer, KafkaConfig{Topic: "logs", Key: KeyByField("request_id", "service")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var mu sync.Mutex
+	var got []error
+	log, err := NewLogger(filepath.Join(t.TempDir(), "app.log"), DEBUG, 1, false,
+		WithFormat(FormatJSON), WithSink("kafka", sink, INFO),
+		WithErrorHandler(func(err error) { mu.Lock(); got = append(got, err); mu.Unlock() }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	log.StartWorker()
+	log.Info("a", String("request_id", "r1"), String("service", "api"))
+	log.Info("b", String("service", "api"))
+	log.Info("c")
+	log.Info("d", String("request_id", "bad"))
+	log.Close()
+
+	keys := []string{"r1", "api", "", "bad"}
+	if len(producer.msgs) != len(keys) {
+		t.Fatalf("expected %d messages, got %d", len(keys), len(producer.msgs))
+	}
+	for i, msg := range producer.msgs {
+		if msg.Topic != "logs" || string(msg.Key) != keys[i] || !bytes.HasSuffix(msg.Value, []byte("\n")) {
+			t.Fatalf("unexpected message %d: %+v", i, msg)
+		}
+	}
+	var werr *WriteError
+	if len(got) != 1 || !errors.As(got[0], &werr) || werr.Op != OpSink || werr.Name != "kafka" {
+		t.Fatalf("unexpected errors: %v", got)
+	}
+}
+
+func TestCLIMode(t *testing.T) {
+	fs := flag.NewFlagSet("tool", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	mode := LogFormatFlag(fs)
+	if *mode != CLIHuman {
+		t.Fatalf("expected default human, got %q", *mode)
+	}
+	if err := fs.Parse([]string{"--log-format", "bogus"}); err == nil {
+		t.Fatal("expected error for unknown format")
+	}
+	if err := fs.Parse([]string{"--log-format=quiet"}); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	path := filepath.Join(t.TempDir(), "app.log")
+	log, err := NewLogger(path, DEBUG, 1, false, WithCLIMode(*mode), WithConsoleWriter(&buf))
+	if err != nil {
+		t.Fatal(err)
+	}
+	log.StartWorker()
+	log.Info("progress")
+	log.Error("failed")
+	log.Close()
+	if got := buf.String(); got != "[ERROR] failed\n" {
+		t.Fatalf("unexpected console output %q", got)
+	}
+	data, _ := os.ReadFile(path)
+	if !strings.Contains(string(data), "progress") {
+		t.Fatalf("expected file to keep info entries, got %q", data)
+	}
+}
+
+func TestAuditLog(t *testing.T) {
+	key := []byte("secret")
+	for _, format := range []Format{FormatText, FormatJSON} {
+		path := filepath.Join(t.TempDir(), "audit.log")
+		log, err := NewLogger(path, DEBUG, 1, false, WithFormat(format), WithAudit(key))
+		if err != nil {
+			t.Fatal(err)
+		}
+		log.StartWorker()
+		for i := 0; i < 5; i++ {
+			log.Info("transfer", Int("amount", 100*i), String("user", "alice"))
+		}
+		log.Close()
+
+		summary, err := VerifyAuditLog(path, key)
+		if err != nil || summary.Entries != 5 || summary.FirstPrev != AuditGenesis {
+			t.Fatalf("%v: unexpected result %+v, %v", format, summary, err)
+		}
+		if _, err := VerifyAuditLog(path, []byte("wrong")); err == nil {
+			t.Fatalf("%v: expected wrong key to fail", format)
+		}
+
+		data, _ := os.ReadFile(path)
+		lines := strings.SplitAfter(string(data), "\n")
+		sep := "="
+		if format == FormatJSON {
+			sep = `":`
+		}
+		tampered := strings.Replace(string(data), "amount"+sep+"200", "amount"+sep+"900", 1)
+		removed := strings.Join(append(lines[:2:2], lines[3:]...), "")
+		for name, content := range map[string]string{"modified": tampered, "removed": removed} {
+			os.WriteFile(path, []byte(content), 0644)
+			var aerr *AuditError
+			if _, err := VerifyAuditLog(path, key); !errors.As(err, &aerr) || aerr.Line != 3 {
+				t.Fatalf("%v: expected %s entry to fail at line 3, got %v", format, name, err)
+			}
+		}
+	}
+}
+
+func TestAuditChainAcrossRestarts(t *testing.T) {
+	key := []byte("secret")
+	path := filepath.Join(t.TempDir(), "audit.log")
+	run := func(n int) {
+		log, err := NewLogger(path, DEBUG, 1, false, WithFormat(FormatJSON), WithAudit(key))
+		if err != nil {
+			t.Fatal(err)
+		}
+		log.StartWorker()
+		for i := 0; i < n; i++ {
+			log.Info("transfer", Int("amount", i))
+		}
+		log.Close()
+	}
+	verify := func(path string) AuditSummary {
+		t.Helper()
+		summary, err := VerifyAuditLog(path, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return summary
+	}
+
+	run(3)
+	first := verify(path)
+	// 重启时旧文件被切割为归档，新文件接着上一次的最后一条
+	run(2)
+	archives, _ := Archives(path)
+	if len(archives) != 1 {
+		t.Fatalf("archives = %v", archives)
+	}
+	if archived := verify(archives[0]); archived != first {
+		t.Fatalf("archive %+v differs from the first run %+v", archived, first)
+	}
+	second := verify(path)
+	if second.Entries != 2 || second.FirstPrev != first.LastHash {
+		t.Fatalf("restart did not continue the chain: first %+v, second %+v", first, second)
+	}
+
+	// 当前文件不在时从最新的归档继续
+	os.Rename(path, path+".moved")
+	run(1)
+	if third := verify(path); third.FirstPrev != first.LastHash {
+		t.Fatalf("expected the newest archive's hash %s, got %+v", first.LastHash, third)
+	}
+}
+
+func TestDefaultLogDir(t *testing.T) {
+	env := map[string]string{"XDG_STATE_HOME": "/xdg/state", "ProgramData": `D:\ProgramData`}
+	getenv := func(k string) string { return env[k] }
+	noenv := func(string) string { return "" }
+	cases := []struct {
+		goos   string
+		root   bool
+		getenv func(string) string
+		want   string
+	}{
+		{"linux", false, getenv, filepath.Join("/xdg/state", "tool")},
+		{"linux", false, noenv, filepath.Join("/home/u", ".local", "state", "tool")},
+		{"freebsd", true, getenv, filepath.Join("/var/log", "tool")},
+		{"darwin", false, getenv, filepath.Join("/home/u", "Library", "Logs", "tool")},
+		{"darwin", true, getenv, filepath.Join("/Library/Logs", "tool")},
+		{"windows", false, getenv, filepath.Join(`D:\ProgramData`, "tool", "logs")},
+	}
+	for _, c := range cases {
+		if got := logDirFor(c.goos, "tool", "/home/u", c.root, c.getenv); got != c.want {
+			t.Errorf("%s root=%v: got %q, want %q", c.goos, c.root, got, c.want)
+		}
+	}
+	if got := logDirFor("linux", "tool", "", false, noenv); got != "logs" {
+		t.Errorf("expected fallback to logs, got %q", got)
+	}
+}
+
+func TestEncryption(t *testing.T) {
+	keys := &StaticKeys{Current: "k1", Keys: map[string][]byte{
+		"k1": bytes.Repeat([]byte{1}, 32),
+		"k2": bytes.Repeat([]byte{2}, 16),
+	}}
+	path := filepath.Join(t.TempDir(), "app.log")
+	for i, id := range []string{"k1", "k2"} {
+		keys.Current = id
+		// 第二次创建时旧文件被切割，新文件使用新密钥
+		log, err := NewLogger(path, DEBUG, 1, false, WithEncryption(keys))
+		if err != nil {
+			t.Fatal(err)
+		}
+		log.StartWorker()
+		for j := 0; j < 3; j++ {
+			log.Info("card charged", Int("run", i), Int("n", j))
+		}
+		log.Close()
+	}
+
+	archives, err := Archives(path)
+	if err != nil || len(archives) != 1 {
+		t.Fatalf("expected 1 archive, got %v, %v", archives, err)
+	}
+	for i, p := range []string{archives[0], path} {
+		data, _ := os.ReadFile(p)
+		if !strings.HasPrefix(string(data), encryptMagic) || strings.Contains(string(data), "card charged") {
+			t.Fatalf("%s is not encrypted", p)
+		}
+		r, err := OpenEncrypted(p, keys)
+		if err != nil {
+			t.Fatal(err)
+		}
+		plain, err := io.ReadAll(r)
+		r.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n := strings.Count(string(plain), "card charged run="+strconv.Itoa(i)); n != 3 {
+			t.Fatalf("%s: expected 3 decrypted lines, got %q", p, plain)
+		}
+	}
+
+	data, _ := os.ReadFile(path)
+	data[len(data)-1] ^= 1
+	if _, err := io.ReadAll(NewDecryptReader(bytes.NewReader(data), keys)); err == nil {
+		t.Fatal("expected tampered record to fail")
+	}
+}
+
+func TestStrictPaths(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := NewLogger(dir+"/logs/../../etc/app.log", DEBUG, 1, false); !errors.Is(err, ErrUnsafePath) {
+		t.Fatalf("expected traversal to be rejected, got %v", err)
+	}
+	log, err := NewLogger(filepath.Join(dir, "logs", "app.log"), DEBUG, 1, false, WithStrictPaths())
+	if err != nil {
+		t.Fatal(err)
+	}
+	log.Close()
+
+	target := filepath.Join(dir, "target.log")
+	os.WriteFile(target, nil, 0644)
+	link := filepath.Join(dir, "link.log")
+	if err := os.Symlink(target, link); err != nil {
+		t.Skip("symlinks not supported:", err)
+	}
+	if _, err := NewLogger(link, DEBUG, 1, false, WithStrictPaths()); !errors.Is(err, ErrUnsafePath) {
+		t.Fatalf("expected symlink to be rejected, got %v", err)
+	}
+	linkDir := filepath.Join(dir, "linkdir")
+	os.Symlink(dir, linkDir)
+	if _, err := NewLogger(filepath.Join(linkDir, "app.log"), DEBUG, 1, false, WithStrictPaths()); !errors.Is(err, ErrUnsafePath) {
+		t.Fatalf("expected symlinked directory to be rejected, got %v", err)
+	}
+	if runtime.GOOS != "windows" {
+		os.Chmod(dir, 0777)
+		if _, err := NewLogger(filepath.Join(dir, "app.log"), DEBUG, 1, false, WithStrictPaths()); !errors.Is(err, ErrUnsafePath) {
+			t.Fatalf("expected world-writable directory to be rejected, got %v", err)
+		}
+	}
+}
+
+func TestBinaryFormat(t *testing.T) {
+	dir := t.TempDir()
+	base := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	var console bytes.Buffer
+	sizes := map[Format]int64{}
+	for _, format := range []Format{FormatJSON, FormatBinary} {
+		path := filepath.Join(dir, format.String()+".log")
+		now := base
+		log, err := NewLogger(path, DEBUG, 10, false, WithFormat(format), WithConsoleWriter(&console),
+			WithClock(func() time.Time { now = now.Add(time.Second); return now }))
+		if err != nil {
+			t.Fatal(err)
+		}
+		log.StartWorker()
+		for i := 0; i < 200; i++ {
+			level := INFO
+			if i%10 == 0 {
+				level = ERROR
+			}
+			log.log(level, "request handled", []Field{String("path", "/api/orders"), Int("status", 200+i%3), Int64("bytes", -int64(i)*1000),
+				Any("latency", 1.5), Bool("cached", i%2 == 0), Any("at", base), Any("tags", []string{"a", "b"})})
+		}
+		log.Close()
+		stat, _ := os.Stat(path)
+		sizes[format] = stat.Size()
+	}
+	if sizes[FormatBinary] > sizes[FormatJSON]*6/10 {
+		t.Errorf("binary log is not compact enough: %d bytes vs %d bytes JSON", sizes[FormatBinary], sizes[FormatJSON])
+	}
+	if !strings.Contains(console.String(), "[INFO] request handled path=/api/orders") {
+		t.Fatalf("expected text console output, got %q", console.String()[:100])
+	}
+
+	r, err := OpenReader(filepath.Join(dir, "binary.log"), ReaderOptions{MinLevel: ERROR, Since: base.Add(50 * time.Second)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	var got []Entry
+	for r.Next() {
+		got = append(got, r.Entry())
+	}
+	if err := r.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 15 {
+		t.Fatalf("expected 15 filtered entries, got %d", len(got))
+	}
+	e := got[0]
+	want := []Field{String("path", "/api/orders"), Any("status", int64(202)), Any("bytes", int64(-50000)),
+		Any("latency", 1.5), Bool("cached", true), Any("at", base), Any("tags", json.RawMessage(`["a","b"]`))}
+	if e.Level != ERROR || e.Message != "request handled" || !e.Time.Equal(base.Add(52*time.Second)) || len(e.Fields) != len(want) {
+		t.Fatalf("unexpected entry %+v", e)
+	}
+	for i, f := range want {
+		if at, ok := f.Value.(time.Time); ok {
+			if !e.Fields[i].Value.(time.Time).Equal(at) {
+				t.Fatalf("field %s: got %v", f.Key, e.Fields[i].Value)
+			}
+		} else if fmt.Sprint(e.Fields[i].Value) != fmt.Sprint(f.Value) || e.Fields[i].Key != f.Key {
+			t.Fatalf("field %d: got %s=%#v, want %s=%#v", i, e.Fields[i].Key, e.Fields[i].Value, f.Key, f.Value)
+		}
+	}
+}
+
+func TestQuery(t *testing.T) {
+	dir := t.TempDir()
+	base := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	for i, format := range []Format{FormatJSON, FormatBinary} {
+		now := base.Add(time.Duration(i) * 500 * time.Millisecond)
+		log, err := NewLogger(filepath.Join(dir, format.String()+".log"), DEBUG, 10, false, WithFormat(format),
+			WithArchiveCompression(Gzip), WithClock(func() time.Time { now = now.Add(time.Second); return now }))
+		if err != nil {
+			t.Fatal(err)
+		}
+		log.StartWorker()
+		for j := 0; j < 10; j++ {
+			if j == 5 {
+				log.Drain(context.Background())
+				log.mu.Lock()
+				log.rotate()
+				log.mu.Unlock()
+			}
+			log.Error("payment failed", String("format", format.String()), Int("user_id", j%3))
+			log.Info("payment ok", Int("user_id", j%3))
+		}
+		log.Close()
+	}
+
+	entries, err := Query(dir, QueryOptions{
+		MinLevel:     ERROR,
+		MessageRegex: regexp.MustCompile(`^payment`),
+		Fields:       map[string]string{"user_id": "1"},
+		Since:        base.Add(5 * time.Second),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 每种格式 user_id=1 的 ERROR 有 j=1,4,7 三条，j=1 早于 Since
+	if len(entries) != 4 {
+		t.Fatalf("expected 4 entries, got %d: %+v", len(entries), entries)
+	}
+	for i, e := range entries {
+		if e.Level != ERROR || (i > 0 && e.Time.Before(entries[i-1].Time)) {
+			t.Fatalf("unexpected entry %d: %+v", i, e)
+		}
+	}
+	if got := entries[0].Fields[0].Value; got != "json" {
+		t.Fatalf("expected json entry first, got %v", got)
+	}
+
+	entries, err = Query(dir, QueryOptions{Name: "binary.log", Limit: 3})
+	if err != nil || len(entries) != 3 || entries[0].Fields[0].Value != "binary" {
+		t.Fatalf("unexpected result %+v, %v", entries, err)
+	}
+}
+
+func TestQuerySkipsErrorStream(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	log, err := NewLogger(path, DEBUG, 10, false, WithFormat(FormatJSON), WithErrorStream("", ERROR))
+	if err != nil {
+		t.Fatal(err)
+	}
+	log.StartWorker()
+	log.Info("ok")
+	log.Error("failed")
+	log.Drain(context.Background())
+	log.mu.Lock()
+	log.rotate()
+	log.mu.Unlock()
+	log.Error("failed again")
+	log.Close()
+	// 错误日志文件的归档同样跳过
+	os.Rename(errFilePath(path), filepath.Join(dir, "app.err.log.20240601_120000.log"))
+	os.WriteFile(errFilePath(path), nil, 0644)
+
+	entries, err := Query(dir, QueryOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, e := range entries {
+		got = append(got, e.Message)
+	}
+	if fmt.Sprint(got) != "[ok failed failed again]" {
+		t.Fatalf("expected each entry once, got %q", got)
+	}
+	entries, err = Query(dir, QueryOptions{Name: "app.err.log"})
+	if err != nil || len(entries) != 2 {
+		t.Fatalf("explicit error stream query: %+v, %v", entries, err)
+	}
+}
+
+func TestRetryRename(t *testing.T) {
+	dir := t.TempDir()
+	src, dst := filepath.Join(dir, "app.log"), filepath.Join(dir, "app.log.20240601_120000.log")
+	errLocked := errors.New("sharing violation")
+	transient := func(err error) bool { return err == errLocked }
+
+	// 前两次被占用，第三次成功
+	os.WriteFile(src, []byte("a\n"), 0644)
+	calls := 0
+	err := retryRename(src, dst, func(a, b string) error {
+		if calls++; calls <= 2 {
+			return errLocked
+		}
+		return os.Rename(a, b)
+	}, transient)
+	if err != nil || calls != 3 {
+		t.Fatalf("expected success on third attempt, got %v after %d calls", err, calls)
+	}
+
+	// 一直被占用时复制后清空原文件
+	os.Remove(dst)
+	os.WriteFile(src, []byte("b\n"), 0644)
+	err = retryRename(src, dst, func(string, string) error { return errLocked }, transient)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(dst); string(data) != "b\n" {
+		t.Fatalf("unexpected archive content %q", data)
+	}
+	if info, _ := os.Stat(src); info == nil || info.Size() != 0 {
+		t.Fatalf("expected original file to be truncated, got %v", info)
+	}
+}
+
+func TestStaticFields(t *testing.T) {
+	host, _ := os.Hostname()
+	for format, want := range map[Format]string{
+		FormatText: fmt.Sprintf("[INFO] started host=%s pid=%d service=billing version=1.4.2 port=8080\n", host, os.Getpid()),
+		FormatJSON: fmt.Sprintf(`"msg":"started","host":%q,"pid":%d,"service":"billing","version":"1.4.2","port":8080}`+"\n", host, os.Getpid()),
+	} {
+		var buf bytes.Buffer
+		log, err := NewLogger(filepath.Join(t.TempDir(), "app.log"), DEBUG, 1, false, WithFormat(format), WithConsoleWriter(&buf),
+			WithStaticFields(Hostname(), PID(), Service("billing"), Version("1.4.2")))
+		if err != nil {
+			t.Fatal(err)
+		}
+		log.StartWorker()
+		log.Info("started", Int("port", 8080))
+		log.Close()
+		if !strings.HasSuffix(buf.String(), want) {
+			t.Fatalf("%v: unexpected output %q, want suffix %q", format, buf.String(), want)
+		}
+	}
+	if f := BuildVersion(); f.Key != VersionKey || f.Value == "" {
+		t.Fatalf("unexpected build version field %+v", f)
+	}
+}
+
+func TestReadOnlyFallback(t *testing.T) {
+	if !isReadOnlyFS(&os.PathError{Op: "open", Path: "/app/logs/app.log", Err: syscall.EROFS}) {
+		t.Fatal("expected EROFS to be detected")
+	}
+	log, err := NewLogger(filepath.Join(t.TempDir(), "app.log"), DEBUG, 1, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	log.closeFile()
+	log.fallbackReadOnly(syscall.EROFS)
+	var buf bytes.Buffer
+	log.consoleWriter = &buf
+	log.StartWorker()
+	log.Info("hello")
+	log.Close()
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if !log.ReadOnlyFallback() || len(lines) != 2 || !strings.Contains(lines[0], `"level":"WARN"`) || !strings.Contains(lines[1], `"msg":"hello"`) {
+		t.Fatalf("unexpected output %q", buf.String())
+	}
+}
+
+func TestRecover(t *testing.T) {
+	log, entries := newCaptureLogger(t)
+	func() {
+		defer func() {
+			if v := recover(); v != "boom" {
+				t.Fatalf("expected re-panic with boom, got %v", v)
+			}
+		}()
+		defer Recover(log)
+		panic("boom")
+	}()
+
+	handler := RecoveryMiddleware(log, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic(errors.New("nil map"))
+	}))
+	req := httptest.NewRequest(http.MethodPost, "/orders", nil)
+	req.Header.Set(RequestIDHeader, "req-1")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusInternalServerError {
+		t.Fatalf("expected 500, got %d", rec.Code)
+	}
+
+	// 已经发出响应头时不再写入 500 和错误信息
+	streaming := RecoveryMiddleware(log, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+		w.Write([]byte("partial"))
+		panic("midway")
+	}))
+	rec = httptest.NewRecorder()
+	streaming.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/stream", nil))
+	if rec.Code != http.StatusAccepted || rec.Body.String() != "partial" {
+		t.Fatalf("started response was modified: %d %q", rec.Code, rec.Body.String())
+	}
+	log.Close()
+
+	got := entries()
+	if len(got) != 3 {
+		t.Fatalf("expected 3 entries, got %d", len(got))
+	}
+	for i, want := range []string{"false", "true"} {
+		var started string
+		for _, f := range got[i+1].Fields {
+			if f.Key == "response_started" {
+				started = rawTextValue(f.Value)
+			}
+		}
+		if started != want {
+			t.Fatalf("entry %d: response_started = %q, want %s", i+1, started, want)
+		}
+	}
+	fields := map[string]string{}
+	for _, f := range got[1].Fields {
+		fields[f.Key] = rawTextValue(f.Value)
+	}
+	if got[1].Level != ERROR || fields["panic_kind"] != PanicKindError || fields["panic_value"] != "nil map" || fields["path"] != "/orders" || fields["request_id"] != "req-1" || !strings.Contains(fields["stack"], "TestRecover") {
+		t.Fatalf("unexpected entry %+v", got[1])
+	}
+}
+
+func TestPanicFields(t *testing.T) {
+	capture := func(f func()) (fields map[string]string) {
+		defer func() {
+			v := recover()
+			fields = map[string]string{}
+			for _, f := range PanicFields(v, debug.Stack()) {
+				fields[f.Key] = rawTextValue(f.Value)
+			}
+		}()
+		f()
+		return nil
+	}
+	var m map[string]int
+	cases := []struct {
+		f         func()
+		kind, typ string
+		valuePart string
+	}{
+		{func() { m["a"] = 1 }, PanicKindRuntime, "runtime.plainError", "nil map"},
+		{func() { panic(io.ErrUnexpectedEOF) }, PanicKindError, "*errors.errorString", "unexpected EOF"},
+		{func() { panic("boom") }, PanicKindString, "string", "boom"},
+		{func() { panic(42) }, PanicKindOther, "int", "42"},
+	}
+	for _, c := range cases {
+		fields := capture(c.f)
+		if fields["panic_kind"] != c.kind || fields["panic_type"] != c.typ || !strings.Contains(fields["panic_value"], c.valuePart) {
+			t.Errorf("unexpected fields %v, want kind=%s type=%s", fields, c.kind, c.typ)
+		}
+		stack := fields["stack"]
+		if !strings.HasPrefix(stack, "goroutine ") || strings.Contains(stack, "runtime/debug.Stack") || !strings.Contains(stack, "TestPanicFields.func") {
+			t.Errorf("unexpected stack:\n%s", stack)
+		}
+	}
+}
+
+func TestDumpGoroutines(t *testing.T) {
+	log, entries := newCaptureLogger(t)
+	block := make(chan struct{})
+	go func() { <-block }()
+	defer close(block)
+
+	srv := httptest.NewServer(log.GoroutineDumpHandler(WARN))
+	defer srv.Close()
+	if resp, err := http.Get(srv.URL); err != nil || resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Fatalf("expected GET to be rejected, got %v, %v", resp, err)
+	}
+	resp, err := http.Post(srv.URL, "text/plain", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	log.Close()
+
+	got := entries()
+	if len(got) != 1 || got[0].Level != WARN || !strings.HasPrefix(string(body), "logged ") {
+		t.Fatalf("unexpected result %q, %+v", body, got)
+	}
+	n, _ := got[0].Fields[0].Value.(int)
+	dump, _ := got[0].Fields[1].Value.(string)
+	if n < 2 || strings.Count(dump, "goroutine ") < n || !strings.Contains(dump, "TestDumpGoroutines.func") {
+		t.Fatalf("unexpected dump of %d goroutines:\n%s", n, dump)
+	}
+}
+
+func TestAccessLogMiddleware(t *testing.T) {
+	log, entries := newCaptureLogger(t)
+	mw := AccessLogMiddleware(log, AccessLogConfig{Headers: []string{"User-Agent"}, ExcludePaths: []string{"/healthz", "/static/*"}})
+	handler := mw(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/fail" {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+		io.WriteString(w, "hello")
+	}))
+	for _, path := range []string{"/orders", "/healthz", "/static/app.js", "/fail"} {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("User-Agent", "curl/8.0")
+		req.Header.Set(RequestIDHeader, "req-"+path)
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	log.Close()
+
+	got := entries()
+	if len(got) != 2 {
+		t.Fatalf("expected 2 access log entries, got %d", len(got))
+	}
+	want := []struct {
+		level  LogLevel
+		fields string
+	}{
+		{INFO, "method=GET path=/orders remote_addr=192.0.2.1:1234 request_id=req-/orders status=200 bytes=5 header_user_agent=curl/8.0"},
+		{ERROR, "method=GET path=/fail remote_addr=192.0.2.1:1234 request_id=req-/fail status=502 bytes=5 header_user_agent=curl/8.0"},
+	}
+	for i, e := range got {
+		var parts []string
+		for _, f := range e.Fields {
+			if f.Key != "latency" {
+				parts = append(parts, f.Key+"="+rawTextValue(f.Value))
+			}
+		}
+		if e.Level != want[i].level || strings.Join(parts, " ") != want[i].fields {
+			t.Fatalf("entry %d: got %v %s", i, e.Level, strings.Join(parts, " "))
+		}
+	}
+}
+
+func TestRuntimeStats(t *testing.T) {
+	log, entries := newCaptureLogger(t, WithRuntimeStats(5*time.Millisecond))
+	runtime.GC()
+	deadline := time.Now().Add(2 * time.Second)
+	for len(entries()) < 2 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	log.Close()
+
+	got := entries()
+	if len(got) < 2 || got[0].Message != "runtime stats" || got[0].Level != INFO {
+		t.Fatalf("expected periodic runtime stats, got %+v", got)
+	}
+	fields := map[string]interface{}{}
+	for _, f := range got[0].Fields {
+		fields[f.Key] = f.Value
+	}
+	if n, _ := fields["goroutines"].(int); n < 1 {
+		t.Fatalf("unexpected goroutines %v", fields["goroutines"])
+	}
+	if n, _ := fields["num_gc"].(uint32); n < 1 {
+		t.Fatalf("expected at least one GC, got %v", fields["num_gc"])
+	}
+	if n, _ := fields["heap_alloc"].(uint64); n == 0 {
+		t.Fatalf("unexpected heap_alloc %v", fields["heap_alloc"])
+	}
+}
+
+func TestRotateBeforeWriteWithHeaderFooter(t *testing.T) {
+	dir := t.TempDir()
+	var mu sync.Mutex
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	clock := func() time.Time {
+		// 每次调用前进一秒，避免同一秒内切割的归档文件重名
+		mu.Lock()
+		defer mu.Unlock()
+		now = now.Add(time.Second)
+		return now
+	}
+	header := func(path string) []byte { return []byte("# logx schema=1 file=" + filepath.Base(path) + "\n") }
+	footer := func(string) []byte { return []byte("# end\n") }
+	log, err := NewLogger(filepath.Join(dir, "app.log"), DEBUG, 1, false,
+		WithClock(clock), WithFileHeader(header), WithFileFooter(footer))
+	if err != nil {
+		t.Fatal(err)
+	}
+	const limit = 400
+	log.maxSize = limit
+	log.StartWorker()
+	for i := 0; i < 30; i++ {
+		log.Info("request done", Field{Key: "seq", Value: i}, Field{Key: "path", Value: "/api/orders"})
+	}
+	log.Close()
+
+	files, _ := filepath.Glob(filepath.Join(dir, "app.log*"))
+	if len(files) < 3 {
+		t.Fatalf("expected several rotated files, got %v", files)
+	}
+	total := 0
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(data) > limit {
+			t.Fatalf("%s has %d bytes, exceeds %d", file, len(data), limit)
+		}
+		lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+		if lines[0] != "# logx schema=1 file=app.log" || lines[len(lines)-1] != "# end" {
+			t.Fatalf("%s missing header or footer: %q", file, data)
+		}
+		for _, line := range lines[1 : len(lines)-1] {
+			entry, err := ParseLine([]byte(line))
+			if err != nil || entry.Message != "request done" {
+				t.Fatalf("%s has split or broken entry %q: %v", file, line, err)
+			}
+			total++
+		}
+	}
+	if total != 30 {
+		t.Fatalf("expected 30 entries across files, got %d", total)
+	}
+}
+
+func TestConfigAndSchema(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	cfg, err := LoadConfig([]byte(`{"file":` + strconv.Quote(path) + `,"level":"warn","format":"json","flush_interval":"50ms","redact_keys":["password"]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.FlushInterval != ConfigDuration(50*time.Millisecond) {
+		t.Fatalf("unexpected flush_interval %v", cfg.FlushInterval)
+	}
+	for _, bad := range []string{
+		`{"level":"info"}`,
+		`{"file":"a.log","level":"loud"}`,
+		`{"file":"a.log","colour":"never"}`,
+		`{"file":"a.log","dedup":"soon"}`,
+		`{"file":"a.log","workers":-1}`,
+	} {
+		if _, err := LoadConfig([]byte(bad)); err == nil {
+			t.Fatalf("expected %s to be rejected", bad)
+		}
+	}
+
+	log, err := NewLoggerFromConfig(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	log.StartWorker()
+	log.Info("hidden")
+	log.Warn("login", Field{Key: "password", Value: "hunter2"})
+
+	cfg.Level = "debug"
+	if err := log.ApplyConfig(cfg); err != nil {
+		t.Fatal(err)
+	}
+	log.Debug("now visible")
+	changed := cfg
+	changed.Format = "text"
+	if err := log.ApplyConfig(changed); err == nil || !strings.Contains(err.Error(), "format") {
+		t.Fatalf("expected format change to be rejected, got %v", err)
+	}
+	log.Close()
+
+	data, _ := os.ReadFile(path)
+	if strings.Contains(string(data), "hidden") || strings.Contains(string(data), "hunter2") ||
+		!strings.Contains(string(data), `"msg":"now visible"`) {
+		t.Fatalf("unexpected output %s", data)
+	}
+
+	var schema struct {
+		Required   []string                          `json:"required"`
+		Properties map[string]map[string]interface{} `json:"properties"`
+	}
+	if err := json.Unmarshal(ConfigSchema(), &schema); err != nil {
+		t.Fatal(err)
+	}
+	if len(schema.Required) != 1 || schema.Required[0] != "file" {
+		t.Fatalf("unexpected required %v", schema.Required)
+	}
+	if len(schema.Properties) != reflect.TypeOf(Config{}).NumField() {
+		t.Fatalf("schema has %d properties", len(schema.Properties))
+	}
+	if enum, _ := schema.Properties["level"]["enum"].([]interface{}); len(enum) != 4 {
+		t.Fatalf("unexpected level schema %v", schema.Properties["level"])
+	}
+	if schema.Properties["flush_interval"]["type"] != "string" || schema.Properties["redact_keys"]["type"] != "array" {
+		t.Fatalf("unexpected property types %v", schema.Properties)
+	}
+	if schema.Properties["level"]["x-logx-reloadable"] != true {
+		t.Fatalf("level should be marked reloadable")
+	}
+}
+
+func TestConfigOptions(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	cfg, err := LoadConfig([]byte(fmt.Sprintf(`{"file":%q,"format":"json","max_backups":2,
+		"sampling_first":1,"sampling_tick":"1h","error_stream_level":"error",
+		"stats_checkpoint":%q,"wal_dir":%q,"wal_sync":true,"audit":true}`,
+		path, filepath.Join(dir, "stats.json"), filepath.Join(dir, "wal"))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, bad := range []string{
+		`{"file":"a.log","wal_sync":true}`,
+		`{"file":"a.log","error_stream_level":"loud"}`,
+		`{"file":"a.log","error_stream_file":"a.err.log"}`,
+		`{"file":"a.log","max_backups":-1}`,
+		`{"file":"a.log","sampling_first":-1}`,
+	} {
+		if _, err := LoadConfig([]byte(bad)); err == nil {
+			t.Fatalf("expected %s to be rejected", bad)
+		}
+	}
+
+	log, err := NewLoggerFromConfig(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	log.StartWorker()
+	for i := 0; i < 3; i++ {
+		log.Info("repeated")
+	}
+	log.Error("failed")
+	changed := cfg
+	changed.MaxBackups = 5
+	if err := log.ApplyConfig(changed); err == nil || !strings.Contains(err.Error(), "max_backups") {
+		t.Fatalf("expected max_backups change to be rejected, got %v", err)
+	}
+	log.Close()
+
+	if st := log.Stats(); st.Dropped != 2 {
+		t.Fatalf("sampling not applied: dropped %d", st.Dropped)
+	}
+	// repeated、failed 和 Close 时输出的采样汇总
+	if summary, err := VerifyAuditLog(path, nil); err != nil || summary.Entries != 3 {
+		t.Fatalf("audit not applied: %+v, %v", summary, err)
+	}
+	if data, _ := os.ReadFile(errFilePath(path)); !strings.Contains(string(data), "failed") || strings.Contains(string(data), "repeated") {
+		t.Fatalf("unexpected error stream %q", data)
+	}
+	if log.maxBackups != 2 || log.wal == nil || !log.wal.cfg.Sync {
+		t.Fatal("max_backups or wal options not applied")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "stats.json")); err != nil {
+		t.Fatalf("stats checkpoint not written: %v", err)
+	}
+}
+
+func TestFilterRules(t *testing.T) {
+	rules, err := ParseFilter(`Deny(level<WARN, module="http"), Allow(messageRegex="slow query")`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	log, entries := newCaptureLogger(t, WithFilter(rules...))
+	log.SetLevel(INFO)
+	log.Info("request served", Module("http"))
+	log.Warn("upstream timeout", Module("http"))
+	log.Info("cache warmed", Module("cache"))
+	log.Debug("slow query took=2s")
+	log.Debug("fast query")
+	log.Close()
+
+	var got []string
+	for _, e := range entries() {
+		got = append(got, e.Message)
+	}
+	if want := []string{"upstream timeout", "cache warmed", "slow query took=2s"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	if n := log.Stats().Filtered; n != 2 {
+		t.Fatalf("expected 2 filtered entries, got %d", n)
+	}
+
+	for _, bad := range []string{`Deny(level<LOUD)`, `Allow(module~"x")`, `Skip(module=x)`, `Deny(module="x"`} {
+		if _, err := ParseFilter(bad); err == nil {
+			t.Fatalf("expected %q to be rejected", bad)
+		}
+	}
+}
+
+func TestLinePrefix(t *testing.T) {
+	clock := func() time.Time { return time.Date(2024, 1, 2, 3, 4, 5, 123456000, time.UTC) }
+	cases := []struct {
+		prefix string
+		flags  int
+		want   string
+	}{
+		{"", 0, "[INFO] tick\n"},
+		{"billing ", stdlog.LstdFlags | stdlog.LUTC, "billing 2024/01/02 03:04:05 [INFO] tick\n"},
+		{"| ", stdlog.Ltime | stdlog.Lmicroseconds | stdlog.LUTC | stdlog.Lmsgprefix, "03:04:05.123456 | [INFO] tick\n"},
+	}
+	for _, c := range cases {
+		path := filepath.Join(t.TempDir(), "app.log")
+		log, err := NewLogger(path, DEBUG, 1, false, WithClock(clock), WithLinePrefix(c.prefix, c.flags))
+		if err != nil {
+			t.Fatal(err)
+		}
+		log.StartWorker()
+		log.Info("tick")
+		log.Close()
+		if data, _ := os.ReadFile(path); string(data) != c.want {
+			t.Fatalf("flags %d: got %q, want %q", c.flags, data, c.want)
+		}
+	}
+}
+
+func TestEscalate(t *testing.T) {
+	log, entries := newCaptureLogger(t)
+	log.SetLevel(INFO)
+
+	stop := log.EscalateModule("db", DEBUG, time.Hour)
+	log.Debug("query plan", Module("db"))
+	log.Debug("handler detail", Module("http"))
+	stop()
+	log.Debug("query plan after", Module("db"))
+
+	log.Escalate(DEBUG, 20*time.Millisecond)
+	log.Debug("global detail")
+	deadline := time.Now().Add(2 * time.Second)
+	for log.minLevel() != INFO && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	log.Debug("global detail after")
+	log.Close()
+
+	var got []string
+	for _, e := range entries() {
+		got = append(got, e.Message)
+	}
+	want := []string{
+		"level escalation started", "query plan", "level escalation ended",
+		"level escalation started", "global detail", "level escalation ended",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	if last := entries()[5]; last.Fields[0].Value != "INFO" || last.Fields[1].Value != "expired" {
+		t.Fatalf("unexpected end marker %+v", last)
+	}
+}
+
+func TestCallTimeAndSkew(t *testing.T) {
+	for _, writeTime := range []bool{false, true} {
+		var mu sync.Mutex
+		now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.Local)
+		clock := func() time.Time {
+			mu.Lock()
+			defer mu.Unlock()
+			return now
+		}
+		opts := []Option{WithClock(clock), WithTimeSkewField("")}
+		if writeTime {
+			opts = append(opts, WithWriteTime())
+		}
+		path := filepath.Join(t.TempDir(), "app.log")
+		log, err := NewLogger(path, DEBUG, 1, false, opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		log.Info("queued")
+		// 模拟队列积压：调用之后3秒才开始写入
+		mu.Lock()
+		now = now.Add(3 * time.Second)
+		mu.Unlock()
+		log.StartWorker()
+		log.Close()
+
+		want := "2024/01/02 03:04:05 [INFO] queued write_skew=3s\n"
+		if writeTime {
+			want = "2024/01/02 03:04:08 [INFO] queued write_skew=3s\n"
+		}
+		if data, _ := os.ReadFile(path); string(data) != want {
+			t.Fatalf("writeTime=%v: got %q, want %q", writeTime, data, want)
+		}
+	}
+}
+
+func TestEventLogSink(t *testing.T) {
+	for level, want := range map[LogLevel]uint16{DEBUG: eventTypeInformation, INFO: eventTypeInformation, WARN: eventTypeWarning, ERROR: eventTypeError} {
+		if got := eventType(level); got != want {
+			t.Fatalf("level %v: got event type %d, want %d", level, got, want)
+		}
+	}
+	if _, err := NewEventLogSink(EventLogConfig{}); err == nil {
+		t.Fatal("expected missing source to be rejected")
+	}
+	if runtime.GOOS != "windows" {
+		if _, err := NewEventLogSink(EventLogConfig{Source: "logx-test"}); !errors.Is(err, ErrEventLogUnsupported) {
+			t.Fatalf("expected ErrEventLogUnsupported, got %v", err)
+		}
+	}
+}
+
+func TestWALRecovery(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	cfg := SpoolConfig{Dir: filepath.Join(dir, "wal")}
+
+	// 日志已经进入预写队列，但进程在写入文件之前退出
+	crashed, err := NewLogger(path, DEBUG, 1, false, WithWAL(cfg))
+	if err != nil {
+		t.Fatal(err)
+	}
+	crashed.Info("order created", Field{Key: "order_id", Value: 42})
+	crashed.Warn("payment pending")
+	close(crashed.done)
+	crashed.wal.Close()
+	crashed.closeFile()
+	if data, _ := os.ReadFile(path); len(data) != 0 {
+		t.Fatalf("expected nothing written before the crash, got %q", data)
+	}
+
+	log, err := NewLogger(path, DEBUG, 1, false, WithWAL(cfg), WithFormat(FormatJSON))
+	if err != nil {
+		t.Fatal(err)
+	}
+	log.StartWorker()
+	log.Info("restarted")
+	if err := log.Drain(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if s := log.wal.Stats(); s.Pending != 0 || s.Acked != 3 {
+		t.Fatalf("unexpected wal stats %+v", s)
+	}
+	log.Close()
+
+	data, _ := os.ReadFile(path)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 3 || !strings.Contains(lines[0], `"msg":"order created","order_id":42`) ||
+		!strings.Contains(lines[1], `"msg":"payment pending"`) || !strings.Contains(lines[2], `"msg":"restarted"`) {
+		t.Fatalf("unexpected output %q", lines)
+	}
+}
+
+// 乱序完成 Prepare 的输出目标
+type slowPrepareSink struct {
+	mu    sync.Mutex
+	got   []string
+	delay func(*Entry) time.Duration
+}
+
+func (s *slowPrepareSink) Prepare(entry *Entry, line []byte) ([]byte, error) {
+	time.Sleep(s.delay(entry))
+	return line, nil
+}
+
+func (s *slowPrepareSink) Write(entry *Entry, line []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.got = append(s.got, entry.Message)
+	return nil
+}
+
+func (s *slowPrepareSink) Close() error { return nil }
+
+func TestAsyncSinkOrdering(t *testing.T) {
+	for _, ordered := range []bool{true, false} {
+		sink := &slowPrepareSink{delay: func(e *Entry) time.Duration {
+			// 序号小的日志处理得更慢
+			n, _ := strconv.Atoi(e.Message)
+			return time.Duration(20-n) * time.Millisecond
+		}}
+		log, err := NewLogger(filepath.Join(t.TempDir(), "app.log"), DEBUG, 1, false,
+			WithSink("async", NewAsyncSink(sink, AsyncSinkConfig{Workers: 8, Ordered: ordered}), DEBUG))
+		if err != nil {
+			t.Fatal(err)
+		}
+		log.StartWorker()
+		var want []string
+		for i := 0; i < 20; i++ {
+			want = append(want, strconv.Itoa(i))
+			log.Info(strconv.Itoa(i))
+		}
+		log.Close()
+
+		sorted := append([]string(nil), sink.got...)
+		sort.Slice(sorted, func(i, j int) bool {
+			a, _ := strconv.Atoi(sorted[i])
+			b, _ := strconv.Atoi(sorted[j])
+			return a < b
+		})
+		if !reflect.DeepEqual(sorted, want) {
+			t.Fatalf("ordered=%v: lost entries %v", ordered, sink.got)
+		}
+		if ordered && !reflect.DeepEqual(sink.got, want) {
+			t.Fatalf("expected enqueue order, got %v", sink.got)
+		}
+	}
+}
+
+func TestExpiredAlertsDropped(t *testing.T) {
+	d := &flakyDeliverer{failures: 3, seen: map[string]bool{}}
+	spool, err := NewSpoolSink(d, SpoolConfig{Dir: t.TempDir(), RetryMin: time.Millisecond, RetryMax: 5 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	async := &slowPrepareSink{delay: func(*Entry) time.Duration { return 0 }}
+	log, err := NewLogger(filepath.Join(t.TempDir(), "app.log"), DEBUG, 1, false,
+		WithSink("pager", spool, ERROR), WithSink("chat", NewAsyncSink(async, AsyncSinkConfig{}), ERROR))
+	if err != nil {
+		t.Fatal(err)
+	}
+	log.StartWorker()
+	// 告警在故障期间已经过期
+	log.Error("disk almost full", ExpiresAt(time.Now().Add(-time.Minute)))
+	log.Error("database down", ExpiresIn(time.Hour))
+	if err := log.Drain(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	waitAcked(t, spool, 2)
+	log.Close()
+
+	if len(d.lines) != 1 || !strings.Contains(d.lines[0], "database down") {
+		t.Fatalf("expected only the live alert delivered, got %q", d.lines)
+	}
+	if n := spool.Stats().Expired; n != 1 {
+		t.Fatalf("expected 1 expired record, got %d", n)
+	}
+	if !reflect.DeepEqual(async.got, []string{"database down"}) {
+		t.Fatalf("async sink got %v", async.got)
+	}
+}
+
+func TestPipelineStages(t *testing.T) {
+	var seen []string
+	log, entries := newCaptureLogger(t,
+		WithRedaction(RedactionConfig{Keys: []string{"token"}}),
+		WithFilter(Deny(FieldEquals("drop", "yes"))),
+		WithStageBefore("first", StageRedact, func(e *Entry) bool {
+			v, _ := fieldValue(e, "token")
+			seen = append(seen, fmt.Sprint(v))
+			return true
+		}),
+		WithStageAfter("tag", StageFilter, func(e *Entry) bool {
+			if e.Message == "skip" {
+				return false
+			}
+			e.Fields = append(e.Fields, String("stage", "tag"))
+			return true
+		}))
+	log.Info("hello", String("token", "secret"))
+	log.Info("dropped", String("token", "x"), String("drop", "yes"))
+	log.Info("skip")
+	log.Close()
+
+	want := []string{StageLevel, StageSampling, StageWriteTime, StageEnrich, "first", StageRedact, StageDryRun, StageFilter, "tag",
+		StageRoute, StageIndex, StageCardinality, StageBlobs, StageTruncate, StageDedup, StageOutput}
+	if got := log.Pipeline(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("pipeline %v", got)
+	}
+	if !reflect.DeepEqual(seen, []string{"secret", "x", "<nil>"}) {
+		t.Fatalf("custom stage before redaction saw %v", seen)
+	}
+	got := entries()
+	if len(got) != 1 || got[0].Message != "hello" {
+		t.Fatalf("unexpected entries %+v", got)
+	}
+	if v, _ := fieldValue(&got[0], "stage"); v != "tag" {
+		t.Fatalf("stage field missing: %+v", got[0].Fields)
+	}
+	if v, _ := fieldValue(&got[0], "token"); v == "secret" {
+		t.Fatalf("token not redacted")
+	}
+
+	for _, opt := range []Option{
+		WithStageAfter("x", StageDedup, func(*Entry) bool { return true }),
+		WithStageBefore("x", StageSampling, func(*Entry) bool { return true }),
+		WithStageAfter(StageFilter, StageRedact, func(*Entry) bool { return true }),
+	} {
+		if _, err := NewLogger(filepath.Join(t.TempDir(), "app.log"), DEBUG, 1, false, opt); err == nil {
+			t.Fatal("expected invalid stage insertion to fail")
+		}
+	}
+}
+
+func TestStageStats(t *testing.T) {
+	log, _ := newCaptureLogger(t,
+		WithSampling(SamplingConfig{Tick: time.Hour, First: 2}),
+		WithFilter(Deny(MessageMatches(regexp.MustCompile("noise")))))
+	for i := 0; i < 5; i++ {
+		log.Info("hot")
+	}
+	log.Info("noise")
+	log.Close()
+
+	got := map[string]StageStats{}
+	var order []string
+	for _, st := range log.Stats().Stages {
+		got[st.Name] = st
+		order = append(order, st.Name)
+	}
+	if want := []string{StageSampling, StageFilter, StageOutput}; !reflect.DeepEqual(order, want) {
+		t.Fatalf("stages %v, want %v", order, want)
+	}
+	if s := got[StageSampling]; s.In != 6 || s.Dropped != 3 || s.Out != 3 {
+		t.Fatalf("sampling %+v", s)
+	}
+	// Close 时写出的采样汇总不经过 sampling
+	if s := got[StageFilter]; s.In != 4 || s.Dropped != 1 || s.Time <= 0 {
+		t.Fatalf("filter %+v", s)
+	}
+	if s := got[StageOutput]; s.In != 3 {
+		t.Fatalf("output %+v", s)
+	}
+
+	var buf bytes.Buffer
+	log.Collector().WriteTo(&buf)
+	if !strings.Contains(buf.String(), `logx_stage_dropped_total{stage="sampling"} 3`) {
+		t.Fatalf("missing stage metric:\n%s", buf.String())
+	}
+}
+
+func TestRoutes(t *testing.T) {
+	var mu sync.Mutex
+	got := map[string][]string{}
+	record := func(name string) Sink {
+		return captureSink(func(e *Entry) {
+			mu.Lock()
+			got[name] = append(got[name], e.Message+":"+levelString(e.Level))
+			mu.Unlock()
+		})
+	}
+	rules, err := ParseRoutes(`
+		module=health -> drop
+		level>=ERROR, tag=security -> pager, archive
+		messageRegex="retrying" -> downgrade(WARN)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	log, err := NewLogger(filepath.Join(t.TempDir(), "app.log"), DEBUG, 1, false,
+		WithSink("pager", record("pager"), ERROR),
+		WithSink("archive", record("archive"), DEBUG),
+		WithRoutes(rules...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	log.StartWorker()
+	log.Info("ping", Module("health"))
+	log.Error("login failure", Tags("security"))
+	log.Error("retrying upload")
+	log.Error("disk full")
+	log.Drain(context.Background())
+
+	if err := log.SetRoutes(Route().To("nowhere")); err == nil {
+		t.Fatal("expected unknown sink to be rejected")
+	}
+	if err := log.SetRoutes(Route(LevelAtLeast(ERROR)).To("archive")); err != nil {
+		t.Fatal(err)
+	}
+	log.Error("after reload")
+	log.Close()
+
+	want := map[string][]string{
+		"pager":   {"login failure:ERROR", "disk full:ERROR"},
+		"archive": {"login failure:ERROR", "retrying upload:WARN", "disk full:ERROR", "after reload:ERROR"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for _, bad := range []string{`level>=ERROR`, `* -> downgrade(LOUD)`, `module=x ->`} {
+		if _, err := ParseRoutes(bad); err == nil {
+			t.Fatalf("expected %q to be rejected", bad)
+		}
+	}
+}
+
+func TestDryRun(t *testing.T) {
+	var mu sync.Mutex
+	var paged []string
+	pager := captureSink(func(e *Entry) {
+		mu.Lock()
+		paged = append(paged, e.Message)
+		mu.Unlock()
+	})
+	candidate, err := ParseRoutes(`module=health -> drop; messageRegex="retrying" -> downgrade(WARN)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	log, err := NewLogger(filepath.Join(t.TempDir(), "app.log"), DEBUG, 1, false,
+		WithSink("pager", pager, ERROR),
+		WithDryRun(DryRunConfig{Filter: []FilterRule{Deny(LevelBelow(INFO))}, Routes: candidate}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	log.StartWorker()
+	log.Debug("cache miss")
+	log.Info("ping", Module("health"))
+	log.Error("retrying upload")
+	log.Error("disk full")
+	log.Close()
+
+	// 试运行不影响输出
+	if want := []string{"retrying upload", "disk full"}; !reflect.DeepEqual(paged, want) {
+		t.Fatalf("paged %v, want %v", paged, want)
+	}
+	want := &DryRunStats{
+		Evaluated:  4,
+		Dropped:    2,
+		Downgraded: 1,
+		Routed:     map[string]uint64{"pager": 1},
+		FilterHits: []uint64{1},
+		RouteHits:  []uint64{1, 1},
+	}
+	if got := log.Stats().DryRun; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+	if err := log.SetDryRun(DryRunConfig{Routes: []RouteRule{Route().To("missing")}}); err == nil {
+		t.Fatal("expected unknown sink to be rejected")
+	}
+	log.SetDryRun(DryRunConfig{})
+	if log.DryRunStats() != nil {
+		t.Fatal("expected dry run to stop")
+	}
+}
+
+func TestSameSecondArchivesAndMaxBackups(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	clock := func() time.Time { return time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC) }
+	log, err := NewLogger(path, DEBUG, 1, false, WithClock(clock), WithMaxBackups(3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	log.maxSize = 300
+	log.StartWorker()
+	for i := 0; i < 40; i++ {
+		log.Info("request done", Int("seq", i), String("path", "/api/orders"))
+	}
+	log.Close()
+
+	archives, err := Archives(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(archives) != 3 {
+		t.Fatalf("expected 3 archives, got %v", archives)
+	}
+	// 同一秒切割的归档不会互相覆盖，按顺序读出的序号连续且以最后一条结束
+	var seqs []int
+	for _, file := range append(archives, path) {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+			entry, err := ParseLine([]byte(line))
+			if err != nil {
+				t.Fatal(err)
+			}
+			v, _ := fieldValue(&entry, "seq")
+			n, _ := strconv.Atoi(fmt.Sprint(v))
+			seqs = append(seqs, n)
+		}
+	}
+	for i := 1; i < len(seqs); i++ {
+		if seqs[i] != seqs[i-1]+1 {
+			t.Fatalf("gap in retained entries: %v", seqs)
+		}
+	}
+	if seqs[len(seqs)-1] != 39 || seqs[0] == 0 {
+		t.Fatalf("unexpected retained range %v", seqs)
+	}
+}
+
+func TestVersionHeader(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	clock := func() time.Time {
+		now = now.Add(time.Second)
+		return now
+	}
+	header := func(string) []byte { return []byte("# service=billing\n") }
+	log, err := NewLogger(filepath.Join(dir, "app.log"), DEBUG, 1, false, WithClock(clock), WithFormat(FormatJSON),
+		WithVersionHeader(), WithFileHeader(header), WithArchiveCompression(Gzip))
+	if err != nil {
+		t.Fatal(err)
+	}
+	log.maxSize = 400
+	log.StartWorker()
+	for i := 0; i < 20; i++ {
+		log.Info("request done", Int("seq", i))
+	}
+	log.Close()
+
+	files, _ := filepath.Glob(filepath.Join(dir, "app.log*"))
+	if len(files) < 3 {
+		t.Fatalf("expected several rotated files, got %v", files)
+	}
+	host, _ := os.Hostname()
+	for _, file := range files {
+		h, err := ReadFileHeader(file, nil)
+		if err != nil || h == nil {
+			t.Fatalf("%s: header %v, %v", file, h, err)
+		}
+		if h.Format != "json" || h.Schema != CurrentSchemaVersion || h.Host != host || h.Version == "" || !h.Start.After(time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)) {
+			t.Fatalf("%s: unexpected header %+v", file, h)
+		}
+	}
+	entries, err := Query(dir, QueryOptions{})
+	if err != nil || len(entries) != 20 {
+		t.Fatalf("expected 20 entries without headers, got %d: %v", len(entries), err)
+	}
+
+	h := FileHeader{Version: "v1.2.0", Format: "text", Start: now, Host: "web 1"}
+	parsed, err := ParseFileHeader(h.String() + " future=1\n")
+	if err != nil || parsed != h {
+		t.Fatalf("round trip %q: %+v, %v", h.String(), parsed, err)
+	}
+	plain := filepath.Join(dir, "plain.log")
+	os.WriteFile(plain, []byte("2024/01/02 03:04:05 [INFO] hello\n"), 0644)
+	if h, err := ReadFileHeader(plain, nil); h != nil || err != nil {
+		t.Fatalf("expected no header, got %v, %v", h, err)
+	}
+}
+
+func TestLongLines(t *testing.T) {
+	huge := strings.Repeat("x", DefaultMaxLineSize+1)
+	input := "2024/01/02 03:04:05 [INFO] before\n" +
+		`{"time":"2024-01-02T03:04:06Z","level":"INFO","msg":"` + huge + `"}` + "\n" +
+		"2024/01/02 03:04:07 [INFO] after\n" +
+		"2024/01/02 03:04:08 [INFO] " + strings.Repeat("y", 100*1024) + "\n" +
+		"2024/01/02 03:04:09 [INFO] last"
+	read := func(max int) []string {
+		var msgs []string
+		_, err := Replay(context.Background(), strings.NewReader(input), func(e Entry) error {
+			msgs = append(msgs, e.Message[:min(len(e.Message), 8)])
+			return nil
+		}, ReplayOptions{MaxLineSize: max})
+		if err != nil {
+			t.Fatalf("max %d: %v", max, err)
+		}
+		return msgs
+	}
+	if got := strings.Join(read(0), ","); got != "before,after,yyyyyyyy,last" {
+		t.Fatalf("default limit: %s", got)
+	}
+	if got := strings.Join(read(1024), ","); got != "before,after,last" {
+		t.Fatalf("1KB limit: %s", got)
+	}
+
+	dir := t.TempDir()
+	log, err := NewLogger(filepath.Join(dir, "app.log"), DEBUG, 100, false, WithFormat(FormatBinary))
+	if err != nil {
+		t.Fatal(err)
+	}
+	log.StartWorker()
+	log.Info("small")
+	log.Info(strings.Repeat("z", 4096))
+	log.Info("small again")
+	log.Close()
+	entries, err := Query(dir, QueryOptions{MaxLineSize: 1024})
+	if err != nil || len(entries) != 2 || entries[1].Message != "small again" {
+		t.Fatalf("expected oversized binary record to be skipped, got %d entries: %v", len(entries), err)
+	}
+}
+
+func TestChildLogger(t *testing.T) {
+	dir := t.TempDir()
+	log, err := NewLogger(filepath.Join(dir, "app.log"), DEBUG, 10, false, WithFormat(FormatJSON), WithCaller(true),
+		WithStaticFields(Service("billing"), String("region", "eu")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	log.StartWorker()
+	tenant := log.With(String("tenant", "acme"), String("user_id", "42"))
+	worker := tenant.With(Service("billing-worker"), String("tenant", "globex")).Without("user_id", "region")
+	log.Info("parent")
+	tenant.Info("tenant")
+	worker.Warn("worker", Int("job", 7))
+	log.Close()
+
+	entries, err := Query(dir, QueryOptions{})
+	if err != nil || len(entries) != 3 {
+		t.Fatalf("expected 3 entries, got %d: %v", len(entries), err)
+	}
+	want := map[string]string{
+		"parent": "service=billing region=eu",
+		"tenant": "service=billing region=eu tenant=acme user_id=42",
+		"worker": "service=billing-worker tenant=globex job=7",
+	}
+	for _, e := range entries {
+		var got []string
+		for _, f := range e.Fields {
+			got = append(got, fmt.Sprintf("%s=%v", f.Key, f.Value))
+		}
+		if strings.Join(got, " ") != want[e.Message] {
+			t.Errorf("%s: fields %v, want %s", e.Message, got, want[e.Message])
+		}
+		if filepath.Base(e.File) != "logx_test.go" {
+			t.Errorf("%s: caller %s:%d", e.Message, e.File, e.Line)
+		}
+	}
+	if len(tenant.Fields()) != 2 {
+		t.Fatalf("With must not modify the parent child logger: %v", tenant.Fields())
+	}
+}
+
+func TestPerCallSinks(t *testing.T) {
+	var mu sync.Mutex
+	got := map[string][]string{}
+	record := func(name string) Sink {
+		return captureSink(func(e *Entry) {
+			mu.Lock()
+			got[name] = append(got[name], e.Message)
+			mu.Unlock()
+		})
+	}
+	var errs []error
+	log, err := NewLogger(filepath.Join(t.TempDir(), "app.log"), DEBUG, 1, false,
+		WithSink("pager", record("pager"), ERROR),
+		WithSink("audit", record("audit"), ERROR),
+		WithRoutes(Route(LevelAtLeast(ERROR)).To("pager")),
+		WithErrorHandler(func(err error) { errs = append(errs, err) }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	log.StartWorker()
+	log.Info("role changed")
+	log.To("audit").Info("role changed", String("user", "42"))
+	log.To("audit").Error("export started")
+	log.Also("audit").Error("payment failed")
+	log.With(String("tenant", "acme")).To("pager", "audit").Warn("quota exceeded")
+	log.To("nowhere").Info("lost")
+	log.Close()
+
+	want := map[string][]string{
+		"pager": {"payment failed", "quota exceeded"},
+		"audit": {"role changed", "export started", "payment failed", "quota exceeded"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	if len(errs) != 1 || !strings.Contains(errs[0].Error(), "nowhere") {
+		t.Fatalf("expected unknown sink to be reported, got %v", errs)
+	}
+}
+
+func TestManageArchives(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.Local)
+	clock := func() time.Time {
+		now = now.Add(time.Second)
+		return now
+	}
+	log, err := NewLogger(filepath.Join(dir, "app.log"), DEBUG, 1, false, WithClock(clock))
+	if err != nil {
+		t.Fatal(err)
+	}
+	log.maxSize = 300
+	log.StartWorker()
+	for i := 0; i < 20; i++ {
+		log.Info("request done", Int("seq", i))
+	}
+	log.Drain(context.Background())
+
+	archives, err := log.Archives()
+	if err != nil || len(archives) < 3 {
+		t.Fatalf("expected several archives, got %v: %v", archives, err)
+	}
+	for i, a := range archives {
+		if a.Size == 0 || a.Compression != "" || a.First.IsZero() || !a.First.Before(a.Rotated.Add(time.Second)) {
+			t.Fatalf("unexpected archive info %+v", a)
+		}
+		if i > 0 && !a.First.After(archives[i-1].First) {
+			t.Fatalf("archives out of order: %+v", archives)
+		}
+	}
+	if _, err := log.CompressArchive(archives[0].Path, nil); err == nil {
+		t.Fatal("expected an error without a configured compressor")
+	}
+	compressed, err := log.CompressArchive(archives[0].Path, Gzip)
+	if err != nil || compressed != archives[0].Path+".gzip" {
+		t.Fatalf("compress: %s, %v", compressed, err)
+	}
+	if err := log.DeleteArchive(archives[1].Path); err != nil {
+		t.Fatal(err)
+	}
+	if err := log.DeleteArchive(filepath.Join(dir, "app.log")); err == nil {
+		t.Fatal("expected the active log file to be rejected")
+	}
+	after, _ := log.Archives()
+	if len(after) != len(archives)-1 || after[0].Compression != "gzip" || !after[0].First.Equal(archives[0].First) {
+		t.Fatalf("unexpected archives after compress and delete: %+v", after)
+	}
+	log.Close()
+}
+
+func TestArena(t *testing.T) {
+	dir := t.TempDir()
+	log, err := NewLogger(filepath.Join(dir, "app.log"), DEBUG, 100, false, WithFormat(FormatJSON),
+		WithArena(16), WithStaticFields(Service("billing")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	log.StartWorker()
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			child := log.With(Int("g", g))
+			for i := 0; i < 200; i++ {
+				child.Info("tick", Int("i", i))
+			}
+		}(g)
+	}
+	wg.Wait()
+	log.Close()
+
+	entries, err := Query(dir, QueryOptions{})
+	if err != nil || len(entries) != 1600 {
+		t.Fatalf("expected 1600 entries, got %d: %v", len(entries), err)
+	}
+	seen := map[string]bool{}
+	for _, e := range entries {
+		if len(e.Fields) != 3 || e.Fields[0].Key != ServiceKey || e.Fields[1].Key != "g" || e.Fields[2].Key != "i" {
+			t.Fatalf("fields overwritten: %v", e.Fields)
+		}
+		seen[fmt.Sprint(e.Fields[1].Value, "/", e.Fields[2].Value)] = true
+	}
+	if len(seen) != 1600 {
+		t.Fatalf("expected 1600 distinct entries, got %d", len(seen))
+	}
+}
+
+func TestClosedPolicy(t *testing.T) {
+	for _, policy := range []ClosedPolicy{ClosedDrop, ClosedStderr} {
+		var stderr bytes.Buffer
+		log, err := NewLogger(filepath.Join(t.TempDir(), "app.log"), INFO, 1, false, WithErrorConsoleWriter(&stderr),
+			WithClosedPolicy(policy), WithStaticFields(Service("billing")), WithRedaction(RedactionConfig{Keys: []string{"password"}}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		log.StartWorker()
+		log.Info("before close")
+		log.Close()
+		log.Info("shutting down", String("password", "hunter2"))
+		log.Debug("below level")
+		log.Close()
+
+		if n := log.Stats().AfterClose; n != 1 {
+			t.Fatalf("policy %d: expected 1 entry after close, got %d", policy, n)
+		}
+		out := stderr.String()
+		switch policy {
+		case ClosedDrop:
+			if out != "" {
+				t.Fatalf("expected nothing on stderr, got %q", out)
+			}
+		case ClosedStderr:
+			if !strings.Contains(out, "[INFO] shutting down service=billing password=") || strings.Contains(out, "hunter2") || strings.Contains(out, "before close") {
+				t.Fatalf("unexpected stderr output %q", out)
+			}
+		}
+	}
+}
+
+func TestTryLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	log, err := NewLogger(path, INFO, 1, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 写入协程未启动，队列不会被取出
+	for i := 0; i < queueSize; i++ {
+		if err := log.TryInfo("accepted", Int("i", i)); err != nil {
+			t.Fatalf("entry %d: %v", i, err)
+		}
+	}
+	if err := log.TryError("overflow"); !errors.Is(err, ErrQueueFull) {
+		t.Fatalf("expected ErrQueueFull, got %v", err)
+	}
+	if err := log.TryDebug("quiet"); !errors.Is(err, ErrBelowLevel) {
+		t.Fatalf("expected ErrBelowLevel, got %v", err)
+	}
+	if err := log.With(String("k", "v")).TryDebug("quiet"); !errors.Is(err, ErrBelowLevel) {
+		t.Fatalf("expected ErrBelowLevel from child, got %v", err)
+	}
+	if s := log.Stats(); s.Dropped != 0 {
+		t.Fatalf("rejected entries should not count as dropped, got %d", s.Dropped)
+	}
+	log.StartWorker()
+	log.Close()
+	if err := log.With(String("k", "v")).TryWarn("late"); !errors.Is(err, ErrClosed) {
+		t.Fatalf("expected ErrClosed, got %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(string(data), "accepted"); n != queueSize {
+		t.Fatalf("expected %d entries written, got %d", queueSize, n)
+	}
+}
+
+func TestNumberFormat(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	log, err := NewLogger(path, INFO, 1, false, WithFormat(FormatJSON),
+		WithNumberFormat(NumberFormat{NoExponent: true, BigIntAsString: true}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	log.StartWorker()
+	log.Info("numbers", Int64("id", 1<<60), Int64("neg", -(1<<60)), Int("small", 42), Any("big", uint64(math.MaxUint64)),
+		Any("tiny", 1e-7), Any("huge", 1e21), Any("nan", math.NaN()))
+	log.Close()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{`"id":"1152921504606846976"`, `"neg":"-1152921504606846976"`, `"small":42`,
+		`"big":"18446744073709551615"`, `"tiny":0.0000001`, `"huge":1000000000000000000000`, `"nan":"NaN"`} {
+		if !strings.Contains(string(data), want) {
+			t.Fatalf("expected %s in %s", want, data)
+		}
+	}
+
+	var buf bytes.Buffer
+	f := NumberFormat{FloatPrecision: 2}
+	buf.Write(f.appendFloat(nil, 3.14159, 64))
+	if buf.String() != "3.14" {
+		t.Fatalf("expected 3.14, got %s", buf.String())
+	}
+}
+
+func TestFieldGroups(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	log, err := NewLogger(path, INFO, 1, false, WithFormat(FormatJSON), WithCaller(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	log.StartWorker()
+	log.With(String("service", "api")).WithGroup("http").With(Int("status", 200)).Info("request", String("method", "GET"))
+	log.WithGroup("empty").Info("no fields")
+	sl := slog.New(NewSlogHandler(log)).With("trace", "t1").WithGroup("http").With("status", 404)
+	sl.Info("slog request", slog.Group("url", "path", "/x"), "method", "POST")
+	replayed := slog.NewRecord(time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC), slog.LevelWarn, "replayed", 0)
+	NewSlogHandler(log).Handle(context.Background(), replayed)
+	log.Close()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("expected 4 lines, got %q", data)
+	}
+	for i, want := range []string{`"service":"api","http":{"status":200,"method":"GET"}`, `"msg":"no fields"}`,
+		`"trace":"t1","http":{"status":404,"url":{"path":"/x"},"method":"POST"}`} {
+		if !strings.Contains(lines[i], want) {
+			t.Fatalf("line %d: expected %s in %s", i, want, lines[i])
+		}
+	}
+	if !strings.Contains(lines[2], "logx_test.go") {
+		t.Fatalf("slog caller should point at the test, got %s", lines[2])
+	}
+	if want := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC).Local().Format(time.RFC3339Nano); !strings.Contains(lines[3], `"time":"`+want+`"`) {
+		t.Fatalf("expected the record time %s, got %s", want, lines[3])
+	}
+
+	text := appendTextFields(nil, []Field{Group("http", Int("status", 200), Group("url", String("path", "/x")))})
+	if string(text) != " http.status=200 http.url.path=/x" {
+		t.Fatalf("unexpected text fields %q", text)
+	}
+}
+
+func TestStatsCheckpoint(t *testing.T) {
+	dir := t.TempDir()
+	state := filepath.Join(dir, "logx-state.json")
+	open := func() *Logger {
+		log, err := NewLogger(filepath.Join(dir, "app.log"), INFO, 1, false, WithStatsCheckpoint(state, time.Hour))
+		if err != nil {
+			t.Fatal(err)
+		}
+		log.StartWorker()
+		return log
+	}
+	log := open()
+	for i := 0; i < 3; i++ {
+		log.Info("before restart")
+	}
+	log.Error("failed")
+	log.Close()
+
+	log = open()
+	log.Info("after restart")
+	if err := log.Drain(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	s := log.Stats()
+	if s.Entries[INFO] != 4 || s.Entries[ERROR] != 1 {
+		t.Fatalf("expected counts to continue from the checkpoint, got %v", s.Entries)
+	}
+	if err := log.SaveStatsCheckpoint(); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"info":4`) {
+		t.Fatalf("unexpected checkpoint %s", data)
+	}
+	log.Close()
+
+	// 损坏的检查点不影响创建，从0开始
+	os.WriteFile(state, []byte("{"), 0644)
+	var reported error
+	log, err = NewLogger(filepath.Join(dir, "app.log"), INFO, 1, false, WithStatsCheckpoint(state, time.Hour),
+		WithErrorHandler(func(err error) { reported = err }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := log.Stats(); s.Entries[INFO] != 0 || reported == nil {
+		t.Fatalf("expected reset counts and a reported error, got %v, %v", s.Entries, reported)
+	}
+	log.Close()
+}
+
+func TestWorkerPool(t *testing.T) {
+	dir := t.TempDir()
+	pool := NewWorkerPool(WorkerPoolConfig{Workers: 2, Quantum: 8})
+	const loggers, perLogger = 200, 50
+	logs := make([]*Logger, loggers)
+	for i := range logs {
+		log, err := NewLogger(filepath.Join(dir, fmt.Sprintf("tenant-%d.log", i)), INFO, 1, false,
+			WithWorkerPool(pool), WithQueueSize(16))
+		if err != nil {
+			t.Fatal(err)
+		}
+		log.StartWorker()
+		logs[i] = log
+	}
+	var wg sync.WaitGroup
+	for _, log := range logs {
+		wg.Add(1)
+		go func(log *Logger) {
+			defer wg.Done()
+			for j := 0; j < perLogger; j++ {
+				log.Info("entry", Int("seq", j))
+			}
+		}(log)
+	}
+	wg.Wait()
+	for _, log := range logs {
+		log.Close()
+	}
+	pool.Close()
+
+	for i := range logs {
+		data, err := os.ReadFile(filepath.Join(dir, fmt.Sprintf("tenant-%d.log", i)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+		if len(lines) != perLogger {
+			t.Fatalf("logger %d: expected %d lines, got %d", i, perLogger, len(lines))
+		}
+		for j, line := range lines {
+			if !strings.HasSuffix(line, fmt.Sprintf("seq=%d", j)) {
+				t.Fatalf("logger %d: out of order line %d: %s", i, j, line)
+			}
+		}
+	}
+
+	if _, err := NewLogger(filepath.Join(dir, "dedup.log"), INFO, 1, false, WithWorkerPool(pool), WithDedup(time.Second)); err == nil {
+		t.Fatal("expected WithDedup to be rejected")
+	}
+}
+
+func TestWALPreservesTargets(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	cfg := SpoolConfig{Dir: filepath.Join(dir, "wal")}
+	noDelay := func(*Entry) time.Duration { return 0 }
+	open := func(audit, other Sink) *Logger {
+		log, err := NewLogger(path, INFO, 1, false, WithWAL(cfg), WithCaller(true), WithStaticFields(Service("billing")),
+			WithSink("audit", audit, INFO), WithSink("other", other, INFO))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return log
+	}
+
+	crashed := open(&slowPrepareSink{delay: noDelay}, &slowPrepareSink{delay: noDelay})
+	crashed.To("audit").Info("only audit")
+	crashed.Also("audit").Without("service").Info("also audit")
+	close(crashed.done)
+	crashed.wal.Close()
+	crashed.closeFile()
+
+	audit, other := &slowPrepareSink{delay: noDelay}, &slowPrepareSink{delay: noDelay}
+	log := open(audit, other)
+	log.StartWorker()
+	if err := log.Drain(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	log.Close()
+
+	if !reflect.DeepEqual(audit.got, []string{"only audit", "also audit"}) || !reflect.DeepEqual(other.got, []string{"also audit"}) {
+		t.Fatalf("unexpected delivery after replay: audit %q, other %q", audit.got, other.got)
+	}
+	data, _ := os.ReadFile(path)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 2 || !strings.Contains(lines[0], "logx_test.go") || !strings.Contains(lines[0], "service=billing") ||
+		strings.Contains(lines[1], "service=") {
+		t.Fatalf("caller or hidden static fields lost after replay: %q", lines)
+	}
+}
+
+func TestWALRedactionAndEncryption(t *testing.T) {
+	keys := StaticKeys{Current: "k1", Keys: map[string][]byte{"k1": bytes.Repeat([]byte{7}, 32)}}
+	for _, encrypted := range []bool{false, true} {
+		dir := t.TempDir()
+		cfg := SpoolConfig{Dir: filepath.Join(dir, "wal")}
+		var entries []Entry
+		open := func() *Logger {
+			opts := []Option{WithWAL(cfg), WithRedaction(RedactionConfig{Keys: []string{"password"}}),
+				WithSink("capture", captureSink(func(e *Entry) { entries = append(entries, *e) }), INFO)}
+			if encrypted {
+				opts = append(opts, WithEncryption(keys))
+			}
+			log, err := NewLogger(filepath.Join(dir, "app.log"), INFO, 1, false, opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return log
+		}
+
+		crashed := open()
+		crashed.Info("login", String("password", "hunter2"), Indexed("tenant", "acme-corp"), Payload("body", "payload-text"))
+		close(crashed.done)
+		crashed.wal.Close()
+		crashed.closeFile()
+
+		var segments []byte
+		filepath.Walk(cfg.Dir, func(path string, info os.FileInfo, err error) error {
+			if err == nil && !info.IsDir() {
+				data, _ := os.ReadFile(path)
+				segments = append(segments, data...)
+			}
+			return nil
+		})
+		if len(segments) == 0 {
+			t.Fatal("no WAL segment written")
+		}
+		if bytes.Contains(segments, []byte("hunter2")) {
+			t.Fatalf("encrypted=%v: redacted field written to the WAL in plaintext", encrypted)
+		}
+		if encrypted && bytes.Contains(segments, []byte("acme-corp")) {
+			t.Fatal("WAL record not encrypted")
+		}
+
+		log := open()
+		log.StartWorker()
+		if err := log.Drain(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		log.Close()
+		if len(entries) != 1 || len(entries[0].Fields) != 3 {
+			t.Fatalf("encrypted=%v: replayed %+v", encrypted, entries)
+		}
+		f := entries[0].Fields
+		if f[0].Value == "hunter2" || f[1].Hint != HintIndexed || f[2].Hint != HintPayload || f[1].Value != "acme-corp" {
+			t.Fatalf("encrypted=%v: fields after replay %+v", encrypted, f)
+		}
+	}
+}
+
+func TestCloseDuringLogging(t *testing.T) {
+	for round := 0; round < 20; round++ {
+		path := filepath.Join(t.TempDir(), "app.log")
+		log, err := NewLogger(path, INFO, 100, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		log.StartWorker()
+		const producers, perProducer = 8, 200
+		var wg sync.WaitGroup
+		start := make(chan struct{})
+		for i := 0; i < producers; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				<-start
+				for j := 0; j < perProducer; j++ {
+					log.Info("racing close")
+				}
+			}()
+		}
+		close(start)
+		time.Sleep(time.Duration(round%5) * 100 * time.Microsecond)
+		log.Close()
+		wg.Wait()
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		written := uint64(strings.Count(string(data), "racing close"))
+		if got := written + log.Stats().AfterClose; got != producers*perProducer {
+			t.Fatalf("round %d: %d written + %d after close, expected %d", round, written, log.Stats().AfterClose, producers*perProducer)
+		}
+	}
+}
+
+func TestMultiProcessRotation(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	open := func() *Logger {
+		log, err := NewLogger(path, INFO, 1, false, WithMultiProcess(), WithArchiveCompression(Gzip))
+		if err != nil {
+			t.Fatal(err)
+		}
+		log.maxSize = 2048
+		log.StartWorker()
+		return log
+	}
+	// 两个日志记录器模拟写同一个文件的两个进程
+	a, b := open(), open()
+	const perLogger = 100
+	for i := 0; i < perLogger; i++ {
+		// a 的日志更长，切割时 b 写入的文件还没有达到上限
+		for name, log := range map[string]*Logger{"a": a, "b": b} {
+			pad := ""
+			if name == "a" {
+				pad = strings.Repeat("x", 300)
+			}
+			log.Info("shared file", String("pad", pad), String("from", name), Int("seq", i))
+			if err := log.Drain(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	a.Close()
+	b.Close()
+
+	files, _ := filepath.Glob(filepath.Join(dir, "app.log*"))
+	seen := map[string]bool{}
+	archives := 0
+	for _, file := range files {
+		if strings.HasSuffix(file, ".lock") {
+			continue
+		}
+		if file != path {
+			archives++
+		}
+		r, done, err := openLogReader(file, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, _ := io.ReadAll(r)
+		done()
+		for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+			if i := strings.Index(line, "from="); i >= 0 {
+				seen[line[i:]] = true
+			}
+		}
+	}
+	if archives == 0 {
+		t.Fatal("expected rotations")
+	}
+	if len(seen) != 2*perLogger {
+		t.Fatalf("expected %d distinct lines across %d files, got %d", 2*perLogger, len(files), len(seen))
+	}
+}
+
+func TestREPL(t *testing.T) {
+	var console bytes.Buffer
+	log, err := NewLogger(filepath.Join(t.TempDir(), "app.log"), DEBUG, 1, true, WithConsoleWriter(&console), WithColor(ColorNever))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, step := range []struct{ cmd, want string }{
+		{"level warn", "ok: console level WARN"},
+		{"filter user_id=42", "ok: filter user_id=42"},
+		{"pause", "ok: console paused"},
+		{"status", `level=WARN filter="user_id=42" paused=true`},
+		{"resume", "ok: console resumed"},
+		{"level bogus", "error: "},
+		{"frobnicate", "error: unknown command frobnicate"},
+	} {
+		if got := log.execREPL(step.cmd); !strings.HasPrefix(got, step.want) {
+			t.Fatalf("%s: expected %q, got %q", step.cmd, step.want, got)
+		}
+	}
+	log.StartWorker()
+	log.Warn("hidden by filter", Int("user_id", 7))
+	log.Error("shown", Int("user_id", 42))
+	log.Info("below console level", Int("user_id", 42))
+	if err := log.Drain(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	log.execREPL("filter")
+	log.execREPL("level reset")
+
+	for addr, ok := range map[string]bool{
+		"unix:/tmp/logx.sock": true, "tcp:127.0.0.1:7070": true, "127.0.0.1:7070": true, "localhost:7070": true, "[::1]:7070": true,
+		"tcp:0.0.0.0:7070": false, ":7070": false, "10.0.0.1:7070": false, "unix:": false, "7070": false,
+	} {
+		if _, _, err := parseREPLAddr(addr); (err == nil) != ok {
+			t.Fatalf("%s: unexpected result %v", addr, err)
+		}
+	}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	log.serveREPLListener(ln)
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	fmt.Fprintln(conn, "pause")
+	reply, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil || reply != "ok: console paused\n" {
+		t.Fatalf("unexpected reply %q, %v", reply, err)
+	}
+	log.Close()
+	// Close 关闭连接和监听器
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	if _, err := conn.Read(make([]byte, 1)); err != io.EOF {
+		t.Fatalf("expected the connection to be closed, got %v", err)
+	}
+	if _, err := net.Dial("tcp", ln.Addr().String()); err == nil {
+		t.Fatal("expected the listener to be closed")
+	}
+	out := console.String()
+	if strings.Contains(out, "hidden by filter") || !strings.Contains(out, "shown") || strings.Contains(out, "below console level") {
+		t.Fatalf("unexpected console output %q", out)
+	}
+}
+
+func TestBufferedWritesAndSync(t *testing.T) {
+	dir := t.TempDir()
+	read := func(path string) string {
+		data, _ := os.ReadFile(path)
+		return string(data)
+	}
+
+	// 定时刷新
+	path := filepath.Join(dir, "interval.log")
+	log, err := NewLogger(path, INFO, 1, false, WithBufferedWrites(0, 200*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	log.StartWorker()
+	log.Info("buffered")
+	if err := log.Drain(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got := read(path); got != "" {
+		t.Fatalf("expected nothing before the flush interval, got %q", got)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for !strings.Contains(read(path), "buffered") {
+		if time.Now().After(deadline) {
+			t.Fatal("buffer was not flushed on the interval")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	log.Close()
+	if err := log.Sync(); err != nil {
+		t.Fatalf("Sync after Close: %v", err)
+	}
+
+	// ERROR 及以上立即刷新
+	path = filepath.Join(dir, "onerror.log")
+	log, err = NewLogger(path, INFO, 1, false, WithBufferedWrites(0, time.Hour), WithSyncPolicy(SyncOnError))
+	if err != nil {
+		t.Fatal(err)
+	}
+	log.StartWorker()
+	log.Info("still buffered")
+	log.Drain(context.Background())
+	if got := read(path); got != "" {
+		t.Fatalf("expected INFO to stay buffered, got %q", got)
+	}
+	log.Error("flushed")
+	log.Drain(context.Background())
+	if got := read(path); !strings.Contains(got, "still buffered") || !strings.Contains(got, "flushed") {
+		t.Fatalf("expected ERROR to flush the buffer, got %q", got)
+	}
+	log.Close()
+
+	// 未开启缓冲写入时 SyncInterval 也会定时 fsync
+	log, err = NewLogger(filepath.Join(dir, "sync.log"), INFO, 1, false, WithSyncPolicy(SyncInterval))
+	if err != nil {
+		t.Fatal(err)
+	}
+	log.StartWorker()
+	deadline = time.Now().Add(2 * time.Second)
+	for {
+		stacks := make([]byte, 1<<20)
+		if stacks = stacks[:runtime.Stack(stacks, true)]; bytes.Contains(stacks, []byte("runFlusher")) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("expected the sync ticker to run without WithBufferedWrites")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	log.Close()
+}
+
+func TestErrorBudget(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	log, err := NewLogger(path, INFO, 1, false, WithFormat(FormatJSON), WithErrorBudget(ErrorBudgetConfig{Interval: time.Hour, Target: 0.01}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	log.StartWorker()
+	window := func(requests, errors int) {
+		log.RecordRequests(requests)
+		for i := 0; i < errors; i++ {
+			log.Error("request failed")
+		}
+		log.emitErrorBudget()
+	}
+	window(1000, 5)  // 0.5%，在预算内
+	window(1000, 50) // 5%，燃烧速率5
+	window(0, 3)     // 没有请求，全部失败
+	window(0, 0)
+	log.Close()
+
+	data, _ := os.ReadFile(path)
+	var reports []string
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		if strings.Contains(line, `"msg":"error budget"`) {
+			reports = append(reports, line)
+		}
+	}
+	if len(reports) != 4 {
+		t.Fatalf("expected 4 reports, got %q", reports)
+	}
+	for i, want := range [][]string{
+		{`"level":"INFO"`, `"error_ratio":0.005`, `"burn_rate":0.5`},
+		{`"level":"WARN"`, `"error_ratio":0.05`, `"burn_rate":5`},
+		{`"level":"ERROR"`, `"error_ratio":null`, `"burn_rate":"+Inf"`},
+		{`"level":"INFO"`, `"error_ratio":0,`, `"burn_rate":0`},
+	} {
+		for _, w := range want {
+			if !strings.Contains(reports[i], w) {
+				t.Fatalf("report %d: expected %s in %s", i, w, reports[i])
+			}
+		}
+	}
+}
+
+func TestCollectorExposition(t *testing.T) {
+	log, err := NewLogger(filepath.Join(t.TempDir(), "app.log"), INFO, 1, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	log.StartWorker()
+	log.Info("one")
+	log.Warn("two")
+	log.Drain(context.Background())
+	host := "机房 \"a\"\nb\\c"
+	srv := httptest.NewServer(log.Collector(map[string]string{"host": host}))
+	defer srv.Close()
+	resp, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	log.Close()
+
+	// 按文本格式解析：# TYPE 行之后是该指标的样本，标签值中的 \\、\"、\n 需要反转义
+	types := map[string]string{}
+	samples := map[string]float64{}
+	sampleRe := regexp.MustCompile(`^([a-z_]+)\{((?:[a-z_]+="(?:[^"\\]|\\.)*",?)*)\} (\S+)$`)
+	labelRe := regexp.MustCompile(`([a-z_]+)="((?:[^"\\]|\\.)*)"`)
+	unescape := strings.NewReplacer(`\\`, `\`, `\"`, `"`, `\n`, "\n")
+	for _, line := range strings.Split(strings.TrimSpace(string(body)), "\n") {
+		if strings.HasPrefix(line, "# TYPE ") {
+			parts := strings.Fields(line)
+			types[parts[2]] = parts[3]
+			continue
+		}
+		if strings.HasPrefix(line, "# HELP ") {
+			continue
+		}
+		m := sampleRe.FindStringSubmatch(line)
+		if m == nil {
+			t.Fatalf("unparsable line %q in:\n%s", line, body)
+		}
+		if _, ok := types[m[1]]; !ok {
+			t.Fatalf("sample %s before its TYPE line", m[1])
+		}
+		labels := map[string]string{}
+		for _, lm := range labelRe.FindAllStringSubmatch(m[2], -1) {
+			labels[lm[1]] = unescape.Replace(lm[2])
+		}
+		if labels["host"] != host {
+			t.Fatalf("label value %q, want %q", labels["host"], host)
+		}
+		v, err := strconv.ParseFloat(m[3], 64)
+		if err != nil {
+			t.Fatalf("bad value in %q", line)
+		}
+		samples[m[1]+"/"+labels["level"]] = v
+	}
+	if types["logx_entries_total"] != "counter" || types["logx_queue_depth"] != "gauge" {
+		t.Fatalf("unexpected types %v", types)
+	}
+	if samples["logx_entries_total/info"] != 1 || samples["logx_entries_total/warn"] != 1 || samples["logx_queue_capacity/"] != queueSize {
+		t.Fatalf("unexpected samples %v", samples)
+	}
+}
+
+func TestCardinalityGuard(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	log, err := NewLogger(path, INFO, 1, false, WithFormat(FormatJSON),
+		WithCardinalityGuard(CardinalityConfig{Keys: []string{"user_id"}, Limit: 2, Action: CardinalityHash}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	log.StartWorker()
+	for _, id := range []string{"u1", "u2", "u1", "u3", "u4"} {
+		log.Info("request", String("user_id", id), String("path", id))
+	}
+	log.Close()
+
+	data, _ := os.ReadFile(path)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	var requests, warnings []string
+	for _, line := range lines {
+		if strings.Contains(line, "high cardinality") {
+			warnings = append(warnings, line)
+		} else {
+			requests = append(requests, line)
+		}
+	}
+	if len(warnings) != 1 || !strings.Contains(warnings[0], `"field":"user_id","limit":2`) {
+		t.Fatalf("expected one warning for user_id, got %q", warnings)
+	}
+	for i, want := range []string{`"user_id":"u1"`, `"user_id":"u2"`, `"user_id":"u1"`, `"user_id":"` + hashValue("u3") + `"`, `"user_id":"` + hashValue("u4") + `"`} {
+		if !strings.Contains(requests[i], want) {
+			t.Fatalf("line %d: expected %s in %s", i, want, requests[i])
+		}
+	}
+	// 不检查的字段保持原值
+	if !strings.Contains(requests[4], `"path":"u4"`) {
+		t.Fatalf("unwatched field changed: %s", requests[4])
+	}
+}
+
+func TestReopenAfterRename(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	log, err := NewLogger(path, INFO, 1, false, WithReopenCheck(5*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	log.StartWorker()
+	defer log.Close()
+
+	log.Info("before")
+	log.Drain(context.Background())
+	// 模拟 logrotate 的 create 模式：移走文件，由日志记录器重新创建
+	moved := filepath.Join(dir, "app.log.1")
+	if err := os.Rename(path, moved); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		log.Info("after")
+		log.Drain(context.Background())
+		data, _ := os.ReadFile(path)
+		if strings.Contains(string(data), "after") {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("log file was not reopened after rename")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	old, _ := os.ReadFile(moved)
+	if !strings.Contains(string(old), "before") {
+		t.Fatalf("moved file lost earlier entries: %q", old)
+	}
+	data, _ := os.ReadFile(path)
+	if strings.Contains(string(data), "before") {
+		t.Fatalf("reopened file contains entries from before the rename: %q", data)
+	}
+
+	// 手动 Reopen 同样生效
+	os.Rename(path, filepath.Join(dir, "app.log.2"))
+	if err := log.Reopen(); err != nil {
+		t.Fatal(err)
+	}
+	log.Info("manual")
+	log.Drain(context.Background())
+	if data, _ := os.ReadFile(path); !strings.Contains(string(data), "manual") {
+		t.Fatalf("Reopen did not recreate the file: %q", data)
+	}
+}
+
+func TestDegradationReport(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	log, err := NewLogger(path, INFO, 1, false, WithFormat(FormatJSON),
+		WithSampling(SamplingConfig{Tick: time.Hour, First: 1}),
+		WithSink("bad", failingSink{}, ERROR),
+		WithErrorHandler(func(error) {}),
+		WithDegradationReport(20*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	log.StartWorker()
+	defer log.Close()
+
+	for i := 0; i < 3; i++ {
+		log.Info("same")
+	}
+	log.Error("boom")
+	log.Drain(context.Background())
+	if d := log.Stats().Degradations; len(d) != 1 || d[0].Counts["dropped"] != 2 || d[0].Counts["sink_error"] != 1 {
+		t.Fatalf("ongoing degradation = %+v", d)
+	}
+
+	// 平静 quiet 时间后输出汇总日志
+	var report string
+	deadline := time.Now().Add(2 * time.Second)
+	for report == "" {
+		if time.Now().After(deadline) {
+			t.Fatal("no degradation report")
+		}
+		time.Sleep(10 * time.Millisecond)
+		log.Drain(context.Background())
+		data, _ := os.ReadFile(path)
+		for _, line := range strings.Split(string(data), "\n") {
+			if strings.Contains(line, "logx degradation report") {
+				report = line
+			}
+		}
+	}
+	var got map[string]interface{}
+	if err := json.Unmarshal([]byte(report), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got["level"] != "WARN" || got["dropped"] != 2.0 || got["sink_error"] != 1.0 || got["start"] == nil || got["end"] == nil {
+		t.Fatalf("report = %s", report)
+	}
+	d := log.Stats().Degradations
+	if len(d) != 1 || d[0].End.Before(d[0].Start) {
+		t.Fatalf("finished degradation = %+v", d)
+	}
+}
+
+// 每个字节异或 0x5a 的测试用编码
+type xorCompressor struct{ name string }
+
+func (c xorCompressor) Name() string { return c.name }
+
+func (xorCompressor) NewWriter(w io.Writer) (io.WriteCloser, error) {
+	return xorWriter{w}, nil
+}
+
+func (xorCompressor) NewReader(r io.Reader) (io.ReadCloser, error) {
+	return io.NopCloser(xorReader{r}), nil
+}
+
+type xorWriter struct{ w io.Writer }
+
+func (x xorWriter) Write(p []byte) (int, error) {
+	out := make([]byte, len(p))
+	for i, b := range p {
+		out[i] = b ^ 0x5a
+	}
+	return x.w.Write(out)
+}
+
+func (xorWriter) Close() error { return nil }
+
+type xorReader struct{ r io.Reader }
+
+func (x xorReader) Read(p []byte) (int, error) {
+	n, err := x.r.Read(p)
+	for i := range p[:n] {
+		p[i] ^= 0x5a
+	}
+	return n, err
+}
+
+func TestCompressorRegistry(t *testing.T) {
+	c := xorCompressor{name: "xortest"}
+	if _, ok := LookupCompressor(c.Name()); ok {
+		t.Fatal("compressor registered before RegisterCompressor")
+	}
+	RegisterCompressor(c)
+	got, ok := LookupCompressor("xortest")
+	if !ok || got != Compressor(c) {
+		t.Fatalf("LookupCompressor = %v, %v", got, ok)
+	}
+
+	data, err := Compress(got, []byte("hello logx"))
+	if err != nil || string(data) == "hello logx" {
+		t.Fatalf("Compress = %q, %v", data, err)
+	}
+	r, _ := got.NewReader(bytes.NewReader(data))
+	if plain, _ := io.ReadAll(r); string(plain) != "hello logx" {
+		t.Fatalf("round trip = %q", plain)
+	}
+
+	// 归档按扩展名找到注册的编码解压
+	path := filepath.Join(t.TempDir(), "app.log.1.log")
+	os.WriteFile(path, []byte("line one\nline two\n"), 0644)
+	archive, err := CompressFile(got, path)
+	if err != nil || archive != path+".xortest" {
+		t.Fatalf("CompressFile = %q, %v", archive, err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("original file not removed: %v", err)
+	}
+	br, closeFile, err := openLogReader(archive, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	plain, _ := io.ReadAll(br)
+	closeFile()
+	if string(plain) != "line one\nline two\n" {
+		t.Fatalf("archive content = %q", plain)
+	}
+
+	cfg := Config{File: "app.log", Compression: "xortest"}
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := LookupCompressor("nope"); ok {
+		t.Fatal("unknown compressor found")
+	}
+	cfg.Compression = "nope"
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), `unknown compression "nope"`) {
+		t.Fatalf("Validate = %v", err)
+	}
+}
+
+func TestCallerHyperlink(t *testing.T) {
+	for _, mode := range []ColorMode{ColorAlways, ColorNever} {
+		var buf bytes.Buffer
+		log, err := NewLogger(filepath.Join(t.TempDir(), "console.log"), DEBUG, 1, false,
+			WithConsoleWriter(&buf), WithColor(mode), WithCallerHyperlink(VSCodeLinkTemplate))
+		if err != nil {
+			t.Fatal(err)
+		}
+		log.StartWorker()
+		_, file, line, _ := runtime.Caller(0)
+		log.Info("linked")
+		log.Close()
+
+		short := fmt.Sprintf("logx/logx_test.go:%d", line+1)
+		link := fmt.Sprintf("\033]8;;vscode://file/%s:%d\033\\%s\033]8;;\033\\", file, line+1, short)
+		got := buf.String()
+		switch mode {
+		case ColorAlways:
+			if !strings.Contains(got, link+" ") || !strings.HasSuffix(got, "linked"+resetColor+"\n") {
+				t.Fatalf("expected hyperlink %q in %q", link, got)
+			}
+		case ColorNever:
+			// 不使用颜色时输出普通文本
+			if strings.Contains(got, "\033") || !strings.Contains(got, short) {
+				t.Fatalf("unexpected plain output: %q", got)
+			}
+		}
+	}
+}
+
+type failingBlobStore struct{}
+
+func (failingBlobStore) Put(string, []byte) (string, error) { return "", syscall.ENOSPC }
+
+func TestBlobStore(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	log, err := NewLogger(path, INFO, 1, false, WithFormat(FormatJSON),
+		WithBlobStore(NewDirBlobStore(filepath.Join(dir, "blobs")), 16))
+	if err != nil {
+		t.Fatal(err)
+	}
+	log.StartWorker()
+	payload := bytes.Repeat([]byte("x"), 100)
+	fields := []Field{Attachment("payload", payload), String("body", strings.Repeat("y", 17)), String("small", "ok")}
+	log.Info("upload", fields...)
+	log.Close()
+
+	if v, ok := fields[0].Value.([]byte); !ok || len(v) != 100 {
+		t.Fatal("caller's fields were modified")
+	}
+	data, _ := os.ReadFile(path)
+	var got map[string]interface{}
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("%v: %s", err, data)
+	}
+	// 文本和 JSON 中都以 BlobRef.String 的形式保存引用
+	ref := regexp.MustCompile(`^blob:(.+)\((\d+) bytes\)$`)
+	payloadRef := ref.FindStringSubmatch(fmt.Sprint(got["payload"]))
+	bodyRef := ref.FindStringSubmatch(fmt.Sprint(got["body"]))
+	if got["small"] != "ok" || payloadRef == nil || payloadRef[2] != "100" || bodyRef == nil || bodyRef[2] != "17" || payloadRef[1] == bodyRef[1] {
+		t.Fatalf("unexpected entry: %s", data)
+	}
+	if stored, err := os.ReadFile(payloadRef[1]); err != nil || !bytes.Equal(stored, payload) {
+		t.Fatalf("stored blob = %q, %v", stored, err)
+	}
+
+	// 保存失败时保留原始字段并报告错误
+	var errs []error
+	log, err = NewLogger(filepath.Join(dir, "fail.log"), INFO, 1, false, WithFormat(FormatJSON),
+		WithBlobStore(failingBlobStore{}, 16), WithErrorHandler(func(err error) { errs = append(errs, err) }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	log.StartWorker()
+	log.Info("upload", String("body", strings.Repeat("y", 17)))
+	log.Close()
+	var werr *WriteError
+	if len(errs) != 1 || !errors.As(errs[0], &werr) || werr.Op != OpBlob || werr.Name != "body" {
+		t.Fatalf("errors = %v", errs)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "fail.log")); !strings.Contains(string(data), strings.Repeat("y", 17)) {
+		t.Fatalf("field lost after failed offload: %s", data)
+	}
+}
+
+func TestFirstError(t *testing.T) {
+	log, err := NewLogger(filepath.Join(t.TempDir(), "app.log"), DEBUG, 1, false,
+		WithRedaction(RedactionConfig{Keys: []string{"password"}}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	log.StartWorker()
+	if _, ok := log.FirstError(); ok {
+		t.Fatal("FirstError before any error")
+	}
+	log.Warn("not an error")
+	log.Error("first", String("password", "hunter2"), Int("attempt", 1))
+	log.Error("second")
+	log.Drain(context.Background())
+
+	first, ok := log.FirstError()
+	if !ok || first.Message != "first" || first.Level != ERROR || len(first.Fields) != 2 {
+		t.Fatalf("FirstError = %+v, %v", first, ok)
+	}
+	if first.Fields[0].Value == "hunter2" {
+		t.Fatal("FirstError returned an unredacted field")
+	}
+
+	log.ResetFirstError()
+	if _, ok := log.FirstError(); ok {
+		t.Fatal("FirstError after ResetFirstError")
+	}
+	log.Error("third")
+	log.Close()
+	if first, ok := log.FirstError(); !ok || first.Message != "third" {
+		t.Fatalf("FirstError after reset = %+v, %v", first, ok)
+	}
+}
+
+func TestExitPolicy(t *testing.T) {
+	p := NewExitPolicy().SetCode(WARN, 3)
+	log, err := NewLogger(filepath.Join(t.TempDir(), "app.log"), INFO, 1, false, WithExitPolicy(p))
+	if err != nil {
+		t.Fatal(err)
+	}
+	log.StartWorker()
+	defer log.Close()
+
+	if _, ok := p.MaxLevel(); ok || p.SuggestedExitCode() != 0 {
+		t.Fatal("policy should start empty")
+	}
+	// 被等级过滤掉的日志不计入
+	log.Debug("hidden")
+	log.Info("ok")
+	if level, ok := p.MaxLevel(); !ok || level != INFO || p.SuggestedExitCode() != 0 {
+		t.Fatalf("after INFO: %v %v code=%d", level, ok, p.SuggestedExitCode())
+	}
+	log.Warn("careful")
+	if code := p.SuggestedExitCode(); code != 3 {
+		t.Fatalf("after WARN code = %d", code)
+	}
+	log.Error("failed")
+	log.Info("recovered")
+	if level, _ := p.MaxLevel(); level != ERROR || p.SuggestedExitCode() != 1 {
+		t.Fatalf("after ERROR: %v code=%d", level, p.SuggestedExitCode())
+	}
+	p.Reset()
+	if _, ok := p.MaxLevel(); ok || p.SuggestedExitCode() != 0 {
+		t.Fatal("Reset did not clear the policy")
+	}
+}
+
+func TestColorModes(t *testing.T) {
+	run := func(opts ...Option) string {
+		var buf bytes.Buffer
+		log, err := NewLogger(filepath.Join(t.TempDir(), "console.log"), DEBUG, 1, false,
+			append([]Option{WithConsoleWriter(&buf)}, opts...)...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		log.StartWorker()
+		log.Info("hi")
+		log.Warn("careful")
+		log.Close()
+		return buf.String()
+	}
+	if got := run(); got != "[INFO] hi\n[WARN] careful\n" {
+		t.Fatalf("ColorAuto on a buffer: %q", got)
+	}
+	if got := run(WithColor(ColorAlways)); got != "\033[32m[INFO] hi\033[0m\n\033[33m[WARN] careful\033[0m\n" {
+		t.Fatalf("ColorAlways: %q", got)
+	}
+	if got := run(WithColor(ColorAlways), WithLevelColor(WARN, Style(1, 35)), WithLevelColor(INFO, "")); got != "[INFO] hi\n\033[1;35m[WARN] careful\033[0m\n" {
+		t.Fatalf("custom styles: %q", got)
+	}
+
+	// 字符设备视为终端，NO_COLOR 和 TERM=dumb 关闭颜色；Windows 的 NUL 不是控制台
+	if runtime.GOOS == "windows" {
+		return
+	}
+	tty, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if err != nil {
+		t.Skip(err)
+	}
+	defer tty.Close()
+	t.Setenv("NO_COLOR", "")
+	os.Unsetenv("NO_COLOR")
+	t.Setenv("TERM", "xterm")
+	if !isTerminal(tty) || !colorTerminal(tty) {
+		t.Fatal("character device not detected as a terminal")
+	}
+	f, _ := os.Create(filepath.Join(t.TempDir(), "plain"))
+	defer f.Close()
+	if isTerminal(f) || colorTerminal(f) {
+		t.Fatal("regular file detected as a terminal")
+	}
+	t.Setenv("TERM", "dumb")
+	if colorTerminal(tty) {
+		t.Fatal("TERM=dumb should disable color")
+	}
+	t.Setenv("TERM", "xterm")
+	t.Setenv("NO_COLOR", "1")
+	if colorTerminal(tty) {
+		t.Fatal("NO_COLOR should disable color")
+	}
+}
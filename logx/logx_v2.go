@@ -1,12 +1,16 @@
 package logx
 
 import (
+	"bufio"
+	"context"
+	"errors"
 	"fmt"
 	"io"
-	"log"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -29,85 +33,445 @@ var levelColors = map[LogLevel]string{
 const resetColor = "\033[0m"
 
 type Logger struct {
-	mu          sync.Mutex
-	level       LogLevel
-	consoleOut  bool
-	file        *os.File
-	fileWriter  *log.Logger
-	maxSize     int64
-	filePath    string
-	currentSize int64
-	logChan     chan logEntry  // 用于异步日志处理
-	wg          sync.WaitGroup // 等待日志处理完成
+	mu                 sync.Mutex
+	level              atomic.Int64 // 最低等级，调用日志方法时无锁读取，修改时需持有 l.mu
+	consoleOut         bool
+	file               *os.File
+	maxSize            int64
+	filePath           string
+	currentSize        int64
+	queue              *ringBuffer                         // 用于异步日志处理
+	wg                 sync.WaitGroup                      // 等待日志处理完成
+	format             Format                              // 输出格式
+	withCaller         bool                                // 是否记录调用位置
+	callerLink         string                              // 控制台调用位置的超链接模板
+	console            consoleControl                      // 控制台输出的实时调整
+	sampler            *sampler                            // 重复日志采样
+	dedup              *dedupState                         // 连续重复日志合并
+	blobs              BlobStore                           // 超大附件存储
+	blobThreshold      int                                 // 超过该字节数的字段写入 blobs
+	done               chan struct{}                       // 通知后台协程退出
+	bgWg               sync.WaitGroup                      // 等待后台协程退出
+	redactor           *redactor                           // 敏感信息脱敏
+	firstError         *Entry                              // 第一条 ERROR 日志
+	sinks              []sinkRoute                         // 额外的输出目标
+	stderrLevel        *LogLevel                           // 不低于该等级的控制台输出写到 stderr
+	exitPolicy         *ExitPolicy                         // 退出码策略
+	colorMode          ColorMode                           // 控制台颜色模式
+	levelColors        map[LogLevel]string                 // 自定义的等级样式
+	ttyCache           map[io.Writer]bool                  // 输出目标是否为终端
+	consoleWriter      io.Writer                           // 控制台输出目标
+	errConsoleWriter   io.Writer                           // 高等级日志的控制台输出目标
+	closeOnce          sync.Once                           // 保证 Close 只执行一次
+	timeLayout         string                              // 时间格式
+	timeLoc            *time.Location                      // 输出时区
+	clock              func() time.Time                    // 获取当前时间
+	metrics            metrics                             // 运行指标
+	schemaVersion      int                                 // JSON输出的结构版本
+	indexedKeys        map[string]bool                     // 默认作为索引字段的键
+	errorHandler       func(error)                         // 后台写入错误回调
+	optErr             error                               // 应用配置项时的错误
+	cardinality        *cardinalityGuard                   // 高基数字段保护
+	reopenSignals      []os.Signal                         // 收到这些信号时重新打开文件
+	reopenCheck        time.Duration                       // 检查文件是否被移走的间隔
+	budget             *errorBudget                        // 错误预算
+	multiProcess       bool                                // 多进程写同一文件
+	externalRotation   bool                                // 由外部工具切割
+	out                io.Writer                           // 日志文件的写入目标，开启缓冲时为 buffer
+	buffer             *bufio.Writer                       // 写入缓冲区
+	bufferSize         int                                 // 缓冲区大小
+	flushInterval      time.Duration                       // 缓冲区刷新间隔
+	syncPolicy         SyncPolicy                          // fsync 策略
+	degradation        *degradationTracker                 // 降级事件统计
+	archiveCompressor  Compressor                          // 切割后压缩旧文件
+	archiveWg          sync.WaitGroup                      // 等待后台压缩完成
+	batchFlush         bool                                // 每批日志写完后刷新缓冲区
+	workers            int                                 // 并行编码的协程数
+	encodeJobs         chan encodeJob                      // 分发给编码协程的任务
+	encodedLines       []encodedLine                       // 写入协程复用的编码结果
+	maxEntrySize       int                                 // 单条日志消息和字段值的最大字节数
+	processed          atomic.Uint64                       // 写入协程已处理的条数
+	subscribers        []*subscriber                       // 实时订阅
+	subscribeClosed    bool                                // Close 之后的订阅直接关闭
+	progress           *progressLine                       // 与控制台进度条协作
+	audit              *auditChain                         // 审计模式的哈希链
+	encryption         KeyProvider                         // 日志文件加密
+	strictPaths        bool                                // 严格校验日志路径
+	owner              *fileOwner                          // 新建文件和目录的所有者
+	staticFields       []Field                             // 每条日志都带的字段
+	readOnly           bool                                // 文件系统只读，只输出到控制台
+	runtimeStats       time.Duration                       // 定期记录运行时内存和 GC 统计的间隔
+	fileHeader         FileHook                            // 新文件开头写入的内容
+	fileFooter         FileHook                            // 关闭文件前写入的内容
+	headerSize         int64                               // 当前文件中文件头的字节数
+	config             *Config                             // NewLoggerFromConfig 使用的配置，ApplyConfig 据此检查不能热更新的字段
+	filter             *filter                             // WithFilter 的过滤规则
+	linePrefix         string                              // WithLinePrefix 的前缀
+	prefixFlags        int                                 // WithLinePrefix 的标准库 log 标志
+	customPrefix       bool                                // 是否设置了 WithLinePrefix
+	escalation         *escalation                         // Escalate 的全局提升，由 l.mu 保护
+	moduleEscalations  map[string]*escalation              // EscalateModule 的提升，由 l.mu 保护
+	moduleLevels       atomic.Pointer[map[string]LogLevel] // 提升中的模块等级，log 无锁读取
+	writeTime          bool                                // 输出写入时间而不是调用时间
+	skewKey            string                              // 写入时间与调用时间之差的字段名
+	wal                *SpoolSink                          // WithWAL 的预写队列
+	walStarted         chan struct{}                       // StartWorker 后关闭，之后才从预写队列写入
+	walCipher          *walCipher                          // 同时开启 WithEncryption 时加密预写队列的记录
+	stageInserts       []stageInsert                       // WithStageAfter、WithStageBefore 插入的环节
+	pipeline           []stage                             // 写入协程中执行的处理环节
+	stageOrder         []string                            // 所有环节的名称
+	samplingStage      *stageCounters                      // 调用方一侧和加锁后执行的环节的指标
+	dedupStage         *stageCounters
+	outputStage        *stageCounters
+	pipelineStats      []*stageCounters // 与 pipeline 一一对应的指标
+	router             *router          // 路由规则
+	dryRun             *dryRun          // 试运行的规则
+	maxBackups         int              // 保留的归档数量，0 表示不删除
+	pruneMu            sync.Mutex
+	bufferDst          io.Writer     // buffer 写入的目标
+	versionHeader      bool          // 新文件开头写入版本文件头
+	arena              *fieldArena   // WithArena
+	closed             atomic.Bool   // 已经开始 Close，不再接收日志
+	closedPolicy       ClosedPolicy  // Close 之后收到日志时的处理方式
+	numberFormat       *NumberFormat // JSON 输出中数字的格式，nil 为默认
+	checkpointPath     string        // WithStatsCheckpoint 的文件
+	checkpointInterval time.Duration
+	checkpointMu       sync.Mutex
+	pool               *WorkerPool   // WithWorkerPool 的共享写入协程池
+	scheduled          atomic.Bool   // 已在协程池的等待队列中或正在被写入
+	replServers        []*replServer // StartREPL 的监听器，由 mu 保护
 }
 
-type logEntry struct {
-	level LogLevel
-	msg   string
-	time  time.Time
+// Entry 一条日志
+type Entry struct {
+	Level   LogLevel
+	Time    time.Time // 调用日志方法的时间
+	Message string
+	Fields  []Field // 结构化字段
+	File    string  // 调用位置，未开启 WithCaller 时为空
+	Line    int
+
+	belowLevel bool // 低于最低等级，只有 WithFilter 的 Allow 规则匹配时才输出
+	routed     bool // 匹配了 WithRoutes 中的 To 规则，只发送到 routes
+	routes     []string
+	// 子日志记录器去掉或替换的静态字段的键
+	hiddenStatic []string
+	targeted     bool     // 调用时用 To、Also 指定了输出目标，WithRoutes 的 To 规则不再修改
+	alsoSinks    []string // Also 指定的额外的输出目标
 }
 
 func (l *Logger) StartWorker() {
-	l.wg.Add(1)
-	go func() {
-		defer l.wg.Done()
-		for entry := range l.logChan {
-			l.write(entry)
+	if l.wal != nil {
+		close(l.walStarted)
+	}
+	trackWorker(l)
+	if l.pool != nil {
+		l.attachPool()
+	} else {
+		l.wg.Add(1)
+		go func() {
+			defer l.wg.Done()
+			l.runWorker()
+		}()
+	}
+	if l.sampler != nil {
+		l.bgWg.Add(1)
+		go l.runSampler()
+	}
+	if len(l.reopenSignals) > 0 {
+		l.bgWg.Add(1)
+		go l.runReopenSignals()
+	}
+	if l.reopenCheck > 0 {
+		l.bgWg.Add(1)
+		go l.runReopenCheck()
+	}
+	if l.budget != nil {
+		l.bgWg.Add(1)
+		go l.runErrorBudget()
+	}
+	if l.bufferSize > 0 || l.syncPolicy == SyncInterval {
+		l.bgWg.Add(1)
+		go l.runFlusher()
+	}
+	if l.degradation != nil {
+		l.bgWg.Add(1)
+		go l.runDegradationReport()
+	}
+	if l.runtimeStats > 0 {
+		l.bgWg.Add(1)
+		go l.runRuntimeStats()
+	}
+	if l.checkpointPath != "" {
+		l.bgWg.Add(1)
+		go l.runStatsCheckpoint()
+	}
+}
+
+// 写入协程：批量取出日志写入，队列为空时等待；开启合并时按窗口输出重复计数
+func (l *Logger) runWorker() {
+	l.startEncoders()
+	defer l.stopEncoders()
+	var timeout time.Duration
+	if l.dedup != nil {
+		timeout = l.dedup.window
+	}
+	batch := make([]Entry, 0, workerBatchSize)
+	for {
+		batch = l.queue.popBatch(batch[:0], workerBatchSize)
+		if len(batch) > 0 {
+			l.writeBatch(batch)
+			l.processed.Add(uint64(len(batch)))
+			continue
 		}
-	}()
+		if !l.queue.wait(timeout) {
+			break
+		}
+		if l.dedup != nil {
+			l.mu.Lock()
+			l.expireRepeats(time.Now())
+			l.mu.Unlock()
+		}
+	}
+	if l.dedup != nil {
+		l.mu.Lock()
+		l.flushRepeats()
+		l.mu.Unlock()
+	}
 }
 
-func NewLogger(filePath string, level LogLevel, maxSizeMB int64, consoleOut bool) (*Logger, error) {
+func NewLogger(filePath string, level LogLevel, maxSizeMB int64, consoleOut bool, opts ...Option) (*Logger, error) {
 	l := &Logger{
-		level:      level,
-		consoleOut: consoleOut,
-		maxSize:    maxSizeMB * 1024 * 1024,
-		filePath:   filePath,
-		logChan:    make(chan logEntry, 2000), // 异步日志通道
+		consoleOut:       consoleOut,
+		maxSize:          maxSizeMB * 1024 * 1024,
+		filePath:         filePath,
+		queue:            newRingBuffer(queueSize),
+		done:             make(chan struct{}),
+		schemaVersion:    CurrentSchemaVersion,
+		consoleWriter:    os.Stdout,
+		errConsoleWriter: os.Stderr,
+	}
+	l.level.Store(int64(level))
+	for _, opt := range opts {
+		opt(l)
+	}
+	if l.optErr == nil {
+		l.optErr = l.buildPipeline()
+	}
+	if l.optErr == nil && l.router != nil {
+		l.optErr = l.checkRoutes(*l.router.rules.Load())
+	}
+	if l.optErr == nil && l.dryRun != nil {
+		l.optErr = l.SetDryRun(l.dryRun.initial)
+	}
+	if l.optErr == nil && l.audit != nil && l.format == FormatBinary {
+		l.optErr = errors.New("logx: audit mode does not support FormatBinary")
+	}
+	if l.optErr == nil && l.wal != nil && l.encryption != nil {
+		l.walCipher, l.optErr = newWALCipher(l.encryption)
+	}
+	if l.optErr == nil {
+		l.optErr = l.checkWorkerPool()
+	}
+	if l.optErr == nil {
+		l.optErr = l.checkPath()
+	}
+	if l.optErr != nil {
+		if l.wal != nil {
+			l.wal.Close()
+		}
+		l.closeSinks()
+		return nil, l.optErr
+	}
+	if l.checkpointPath != "" {
+		// 在切割之前读取，切割次数等从检查点继续累计
+		l.loadStatsCheckpoint()
+	}
+	if l.audit != nil {
+		l.resumeAuditChain()
 	}
 	if err := l.rotate(); err != nil {
-		return nil, err
+		if !isReadOnlyFS(err) {
+			return nil, err
+		}
+		l.fallbackReadOnly(err)
 	}
+	trackOpen(l)
 	return l, nil
 }
 
 func (l *Logger) rotate() error {
-	if l.file != nil {
-		l.file.Close()
-	}
-
-	dir := filepath.Dir(l.filePath)
-	os.MkdirAll(dir, 0755)
+	l.closeFile()
 
-	timestamp := time.Now().Format("20060102_150405")
-	newPath := fmt.Sprintf("%s.%s.log", l.filePath, timestamp)
+	dirErr := l.mkdirAll(filepath.Dir(l.filePath))
 
+	now := l.now()
 	if _, err := os.Stat(l.filePath); err == nil {
-		os.Rename(l.filePath, newPath)
+		newPath := archivePath(l.filePath, now)
+		if err := renameArchive(l.filePath, newPath); err != nil {
+			l.handleError(OpRotate, l.filePath, err)
+		} else if l.file != nil {
+			l.metrics.rotations.Add(1)
+			if l.archiveCompressor != nil {
+				l.compressArchive(newPath)
+			} else if l.maxBackups > 0 {
+				l.pruneArchives()
+			}
+		}
 	}
 
-	file, err := os.OpenFile(l.filePath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	file, err := l.openLogFile()
 	if err != nil {
+		if dirErr != nil {
+			// 目录创建失败时打开文件的错误只是 ENOENT，返回更准确的原因
+			return dirErr
+		}
 		return err
 	}
 
-	l.file = file
-	l.fileWriter = log.New(io.MultiWriter(file), "", log.LstdFlags) // 创建日志写入器
-	l.currentSize = 0
+	l.setFile(file)
+	l.currentSize = l.headerSize
 	return nil
 }
 
+func (l *Logger) setFile(file *os.File) {
+	var w io.Writer = file
+	if l.encryption != nil {
+		// 每次刷新缓冲区加密为一条记录
+		w = newEncryptWriter(file, l.encryption)
+	}
+	newFile := false
+	if stat, err := file.Stat(); err == nil && stat.Size() == 0 {
+		newFile = true
+	}
+	if l.format == FormatBinary && newFile {
+		w.Write([]byte(binaryMagic))
+	}
+	l.file = file
+	l.out = w
+	l.buffer = nil
+	l.bufferDst = w
+	switch {
+	case l.bufferSize > 0:
+		l.buffer = bufio.NewWriterSize(w, l.bufferSize)
+		l.out = l.buffer
+	case !l.multiProcess:
+		// 未开启缓冲写入时每批日志结束后立即刷新；多进程写同一个文件时必须整行写入，不能合并
+		l.buffer = bufio.NewWriterSize(w, batchBufferSize)
+		l.out = l.buffer
+		l.batchFlush = true
+	}
+	l.headerSize = 0
+	if newFile {
+		l.writeHeader()
+	}
+}
+
 func (l *Logger) SetLevel(level LogLevel) {
 	l.mu.Lock()
 	defer l.mu.Unlock()
-	l.level = level
+	l.setLevelLocked(level)
 }
 
-func (l *Logger) log(level LogLevel, msg string) {
-	if level < l.level {
+func (l *Logger) minLevel() LogLevel {
+	return LogLevel(l.level.Load())
+}
+
+// 调用方需持有 l.mu
+func (l *Logger) setLevelLocked(level LogLevel) {
+	if l.escalation != nil {
+		// 临时提升结束后生效
+		l.escalation.prev = level
 		return
 	}
-	l.logChan <- logEntry{level, msg, time.Now()}
+	l.level.Store(int64(level))
+}
+
+func (l *Logger) log(level LogLevel, msg string, fields []Field) {
+	l.logScoped(level, msg, nil, fields, false)
+}
+
+// child 不为 nil 时加上子日志记录器的字段；try 为 true 时队列满不等待，返回日志的去向，见 TryLog
+func (l *Logger) logScoped(level LogLevel, msg string, child *Child, fields []Field, try bool) error {
+	below := level < l.minLevel()
+	if below && (l.moduleEscalated(level, fields) || child != nil && l.moduleEscalated(level, child.fields)) {
+		below = false
+	}
+	if below && !l.filterMayAllow(level) {
+		return ErrBelowLevel
+	}
+	// 低于最低等级的日志只可能被 WithFilter 的 Allow 规则放行，由写入协程判断，不参与统计和采样
+	if !below {
+		if l.exitPolicy != nil {
+			l.exitPolicy.Observe(level)
+		}
+		if l.budget != nil && level >= ERROR {
+			l.budget.errors.Add(1)
+		}
+		if l.sampler != nil {
+			allowed := l.sampler.allow(level, msg)
+			l.samplingStage.observe(allowed, 0)
+			if !allowed {
+				l.metrics.dropped.Add(1)
+				l.noteDegradation("dropped")
+				return ErrSampled
+			}
+		}
+	}
+	// 复制字段，使可变参数不逃逸，调用方在等级被过滤时不会产生堆分配
+	var copied []Field
+	var hidden []string
+	if child != nil {
+		hidden = child.hidden
+		if len(child.groups) > 0 {
+			fields = child.nest(fields)
+		}
+		if len(child.fields) > 0 {
+			copied = append(l.allocFields(len(child.fields)+len(fields)), child.fields...)
+		}
+	}
+	if copied == nil && len(fields) > 0 {
+		copied = l.allocFields(len(fields))
+	}
+	copied = append(copied, fields...)
+	entry := Entry{Level: level, Message: msg, Time: l.now(), Fields: copied, belowLevel: below, hiddenStatic: hidden}
+	if child != nil && !child.time.IsZero() {
+		entry.Time = child.time
+	}
+	if child != nil && child.sinks != nil {
+		entry.targeted = true
+		if child.sinksOnly {
+			entry.routed, entry.routes = true, child.sinks
+		} else {
+			entry.alsoSinks = child.sinks
+		}
+	}
+	if l.withCaller {
+		if child != nil && child.callerPC != 0 {
+			entry.File, entry.Line = callerFromPC(child.callerPC)
+		} else {
+			entry.File, entry.Line = captureCaller(callerSkipFromLogFunc)
+		}
+	}
+	if try {
+		return l.tryEnqueue(entry)
+	}
+	l.enqueue(entry)
+	return nil
+}
+
+// Emit 直接写入一条已经构造好的日志，例如回放或导入的日志，仍会经过等级过滤
+func (l *Logger) Emit(entry Entry) {
+	if entry.Level < l.minLevel() {
+		if !l.filterMayAllow(entry.Level) {
+			return
+		}
+		entry.belowLevel = true
+	}
+	if entry.Time.IsZero() {
+		entry.Time = l.now()
+	}
+	l.enqueue(entry)
 }
 
 func levelString(level LogLevel) string {
@@ -125,38 +489,306 @@ func levelString(level LogLevel) string {
 	}
 }
 
+// ParseLevel 解析日志等级名称，不区分大小写
+func ParseLevel(s string) (LogLevel, error) {
+	switch strings.ToUpper(strings.TrimSpace(s)) {
+	case "DEBUG":
+		return DEBUG, nil
+	case "INFO":
+		return INFO, nil
+	case "WARN", "WARNING":
+		return WARN, nil
+	case "ERROR":
+		return ERROR, nil
+	default:
+		return INFO, fmt.Errorf("logx: unknown level %q", s)
+	}
+}
+
 // 公共方法
-func (l *Logger) Debug(msg string) { l.log(DEBUG, msg) }
-func (l *Logger) Info(msg string)  { l.log(INFO, msg) }
-func (l *Logger) Warn(msg string)  { l.log(WARN, msg) }
-func (l *Logger) Error(msg string) { l.log(ERROR, msg) }
+func (l *Logger) Debug(msg string, fields ...Field) { l.log(DEBUG, msg, fields) }
+func (l *Logger) Info(msg string, fields ...Field)  { l.log(INFO, msg, fields) }
+func (l *Logger) Warn(msg string, fields ...Field)  { l.log(WARN, msg, fields) }
+func (l *Logger) Error(msg string, fields ...Field) { l.log(ERROR, msg, fields) }
 
+// Close 停止接收日志并等待写入完成，可以重复调用
 func (l *Logger) Close() {
-	close(l.logChan) // 关闭日志通道，停止接收新日志
-	l.wg.Wait()      // 等待所有日志处理完成
-	if l.file != nil {
-		l.file.Close()
+	l.closeOnce.Do(func() {
+		close(l.done) // 先停止后台协程，它们也会写入日志队列
+		l.closeREPL()
+		l.bgWg.Wait()
+		l.closed.Store(true)
+		l.queue.close() // 关闭日志队列，停止接收新日志
+		l.wg.Wait()     // 等待所有日志处理完成
+		if l.wal != nil {
+			l.closeWAL()
+		}
+		l.mu.Lock()
+		l.closeFile()
+		l.file = nil // 之后的 Sync 不再操作已关闭的文件
+		l.mu.Unlock()
+		l.closeSinks()
+		l.mu.Lock()
+		l.closeSubscribers()
+		l.mu.Unlock()
+		l.archiveWg.Wait()
+		if err := l.SaveStatsCheckpoint(); err != nil {
+			l.handleError(OpState, l.checkpointPath, err)
+		}
+		trackClose(l)
+	})
+}
+
+// Drain 等待调用之前放入队列的日志全部写完，需要已经调用 StartWorker，ctx 结束时返回它的错误
+func (l *Logger) Drain(ctx context.Context) error {
+	if l.wal != nil {
+		return l.drainWAL(ctx)
+	}
+	target := l.queue.head.Load()
+	for l.processed.Load() < target {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Millisecond):
+		}
 	}
+	return nil
 }
 
-func (l *Logger) write(entry logEntry) {
+func (l *Logger) write(entry Entry) {
+	batch := [1]Entry{entry}
+	l.writeBatch(batch[:])
+}
+
+// 写入一批日志，格式化之前的处理在锁外完成，整批只加一次锁
+func (l *Logger) writeBatch(batch []Entry) {
+	var warnings []batchWarning
+	kept := batch[:0]
+	for i := range batch {
+		entry, w, ok := l.prepare(batch[i])
+		for _, e := range w {
+			warnings = append(warnings, batchWarning{at: len(kept), entry: e})
+		}
+		if ok {
+			kept = append(kept, entry)
+		}
+	}
+	batch = kept
+
+	var lines []encodedLine
+	if l.encodeJobs != nil && len(batch) >= minParallelBatch {
+		lines = l.encodedLines[:len(batch)]
+		l.encodeBatch(batch, lines)
+		defer releaseLines(lines)
+	}
+
 	l.mu.Lock()
 	defer l.mu.Unlock()
+	for i, entry := range batch {
+		var w []Entry
+		for len(warnings) > 0 && warnings[0].at == i {
+			w = append(w, warnings[0].entry)
+			warnings = warnings[1:]
+		}
+		var pre *encodedLine
+		if lines != nil {
+			pre = &lines[i]
+		}
+		l.writeLocked(entry, w, pre)
+	}
+	for _, w := range warnings {
+		// 批次末尾被丢弃的日志产生的提示
+		l.output(w.entry)
+	}
+	if l.dedup != nil {
+		l.expireRepeats(time.Now())
+	}
+	if l.batchFlush {
+		// 整批日志合并为一次文件写入
+		if err := l.flushBuffer(); err != nil {
+			l.handleError(OpWrite, l.filePath, err)
+		}
+	}
+}
 
-	formatted := fmt.Sprintf("[%s] %s", levelString(entry.level), entry.msg)
+// 高基数保护产生的提示日志，写在第 at 条日志之前
+type batchWarning struct {
+	at    int
+	entry Entry
+}
 
-	if l.consoleOut {
-		color := levelColors[entry.level]
-		fmt.Printf("%s%s%s\n", color, formatted, resetColor)
+// pre 不为空时使用预先编码好的一行，调用方需持有 l.mu
+func (l *Logger) writeLocked(entry Entry, warnings []Entry, pre *encodedLine) {
+	for _, w := range warnings {
+		l.output(w)
 	}
 
-	err := l.fileWriter.Output(3, formatted)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "log write error: %v\n", err)
+	l.recordFirstError(entry)
+	start := time.Now()
+	if l.dedup != nil {
+		absorbed := l.dedup.absorb(entry)
+		if absorbed {
+			l.metrics.coalesced.Add(1)
+		} else {
+			l.flushRepeats()
+			l.dedup.remember(entry)
+		}
+		now := time.Now()
+		l.dedupStage.observe(!absorbed, now.Sub(start))
+		if absorbed {
+			return
+		}
+		start = now
 	}
+	if pre != nil {
+		l.commit(entry, *pre.bufp, pre.bodyStart)
+	} else {
+		l.output(entry)
+	}
+	l.outputStage.observe(true, time.Since(start))
+}
 
-	l.currentSize += int64(len(formatted) + 1)
-	if l.currentSize >= l.maxSize {
-		_ = l.rotate()
+// 格式化并输出单条日志，调用方需持有 l.mu
+func (l *Logger) output(entry Entry) {
+	bufp, bodyStart := l.encode(entry)
+	l.commit(entry, *bufp, bodyStart)
+	putBuffer(bufp)
+}
+
+// 把日志编码到池化的缓冲区，bodyStart 为文本格式中去掉时间前缀后的起始位置。
+// 只读取配置，可以在多个协程中同时调用
+func (l *Logger) encode(entry Entry) (*[]byte, int) {
+	bufp := getBuffer()
+	line := (*bufp)[:0]
+	bodyStart := 0
+	if l.format == FormatJSON {
+		line = l.appendJSON(line, entry)
+	} else if l.format == FormatBinary {
+		line = appendBinary(line, entry)
+	} else {
+		// 默认与标准库 log.LstdFlags 的前缀格式一致，时间取调用日志方法的时间
+		if l.customPrefix {
+			line = l.appendLinePrefix(line, entry.Time)
+		} else {
+			line = l.appendTime(line, entry.Time, defaultTextTimeLayout, false)
+			line = append(line, ' ')
+		}
+		bodyStart = len(line)
+		line = appendText(line, entry)
+		line = append(line, '\n')
+	}
+	*bufp = line
+	return bufp, bodyStart
+}
+
+// 输出编码好的一行，调用方需持有 l.mu
+func (l *Logger) commit(entry Entry, line []byte, bodyStart int) {
+	if l.consoleOut && l.format == FormatBinary {
+		// 二进制格式的控制台仍输出文本
+		textp := getBuffer()
+		*textp = append(appendText((*textp)[:0], entry), '\n')
+		l.writeConsole(&entry, *textp)
+		putBuffer(textp)
+	} else if l.consoleOut {
+		l.writeConsole(&entry, line[bodyStart:])
+	}
+	if l.audit != nil {
+		line = l.audit.seal(line)
+	}
+	l.beforeWrite(len(line))
+	if _, err := l.out.Write(line); err != nil {
+		l.handleError(OpWrite, l.filePath, err)
+	}
+	l.metrics.countEntry(entry.Level, len(line))
+	if len(l.sinks) > 0 {
+		// 只有存在输出目标时才需要取地址，避免每条日志都逃逸到堆上
+		sinkEntry := entry
+		l.writeSinks(&sinkEntry, line)
+	}
+	if len(l.subscribers) > 0 {
+		l.publish(entry)
+	}
+	l.afterWrite(entry.Level, len(line))
+}
+
+// 输出到控制台，body 为不含时间前缀的一行，调用方需持有 l.mu
+func (l *Logger) writeConsole(entry *Entry, body []byte) {
+	if !l.console.allow(entry.Level, body) {
+		return
+	}
+	if l.progress != nil {
+		l.clearProgress()
+		defer l.redrawProgress()
+	}
+	out := l.consoleTarget(entry.Level)
+	if l.format == FormatJSON {
+		out.Write(body)
+		return
+	}
+	color, reset := l.colorFor(out, entry.Level)
+	if color == "" && (entry.File == "" || l.callerLink == "" || !l.useColor(out)) {
+		out.Write(body)
+		return
+	}
+
+	bufp := getBuffer()
+	defer putBuffer(bufp)
+	buf := (*bufp)[:0]
+	if entry.File != "" && l.callerLink != "" && l.useColor(out) {
+		buf = append(buf, color...)
+		buf = append(buf, levelTag(entry.Level)[:len(levelTag(entry.Level))-1]...)
+		buf = append(buf, reset...)
+		buf = append(buf, ' ')
+		buf = append(buf, callerHyperlink(l.callerLink, entry.File, entry.Line)...)
+		buf = append(buf, ' ')
+		buf = append(buf, color...)
+		buf = append(buf, entry.Message...)
+		buf = appendTextFields(buf, entry.Fields)
+	} else {
+		buf = append(buf, color...)
+		buf = append(buf, body[:len(body)-1]...)
+	}
+	buf = append(buf, reset...)
+	buf = append(buf, '\n')
+	*bufp = buf
+	out.Write(buf)
+}
+
+// 累计文件大小并在需要时切割，调用方需持有 l.mu
+func (l *Logger) afterWrite(level LogLevel, n int) {
+	l.syncAfter(level)
+	l.currentSize += int64(n)
+}
+
+// 写入 n 字节之前检查大小，写入后会超过上限时先切割：一条日志不会跨两个文件，
+// 文件也不会超过上限(单条日志本身超过上限时除外)。调用方需持有 l.mu
+func (l *Logger) beforeWrite(n int) {
+	if l.externalRotation || l.readOnly || l.file == nil {
+		return
+	}
+	if l.multiProcess {
+		// 多个进程写同一个文件时以实际大小为准
+		if stat, err := l.file.Stat(); err == nil {
+			l.currentSize = stat.Size()
+			// 其他进程已经切割：继续写旧的文件会写进归档，归档随后可能被压缩或清理删除，日志会丢失
+			if onDisk, err := os.Stat(l.filePath); err != nil || !os.SameFile(stat, onDisk) {
+				if err := l.reopen(); err != nil {
+					l.handleError(OpRotate, l.filePath, err)
+					return
+				}
+			}
+		}
+	}
+	if l.currentSize <= l.headerSize || l.currentSize+int64(n) <= l.maxSize {
+		return
+	}
+	var err error
+	if l.multiProcess {
+		err = l.rotateLocked(int64(n))
+	} else {
+		err = l.rotate()
+	}
+	if err != nil {
+		l.handleError(OpRotate, l.filePath, err)
 	}
 }
@@ -0,0 +1,47 @@
+// Package logxprom 把 logx 的运行指标注册到 prometheus 客户端库：
+//
+//	prometheus.MustRegister(logxprom.New(log.Collector()))
+//
+// 单独作为一个模块，logx 本身不依赖 prometheus
+package logxprom
+
+import (
+	"github.com/capyflow/opensource/logx"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Collector 实现 prometheus.Collector。阶段、输出目标等标签随配置变化，
+// 因此是不预先声明指标的 unchecked collector
+type Collector struct {
+	c *logx.Collector
+}
+
+// New 例如 logxprom.New(log.Collector(map[string]string{"service": "billing"}))
+func New(c *logx.Collector) *Collector {
+	return &Collector{c: c}
+}
+
+// Describe 不发送任何描述，见 prometheus.Collector
+func (c *Collector) Describe(chan<- *prometheus.Desc) {}
+
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	for _, f := range c.c.Gather() {
+		typ := prometheus.GaugeValue
+		if f.Type == "counter" {
+			typ = prometheus.CounterValue
+		}
+		for _, s := range f.Samples {
+			names := make([]string, len(s.Labels))
+			values := make([]string, len(s.Labels))
+			for i, label := range s.Labels {
+				names[i], values[i] = label.Name, label.Value
+			}
+			desc := prometheus.NewDesc(f.Name, f.Help, names, nil)
+			metric, err := prometheus.NewConstMetric(desc, typ, s.Value, values...)
+			if err != nil {
+				metric = prometheus.NewInvalidMetric(desc, err)
+			}
+			ch <- metric
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package logxprom
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/capyflow/opensource/logx"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestRegister(t *testing.T) {
+	log, err := logx.NewLogger(filepath.Join(t.TempDir(), "app.log"), logx.INFO, 1, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	log.StartWorker()
+	log.Info("hello")
+	log.Close()
+
+	reg := prometheus.NewPedanticRegistry()
+	if err := reg.Register(New(log.Collector(map[string]string{"service": "billing"}))); err != nil {
+		t.Fatal(err)
+	}
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range families {
+		if f.GetName() != "logx_entries_total" {
+			continue
+		}
+		for _, m := range f.GetMetric() {
+			labels := map[string]string{}
+			for _, l := range m.GetLabel() {
+				labels[l.GetName()] = l.GetValue()
+			}
+			if labels["level"] == "info" && labels["service"] == "billing" && m.GetCounter().GetValue() == 1 {
+				return
+			}
+		}
+	}
+	t.Fatalf("logx_entries_total{level=\"info\"} 1 not found in %v", families)
+}
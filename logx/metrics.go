@@ -0,0 +1,257 @@
+package logx
+
+import (
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// 日志记录器自身的运行指标
+type metrics struct {
+	entries     [ERROR + 1]atomic.Uint64 // 按等级统计已写入的条数
+	otherLevels atomic.Uint64            // 自定义等级
+	bytes       atomic.Uint64
+	dropped     atomic.Uint64
+	afterClose  atomic.Uint64
+	coalesced   atomic.Uint64
+	rotations   atomic.Uint64
+	writeErrors atomic.Uint64
+	truncated   atomic.Uint64
+	filtered    atomic.Uint64
+}
+
+// Stats 运行指标快照
+type Stats struct {
+	Entries      map[LogLevel]uint64 // 按等级统计已写入的条数
+	Bytes        uint64              // 写入日志文件的字节数
+	Dropped      uint64              // 被采样丢弃的条数
+	Coalesced    uint64              // 被合并为重复计数的条数
+	Rotations    uint64              // 文件切割次数
+	WriteErrors  uint64              // 写入文件或输出目标失败的次数
+	Truncated    uint64              // 超过 WithMaxEntrySize 被截断的条数
+	Filtered     uint64              // 被 WithFilter 的规则过滤的条数
+	AfterClose   uint64              // Close 之后收到的条数，见 WithClosedPolicy
+	QueueDepth   int                 // 等待写入的条数
+	QueueCap     int                 // 队列容量
+	Degradations []DegradationReport // 最近的降级报告，最后一个可能仍在进行中
+	Stages       []StageStats        // 按处理顺序排列的已开启环节的指标，见 Logger.Pipeline
+	DryRun       *DryRunStats        // 试运行规则的统计，见 WithDryRun
+}
+
+func (m *metrics) countEntry(level LogLevel, n int) {
+	if level >= DEBUG && level <= ERROR {
+		m.entries[level].Add(1)
+	} else {
+		m.otherLevels.Add(1)
+	}
+	m.bytes.Add(uint64(n))
+}
+
+// Stats 返回当前的运行指标
+func (l *Logger) Stats() Stats {
+	s := Stats{
+		Entries:     make(map[LogLevel]uint64, len(l.metrics.entries)),
+		Bytes:       l.metrics.bytes.Load(),
+		Dropped:     l.metrics.dropped.Load(),
+		Coalesced:   l.metrics.coalesced.Load(),
+		Rotations:   l.metrics.rotations.Load(),
+		WriteErrors: l.metrics.writeErrors.Load(),
+		Truncated:   l.metrics.truncated.Load(),
+		Filtered:    l.metrics.filtered.Load(),
+		AfterClose:  l.metrics.afterClose.Load(),
+		QueueDepth:  l.queue.len(),
+		QueueCap:    l.queue.cap(),
+		Stages:      l.stageStats(),
+		DryRun:      l.DryRunStats(),
+	}
+	for level := range l.metrics.entries {
+		s.Entries[LogLevel(level)] = l.metrics.entries[level].Load()
+	}
+	if l.degradation != nil {
+		s.Degradations = l.degradation.snapshot()
+	}
+	if n := l.metrics.otherLevels.Load(); n > 0 {
+		s.Entries[LogLevel(-1)] = n
+	}
+	return s
+}
+
+// Collector 以 Prometheus 文本格式导出日志记录器的运行指标。
+// logx 不依赖 prometheus 客户端库，Collector 实现了 http.Handler，
+// 可以直接挂到 /metrics 上，也可以通过 WriteTo 合并到已有的指标输出中；
+// 需要注册到 prometheus.Registry 时使用 logxprom 模块，它通过 Gather 实现 prometheus.Collector
+type Collector struct {
+	l      *Logger
+	labels []Label
+}
+
+// MetricFamily 一个指标及其样本，Type 为 counter 或 gauge
+type MetricFamily struct {
+	Name    string
+	Help    string
+	Type    string
+	Samples []Sample
+}
+
+// Sample 一个样本，Labels 包括 Collector 的常量标签
+type Sample struct {
+	Labels []Label
+	Value  float64
+}
+
+// Label 标签名和值
+type Label struct {
+	Name, Value string
+}
+
+// Collector 返回导出运行指标的 Collector，labels 为附加到所有指标上的常量标签
+func (l *Logger) Collector(labels ...map[string]string) *Collector {
+	c := &Collector{l: l}
+	if len(labels) > 0 && len(labels[0]) > 0 {
+		keys := make([]string, 0, len(labels[0]))
+		for k := range labels[0] {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			c.labels = append(c.labels, Label{k, labels[0][k]})
+		}
+	}
+	return c
+}
+
+func (c *Collector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	c.WriteTo(w)
+}
+
+// 按指标逐个添加样本
+type familyBuilder struct {
+	c        *Collector
+	families []MetricFamily
+}
+
+func (b *familyBuilder) family(name, typ, help string) {
+	b.families = append(b.families, MetricFamily{Name: name, Help: help, Type: typ})
+}
+
+// 向最后一个指标添加样本，labels 为成对的标签名和值
+func (b *familyBuilder) sample(value float64, labels ...string) {
+	f := &b.families[len(b.families)-1]
+	all := make([]Label, 0, len(labels)/2+len(b.c.labels))
+	for i := 0; i+1 < len(labels); i += 2 {
+		all = append(all, Label{labels[i], labels[i+1]})
+	}
+	f.Samples = append(f.Samples, Sample{Labels: append(all, b.c.labels...), Value: value})
+}
+
+func (b *familyBuilder) metric(name, typ, help string, value float64) {
+	b.family(name, typ, help)
+	b.sample(value)
+}
+
+// Gather 返回当前所有指标
+func (c *Collector) Gather() []MetricFamily {
+	s := c.l.Stats()
+	b := &familyBuilder{c: c}
+
+	b.family("logx_entries_total", "counter", "Entries written by level.")
+	levels := make([]int, 0, len(s.Entries))
+	for level := range s.Entries {
+		levels = append(levels, int(level))
+	}
+	sort.Ints(levels)
+	for _, level := range levels {
+		b.sample(float64(s.Entries[LogLevel(level)]), "level", strings.ToLower(levelString(LogLevel(level))))
+	}
+	b.metric("logx_bytes_total", "counter", "Bytes written to the log file.", float64(s.Bytes))
+	b.metric("logx_dropped_total", "counter", "Entries dropped by sampling.", float64(s.Dropped))
+	b.metric("logx_coalesced_total", "counter", "Entries coalesced into repeat counters.", float64(s.Coalesced))
+	b.metric("logx_rotations_total", "counter", "Log file rotations.", float64(s.Rotations))
+	b.metric("logx_write_errors_total", "counter", "Failed writes to the log file or sinks.", float64(s.WriteErrors))
+	b.metric("logx_truncated_total", "counter", "Entries truncated to the maximum entry size.", float64(s.Truncated))
+	b.metric("logx_filtered_total", "counter", "Entries dropped by filter rules.", float64(s.Filtered))
+	b.metric("logx_after_close_total", "counter", "Entries logged after Close.", float64(s.AfterClose))
+	b.metric("logx_queue_depth", "gauge", "Entries waiting to be written.", float64(s.QueueDepth))
+	b.metric("logx_queue_capacity", "gauge", "Capacity of the entry queue.", float64(s.QueueCap))
+
+	b.family("logx_stage_in_total", "counter", "Entries entering each pipeline stage.")
+	for _, st := range s.Stages {
+		b.sample(float64(st.In), "stage", st.Name)
+	}
+	b.family("logx_stage_dropped_total", "counter", "Entries dropped or coalesced by each pipeline stage.")
+	for _, st := range s.Stages {
+		b.sample(float64(st.Dropped), "stage", st.Name)
+	}
+	b.family("logx_stage_seconds_total", "counter", "Time spent in each pipeline stage.")
+	for _, st := range s.Stages {
+		b.sample(st.Time.Seconds(), "stage", st.Name)
+	}
+	if d := s.DryRun; d != nil {
+		b.metric("logx_dry_run_evaluated_total", "counter", "Entries evaluated against dry-run rules.", float64(d.Evaluated))
+		b.metric("logx_dry_run_dropped_total", "counter", "Entries dry-run rules would drop.", float64(d.Dropped))
+		b.metric("logx_dry_run_downgraded_total", "counter", "Entries dry-run rules would downgrade.", float64(d.Downgraded))
+		b.family("logx_dry_run_routed_total", "counter", "Entries dry-run rules would send to each sink.")
+		names := make([]string, 0, len(d.Routed))
+		for name := range d.Routed {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			b.sample(float64(d.Routed[name]), "sink", name)
+		}
+	}
+	return b.families
+}
+
+// WriteTo 以 Prometheus 文本格式写出所有指标
+func (c *Collector) WriteTo(w io.Writer) (int64, error) {
+	var buf []byte
+	for _, f := range c.Gather() {
+		buf = append(buf, "# HELP "+f.Name+" "...)
+		buf = append(buf, promHelpEscaper.Replace(f.Help)...)
+		buf = append(buf, "\n# TYPE "+f.Name+" "+f.Type+"\n"...)
+		for _, sample := range f.Samples {
+			buf = append(buf, f.Name...)
+			if len(sample.Labels) > 0 {
+				buf = append(buf, '{')
+				for i, label := range sample.Labels {
+					if i > 0 {
+						buf = append(buf, ',')
+					}
+					buf = append(buf, label.Name+`="`...)
+					buf = append(buf, promLabelEscaper.Replace(label.Value)...)
+					buf = append(buf, '"')
+				}
+				buf = append(buf, '}')
+			}
+			buf = append(buf, ' ')
+			buf = appendPromValue(buf, sample.Value)
+			buf = append(buf, '\n')
+		}
+	}
+	n, err := w.Write(buf)
+	return int64(n), err
+}
+
+// 文本格式只转义反斜杠、双引号(仅标签值)和换行，其他字符(包括非 ASCII)原样输出
+var (
+	promLabelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	promHelpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+)
+
+func appendPromValue(buf []byte, v float64) []byte {
+	switch {
+	case math.IsInf(v, 1):
+		return append(buf, "+Inf"...)
+	case math.IsInf(v, -1):
+		return append(buf, "-Inf"...)
+	case math.IsNaN(v):
+		return append(buf, "NaN"...)
+	}
+	return strconv.AppendFloat(buf, v, 'f', -1, 64)
+}
@@ -0,0 +1,56 @@
+package logx
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// WithMultiProcess 多个进程写同一个日志文件时使用：切割前通过 <文件名>.lock 加建议锁，
+// 并检查文件是否已被其他进程切割，避免重复重命名导致日志丢失。
+// 开启后每次写入都会读取文件实际大小，并检查路径是否仍指向打开的文件，被其他进程切割后重新打开
+func WithMultiProcess() Option {
+	return func(l *Logger) {
+		l.multiProcess = true
+	}
+}
+
+// WithExternalRotation 关闭内部切割，只以 O_APPEND 方式追加写入，由外部工具(logrotate 等)负责切割，
+// 通常与 WithReopenCheck 或 WithReopenOnSignal 一起使用
+func WithExternalRotation() Option {
+	return func(l *Logger) {
+		l.externalRotation = true
+	}
+}
+
+// 持有文件锁时切割，pending 为即将写入的字节数，调用方需持有 l.mu
+func (l *Logger) rotateLocked(pending int64) error {
+	if err := l.mkdirAll(filepath.Dir(l.filePath)); err != nil {
+		return err
+	}
+	lock, err := os.OpenFile(l.filePath+".lock", os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return err
+	}
+	defer lock.Close()
+	if err := l.chown(lock); err != nil {
+		return err
+	}
+	if err := lockFile(lock); err != nil {
+		return err
+	}
+	defer unlockFile(lock)
+
+	// 其他进程可能已经完成了切割，此时只需要重新打开
+	if l.file != nil {
+		current, err1 := l.file.Stat()
+		onDisk, err2 := os.Stat(l.filePath)
+		if err1 == nil && err2 == nil && !os.SameFile(current, onDisk) {
+			return l.reopen()
+		}
+		if err2 == nil && onDisk.Size()+pending <= l.maxSize {
+			l.currentSize = onDisk.Size()
+			return nil
+		}
+	}
+	return l.rotate()
+}
@@ -0,0 +1,92 @@
+package logx
+
+import (
+	"math"
+	"strconv"
+)
+
+// JavaScript 的 Number 能精确表示的最大整数 2^53-1
+const maxSafeInteger = 1<<53 - 1
+
+// NumberFormat JSON 输出中数字字段的格式，零值与默认输出相同。
+// strconv 的格式化与系统区域设置无关，不会出现逗号小数点
+type NumberFormat struct {
+	FloatPrecision int  // 大于0时浮点数固定保留的小数位数，不使用科学计数法
+	NoExponent     bool // 浮点数以最短的小数形式输出，不使用科学计数法，例如 1e-07 输出为 0.0000001
+	BigIntAsString bool // 超出 ±(2^53-1) 的整数输出为字符串，JavaScript 解析时不会丢失精度(例如64位ID)
+}
+
+// WithNumberFormat 设置 JSON 格式中数字字段的输出方式，只影响字段值本身，
+// 结构体、map 等通过 encoding/json 编码的值不受影响
+func WithNumberFormat(f NumberFormat) Option {
+	return func(l *Logger) {
+		if f == (NumberFormat{}) {
+			l.numberFormat = nil
+			return
+		}
+		l.numberFormat = &f
+	}
+}
+
+// 按 NumberFormat 编码数字，不是数字或不需要特殊处理时返回 false
+func (f *NumberFormat) appendJSON(buf []byte, v interface{}) ([]byte, bool) {
+	switch val := v.(type) {
+	case float64:
+		return f.appendFloat(buf, val, 64), true
+	case float32:
+		return f.appendFloat(buf, float64(val), 32), true
+	case int:
+		return f.appendInt(buf, int64(val))
+	case int64:
+		return f.appendInt(buf, val)
+	case uint:
+		return f.appendUint(buf, uint64(val))
+	case uint64:
+		return f.appendUint(buf, val)
+	}
+	return buf, false
+}
+
+func (f *NumberFormat) appendFloat(buf []byte, v float64, bits int) []byte {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return appendJSONFloat(buf, v, bits)
+	}
+	switch {
+	case f.FloatPrecision > 0:
+		return strconv.AppendFloat(buf, v, 'f', f.FloatPrecision, bits)
+	case f.NoExponent:
+		return strconv.AppendFloat(buf, v, 'f', -1, bits)
+	}
+	return strconv.AppendFloat(buf, v, 'g', -1, bits)
+}
+
+func (f *NumberFormat) appendInt(buf []byte, v int64) ([]byte, bool) {
+	if !f.BigIntAsString || (v <= maxSafeInteger && v >= -maxSafeInteger) {
+		return buf, false
+	}
+	buf = append(buf, '"')
+	buf = strconv.AppendInt(buf, v, 10)
+	return append(buf, '"'), true
+}
+
+func (f *NumberFormat) appendUint(buf []byte, v uint64) ([]byte, bool) {
+	if !f.BigIntAsString || v <= maxSafeInteger {
+		return buf, false
+	}
+	buf = append(buf, '"')
+	buf = strconv.AppendUint(buf, v, 10)
+	return append(buf, '"'), true
+}
+
+// 编码字段值，开启 WithNumberFormat 时按其格式输出数字，分组中的字段也一样
+func (l *Logger) appendJSONValue(buf []byte, v interface{}) []byte {
+	if g, ok := v.(FieldGroup); ok {
+		return l.appendJSONGroup(buf, g)
+	}
+	if l.numberFormat != nil {
+		if out, ok := l.numberFormat.appendJSON(buf, v); ok {
+			return out
+		}
+	}
+	return appendJSONValue(buf, v)
+}
@@ -0,0 +1,29 @@
+package logx
+
+import "io"
+
+// Option 日志记录器的可选配置
+type Option func(*Logger)
+
+// WithFormat 设置日志输出格式
+func WithFormat(format Format) Option {
+	return func(l *Logger) {
+		l.format = format
+	}
+}
+
+// WithConsoleWriter 设置控制台输出的目标(默认 os.Stdout)并开启控制台输出，
+// 可以是 stderr、界面控件、测试用的缓冲区或管道
+func WithConsoleWriter(w io.Writer) Option {
+	return func(l *Logger) {
+		l.consoleOut = true
+		l.consoleWriter = w
+	}
+}
+
+// WithErrorConsoleWriter 设置 WithErrorStream 中高等级日志的控制台输出目标，默认 os.Stderr
+func WithErrorConsoleWriter(w io.Writer) Option {
+	return func(l *Logger) {
+		l.errConsoleWriter = w
+	}
+}
@@ -0,0 +1,55 @@
+package logx
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+)
+
+// WithOwner 设置日志记录器创建的日志文件、目录、锁文件和压缩归档的所有者和属组，
+// 例如以 root 运行时 WithOwner("", "logs") 让采集程序所在的 logs 组可以读取。
+// owner、group 可以是名称或数字 ID，为空表示不修改；只支持类 Unix 系统，修改失败时打开文件也视为失败
+func WithOwner(owner, group string) Option {
+	return func(l *Logger) {
+		o, err := lookupOwner(owner, group)
+		if err != nil {
+			l.optErr = err
+			return
+		}
+		l.owner = o
+	}
+}
+
+type fileOwner struct {
+	uid, gid int // -1 表示不修改
+}
+
+// 创建目录，并修改新建的各级目录的所有者
+func (l *Logger) mkdirAll(dir string) error {
+	if l.owner == nil {
+		return os.MkdirAll(dir, 0755)
+	}
+	var created []string
+	for d := dir; ; d = filepath.Dir(d) {
+		if _, err := os.Stat(d); !errors.Is(err, os.ErrNotExist) || d == filepath.Dir(d) {
+			break
+		}
+		created = append(created, d)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	for _, d := range created {
+		if err := os.Chown(d, l.owner.uid, l.owner.gid); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (l *Logger) chown(file *os.File) error {
+	if l.owner == nil {
+		return nil
+	}
+	return file.Chown(l.owner.uid, l.owner.gid)
+}
@@ -0,0 +1,16 @@
+//go:build !unix
+
+package logx
+
+import (
+	"errors"
+	"os"
+)
+
+func lookupOwner(owner, group string) (*fileOwner, error) {
+	return nil, errors.New("logx: WithOwner is only supported on unix")
+}
+
+func copyOwner(dst *os.File, src os.FileInfo) error {
+	return nil
+}
@@ -0,0 +1,46 @@
+//go:build unix
+
+package logx
+
+import (
+	"os"
+	"os/user"
+	"strconv"
+	"syscall"
+)
+
+func lookupOwner(owner, group string) (*fileOwner, error) {
+	o := &fileOwner{uid: -1, gid: -1}
+	if owner != "" {
+		id, err := strconv.Atoi(owner)
+		if err != nil {
+			u, err := user.Lookup(owner)
+			if err != nil {
+				return nil, err
+			}
+			id, _ = strconv.Atoi(u.Uid)
+		}
+		o.uid = id
+	}
+	if group != "" {
+		id, err := strconv.Atoi(group)
+		if err != nil {
+			g, err := user.LookupGroup(group)
+			if err != nil {
+				return nil, err
+			}
+			id, _ = strconv.Atoi(g.Gid)
+		}
+		o.gid = id
+	}
+	return o, nil
+}
+
+// 压缩后的归档保持原文件的所有者
+func copyOwner(dst *os.File, src os.FileInfo) error {
+	stat, ok := src.Sys().(*syscall.Stat_t)
+	if !ok || (int(stat.Uid) == os.Geteuid() && int(stat.Gid) == os.Getegid()) {
+		return nil
+	}
+	return dst.Chown(int(stat.Uid), int(stat.Gid))
+}
@@ -0,0 +1,50 @@
+//go:build unix
+
+package logx
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+func TestOwner(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("requires root")
+	}
+	dir := filepath.Join(t.TempDir(), "var", "log")
+	path := filepath.Join(dir, "app.log")
+	log, err := NewLogger(path, DEBUG, 1, false, WithOwner("4242", "4343"), WithArchiveCompression(Gzip))
+	if err != nil {
+		t.Fatal(err)
+	}
+	log.StartWorker()
+	log.Info("hello")
+	log.mu.Lock()
+	err = log.rotate()
+	log.mu.Unlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	log.Close()
+
+	archives, _ := Archives(path)
+	if len(archives) != 1 || !strings.HasSuffix(archives[0], ".gzip") {
+		t.Fatalf("expected a compressed archive, got %v", archives)
+	}
+	for _, p := range []string{filepath.Dir(dir), dir, path, archives[0]} {
+		info, err := os.Stat(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		stat := info.Sys().(*syscall.Stat_t)
+		if stat.Uid != 4242 || stat.Gid != 4343 {
+			t.Fatalf("%s is owned by %d:%d", p, stat.Uid, stat.Gid)
+		}
+	}
+	if _, err := NewLogger(path, DEBUG, 1, false, WithOwner("", "no-such-group-logx")); err == nil {
+		t.Fatal("expected unknown group to fail")
+	}
+}
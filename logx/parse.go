@@ -0,0 +1,163 @@
+package logx
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrUnrecognizedLine 无法识别的日志行
+var ErrUnrecognizedLine = errors.New("logx: unrecognized log line")
+
+var (
+	textLineRe  = regexp.MustCompile(`^(\d{4}/\d{2}/\d{2} \d{2}:\d{2}:\d{2}(?:\.\d+)?) \[([A-Z]+)\] (.*)$`)
+	callerRe    = regexp.MustCompile(`^\S+\.go:\d+$`)
+	tailFieldRe = regexp.MustCompile(` ([A-Za-z_][\w.\-]*)=("(?:[^"\\]|\\.)*"|[^\s"]*)$`)
+)
+
+// ParseLine 解析一行由 logx 写出的日志(文本或JSON格式)，文本格式中的时间按本地时区解析
+func ParseLine(line []byte) (Entry, error) {
+	line = bytes.TrimRight(line, "\r\n")
+	if len(line) > 0 && line[0] == '{' {
+		return parseJSONLine(line)
+	}
+	return parseTextLine(string(line))
+}
+
+func parseTextLine(line string) (Entry, error) {
+	m := textLineRe.FindStringSubmatch(line)
+	if m == nil {
+		return Entry{}, ErrUnrecognizedLine
+	}
+	t, err := time.ParseInLocation(defaultTextTimeLayout, m[1], time.Local)
+	if err != nil {
+		return Entry{}, err
+	}
+	level, err := ParseLevel(m[2])
+	if err != nil {
+		return Entry{}, err
+	}
+	entry := Entry{Level: level, Time: t}
+	rest := m[3]
+
+	// 从行尾开始解析 k=v 字段
+	var fields []Field
+	for {
+		fm := tailFieldRe.FindStringSubmatchIndex(rest)
+		if fm == nil {
+			break
+		}
+		key := rest[fm[2]:fm[3]]
+		raw := rest[fm[4]:fm[5]]
+		var value interface{} = raw
+		if strings.HasPrefix(raw, `"`) {
+			if s, err := strconv.Unquote(raw); err == nil {
+				value = s
+			}
+		}
+		fields = append(fields, Field{Key: key, Value: value})
+		rest = rest[:fm[0]]
+	}
+	for i, j := 0, len(fields)-1; i < j; i, j = i+1, j-1 {
+		fields[i], fields[j] = fields[j], fields[i]
+	}
+	entry.Fields = fields
+
+	if caller, msg, ok := strings.Cut(rest, " "); ok && callerRe.MatchString(caller) {
+		entry.File, entry.Line = splitCaller(caller)
+		rest = msg
+	}
+	entry.Message = rest
+	return entry, nil
+}
+
+func parseJSONLine(line []byte) (Entry, error) {
+	dec := json.NewDecoder(bytes.NewReader(line))
+	dec.UseNumber()
+	var m map[string]interface{}
+	if err := dec.Decode(&m); err != nil {
+		return Entry{}, fmt.Errorf("%w: %v", ErrUnrecognizedLine, err)
+	}
+	levelName, _ := m["level"].(string)
+	level, err := ParseLevel(levelName)
+	if err != nil {
+		return Entry{}, err
+	}
+	entry := Entry{Level: level}
+	entry.Message, _ = m["msg"].(string)
+	switch t := m["time"].(type) {
+	case string:
+		entry.Time, err = time.Parse(time.RFC3339Nano, t)
+		if err != nil {
+			return Entry{}, err
+		}
+	case json.Number:
+		// 整数时间戳，根据位数判断精度
+		n, err := t.Int64()
+		if err != nil {
+			return Entry{}, err
+		}
+		entry.Time = epochTime(n)
+	}
+	if caller, ok := m["caller"].(string); ok {
+		entry.File, entry.Line = splitCaller(caller)
+	}
+
+	// 保持字段在原始行中的顺序
+	for _, key := range jsonKeyOrder(line) {
+		if reservedJSONKeys[key] {
+			continue
+		}
+		if v, ok := m[key]; ok {
+			entry.Fields = append(entry.Fields, Field{Key: strings.TrimPrefix(key, "fields."), Value: v})
+		}
+	}
+	return entry, nil
+}
+
+func epochTime(n int64) time.Time {
+	switch {
+	case n > 1e17:
+		return time.Unix(0, n)
+	case n > 1e11:
+		return time.UnixMilli(n)
+	default:
+		return time.Unix(n, 0)
+	}
+}
+
+// 顶层对象中键的出现顺序
+func jsonKeyOrder(line []byte) []string {
+	dec := json.NewDecoder(bytes.NewReader(line))
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+		return nil
+	}
+	var keys []string
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return keys
+		}
+		key, _ := tok.(string)
+		keys = append(keys, key)
+		var skip json.RawMessage
+		if err := dec.Decode(&skip); err != nil {
+			return keys
+		}
+	}
+	return keys
+}
+
+func splitCaller(caller string) (string, int) {
+	i := strings.LastIndexByte(caller, ':')
+	if i < 0 {
+		return caller, 0
+	}
+	line, _ := strconv.Atoi(caller[i+1:])
+	return caller[:i], line
+}
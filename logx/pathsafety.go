@@ -0,0 +1,90 @@
+package logx
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ErrUnsafePath 日志文件路径没有通过安全检查
+var ErrUnsafePath = errors.New("logx: unsafe log path")
+
+// WithStrictPaths 开启严格的路径检查，适合以较高权限运行、路径可能受外部输入影响的程序：
+// 路径中不能出现 ".."，日志文件及其所在目录不能是符号链接，日志文件不能有多个硬链接，
+// 并且(类 Unix 系统上)文件和目录必须属于当前用户或 root，目录不能被其他用户写入(设置了粘滞位的除外)。
+// 之后每次打开文件都不跟随符号链接
+func WithStrictPaths() Option {
+	return func(l *Logger) {
+		l.strictPaths = true
+	}
+}
+
+// 创建日志记录器时检查路径。默认只拒绝空路径和中间出现的 ".."(例如 logs/../../etc/passwd)，
+// 开头的 ../ 视为有意的相对路径
+func (l *Logger) checkPath() error {
+	path := l.filePath
+	if path == "" || strings.ContainsRune(path, 0) {
+		return fmt.Errorf("%w: %q", ErrUnsafePath, path)
+	}
+	leading := true
+	for _, elem := range strings.FieldsFunc(path, isPathSeparator) {
+		if elem != ".." {
+			leading = false
+			continue
+		}
+		if !leading || l.strictPaths {
+			return fmt.Errorf("%w: %q contains \"..\"", ErrUnsafePath, path)
+		}
+	}
+	if !l.strictPaths {
+		return nil
+	}
+	if err := checkPathTarget(path, false); err != nil {
+		return err
+	}
+	return checkPathTarget(filepath.Dir(path), true)
+}
+
+func isPathSeparator(r rune) bool {
+	return r < 0x80 && os.IsPathSeparator(uint8(r))
+}
+
+// 检查文件或目录本身，不存在时跳过
+func checkPathTarget(path string, dir bool) error {
+	info, err := os.Lstat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if info.Mode()&os.ModeSymlink != 0 {
+		return fmt.Errorf("%w: %s is a symlink", ErrUnsafePath, path)
+	}
+	if dir && !info.IsDir() {
+		return fmt.Errorf("%w: %s is not a directory", ErrUnsafePath, path)
+	}
+	if !dir && !info.Mode().IsRegular() {
+		return fmt.Errorf("%w: %s is not a regular file", ErrUnsafePath, path)
+	}
+	return checkOwnership(path, info, dir)
+}
+
+// 打开(或创建)日志文件，严格模式下不跟随符号链接，设置了 WithOwner 时修改所有者
+func (l *Logger) openLogFile() (*os.File, error) {
+	flag := os.O_CREATE | os.O_WRONLY | os.O_APPEND
+	if l.strictPaths {
+		flag |= noFollow
+	}
+	file, err := os.OpenFile(l.filePath, flag, 0644)
+	if err != nil {
+		return nil, err
+	}
+	if err := l.chown(file); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return file, nil
+}
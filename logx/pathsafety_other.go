@@ -0,0 +1,12 @@
+//go:build !unix
+
+package logx
+
+import "os"
+
+const noFollow = 0
+
+// 非类 Unix 系统上不检查所有者
+func checkOwnership(path string, info os.FileInfo, dir bool) error {
+	return nil
+}
@@ -0,0 +1,29 @@
+//go:build unix
+
+package logx
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+const noFollow = syscall.O_NOFOLLOW
+
+func checkOwnership(path string, info os.FileInfo, dir bool) error {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return nil
+	}
+	if uid := os.Geteuid(); int(stat.Uid) != uid && stat.Uid != 0 {
+		return fmt.Errorf("%w: %s is owned by uid %d", ErrUnsafePath, path, stat.Uid)
+	}
+	if dir {
+		if info.Mode().Perm()&0022 != 0 && info.Mode()&os.ModeSticky == 0 {
+			return fmt.Errorf("%w: %s is writable by other users", ErrUnsafePath, path)
+		}
+	} else if stat.Nlink > 1 {
+		return fmt.Errorf("%w: %s has %d hard links", ErrUnsafePath, path, stat.Nlink)
+	}
+	return nil
+}
@@ -0,0 +1,230 @@
+package logx
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// 处理流程中内置环节的名称，按执行顺序排列。level 和 sampling 在调用日志方法的协程中执行，
+// 其余环节在写入协程中编码之前执行，最后经过 dedup 合并重复日志后由 output 写入文件和各输出目标
+const (
+	StageLevel       = "level"       // 最低等级、模块临时调高等级
+	StageSampling    = "sampling"    // WithSampling 采样
+	StageWriteTime   = "write_time"  // WithWriteTime、WithTimeSkewField
+	StageEnrich      = "enrich"      // WithStaticFields
+	StageRedact      = "redact"      // WithRedaction
+	StageDryRun      = "dry_run"     // WithDryRun，只统计不修改日志
+	StageFilter      = "filter"      // WithFilter
+	StageRoute       = "route"       // WithRoutes
+	StageIndex       = "index"       // WithIndexedKeys
+	StageCardinality = "cardinality" // WithCardinalityGuard
+	StageBlobs       = "blobs"       // WithBlobStore
+	StageTruncate    = "truncate"    // WithMaxEntrySize
+	StageDedup       = "dedup"       // WithDedup
+	StageOutput      = "output"      // 文件、控制台和 WithSink 等输出目标
+)
+
+// StageFunc 自定义处理环节，可以修改日志，返回 false 时丢弃该条日志，后面的环节不再执行。
+// 在编码之前、不持有锁时调用，同步写入等情况下可能被多个协程同时调用，需要自行保证并发安全
+type StageFunc func(entry *Entry) bool
+
+type stage struct {
+	name    string
+	enabled bool
+	run     func(entry *Entry, warnings *[]Entry) bool
+}
+
+// 单个环节的计数，Out 为 in - dropped
+type stageCounters struct {
+	in      atomic.Uint64
+	dropped atomic.Uint64
+	nanos   atomic.Int64
+}
+
+func (c *stageCounters) observe(kept bool, d time.Duration) {
+	c.in.Add(1)
+	if !kept {
+		c.dropped.Add(1)
+	}
+	c.nanos.Add(int64(d))
+}
+
+// StageStats 单个处理环节的指标
+type StageStats struct {
+	Name    string
+	In      uint64        // 进入该环节的条数
+	Out     uint64        // 通过该环节的条数
+	Dropped uint64        // 被该环节丢弃或合并的条数
+	Time    time.Duration // 累计耗时，sampling 在调用方执行，不统计耗时
+}
+
+type stageInsert struct {
+	name   string
+	anchor string
+	before bool
+	fn     StageFunc
+}
+
+// WithStageAfter 在名为 after 的环节之后插入自定义环节 name，after 可以是内置环节或之前插入的自定义环节。
+// 插在 sampling 之后即为写入协程中的第一个环节；不能插在 dedup 之后
+func WithStageAfter(name, after string, fn StageFunc) Option {
+	return func(l *Logger) {
+		l.stageInserts = append(l.stageInserts, stageInsert{name: name, anchor: after, fn: fn})
+	}
+}
+
+// WithStageBefore 在名为 before 的环节之前插入自定义环节 name，不能插在 sampling 之前
+func WithStageBefore(name, before string, fn StageFunc) Option {
+	return func(l *Logger) {
+		l.stageInserts = append(l.stageInserts, stageInsert{name: name, anchor: before, before: true, fn: fn})
+	}
+}
+
+func (l *Logger) builtinStages() []stage {
+	return []stage{
+		{StageWriteTime, l.writeTime || l.skewKey != "", func(e *Entry, _ *[]Entry) bool {
+			*e = l.stampWriteTime(*e)
+			return true
+		}},
+		{StageEnrich, len(l.staticFields) > 0, func(e *Entry, _ *[]Entry) bool {
+			*e = l.applyStaticFields(*e)
+			return true
+		}},
+		{StageRedact, l.redactor != nil, func(e *Entry, _ *[]Entry) bool {
+			*e = l.redactor.apply(*e)
+			return true
+		}},
+		{StageDryRun, l.dryRun != nil, func(e *Entry, _ *[]Entry) bool {
+			l.evaluateDryRun(e)
+			return true
+		}},
+		{StageFilter, l.filter != nil, func(e *Entry, _ *[]Entry) bool {
+			if !l.filter.keep(e) {
+				l.metrics.filtered.Add(1)
+				return false
+			}
+			return true
+		}},
+		{StageRoute, l.router != nil, func(e *Entry, _ *[]Entry) bool {
+			return l.route(e)
+		}},
+		{StageIndex, l.indexedKeys != nil, func(e *Entry, _ *[]Entry) bool {
+			if len(e.Fields) > 0 {
+				*e = l.applyIndexHints(*e)
+			}
+			return true
+		}},
+		{StageCardinality, l.cardinality != nil, func(e *Entry, warnings *[]Entry) bool {
+			if len(e.Fields) > 0 {
+				var w []Entry
+				*e, w = l.cardinality.check(*e)
+				*warnings = append(*warnings, w...)
+			}
+			return true
+		}},
+		{StageBlobs, l.blobs != nil, func(e *Entry, _ *[]Entry) bool {
+			if len(e.Fields) > 0 {
+				*e = l.offloadBlobs(*e)
+			}
+			return true
+		}},
+		{StageTruncate, l.maxEntrySize > 0, func(e *Entry, _ *[]Entry) bool {
+			*e = l.truncateEntry(*e)
+			return true
+		}},
+	}
+}
+
+// 由内置环节和 WithStageAfter、WithStageBefore 插入的环节组成写入协程中执行的处理流程
+func (l *Logger) buildPipeline() error {
+	stages := l.builtinStages()
+	for _, ins := range l.stageInserts {
+		if ins.name == "" || ins.fn == nil {
+			return fmt.Errorf("logx: stage needs a name and a function")
+		}
+		switch ins.name {
+		case StageLevel, StageSampling, StageDedup, StageOutput:
+			return fmt.Errorf("logx: duplicate stage %q", ins.name)
+		}
+		at := -1
+		for i, s := range stages {
+			if s.name == ins.name {
+				return fmt.Errorf("logx: duplicate stage %q", ins.name)
+			}
+			if s.name == ins.anchor {
+				at = i
+			}
+		}
+		switch {
+		case at >= 0 && !ins.before:
+			at++
+		case at >= 0:
+		case ins.anchor == StageLevel && !ins.before, ins.anchor == StageSampling && !ins.before:
+			at = 0
+		case ins.anchor == StageDedup && ins.before:
+			at = len(stages)
+		default:
+			return fmt.Errorf("logx: cannot insert stage %q at %q", ins.name, ins.anchor)
+		}
+		fn := ins.fn
+		s := stage{name: ins.name, enabled: true, run: func(e *Entry, _ *[]Entry) bool { return fn(e) }}
+		stages = append(stages[:at], append([]stage{s}, stages[at:]...)...)
+	}
+	l.stageOrder = []string{StageLevel, StageSampling}
+	l.pipeline, l.pipelineStats = nil, nil
+	l.samplingStage = &stageCounters{}
+	l.dedupStage = &stageCounters{}
+	l.outputStage = &stageCounters{}
+	for _, s := range stages {
+		l.stageOrder = append(l.stageOrder, s.name)
+		if s.enabled {
+			l.pipeline = append(l.pipeline, s)
+			l.pipelineStats = append(l.pipelineStats, &stageCounters{})
+		}
+	}
+	l.stageOrder = append(l.stageOrder, StageDedup, StageOutput)
+	return nil
+}
+
+// 按处理顺序返回已开启环节的指标，level 在热路径上，不统计
+func (l *Logger) stageStats() []StageStats {
+	var stats []StageStats
+	add := func(name string, c *stageCounters) {
+		in, dropped := c.in.Load(), c.dropped.Load()
+		stats = append(stats, StageStats{Name: name, In: in, Out: in - dropped, Dropped: dropped, Time: time.Duration(c.nanos.Load())})
+	}
+	if l.sampler != nil {
+		add(StageSampling, l.samplingStage)
+	}
+	for i, s := range l.pipeline {
+		add(s.name, l.pipelineStats[i])
+	}
+	if l.dedup != nil {
+		add(StageDedup, l.dedupStage)
+	}
+	add(StageOutput, l.outputStage)
+	return stats
+}
+
+// Pipeline 按执行顺序返回处理流程中所有环节的名称，包括未开启的内置环节
+func (l *Logger) Pipeline() []string {
+	return append([]string(nil), l.stageOrder...)
+}
+
+// 依次执行写入协程中的处理环节，不需要持有 l.mu。返回 false 表示日志被丢弃，
+// warnings 为高基数保护等环节产生的提示日志，即使日志被丢弃也要写出
+func (l *Logger) prepare(entry Entry) (Entry, []Entry, bool) {
+	var warnings []Entry
+	start := time.Now()
+	for i, s := range l.pipeline {
+		ok := s.run(&entry, &warnings)
+		now := time.Now()
+		l.pipelineStats[i].observe(ok, now.Sub(start))
+		start = now
+		if !ok {
+			return entry, warnings, false
+		}
+	}
+	return entry, warnings, true
+}
@@ -0,0 +1,60 @@
+package logx
+
+import (
+	"log"
+	"time"
+)
+
+// WithLinePrefix 控制文本格式每行开头的前缀，取代默认的 log.LstdFlags 风格时间前缀。
+// prefix 和 flags 的含义与标准库 log.New 相同，支持 log.Ldate、log.Ltime、log.Lmicroseconds、
+// log.LUTC 和 log.Lmsgprefix；时间取调用日志方法的时间，设置了 WithTimeFormat 时按其格式输出。
+// WithLinePrefix("", 0) 完全去掉前缀，适合输出会再被 journald、容器运行时等加上时间戳的场景。
+// 前缀与默认格式不同时 ParseLine、Query 等无法识别这些行
+func WithLinePrefix(prefix string, flags int) Option {
+	return func(l *Logger) {
+		l.linePrefix = prefix
+		l.prefixFlags = flags
+		l.customPrefix = true
+	}
+}
+
+// 按 WithLinePrefix 的配置写入前缀
+func (l *Logger) appendLinePrefix(buf []byte, t time.Time) []byte {
+	if l.prefixFlags&log.Lmsgprefix == 0 {
+		buf = append(buf, l.linePrefix...)
+	}
+	if l.prefixFlags&(log.Ldate|log.Ltime|log.Lmicroseconds) != 0 {
+		if l.prefixFlags&log.LUTC != 0 {
+			t = t.UTC()
+		} else if l.timeLoc != nil {
+			t = t.In(l.timeLoc)
+		}
+		if l.timeLayout != "" {
+			buf = l.appendTime(buf, t, "", false)
+		} else {
+			buf = t.AppendFormat(buf, prefixLayout(l.prefixFlags))
+		}
+		buf = append(buf, ' ')
+	}
+	if l.prefixFlags&log.Lmsgprefix != 0 {
+		buf = append(buf, l.linePrefix...)
+	}
+	return buf
+}
+
+func prefixLayout(flags int) string {
+	layout := ""
+	if flags&log.Ldate != 0 {
+		layout = "2006/01/02"
+	}
+	if flags&(log.Ltime|log.Lmicroseconds) != 0 {
+		if layout != "" {
+			layout += " "
+		}
+		layout += "15:04:05"
+		if flags&log.Lmicroseconds != 0 {
+			layout += ".000000"
+		}
+	}
+	return layout
+}
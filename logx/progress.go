@@ -0,0 +1,72 @@
+package logx
+
+import (
+	"bytes"
+	"io"
+)
+
+// 回到行首并清除整行
+const clearLine = "\r\033[2K"
+
+// WithProgressAware 控制台输出与终端上的进度条、加载动画协作：进度条通过 ProgressWriter 绘制，
+// 输出日志前先清除进度行，日志写完后重新绘制，避免日志行和进度条混在一起。
+// w 为进度条所在的终端，为空时与控制台输出相同
+func WithProgressAware(w io.Writer) Option {
+	return func(l *Logger) {
+		l.progress = &progressLine{out: w}
+	}
+}
+
+// 由 l.mu 保护
+type progressLine struct {
+	out  io.Writer
+	line []byte // 当前进度行，最后一个 \r 或 \n 之后的内容
+}
+
+// ProgressWriter 返回给进度条库使用的 Writer，例如作为进度条的输出目标。
+// 未开启 WithProgressAware 时直接写入控制台
+func (l *Logger) ProgressWriter() io.Writer {
+	return progressWriter{l: l}
+}
+
+type progressWriter struct {
+	l *Logger
+}
+
+func (w progressWriter) Write(p []byte) (int, error) {
+	l := w.l
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.progress == nil {
+		return l.consoleWriter.Write(p)
+	}
+	pr := l.progress
+	if i := bytes.LastIndexAny(p, "\r\n"); i >= 0 {
+		pr.line = append(pr.line[:0], p[i+1:]...)
+	} else {
+		pr.line = append(pr.line, p...)
+	}
+	return l.progressOut().Write(p)
+}
+
+// 调用方需持有 l.mu
+func (l *Logger) progressOut() io.Writer {
+	if l.progress.out != nil {
+		return l.progress.out
+	}
+	return l.consoleWriter
+}
+
+// 输出日志前清除进度行，调用方需持有 l.mu
+func (l *Logger) clearProgress() {
+	if len(l.progress.line) > 0 {
+		io.WriteString(l.progressOut(), clearLine)
+	}
+}
+
+// 日志写完后重新绘制进度行，调用方需持有 l.mu
+func (l *Logger) redrawProgress() {
+	if len(l.progress.line) > 0 {
+		l.progressOut().Write(l.progress.line)
+	}
+}
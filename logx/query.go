@@ -0,0 +1,193 @@
+package logx
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// QueryOptions 查询条件，零值表示不限制
+type QueryOptions struct {
+	Since        time.Time         // 不早于该时间
+	Until        time.Time         // 早于该时间
+	MinLevel     LogLevel          // 不低于该等级
+	MessageRegex *regexp.Regexp    // 消息匹配该正则
+	Fields       map[string]string // 字段的文本值等于给定值，例如 {"user_id": "42"}
+	Name         string            // 只查询该日志文件及其归档，例如 app.log；为空时查询目录下所有日志文件，不包括 WithErrorStream 默认的 .err.log
+	Keys         KeyProvider       // 解密 WithEncryption 写入的文件
+	Limit        int               // 最多返回的条数，按时间保留最早的
+	MaxLineSize  int               // 单行最大字节数，默认 DefaultMaxLineSize，更长的行(二进制格式为记录)被跳过
+}
+
+// Query 查询 dir 下的日志文件以及切割出的归档(包括压缩后的)，返回按时间排序的匹配日志，
+// 例如最近一小时的所有 ERROR：Query("logs", QueryOptions{Since: time.Now().Add(-time.Hour), MinLevel: ERROR})。
+// 文本、JSON 和二进制格式按内容自动识别，无法识别的行会被跳过
+func Query(dir string, opts QueryOptions) ([]Entry, error) {
+	paths, err := queryFiles(dir, opts.Name)
+	if err != nil {
+		return nil, err
+	}
+	var result []Entry
+	for _, path := range paths {
+		err := readLogFile(path, opts.Keys, opts.MaxLineSize, func(entry Entry) error {
+			if opts.match(&entry) {
+				result = append(result, entry)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool { return result[i].Time.Before(result[j].Time) })
+	if opts.Limit > 0 && len(result) > opts.Limit {
+		result = result[:opts.Limit]
+	}
+	return result, nil
+}
+
+func (o *QueryOptions) match(entry *Entry) bool {
+	if entry.Level < o.MinLevel {
+		return false
+	}
+	if !o.Since.IsZero() && entry.Time.Before(o.Since) {
+		return false
+	}
+	if !o.Until.IsZero() && !entry.Time.Before(o.Until) {
+		return false
+	}
+	if o.MessageRegex != nil && !o.MessageRegex.MatchString(entry.Message) {
+		return false
+	}
+	for key, want := range o.Fields {
+		found := false
+		for _, f := range entry.Fields {
+			if f.Key == key && rawTextValue(f.Value) == want {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// 找出目录下的日志文件：当前文件(.log 结尾)以及 Archives 能识别的归档。
+// 错误日志文件中的日志在主日志文件中都有，只有用 name 指定时才查询，否则 ERROR 会重复出现
+func queryFiles(dir, name string) ([]string, error) {
+	if name != "" {
+		path := filepath.Join(dir, name)
+		paths, err := Archives(path)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := os.Stat(path); err == nil {
+			paths = append(paths, path)
+		}
+		return paths, nil
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var paths []string
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		if isErrorStream(e.Name()) {
+			continue
+		}
+		if strings.HasSuffix(e.Name(), ".log") || isArchive(e.Name()) {
+			paths = append(paths, filepath.Join(dir, e.Name()))
+		}
+	}
+	return paths, nil
+}
+
+// 文件名是否为 <name>.20060102_150405.log[.压缩编码]
+func isArchive(name string) bool {
+	_, ok := archiveBase(name)
+	return ok
+}
+
+// 归档对应的日志文件名
+func archiveBase(name string) (string, bool) {
+	i := strings.LastIndex(name, ".log")
+	if i < 0 {
+		return "", false
+	}
+	base := name[:i]
+	dot := strings.LastIndexByte(base, '.')
+	if dot < 0 {
+		return "", false
+	}
+	_, ok := archiveStamp(base[:dot], name)
+	return base[:dot], ok
+}
+
+// 是否为 errFilePath 生成的错误日志文件或其归档
+func isErrorStream(name string) bool {
+	if base, ok := archiveBase(name); ok {
+		name = base
+	}
+	return strings.HasSuffix(name, ".err.log")
+}
+
+// 按扩展名解压、按文件头解密，再按内容识别格式，依次交给 emit
+func readLogFile(path string, keys KeyProvider, maxLine int, emit func(Entry) error) error {
+	br, closeFile, err := openLogReader(path, keys)
+	if err != nil {
+		return err
+	}
+	defer closeFile()
+	if head, _ := br.Peek(len(binaryMagic)); string(head) == binaryMagic {
+		reader, err := NewReader(br, ReaderOptions{MaxRecordSize: maxLine})
+		if err != nil {
+			return err
+		}
+		for reader.Next() {
+			if err := emit(reader.Entry()); err != nil {
+				return err
+			}
+		}
+		return reader.Err()
+	}
+	_, err = Replay(context.Background(), br, emit, ReplayOptions{MaxLineSize: maxLine})
+	return err
+}
+
+// 打开日志文件，按扩展名解压，keys 不为 nil 时按文件头解密
+func openLogReader(path string, keys KeyProvider) (*bufio.Reader, func(), error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	closeFile := func() { file.Close() }
+
+	var r io.Reader = file
+	if ext := strings.TrimPrefix(filepath.Ext(path), "."); ext != "log" {
+		if c, ok := LookupCompressor(ext); ok {
+			zr, err := c.NewReader(file)
+			if err != nil {
+				file.Close()
+				return nil, nil, err
+			}
+			closeFile = func() { zr.Close(); file.Close() }
+			r = zr
+		}
+	}
+	br := bufio.NewReader(r)
+	if head, _ := br.Peek(len(encryptMagic)); string(head) == encryptMagic && keys != nil {
+		br = bufio.NewReader(NewDecryptReader(br, keys))
+	}
+	return br, closeFile, nil
+}
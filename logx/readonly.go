@@ -0,0 +1,35 @@
+package logx
+
+import (
+	"errors"
+	"io"
+	"os"
+	"syscall"
+)
+
+func isReadOnlyFS(err error) bool {
+	return errors.Is(err, syscall.EROFS)
+}
+
+// 日志目录位于只读文件系统(例如加固过的容器)时不让 NewLogger 失败，
+// 改为向 stdout 输出 JSON，并写一条 WARN 说明原因
+func (l *Logger) fallbackReadOnly(err error) {
+	l.readOnly = true
+	l.file = nil
+	l.buffer = nil
+	l.out = io.Discard
+	l.format = FormatJSON
+	l.consoleOut = true
+	l.consoleWriter = os.Stdout
+	l.enqueue(Entry{
+		Level:   WARN,
+		Time:    l.now(),
+		Message: "logx: log file is on a read-only filesystem, writing JSON to stdout instead",
+		Fields:  []Field{String("path", l.filePath), Err(err)},
+	})
+}
+
+// ReadOnlyFallback 返回是否因为只读文件系统改为只输出到 stdout
+func (l *Logger) ReadOnlyFallback() bool {
+	return l.readOnly
+}
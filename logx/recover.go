@@ -0,0 +1,125 @@
+package logx
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"runtime"
+	"runtime/debug"
+	"time"
+)
+
+// 重新 panic 前等待日志写入文件的最长时间
+const panicFlushTimeout = 2 * time.Second
+
+// RequestIDHeader 读取请求 ID 的请求头
+const RequestIDHeader = "X-Request-Id"
+
+// Recover 在 defer 中使用：捕获 panic，以 ERROR 记录 panic 值和调用栈，等待日志写入文件后重新 panic。
+//
+//	defer logx.Recover(log)
+func Recover(l *Logger) {
+	v := recover()
+	if v == nil {
+		return
+	}
+	l.logPanic(v, nil)
+	ctx, cancel := context.WithTimeout(context.Background(), panicFlushTimeout)
+	l.Drain(ctx)
+	cancel()
+	l.Sync()
+	panic(v)
+}
+
+// RecoveryMiddleware 捕获 next 中的 panic，连同请求信息以 ERROR 记录后返回 500；
+// next 已经发出响应头时不再修改响应，日志中 response_started 为 true。
+// http.ErrAbortHandler 照常向上传递，由 net/http 中断连接
+func RecoveryMiddleware(l *Logger, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rec := &responseRecorder{ResponseWriter: w}
+		defer func() {
+			v := recover()
+			if v == nil {
+				return
+			}
+			if v == http.ErrAbortHandler {
+				panic(v)
+			}
+			started := rec.status != 0
+			l.logPanic(v, append(requestFields(r), Bool("response_started", started)))
+			if !started {
+				http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			}
+		}()
+		next.ServeHTTP(rec, r)
+	})
+}
+
+func (l *Logger) logPanic(v interface{}, fields []Field) {
+	fields = append(fields, PanicFields(v, debug.Stack())...)
+	l.log(ERROR, "panic recovered", fields)
+}
+
+// panic 值的分类
+const (
+	PanicKindRuntime = "runtime_error" // 运行时错误，例如空指针、越界
+	PanicKindError   = "error"         // panic(err)
+	PanicKindString  = "string"        // panic("...")
+	PanicKindOther   = "other"
+)
+
+// PanicFields 把 recover() 得到的值转换为结构化字段，便于崩溃分析按类型聚合：
+// panic_kind(PanicKindRuntime 等)、panic_type(具体的 Go 类型，例如 runtime.boundsError)、
+// panic_value(错误信息或值的文本)以及去掉 recover 和 panic 自身帧的 stack。stack 为 debug.Stack() 的结果
+func PanicFields(v interface{}, stack []byte) []Field {
+	kind := PanicKindOther
+	var value interface{} = fmt.Sprint(v)
+	switch val := v.(type) {
+	case runtime.Error:
+		kind, value = PanicKindRuntime, val
+	case error:
+		kind, value = PanicKindError, val
+	case string:
+		kind = PanicKindString
+	}
+	return []Field{
+		String("panic_kind", kind),
+		String("panic_type", fmt.Sprintf("%T", v)),
+		Any("panic_value", value),
+		String("stack", string(trimPanicStack(stack))),
+	}
+}
+
+// 去掉 panic( 所在帧及之前的帧(debug.Stack、日志记录器和 defer 函数)，保留 goroutine 标题行
+func trimPanicStack(stack []byte) []byte {
+	header, frames, ok := bytes.Cut(stack, []byte("\n"))
+	if !ok {
+		return stack
+	}
+	start := bytes.LastIndex(frames, []byte("\npanic("))
+	if start < 0 {
+		return stack
+	}
+	// 每帧两行：函数和文件位置
+	rest := frames[start+1:]
+	for n := 0; n < 2; n++ {
+		if _, rest, ok = bytes.Cut(rest, []byte("\n")); !ok {
+			return stack
+		}
+	}
+	return append(append(append([]byte(nil), header...), '\n'), rest...)
+}
+
+// 请求的基本信息
+func requestFields(r *http.Request) []Field {
+	fields := []Field{
+		String("method", r.Method),
+		String("path", r.URL.Path),
+		String("remote_addr", r.RemoteAddr),
+	}
+	if id := r.Header.Get(RequestIDHeader); id != "" {
+		fields = append(fields, String("request_id", id))
+	}
+	return fields
+}
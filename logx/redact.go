@@ -0,0 +1,146 @@
+package logx
+
+import (
+	"regexp"
+	"strings"
+)
+
+// 默认屏蔽的字段名(不区分大小写)
+var DefaultRedactKeys = []string{"password", "passwd", "secret", "token", "access_token", "refresh_token", "authorization", "api_key", "cookie"}
+
+// 常用的敏感信息匹配规则
+var (
+	CreditCardPattern = regexp.MustCompile(`\b(?:\d[ -]?){13,19}\b`)
+	EmailPattern      = regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`)
+)
+
+const defaultRedactMask = "[REDACTED]"
+
+// RedactionConfig 脱敏配置
+type RedactionConfig struct {
+	Keys     []string         // 需要整体屏蔽值的字段名
+	Patterns []*regexp.Regexp // 在消息和字符串字段中替换匹配到的内容
+	Mask     string           // 替换文本，默认 [REDACTED]
+}
+
+// WithRedaction 在日志到达任何输出之前对消息和字段脱敏
+func WithRedaction(cfg RedactionConfig) Option {
+	return func(l *Logger) {
+		l.redactor = newRedactor(cfg)
+	}
+}
+
+type redactor struct {
+	keys     map[string]bool
+	patterns []*regexp.Regexp
+	mask     string
+}
+
+func newRedactor(cfg RedactionConfig) *redactor {
+	r := &redactor{
+		keys:     make(map[string]bool, len(cfg.Keys)),
+		patterns: cfg.Patterns,
+		mask:     cfg.Mask,
+	}
+	if r.mask == "" {
+		r.mask = defaultRedactMask
+	}
+	for _, k := range cfg.Keys {
+		r.keys[strings.ToLower(k)] = true
+	}
+	return r
+}
+
+func (r *redactor) sensitiveKey(key string) bool {
+	return r.keys[strings.ToLower(key)]
+}
+
+func (r *redactor) redactString(s string) string {
+	for _, p := range r.patterns {
+		s = p.ReplaceAllString(s, r.mask)
+	}
+	return s
+}
+
+func (r *redactor) apply(entry Entry) Entry {
+	entry.Message = r.redactString(entry.Message)
+	if len(entry.Fields) == 0 {
+		return entry
+	}
+	fields := make([]Field, len(entry.Fields))
+	for i, f := range entry.Fields {
+		fields[i] = f
+		fields[i].Value = r.redactValue(f.Key, f.Value)
+	}
+	entry.Fields = fields
+	return entry
+}
+
+func (r *redactor) redactValue(key string, v interface{}) interface{} {
+	if r.sensitiveKey(key) {
+		return r.mask
+	}
+	switch val := v.(type) {
+	case string:
+		return r.redactString(val)
+	case error:
+		if s := val.Error(); s != r.redactString(s) {
+			return r.redactString(s)
+		}
+		return val
+	case FieldGroup:
+		out := make(FieldGroup, len(val))
+		for i, f := range val {
+			out[i] = f
+			out[i].Value = r.redactValue(f.Key, f.Value)
+		}
+		return out
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(val))
+		for k, item := range val {
+			out[k] = r.redactValue(k, item)
+		}
+		return out
+	case map[string]string:
+		out := make(map[string]string, len(val))
+		for k, item := range val {
+			if r.sensitiveKey(k) {
+				out[k] = r.mask
+			} else {
+				out[k] = r.redactString(item)
+			}
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(val))
+		for i, item := range val {
+			out[i] = r.redactValue("", item)
+		}
+		return out
+	case []Change:
+		// Diff 的结果：路径最后一段是敏感字段时屏蔽新旧值
+		out := make([]Change, len(val))
+		for i, c := range val {
+			out[i] = c
+			if r.sensitiveKey(lastPathSegment(c.Path)) {
+				out[i].Old, out[i].New = r.mask, r.mask
+				continue
+			}
+			out[i].Old = r.redactValue("", c.Old)
+			out[i].New = r.redactValue("", c.New)
+		}
+		return out
+	}
+	return v
+}
+
+// "db.hosts[1]" -> "hosts"
+func lastPathSegment(path string) string {
+	if i := strings.LastIndexByte(path, '.'); i >= 0 {
+		path = path[i+1:]
+	}
+	if i := strings.IndexByte(path, '['); i >= 0 {
+		path = path[:i]
+	}
+	return path
+}
@@ -0,0 +1,59 @@
+package logx
+
+import (
+	"errors"
+	"io"
+	"os"
+	"time"
+)
+
+// 切割时重命名的重试次数和初始间隔，每次翻倍
+const (
+	renameRetries = 5
+	renameBackoff = 10 * time.Millisecond
+)
+
+// 把日志文件移动为归档。Windows 上杀毒软件或索引服务可能短暂打开文件导致重命名失败，
+// 此时按退避间隔重试，仍然失败时改为复制到归档后清空原文件
+func renameArchive(src, dst string) error {
+	return retryRename(src, dst, os.Rename, isTransientRenameError)
+}
+
+func retryRename(src, dst string, rename func(string, string) error, transient func(error) bool) error {
+	err := rename(src, dst)
+	backoff := renameBackoff
+	for i := 0; i < renameRetries && err != nil && transient(err); i++ {
+		time.Sleep(backoff)
+		backoff *= 2
+		err = rename(src, dst)
+	}
+	if err == nil || !transient(err) {
+		return err
+	}
+	if cerr := copyTruncate(src, dst); cerr != nil {
+		return errors.Join(err, cerr)
+	}
+	return nil
+}
+
+// 复制 src 到新建的 dst，再把 src 截断为空
+func copyTruncate(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(out, in)
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(dst)
+		return err
+	}
+	return os.Truncate(src, 0)
+}
@@ -0,0 +1,8 @@
+//go:build !windows
+
+package logx
+
+// 其他系统上打开的文件不会阻止重命名
+func isTransientRenameError(err error) bool {
+	return false
+}
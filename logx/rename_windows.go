@@ -0,0 +1,23 @@
+//go:build windows
+
+package logx
+
+import (
+	"errors"
+	"syscall"
+)
+
+// 文件被其他进程占用时的错误码
+const (
+	errorAccessDenied     syscall.Errno = 5
+	errorSharingViolation syscall.Errno = 32
+	errorLockViolation    syscall.Errno = 33
+)
+
+func isTransientRenameError(err error) bool {
+	var errno syscall.Errno
+	if !errors.As(err, &errno) {
+		return false
+	}
+	return errno == errorAccessDenied || errno == errorSharingViolation || errno == errorLockViolation
+}
@@ -0,0 +1,34 @@
+//go:build windows
+
+package logx
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRenameArchiveSharingViolation(t *testing.T) {
+	dir := t.TempDir()
+	src, dst := filepath.Join(dir, "app.log"), filepath.Join(dir, "app.log.20240601_120000.log")
+	os.WriteFile(src, []byte("line\n"), 0644)
+
+	// 不带 FILE_SHARE_DELETE 打开文件，模拟杀毒软件占用
+	held, err := os.Open(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer held.Close()
+	if err := os.Rename(src, dst); !isTransientRenameError(err) {
+		t.Skipf("rename of an open file did not fail with a sharing violation: %v", err)
+	}
+	if err := renameArchive(src, dst); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(dst); string(data) != "line\n" {
+		t.Fatalf("unexpected archive content %q", data)
+	}
+	if info, _ := os.Stat(src); info == nil || info.Size() != 0 {
+		t.Fatalf("expected original file to be truncated, got %v", info)
+	}
+}
@@ -0,0 +1,105 @@
+package logx
+
+import (
+	"os"
+	"os/signal"
+	"time"
+)
+
+// Reopen 关闭并按原路径重新打开日志文件，不做重命名。
+// 配合外部 logrotate 使用：文件被移走后调用 Reopen，后续日志写入新文件而不是被移走的旧文件
+func (l *Logger) Reopen() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.reopen()
+}
+
+// 调用方需持有 l.mu
+func (l *Logger) reopen() error {
+	file, err := l.openLogFile()
+	if err != nil {
+		return err
+	}
+	stat, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	l.closeFile()
+	l.setFile(file)
+	l.currentSize = stat.Size() + l.headerSize
+	return nil
+}
+
+// WithReopenOnSignal 收到信号时调用 Reopen，未指定信号时使用 SIGUSR1(Windows 上没有默认信号)
+func WithReopenOnSignal(sigs ...os.Signal) Option {
+	return func(l *Logger) {
+		if len(sigs) == 0 && defaultReopenSignal != nil {
+			sigs = []os.Signal{defaultReopenSignal}
+		}
+		l.reopenSignals = sigs
+	}
+}
+
+// WithReopenCheck 定期检查日志文件是否被移走、删除(inode 变化)或被 copytruncate 截断，
+// 发生时自动重新打开
+func WithReopenCheck(interval time.Duration) Option {
+	return func(l *Logger) {
+		l.reopenCheck = interval
+	}
+}
+
+func (l *Logger) runReopenSignals() {
+	defer l.bgWg.Done()
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, l.reopenSignals...)
+	defer signal.Stop(ch)
+	for {
+		select {
+		case <-ch:
+			if err := l.Reopen(); err != nil {
+				l.handleError(OpRotate, l.filePath, err)
+			}
+		case <-l.done:
+			return
+		}
+	}
+}
+
+func (l *Logger) runReopenCheck() {
+	defer l.bgWg.Done()
+	ticker := time.NewTicker(l.reopenCheck)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			l.checkFileIdentity()
+		case <-l.done:
+			return
+		}
+	}
+}
+
+func (l *Logger) checkFileIdentity() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.file == nil {
+		return
+	}
+	current, err := l.file.Stat()
+	if err != nil {
+		return
+	}
+	onDisk, err := os.Stat(l.filePath)
+	if err != nil || !os.SameFile(current, onDisk) {
+		// 文件被移走或删除
+		if err := l.reopen(); err != nil {
+			l.handleError(OpRotate, l.filePath, err)
+		}
+		return
+	}
+	if onDisk.Size() < l.currentSize {
+		// copytruncate：以 O_APPEND 打开，写入位置会自动回到文件末尾，只需要修正大小
+		l.currentSize = onDisk.Size()
+	}
+}
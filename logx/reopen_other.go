@@ -0,0 +1,7 @@
+//go:build !unix
+
+package logx
+
+import "os"
+
+var defaultReopenSignal os.Signal
@@ -0,0 +1,10 @@
+//go:build unix
+
+package logx
+
+import (
+	"os"
+	"syscall"
+)
+
+var defaultReopenSignal os.Signal = syscall.SIGUSR1
@@ -0,0 +1,189 @@
+package logx
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+)
+
+// ErrREPLDisabled 非调试构建(未使用 -tags logxdebug)时调用 StartREPL 返回该错误
+var ErrREPLDisabled = errors.New("logx: repl is only available in builds with -tags logxdebug")
+
+// 控制台输出的实时调整状态，由 l.mu 保护
+type consoleControl struct {
+	levelSet bool     // 是否单独设置了控制台等级
+	level    LogLevel // 控制台最低等级
+	filter   string   // 只输出包含该文本的行，例如 user_id=42
+	paused   bool     // 暂停控制台输出，文件照常写入
+}
+
+// 判断条目是否需要输出到控制台，调用方需持有 l.mu
+func (c *consoleControl) allow(level LogLevel, line []byte) bool {
+	if c.paused {
+		return false
+	}
+	if c.levelSet && level < c.level {
+		return false
+	}
+	if c.filter != "" && !bytes.Contains(line, []byte(c.filter)) {
+		return false
+	}
+	return true
+}
+
+// ServeREPL 从 r 中逐行读取命令并调整控制台输出，结果写入 w，直到 r 结束或收到 quit
+// 支持的命令: level <debug|info|warn|error|reset>、filter [text]、pause、resume、status、help、quit
+func (l *Logger) ServeREPL(r io.Reader, w io.Writer) error {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if line == "quit" || line == "exit" {
+			return nil
+		}
+		fmt.Fprintln(w, l.execREPL(line))
+	}
+	return scanner.Err()
+}
+
+func (l *Logger) execREPL(line string) string {
+	cmd, arg, _ := strings.Cut(line, " ")
+	arg = strings.TrimSpace(arg)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	c := &l.console
+
+	switch strings.ToLower(cmd) {
+	case "level":
+		if arg == "" || arg == "reset" {
+			c.levelSet = false
+			return "ok: console level follows logger level"
+		}
+		level, err := ParseLevel(arg)
+		if err != nil {
+			return "error: " + err.Error()
+		}
+		c.levelSet, c.level = true, level
+		return "ok: console level " + levelString(level)
+	case "filter":
+		c.filter = arg
+		if arg == "" {
+			return "ok: filter cleared"
+		}
+		return "ok: filter " + arg
+	case "pause":
+		c.paused = true
+		return "ok: console paused"
+	case "resume":
+		c.paused = false
+		return "ok: console resumed"
+	case "status":
+		level := "inherit"
+		if c.levelSet {
+			level = levelString(c.level)
+		}
+		return fmt.Sprintf("level=%s filter=%q paused=%v", level, c.filter, c.paused)
+	case "help":
+		return "commands: level <debug|info|warn|error|reset>, filter [text], pause, resume, status, quit"
+	default:
+		return "error: unknown command " + cmd
+	}
+}
+
+// 解析 StartREPL 的地址，只允许 unix 套接字和回环地址："unix:/tmp/app-logx.sock"、
+// "tcp:127.0.0.1:7070"，或不带前缀的 "localhost:7070"
+func parseREPLAddr(addr string) (network, address string, err error) {
+	switch {
+	case strings.HasPrefix(addr, "unix:"):
+		network, address = "unix", strings.TrimPrefix(addr, "unix:")
+		if address == "" {
+			return "", "", fmt.Errorf("logx: repl address %q has no socket path", addr)
+		}
+		return network, address, nil
+	case strings.HasPrefix(addr, "tcp:"):
+		address = strings.TrimPrefix(addr, "tcp:")
+	default:
+		address = addr
+	}
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return "", "", fmt.Errorf("logx: invalid repl address %q: %w", addr, err)
+	}
+	if ip := net.ParseIP(host); host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+		return "", "", fmt.Errorf("logx: repl address %q is not a loopback address", addr)
+	}
+	return "tcp", address, nil
+}
+
+// 调试命令行的监听器和连接，Close 时关闭
+type replServer struct {
+	ln    net.Listener
+	mu    sync.Mutex
+	conns map[net.Conn]struct{}
+	wg    sync.WaitGroup
+}
+
+func (l *Logger) serveREPLListener(ln net.Listener) {
+	s := &replServer{ln: ln, conns: map[net.Conn]struct{}{}}
+	l.mu.Lock()
+	l.replServers = append(l.replServers, s)
+	l.mu.Unlock()
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			s.mu.Lock()
+			if s.conns == nil {
+				// 已经关闭
+				s.mu.Unlock()
+				conn.Close()
+				return
+			}
+			s.conns[conn] = struct{}{}
+			s.mu.Unlock()
+			s.wg.Add(1)
+			go func() {
+				defer s.wg.Done()
+				l.ServeREPL(conn, conn)
+				s.mu.Lock()
+				delete(s.conns, conn)
+				s.mu.Unlock()
+				conn.Close()
+			}()
+		}
+	}()
+}
+
+func (s *replServer) close() {
+	s.ln.Close()
+	s.mu.Lock()
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.conns = nil
+	s.mu.Unlock()
+	s.wg.Wait()
+}
+
+// Close 时关闭调试命令行的监听器和连接
+func (l *Logger) closeREPL() {
+	l.mu.Lock()
+	servers := l.replServers
+	l.replServers = nil
+	l.mu.Unlock()
+	for _, s := range servers {
+		s.close()
+	}
+}
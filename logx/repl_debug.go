@@ -0,0 +1,29 @@
+//go:build logxdebug
+
+package logx
+
+import (
+	"net"
+	"os"
+)
+
+// StartREPL 在后台启动调试命令行，addr 为空时读取标准输入，
+// 否则监听本地地址，例如 "unix:/tmp/app-logx.sock"、"tcp:127.0.0.1:7070" 或 "localhost:7070"，
+// 非回环地址返回错误。Close 时关闭监听器和已有连接(读取标准输入的协程在输入结束前不会退出)
+func (l *Logger) StartREPL(addr string) error {
+	if addr == "" {
+		go l.ServeREPL(os.Stdin, os.Stderr)
+		return nil
+	}
+
+	network, address, err := parseREPLAddr(addr)
+	if err != nil {
+		return err
+	}
+	ln, err := net.Listen(network, address)
+	if err != nil {
+		return err
+	}
+	l.serveREPLListener(ln)
+	return nil
+}
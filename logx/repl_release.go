@@ -0,0 +1,8 @@
+//go:build !logxdebug
+
+package logx
+
+// StartREPL 仅在 -tags logxdebug 构建中可用
+func (l *Logger) StartREPL(addr string) error {
+	return ErrREPLDisabled
+}
@@ -0,0 +1,150 @@
+package logx
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"time"
+)
+
+// ReplayOptions 回放配置
+type ReplayOptions struct {
+	Speed    float64       // 回放速度倍数，1 为原速，2 为两倍速，<=0 表示不等待
+	MinLevel LogLevel      // 只回放不低于该等级的日志
+	MaxWait  time.Duration // 两条日志之间最长的等待时间，0 表示不限制
+	// MaxLineSize 单行最大字节数，默认 DefaultMaxLineSize。更长的行逐段读出后丢弃，不会整行读入内存，
+	// 与无法识别的行一样跳过，不影响后面的日志
+	MaxLineSize int
+}
+
+// DefaultMaxLineSize 读取日志时默认的单行最大字节数
+const DefaultMaxLineSize = 16 << 20
+
+// Replay 读取记录的日志并按原始的时间间隔(可按 Speed 缩放)依次交给 emit，返回回放的条数。
+// 无法识别和超过 MaxLineSize 的行会被跳过
+func Replay(ctx context.Context, r io.Reader, emit func(Entry) error, opts ReplayOptions) (int, error) {
+	lines := newLineReader(r, opts.MaxLineSize)
+	var prev time.Time
+	count := 0
+	for {
+		line, err := lines.next()
+		if err == io.EOF {
+			return count, nil
+		}
+		if err != nil {
+			return count, err
+		}
+		if line == nil {
+			continue
+		}
+		entry, err := ParseLine(line)
+		if err != nil || entry.Level < opts.MinLevel {
+			continue
+		}
+		if !prev.IsZero() && opts.Speed > 0 {
+			wait := time.Duration(float64(entry.Time.Sub(prev)) / opts.Speed)
+			if opts.MaxWait > 0 && wait > opts.MaxWait {
+				wait = opts.MaxWait
+			}
+			if wait > 0 {
+				timer := time.NewTimer(wait)
+				select {
+				case <-ctx.Done():
+					timer.Stop()
+					return count, ctx.Err()
+				case <-timer.C:
+				}
+			}
+		}
+		if err := ctx.Err(); err != nil {
+			return count, err
+		}
+		prev = entry.Time
+		if err := emit(entry); err != nil {
+			return count, err
+		}
+		count++
+	}
+}
+
+// 逐行读取，单行最多 max 字节
+type lineReader struct {
+	r   *bufio.Reader
+	max int
+	buf []byte
+}
+
+func newLineReader(r io.Reader, max int) *lineReader {
+	if max <= 0 {
+		max = DefaultMaxLineSize
+	}
+	return &lineReader{r: bufio.NewReaderSize(r, 64*1024), max: max}
+}
+
+// next 返回下一行(不含换行符)，超过 max 的行读完后返回 nil，读完时返回 io.EOF。
+// 返回的内容在下次调用前有效
+func (lr *lineReader) next() ([]byte, error) {
+	lr.buf = lr.buf[:0]
+	tooLong := false
+	for {
+		chunk, err := lr.r.ReadSlice('\n')
+		if !tooLong {
+			if len(lr.buf)+len(chunk) > lr.max+1 {
+				tooLong, lr.buf = true, lr.buf[:0]
+			} else {
+				lr.buf = append(lr.buf, chunk...)
+			}
+		}
+		switch err {
+		case bufio.ErrBufferFull:
+			continue
+		case nil:
+		case io.EOF:
+			if len(lr.buf) == 0 && !tooLong {
+				return nil, io.EOF
+			}
+		default:
+			return nil, err
+		}
+		if tooLong {
+			return nil, nil
+		}
+		return bytes.TrimSuffix(lr.buf, []byte("\n")), nil
+	}
+}
+
+// ReplayFile 把记录的日志文件回放到当前日志记录器，日志时间为回放时的时间，
+// 原始时间保存在 original_time 字段中
+func (l *Logger) ReplayFile(ctx context.Context, path string, opts ReplayOptions) (int, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+	return Replay(ctx, file, func(entry Entry) error {
+		entry.Fields = append(entry.Fields, Any("original_time", entry.Time))
+		entry.Time = l.now()
+		l.Emit(entry)
+		return nil
+	}, opts)
+}
+
+// ReplayToSink 把记录的日志文件回放到输出目标，写入的行为原始行
+func ReplayToSink(ctx context.Context, path string, sink Sink, opts ReplayOptions) (int, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+	return Replay(ctx, file, func(entry Entry) error {
+		return sink.Write(&entry, encodeReplayLine(entry))
+	}, opts)
+}
+
+// 输出目标需要编码好的行，回放时统一使用JSON格式
+func encodeReplayLine(entry Entry) []byte {
+	l := Logger{schemaVersion: CurrentSchemaVersion}
+	return l.encodeJSON(entry)
+}
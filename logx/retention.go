@@ -0,0 +1,153 @@
+package logx
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const archiveStampLayout = "20060102_150405"
+
+// 切割出的归档路径，同一秒内已经有归档(包括压缩后的)时追加比已有的都大的序号，避免覆盖并保持顺序
+func archivePath(path string, now time.Time) string {
+	base := fmt.Sprintf("%s.%s", path, now.Format(archiveStampLayout))
+	matches, _ := filepath.Glob(base + "*")
+	if len(matches) == 0 {
+		return base + ".log"
+	}
+	last := 0
+	for _, m := range matches {
+		if stamp, ok := archiveStamp(path, m); ok {
+			if n, _ := strconv.Atoi(stamp[len(archiveStampLayout)+1:]); n > last {
+				last = n
+			}
+		}
+	}
+	return fmt.Sprintf("%s_%d.log", base, last+1)
+}
+
+// WithMaxBackups 只保留最近 n 个切割出的归档，更早的在切割后删除；开启 WithArchiveCompression 时在压缩完成后删除
+func WithMaxBackups(n int) Option {
+	return func(l *Logger) {
+		l.maxBackups = n
+	}
+}
+
+// 删除超出 maxBackups 的最早的归档，pruneMu 同时保护压缩和删除归档
+func (l *Logger) pruneArchives() {
+	l.pruneMu.Lock()
+	defer l.pruneMu.Unlock()
+	archives, err := Archives(l.filePath)
+	if err != nil {
+		l.handleError(OpRotate, l.filePath, err)
+		return
+	}
+	// 正在压缩的归档同时存在压缩前后两个文件，按切割时间算作一个
+	var stamps []string
+	groups := map[string][]string{}
+	for _, path := range archives {
+		stamp, _ := archiveStamp(l.filePath, path)
+		if _, ok := groups[stamp]; !ok {
+			stamps = append(stamps, stamp)
+		}
+		groups[stamp] = append(groups[stamp], path)
+	}
+	for len(stamps) > l.maxBackups {
+		for _, path := range groups[stamps[0]] {
+			if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+				l.handleError(OpRotate, path, err)
+			}
+		}
+		stamps = stamps[1:]
+	}
+}
+
+// ArchiveInfo 切割出的归档的信息
+type ArchiveInfo struct {
+	Path        string
+	Size        int64     // 磁盘上的大小
+	Rotated     time.Time // 切割时间，即最后一条日志写入之后的时间
+	First       time.Time // 第一条日志的时间，读取失败或没有日志时为零值
+	Compression string    // 压缩编码的名称，未压缩时为空
+}
+
+// Archives 返回属于该日志记录器的归档，按切割时间从早到晚排列，可以在此基础上实现自己的保留策略
+func (l *Logger) Archives() ([]ArchiveInfo, error) {
+	paths, err := Archives(l.filePath)
+	if err != nil {
+		return nil, err
+	}
+	infos := make([]ArchiveInfo, 0, len(paths))
+	for _, path := range paths {
+		stat, err := os.Stat(path)
+		if errors.Is(err, os.ErrNotExist) {
+			// 期间被压缩或删除
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		info := ArchiveInfo{Path: path, Size: stat.Size()}
+		stamp, _ := archiveStamp(l.filePath, path)
+		info.Rotated, _ = time.ParseInLocation(archiveStampLayout, stamp[:len(archiveStampLayout)], time.Local)
+		if ext := strings.TrimPrefix(filepath.Ext(path), "."); ext != "log" {
+			info.Compression = ext
+		}
+		info.First = l.firstEntryTime(path)
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
+
+var errStopReading = errors.New("logx: stop reading")
+
+func (l *Logger) firstEntryTime(path string) time.Time {
+	var first time.Time
+	readLogFile(path, l.encryption, 0, func(e Entry) error {
+		first = e.Time
+		return errStopReading
+	})
+	return first
+}
+
+// 检查 path 是该日志记录器的归档
+func (l *Logger) checkArchive(path string) error {
+	if _, ok := archiveStamp(l.filePath, path); !ok || filepath.Dir(path) != filepath.Dir(l.filePath) {
+		return fmt.Errorf("logx: %s is not an archive of %s", path, l.filePath)
+	}
+	return nil
+}
+
+// DeleteArchive 删除该日志记录器的归档 path(Archives 返回的路径)
+func (l *Logger) DeleteArchive(path string) error {
+	if err := l.checkArchive(path); err != nil {
+		return err
+	}
+	l.pruneMu.Lock()
+	defer l.pruneMu.Unlock()
+	return os.Remove(path)
+}
+
+// CompressArchive 用 c 压缩该日志记录器未压缩的归档 path，c 为 nil 时使用 WithArchiveCompression 的编码，
+// 返回压缩后的路径
+func (l *Logger) CompressArchive(path string, c Compressor) (string, error) {
+	if err := l.checkArchive(path); err != nil {
+		return "", err
+	}
+	if c == nil {
+		c = l.archiveCompressor
+	}
+	if c == nil {
+		return "", errors.New("logx: no compression configured")
+	}
+	if filepath.Ext(path) != ".log" {
+		return "", fmt.Errorf("logx: %s is already compressed", path)
+	}
+	l.pruneMu.Lock()
+	defer l.pruneMu.Unlock()
+	return CompressFile(c, path)
+}
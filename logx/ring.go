@@ -0,0 +1,229 @@
+package logx
+
+import (
+	"runtime"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	queueSize       = 2048      // 日志队列容量
+	workerBatchSize = 256       // 写入协程每次最多取出的条数
+	batchBufferSize = 64 * 1024 // 未开启缓冲写入时合并一批日志的缓冲区大小
+)
+
+// 放入日志队列，队列满时等待；Close 之后的日志计入丢弃
+func (l *Logger) enqueue(entry Entry) {
+	if l.wal != nil {
+		if l.closed.Load() {
+			l.afterClose(entry)
+			return
+		}
+		l.walAppend(entry)
+		return
+	}
+	if !l.queue.push(entry) {
+		// 只有队列已关闭时才会失败
+		l.afterClose(entry)
+	}
+}
+
+// 放入日志队列，队列满时不等待
+func (l *Logger) tryEnqueue(entry Entry) error {
+	if l.closed.Load() {
+		// 调用方会自己处理，不按 WithClosedPolicy 输出
+		l.metrics.afterClose.Add(1)
+		return ErrClosed
+	}
+	if l.wal != nil {
+		return l.walAppend(entry)
+	}
+	if !l.queue.tryPush(entry) {
+		if l.queue.closed.Load() {
+			l.metrics.afterClose.Add(1)
+			return ErrClosed
+		}
+		return ErrQueueFull
+	}
+	return nil
+}
+
+// ringBuffer 有界的多生产者单消费者无锁队列，替代原来的日志通道。
+// 每个槽位带一个序号：槽位空闲时序号等于可以写入的位置，写入完成后序号为位置+1，
+// 生产者通过 CAS 抢占写入位置，消费者顺序读取，不需要互斥锁
+type ringBuffer struct {
+	mask  uint64
+	slots []ringSlot
+	_     [56]byte // 避免 head 与其他字段共享缓存行
+	head  atomic.Uint64
+	// 正在 tryPush 中的生产者数，关闭后消费者等它归零再取最后一批，
+	// 保证检查 closed 时未关闭的生产者放入的日志都会被写入
+	inflight atomic.Int64
+	_        [48]byte
+	tail     atomic.Uint64 // 只有消费者写入，Stats 会并发读取
+
+	notify   chan struct{} // 唤醒等待中的消费者
+	sleeping atomic.Bool   // 消费者是否在等待
+	closed   atomic.Bool
+	schedule func() // 不为 nil 时由 WorkerPool 消费，有新日志或关闭时调用，代替唤醒消费者
+}
+
+type ringSlot struct {
+	seq   atomic.Uint64
+	entry Entry
+}
+
+// 容量向上取整为2的幂
+func newRingBuffer(capacity int) *ringBuffer {
+	size := 1
+	for size < capacity {
+		size <<= 1
+	}
+	r := &ringBuffer{
+		mask:   uint64(size - 1),
+		slots:  make([]ringSlot, size),
+		notify: make(chan struct{}, 1),
+	}
+	for i := range r.slots {
+		r.slots[i].seq.Store(uint64(i))
+	}
+	return r
+}
+
+// tryPush 队列满或已关闭时返回 false
+func (r *ringBuffer) tryPush(entry Entry) bool {
+	r.inflight.Add(1)
+	defer r.inflight.Add(-1)
+	if r.closed.Load() {
+		return false
+	}
+	pos := r.head.Load()
+	for {
+		slot := &r.slots[pos&r.mask]
+		seq := slot.seq.Load()
+		switch {
+		case seq == pos:
+			if r.head.CompareAndSwap(pos, pos+1) {
+				slot.entry = entry
+				slot.seq.Store(pos + 1)
+				r.wake()
+				return true
+			}
+			pos = r.head.Load()
+		case seq < pos:
+			// 槽位还没有被消费者释放：队列已满
+			return false
+		default:
+			pos = r.head.Load()
+		}
+	}
+}
+
+// push 队列满时等待消费者腾出空间，与向有缓冲通道发送的语义一致；已关闭时返回 false
+func (r *ringBuffer) push(entry Entry) bool {
+	for spins := 0; ; spins++ {
+		if r.tryPush(entry) {
+			return true
+		}
+		if r.closed.Load() {
+			return false
+		}
+		if spins < 16 {
+			runtime.Gosched()
+		} else {
+			time.Sleep(20 * time.Microsecond)
+		}
+	}
+}
+
+func (r *ringBuffer) wake() {
+	if r.schedule != nil {
+		r.schedule()
+		return
+	}
+	if r.sleeping.Load() && r.sleeping.CompareAndSwap(true, false) {
+		select {
+		case r.notify <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// popBatch 取出最多 max 条已经写入完成的日志追加到 buf，只能由消费者调用
+func (r *ringBuffer) popBatch(buf []Entry, max int) []Entry {
+	pos := r.tail.Load()
+	for n := 0; n < max; n++ {
+		slot := &r.slots[pos&r.mask]
+		if slot.seq.Load() != pos+1 {
+			break
+		}
+		buf = append(buf, slot.entry)
+		slot.entry = Entry{}
+		slot.seq.Store(pos + r.mask + 1)
+		pos++
+	}
+	r.tail.Store(pos)
+	return buf
+}
+
+// wait 在队列为空时等待新日志，timeout <= 0 表示一直等待；返回 false 表示队列已关闭且已取空
+func (r *ringBuffer) wait(timeout time.Duration) bool {
+	r.sleeping.Store(true)
+	if r.ready() {
+		r.sleeping.Store(false)
+		return true
+	}
+	if r.closed.Load() {
+		r.sleeping.Store(false)
+		r.settle()
+		return r.ready()
+	}
+	if timeout <= 0 {
+		<-r.notify
+		return true
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-r.notify:
+	case <-timer.C:
+		r.sleeping.Store(false)
+	}
+	return true
+}
+
+// 关闭后等待仍在 tryPush 中的生产者放入完成
+func (r *ringBuffer) settle() {
+	for r.inflight.Load() > 0 {
+		runtime.Gosched()
+	}
+}
+
+// 队首的日志是否已经写入完成
+func (r *ringBuffer) ready() bool {
+	pos := r.tail.Load()
+	return r.slots[pos&r.mask].seq.Load() == pos+1
+}
+
+// close 之后 push 返回 false，消费者取空剩余日志后退出
+func (r *ringBuffer) close() {
+	r.closed.Store(true)
+	if r.schedule != nil {
+		r.schedule()
+		return
+	}
+	r.sleeping.Store(false)
+	select {
+	case r.notify <- struct{}{}:
+	default:
+	}
+}
+
+// 已写入或正在写入的条数
+func (r *ringBuffer) len() int {
+	return int(r.head.Load() - r.tail.Load())
+}
+
+func (r *ringBuffer) cap() int {
+	return len(r.slots)
+}
@@ -0,0 +1,212 @@
+package logx
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync/atomic"
+)
+
+type routeAction int
+
+const (
+	routeDefault   routeAction = iota // 按 WithSink 的最低等级发送
+	routeTo                           // 只发送到指定的输出目标
+	routeDrop                         // 丢弃
+	routeDowngrade                    // 降低等级后按最低等级发送
+)
+
+// RouteRule 路由规则，所有条件同时满足时生效，用 Route 创建后调用 To、Drop 或 Downgrade 指定动作
+type RouteRule struct {
+	conds  []Cond
+	action routeAction
+	sinks  []string
+	level  LogLevel
+}
+
+// Route 创建满足 conds 时生效的路由规则，没有条件时匹配所有日志
+func Route(conds ...Cond) RouteRule {
+	return RouteRule{conds: conds}
+}
+
+// To 只发送到名为 sinks 的输出目标(WithSink 的 name)，不受其最低等级限制；日志文件和控制台不受影响
+func (r RouteRule) To(sinks ...string) RouteRule {
+	r.action, r.sinks = routeTo, sinks
+	return r
+}
+
+// Drop 丢弃日志，日志文件中也不会出现
+func (r RouteRule) Drop() RouteRule {
+	r.action = routeDrop
+	return r
+}
+
+// Downgrade 把日志等级改为 level，再按各输出目标的最低等级发送，例如避免已知的错误触发告警
+func (r RouteRule) Downgrade(level LogLevel) RouteRule {
+	r.action, r.level = routeDowngrade, level
+	return r
+}
+
+type router struct {
+	rules atomic.Pointer[[]RouteRule]
+}
+
+// WithRoutes 设置路由规则，在写入协程中过滤之后按顺序匹配，第一条满足的规则生效，
+// 都不满足时按 WithSink 的最低等级发送，例如：
+//
+//	WithRoutes(
+//		Route(ModuleIs("health")).Drop(),
+//		Route(LevelAtLeast(ERROR), TagIs("security")).To("pagerduty", "archive"),
+//		Route(MessageMatches(regexp.MustCompile("retrying"))).Downgrade(DEBUG),
+//	)
+//
+// 之后可以用 SetRoutes 或 ApplyConfig 热更新
+func WithRoutes(rules ...RouteRule) Option {
+	return func(l *Logger) {
+		l.router = &router{}
+		l.router.rules.Store(&rules)
+	}
+}
+
+var errRoutingDisabled = errors.New("logx: routing is not enabled, create the logger with WithRoutes")
+
+// SetRoutes 替换全部路由规则，规则引用了不存在的输出目标时返回错误且不做修改
+func (l *Logger) SetRoutes(rules ...RouteRule) error {
+	if l.router == nil {
+		return errRoutingDisabled
+	}
+	if err := l.checkRoutes(rules); err != nil {
+		return err
+	}
+	l.router.rules.Store(&rules)
+	return nil
+}
+
+func (l *Logger) checkRoutes(rules []RouteRule) error {
+	for _, r := range rules {
+		for _, name := range r.sinks {
+			found := false
+			for _, route := range l.sinks {
+				if route.name == name {
+					found = true
+					break
+				}
+			}
+			if !found {
+				return fmt.Errorf("logx: route to unknown sink %q", name)
+			}
+		}
+	}
+	return nil
+}
+
+// 返回 false 表示日志被丢弃
+func (l *Logger) route(e *Entry) bool {
+	rules := *l.router.rules.Load()
+	i := matchRoute(rules, e)
+	if i < 0 {
+		return true
+	}
+	switch r := rules[i]; r.action {
+	case routeTo:
+		if !e.targeted {
+			e.routed, e.routes = true, r.sinks
+		}
+	case routeDrop:
+		return false
+	case routeDowngrade:
+		e.Level = r.level
+	}
+	return true
+}
+
+// 第一条满足的规则的下标，都不满足时返回 -1
+func matchRoute(rules []RouteRule, e *Entry) int {
+	for i, r := range rules {
+		if matchAll(r.conds, e) {
+			return i
+		}
+	}
+	return -1
+}
+
+// 输出目标是否接收该条日志
+func (e *Entry) routedTo(route sinkRoute) bool {
+	if containsString(e.alsoSinks, route.name) {
+		return true
+	}
+	if !e.routed {
+		return e.Level >= route.minLevel
+	}
+	return containsString(e.routes, route.name)
+}
+
+// ParseRoutes 解析文本形式的路由规则，可以用在配置文件中。每行(或以 ';' 分隔)一条规则，
+// 条件的写法与 ParseFilter 相同，* 匹配所有日志，例如：
+//
+//	module=health -> drop
+//	level>=ERROR, tag=security -> pagerduty, archive
+//	messageRegex="retrying" -> downgrade(DEBUG)
+func ParseRoutes(s string) ([]RouteRule, error) {
+	p := &filterParser{s: s}
+	var rules []RouteRule
+	for {
+		for p.consume(";") {
+		}
+		p.skipSpace()
+		if p.done() {
+			return rules, nil
+		}
+		rule, err := p.route()
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+}
+
+func (p *filterParser) route() (RouteRule, error) {
+	var rule RouteRule
+	if !p.consume("*") {
+		for {
+			cond, err := p.cond()
+			if err != nil {
+				return rule, err
+			}
+			rule.conds = append(rule.conds, cond)
+			if !p.consume(",") {
+				break
+			}
+		}
+	}
+	if !p.consume("->") {
+		return rule, p.errorf("expected '->'")
+	}
+	switch name := p.ident(); strings.ToLower(name) {
+	case "":
+		return rule, p.errorf("expected drop, downgrade or sink names")
+	case "drop":
+		return rule.Drop(), nil
+	case "downgrade":
+		if !p.consume("(") {
+			return rule, p.errorf("expected '('")
+		}
+		level, err := ParseLevel(p.ident())
+		if err != nil {
+			return rule, err
+		}
+		if !p.consume(")") {
+			return rule, p.errorf("expected ')'")
+		}
+		return rule.Downgrade(level), nil
+	default:
+		sinks := []string{name}
+		for p.consume(",") {
+			if name = p.ident(); name == "" {
+				return rule, p.errorf("expected sink name")
+			}
+			sinks = append(sinks, name)
+		}
+		return rule.To(sinks...), nil
+	}
+}
@@ -0,0 +1,51 @@
+package logx
+
+import (
+	"runtime"
+	"time"
+)
+
+// WithRuntimeStats 每隔 interval 以 INFO 记录一条 LogRuntimeStats 的内存和 GC 统计，
+// 没有指标采集的部署也可以看到资源使用情况。ReadMemStats 会短暂暂停程序，间隔不宜小于几秒
+func WithRuntimeStats(interval time.Duration) Option {
+	return func(l *Logger) {
+		l.runtimeStats = interval
+	}
+}
+
+// LogRuntimeStats 记录一条 "runtime stats" 日志，字段包括 goroutine 数量、堆内存(字节)以及 GC 次数和暂停时间
+func (l *Logger) LogRuntimeStats(level LogLevel) {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	var lastPause time.Duration
+	if m.NumGC > 0 {
+		lastPause = time.Duration(m.PauseNs[(m.NumGC+255)%256])
+	}
+	l.log(level, "runtime stats", []Field{
+		Int("goroutines", runtime.NumGoroutine()),
+		Any("heap_alloc", m.HeapAlloc),
+		Any("heap_inuse", m.HeapInuse),
+		Any("heap_objects", m.HeapObjects),
+		Any("stack_inuse", m.StackInuse),
+		Any("sys", m.Sys),
+		Any("next_gc", m.NextGC),
+		Any("num_gc", m.NumGC),
+		Duration("gc_pause_total", time.Duration(m.PauseTotalNs)),
+		Duration("gc_pause_last", lastPause),
+		Any("gc_cpu_fraction", m.GCCPUFraction),
+	})
+}
+
+func (l *Logger) runRuntimeStats() {
+	defer l.bgWg.Done()
+	ticker := time.NewTicker(l.runtimeStats)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			l.LogRuntimeStats(INFO)
+		case <-l.done:
+			return
+		}
+	}
+}
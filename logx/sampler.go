@@ -0,0 +1,133 @@
+package logx
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// SamplingConfig 采样配置：每个周期内同一(等级,消息)的前 First 条全部输出，之后每 Thereafter 条输出1条
+type SamplingConfig struct {
+	Tick       time.Duration // 统计周期，默认1秒
+	First      int           // 每个周期内全部输出的条数
+	Thereafter int           // 超出 First 后每多少条输出1条，0 表示全部丢弃
+}
+
+// WithSampling 对所有等级启用采样
+func WithSampling(cfg SamplingConfig) Option {
+	return func(l *Logger) {
+		s := l.ensureSampler()
+		for level := DEBUG; level <= ERROR; level++ {
+			s.configs[level] = cfg
+		}
+	}
+}
+
+// WithLevelSampling 对单个等级启用采样，会覆盖 WithSampling 中该等级的配置
+func WithLevelSampling(level LogLevel, cfg SamplingConfig) Option {
+	return func(l *Logger) {
+		l.ensureSampler().configs[level] = cfg
+	}
+}
+
+type sampleKey struct {
+	level LogLevel
+	msg   string
+}
+
+type sampleCounter struct {
+	count      int
+	suppressed int
+}
+
+type sampler struct {
+	mu      sync.Mutex
+	configs map[LogLevel]SamplingConfig
+	counts  map[sampleKey]*sampleCounter
+}
+
+func (l *Logger) ensureSampler() *sampler {
+	if l.sampler == nil {
+		l.sampler = &sampler{
+			configs: make(map[LogLevel]SamplingConfig),
+			counts:  make(map[sampleKey]*sampleCounter),
+		}
+	}
+	return l.sampler
+}
+
+// 返回 false 表示该条日志被采样丢弃
+func (s *sampler) allow(level LogLevel, msg string) bool {
+	cfg, ok := s.configs[level]
+	if !ok {
+		return true
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := sampleKey{level, msg}
+	c := s.counts[key]
+	if c == nil {
+		c = &sampleCounter{}
+		s.counts[key] = c
+	}
+	c.count++
+	if c.count <= cfg.First {
+		return true
+	}
+	if cfg.Thereafter > 0 && (c.count-cfg.First)%cfg.Thereafter == 0 {
+		return true
+	}
+	c.suppressed++
+	return false
+}
+
+// 最小的统计周期，所有等级共用一个定时器
+func (s *sampler) tick() time.Duration {
+	tick := time.Duration(0)
+	for _, cfg := range s.configs {
+		if cfg.Tick > 0 && (tick == 0 || cfg.Tick < tick) {
+			tick = cfg.Tick
+		}
+	}
+	if tick == 0 {
+		tick = time.Second
+	}
+	return tick
+}
+
+// 结束当前周期，返回被丢弃的计数
+func (s *sampler) reset() map[sampleKey]*sampleCounter {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	counts := s.counts
+	s.counts = make(map[sampleKey]*sampleCounter, len(counts))
+	return counts
+}
+
+// 每个周期结束时输出被丢弃日志的汇总
+func (l *Logger) runSampler() {
+	defer l.bgWg.Done()
+	ticker := time.NewTicker(l.sampler.tick())
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			l.emitSamplingSummary()
+		case <-l.done:
+			l.emitSamplingSummary()
+			return
+		}
+	}
+}
+
+func (l *Logger) emitSamplingSummary() {
+	for key, c := range l.sampler.reset() {
+		if c.suppressed == 0 {
+			continue
+		}
+		msg := fmt.Sprintf("suppressed %d similar messages: %s", c.suppressed, key.msg)
+		l.enqueue(Entry{Level: key.level, Message: msg, Time: l.now()})
+	}
+}
@@ -0,0 +1,48 @@
+package logx
+
+import "strconv"
+
+// JSON输出的结构版本，下游解析器可以根据 schema_version 字段选择解析方式
+const (
+	// SchemaLegacy 兼容模式：不输出 schema_version，结构与早期版本完全相同，
+	// 与保留键(time、level、msg 等)同名的字段会原样输出
+	SchemaLegacy = 0
+	// SchemaV1 输出 schema_version，与保留键同名的字段重命名为 fields.<key>，保证键唯一
+	SchemaV1 = 1
+
+	CurrentSchemaVersion = SchemaV1
+)
+
+// JSON输出中日志记录器自身使用的键
+var reservedJSONKeys = map[string]bool{
+	"schema_version": true,
+	"time":           true,
+	"level":          true,
+	"caller":         true,
+	"msg":            true,
+}
+
+// WithSchemaVersion 设置JSON输出的结构版本，默认 CurrentSchemaVersion
+func WithSchemaVersion(version int) Option {
+	return func(l *Logger) {
+		l.schemaVersion = version
+	}
+}
+
+// 写入结构版本，调用方保证在对象开头调用
+func (l *Logger) appendSchemaVersion(buf []byte) []byte {
+	if l.schemaVersion == SchemaLegacy {
+		return buf
+	}
+	buf = append(buf, `"schema_version":`...)
+	buf = strconv.AppendInt(buf, int64(l.schemaVersion), 10)
+	return append(buf, ',')
+}
+
+// 字段在JSON输出中的键
+func (l *Logger) jsonFieldKey(key string) string {
+	if l.schemaVersion >= SchemaV1 && reservedJSONKeys[key] {
+		return "fields." + key
+	}
+	return key
+}
@@ -0,0 +1,157 @@
+package logx
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Sink 额外的日志输出目标
+type Sink interface {
+	// Write 写入一条日志，line 是按日志记录器格式编码好的一行(包含换行符)。
+	// entry 和 line 在 Write 返回后会被复用，需要保留时请自行复制
+	Write(entry *Entry, line []byte) error
+	Close() error
+}
+
+type sinkRoute struct {
+	name     string
+	sink     Sink
+	minLevel LogLevel
+}
+
+// AsyncErrorReporter 在后台发送的输出目标(例如异步生产者、定时批量发送)可以实现该接口，
+// WithSink 会传入一个回调，回调把错误作为 OpSink 的 *WriteError 交给 WithErrorHandler
+type AsyncErrorReporter interface {
+	SetErrorHandler(handler func(error))
+}
+
+// WithSink 添加一个输出目标，只接收不低于 minLevel 的日志
+func WithSink(name string, sink Sink, minLevel LogLevel) Option {
+	return func(l *Logger) {
+		l.sinks = append(l.sinks, sinkRoute{name: name, sink: sink, minLevel: minLevel})
+		if r, ok := sink.(AsyncErrorReporter); ok {
+			r.SetErrorHandler(func(err error) { l.handleError(OpSink, name, err) })
+		}
+	}
+}
+
+// WithErrorStream 把不低于 minLevel 的日志额外写入 errPath，并将这些日志的控制台输出改到 stderr。
+// errPath 为空时根据日志文件名生成，例如 logs/app.log -> logs/app.err.log
+func WithErrorStream(errPath string, minLevel LogLevel) Option {
+	return func(l *Logger) {
+		if errPath == "" {
+			errPath = errFilePath(l.filePath)
+		}
+		sink, err := NewFileSink(errPath, l.maxSize/(1024*1024))
+		if err != nil {
+			l.optErr = err
+			return
+		}
+		l.sinks = append(l.sinks, sinkRoute{name: "err", sink: sink, minLevel: minLevel})
+		level := minLevel
+		l.stderrLevel = &level
+	}
+}
+
+func errFilePath(path string) string {
+	ext := filepath.Ext(path)
+	return strings.TrimSuffix(path, ext) + ".err" + ext
+}
+
+// 控制台输出目标，调用方需持有 l.mu
+func (l *Logger) consoleTarget(level LogLevel) io.Writer {
+	if l.stderrLevel != nil && level >= *l.stderrLevel {
+		return l.errConsoleWriter
+	}
+	return l.consoleWriter
+}
+
+// 调用方需持有 l.mu
+func (l *Logger) writeSinks(entry *Entry, line []byte) {
+	for _, route := range l.sinks {
+		if !entry.routedTo(route) {
+			continue
+		}
+		if err := route.sink.Write(entry, line); err != nil {
+			l.handleError(OpSink, route.name, err)
+		}
+	}
+}
+
+func (l *Logger) closeSinks() {
+	for _, route := range l.sinks {
+		if err := route.sink.Close(); err != nil {
+			l.handleError(OpSink, route.name, err)
+		}
+	}
+}
+
+// FileSink 追加写入文件的输出目标，超过大小后按与主日志文件相同的规则切割
+type FileSink struct {
+	mu      sync.Mutex
+	path    string
+	maxSize int64
+	file    *os.File
+	size    int64
+}
+
+// NewFileSink 打开(或创建)文件，maxSizeMB <= 0 表示不切割
+func NewFileSink(path string, maxSizeMB int64) (*FileSink, error) {
+	s := &FileSink{path: path, maxSize: maxSizeMB * 1024 * 1024}
+	if err := s.open(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *FileSink) open() error {
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(s.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	stat, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	s.file = file
+	s.size = stat.Size()
+	return nil
+}
+
+func (s *FileSink) Write(entry *Entry, line []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.file == nil {
+		return os.ErrClosed
+	}
+	n, err := s.file.Write(line)
+	s.size += int64(n)
+	if err != nil {
+		return err
+	}
+	if s.maxSize > 0 && s.size >= s.maxSize {
+		s.file.Close()
+		renameArchive(s.path, archivePath(s.path, time.Now()))
+		return s.open()
+	}
+	return nil
+}
+
+func (s *FileSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.file == nil {
+		return nil
+	}
+	err := s.file.Close()
+	s.file = nil
+	return err
+}
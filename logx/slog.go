@@ -0,0 +1,106 @@
+package logx
+
+import (
+	"context"
+	"log/slog"
+)
+
+// SlogHandler 把 log/slog 的日志写入 Logger 的 slog.Handler，WithAttrs、WithGroup 对应子日志记录器的 With、WithGroup，
+// 分组在 JSON 格式中输出为嵌套对象。日志时间使用 slog.Record 的时间(为零时使用当前时间)，开启 WithCaller 时使用 slog 记录的调用位置
+type SlogHandler struct {
+	c *Child
+}
+
+// NewSlogHandler 例如 slog.SetDefault(slog.New(logx.NewSlogHandler(log)))
+func NewSlogHandler(l *Logger) *SlogHandler {
+	return &SlogHandler{c: &Child{l: l}}
+}
+
+// slog 的等级之间可以有自定义等级，向下取到最近的等级
+func slogLevel(level slog.Level) LogLevel {
+	switch {
+	case level < slog.LevelInfo:
+		return DEBUG
+	case level < slog.LevelWarn:
+		return INFO
+	case level < slog.LevelError:
+		return WARN
+	}
+	return ERROR
+}
+
+func (h *SlogHandler) Enabled(_ context.Context, level slog.Level) bool {
+	l := h.c.l
+	lv := slogLevel(level)
+	// 按模块临时提升的等级要看字段，交给 Handle 判断
+	return lv >= l.minLevel() || l.filterMayAllow(lv) || l.moduleLevels.Load() != nil
+}
+
+func (h *SlogHandler) Handle(_ context.Context, r slog.Record) error {
+	fields := make([]Field, 0, r.NumAttrs())
+	r.Attrs(func(a slog.Attr) bool {
+		fields = appendSlogAttr(fields, a)
+		return true
+	})
+	c := *h.c
+	c.callerPC, c.time = r.PC, r.Time
+	err := c.l.logScoped(slogLevel(r.Level), r.Message, &c, fields, false)
+	if err == ErrBelowLevel || err == ErrSampled {
+		// 不是错误，slog 只关心是否写入失败
+		return nil
+	}
+	return err
+}
+
+func (h *SlogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	fields := make([]Field, 0, len(attrs))
+	for _, a := range attrs {
+		fields = appendSlogAttr(fields, a)
+	}
+	return &SlogHandler{c: h.c.With(fields...)}
+}
+
+func (h *SlogHandler) WithGroup(name string) slog.Handler {
+	return &SlogHandler{c: h.c.WithGroup(name)}
+}
+
+// 按 slog.Handler 的约定转换属性：忽略空属性，键为空的分组展开到上一层，没有属性的分组不输出
+func appendSlogAttr(fields []Field, a slog.Attr) []Field {
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return fields
+	}
+	switch a.Value.Kind() {
+	case slog.KindGroup:
+		attrs := a.Value.Group()
+		if len(attrs) == 0 {
+			return fields
+		}
+		if a.Key == "" {
+			for _, sub := range attrs {
+				fields = appendSlogAttr(fields, sub)
+			}
+			return fields
+		}
+		group := make([]Field, 0, len(attrs))
+		for _, sub := range attrs {
+			group = appendSlogAttr(group, sub)
+		}
+		return append(fields, Group(a.Key, group...))
+	case slog.KindString:
+		return append(fields, String(a.Key, a.Value.String()))
+	case slog.KindInt64:
+		return append(fields, Int64(a.Key, a.Value.Int64()))
+	case slog.KindUint64:
+		return append(fields, Any(a.Key, a.Value.Uint64()))
+	case slog.KindFloat64:
+		return append(fields, Any(a.Key, a.Value.Float64()))
+	case slog.KindBool:
+		return append(fields, Bool(a.Key, a.Value.Bool()))
+	case slog.KindDuration:
+		return append(fields, Duration(a.Key, a.Value.Duration()))
+	case slog.KindTime:
+		return append(fields, Any(a.Key, a.Value.Time()))
+	}
+	return append(fields, Any(a.Key, a.Value.Any()))
+}
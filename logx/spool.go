@@ -0,0 +1,552 @@
+package logx
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// SpoolRecord 落盘后等待投递的一条日志
+type SpoolRecord struct {
+	ID   string // <spool id>-<seq>，重复投递时不变，接收端据此去重
+	Seq  uint64 // 单调递增，同一个目录重启后继续递增
+	Line []byte
+}
+
+// Deliverer 需要确认的投递目标，Deliver 返回 nil 表示接收端已经确认收到这一批。
+// 返回错误时整批会被重新投递，所以接收端需要按 ID 去重
+type Deliverer interface {
+	Deliver(records []SpoolRecord) error
+}
+
+// SpoolConfig 磁盘队列配置
+type SpoolConfig struct {
+	Dir         string        // 队列目录，每个投递目标使用单独的目录
+	SegmentSize int64         // 单个段文件的大小，默认16MB，全部确认后删除
+	BatchSize   int           // 每次投递的最大条数，默认500
+	Sync        bool          // 每条日志写入后 fsync，审计日志建议开启
+	RetryMin    time.Duration // 投递失败后的首次重试间隔，默认100毫秒，之后翻倍
+	RetryMax    time.Duration // 最长重试间隔，默认30秒
+}
+
+// SpoolStats 磁盘队列状态
+type SpoolStats struct {
+	Written   uint64 // 最后写入的序号
+	Acked     uint64 // 最后确认的序号
+	Pending   uint64 // 等待确认的条数
+	Expired   uint64 // 投递前已经过期而丢弃的条数
+	LastError error  // 最近一次投递失败的错误，投递成功后清空
+}
+
+// SpoolSink 至少一次投递的输出目标：日志先追加写入磁盘队列，后台协程按顺序投递，
+// 只有 Deliverer 确认后才从磁盘删除。进程退出时未确认的日志留在目录中，下次打开同一目录后继续投递
+type SpoolSink struct {
+	cfg       SpoolConfig
+	deliverer Deliverer
+	id        string
+
+	mu      sync.Mutex // 保护写入段
+	seg     spoolFile
+	segSize int64
+	closed  bool
+
+	written atomic.Uint64
+	acked   atomic.Uint64
+	expired atomic.Uint64
+	errMu   sync.Mutex
+	lastErr error
+
+	reader  *spoolReader // 只在投递协程中使用
+	cleaned uint64       // 上次清理时读取位置所在的段
+	notify  chan struct{}
+	done    chan struct{}
+	wg      sync.WaitGroup
+}
+
+// 正在写入的段文件，测试中替换为会写入失败的实现
+type spoolFile interface {
+	io.WriteCloser
+	Truncate(size int64) error
+	Sync() error
+}
+
+const (
+	spoolHeaderSize = 16 // seq(8) + 长度(4) + crc32(4)
+	spoolSegPrefix  = "spool-"
+	spoolSegSuffix  = ".seg"
+)
+
+// NewSpoolSink 打开(或创建)磁盘队列并开始投递
+func NewSpoolSink(deliverer Deliverer, cfg SpoolConfig) (*SpoolSink, error) {
+	if cfg.Dir == "" {
+		return nil, errors.New("logx: spool dir is required")
+	}
+	if cfg.SegmentSize <= 0 {
+		cfg.SegmentSize = 16 << 20
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 500
+	}
+	if cfg.RetryMin <= 0 {
+		cfg.RetryMin = 100 * time.Millisecond
+	}
+	if cfg.RetryMax < cfg.RetryMin {
+		cfg.RetryMax = 30 * time.Second
+	}
+	if err := os.MkdirAll(cfg.Dir, 0755); err != nil {
+		return nil, err
+	}
+	s := &SpoolSink{
+		cfg:       cfg,
+		deliverer: deliverer,
+		notify:    make(chan struct{}, 1),
+		done:      make(chan struct{}),
+	}
+	if err := s.recover(); err != nil {
+		return nil, err
+	}
+	s.wg.Add(1)
+	go s.run()
+	return s, nil
+}
+
+// 读取队列标识和确认位置，截掉最后一个段中崩溃时没写完的记录
+func (s *SpoolSink) recover() error {
+	id, err := os.ReadFile(filepath.Join(s.cfg.Dir, "id"))
+	if errors.Is(err, os.ErrNotExist) {
+		b := make([]byte, 8)
+		rand.Read(b)
+		id = []byte(hex.EncodeToString(b))
+		err = writeFileAtomic(filepath.Join(s.cfg.Dir, "id"), id)
+	}
+	if err != nil {
+		return err
+	}
+	s.id = strings.TrimSpace(string(id))
+
+	if data, err := os.ReadFile(filepath.Join(s.cfg.Dir, "acked")); err == nil {
+		acked, err := strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+		if err != nil {
+			return fmt.Errorf("logx: spool: invalid acked file: %w", err)
+		}
+		s.acked.Store(acked)
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	s.written.Store(s.acked.Load())
+
+	segs, err := s.segments()
+	if err != nil || len(segs) == 0 {
+		return err
+	}
+	last := segs[len(segs)-1]
+	file, err := os.OpenFile(s.segPath(last), os.O_RDWR, 0644)
+	if err != nil {
+		return err
+	}
+	lastSeq, size, err := scanSegment(file)
+	if err == nil {
+		err = file.Truncate(size)
+	}
+	if err == nil {
+		_, err = file.Seek(size, io.SeekStart)
+	}
+	if err != nil {
+		file.Close()
+		return err
+	}
+	if lastSeq > s.written.Load() {
+		s.written.Store(lastSeq)
+	}
+	s.seg, s.segSize = file, size
+	s.removeAcked()
+	return nil
+}
+
+// 返回段中最后一条完整记录的序号和完整记录的总长度
+func scanSegment(file *os.File) (uint64, int64, error) {
+	r := bufio.NewReader(file)
+	var lastSeq uint64
+	var size int64
+	header := make([]byte, spoolHeaderSize)
+	for {
+		if _, err := io.ReadFull(r, header); err != nil {
+			return lastSeq, size, nil
+		}
+		seq, n, sum := decodeSpoolHeader(header)
+		payload := make([]byte, n)
+		if _, err := io.ReadFull(r, payload); err != nil || crc32.ChecksumIEEE(payload) != sum {
+			return lastSeq, size, nil
+		}
+		lastSeq = seq
+		size += int64(spoolHeaderSize) + int64(n)
+	}
+}
+
+func decodeSpoolHeader(h []byte) (seq uint64, n uint32, sum uint32) {
+	return binary.BigEndian.Uint64(h), binary.BigEndian.Uint32(h[8:]), binary.BigEndian.Uint32(h[12:])
+}
+
+func (s *SpoolSink) segPath(first uint64) string {
+	return filepath.Join(s.cfg.Dir, fmt.Sprintf("%s%020d%s", spoolSegPrefix, first, spoolSegSuffix))
+}
+
+// 按第一条记录的序号排列的段
+func (s *SpoolSink) segments() ([]uint64, error) {
+	matches, err := filepath.Glob(filepath.Join(s.cfg.Dir, spoolSegPrefix+"*"+spoolSegSuffix))
+	if err != nil {
+		return nil, err
+	}
+	var firsts []uint64
+	for _, m := range matches {
+		name := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(m), spoolSegPrefix), spoolSegSuffix)
+		if first, err := strconv.ParseUint(name, 10, 64); err == nil {
+			firsts = append(firsts, first)
+		}
+	}
+	sort.Slice(firsts, func(i, j int) bool { return firsts[i] < firsts[j] })
+	return firsts, nil
+}
+
+// 删除全部记录都已确认的段，正在写入的段保留
+func (s *SpoolSink) removeAcked() {
+	segs, err := s.segments()
+	if err != nil {
+		return
+	}
+	acked := s.acked.Load()
+	for i := 0; i+1 < len(segs); i++ {
+		if segs[i+1]-1 <= acked {
+			os.Remove(s.segPath(segs[i]))
+		}
+	}
+}
+
+func (s *SpoolSink) Write(entry *Entry, line []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return os.ErrClosed
+	}
+	seq := s.written.Load() + 1
+	if s.seg == nil || s.segSize >= s.cfg.SegmentSize {
+		if err := s.nextSegment(seq); err != nil {
+			return err
+		}
+	}
+
+	buf := make([]byte, spoolHeaderSize, spoolHeaderSize+len(line))
+	binary.BigEndian.PutUint64(buf, seq)
+	binary.BigEndian.PutUint32(buf[8:], uint32(len(line)))
+	binary.BigEndian.PutUint32(buf[12:], crc32.ChecksumIEEE(line))
+	buf = append(buf, line...)
+	_, err := s.seg.Write(buf)
+	if err == nil && s.cfg.Sync {
+		err = s.seg.Sync()
+	}
+	if err != nil {
+		s.discardTorn(seq)
+		return err
+	}
+	s.segSize += int64(len(buf))
+	s.written.Store(seq)
+	select {
+	case s.notify <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// 写入失败时去掉可能写了一半的记录，之后的记录仍接在最后一条完整记录后面；
+// 截断也失败时关闭该段，下一条写入新的段，读取时遇到损坏的结尾会跳到新的段；
+// 段中还没有完整记录时直接删除，新的段会使用同一个文件名
+func (s *SpoolSink) discardTorn(seq uint64) {
+	if err := s.seg.Truncate(s.segSize); err != nil {
+		s.seg.Close()
+		s.seg = nil
+		if s.segSize == 0 {
+			os.Remove(s.segPath(seq))
+		}
+	}
+}
+
+// 新的段以第一条记录的序号命名，投递时据此找到下一个段
+func (s *SpoolSink) nextSegment(first uint64) error {
+	if s.seg != nil {
+		s.seg.Close()
+		s.seg = nil
+	}
+	file, err := os.OpenFile(s.segPath(first), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	s.seg, s.segSize = file, 0
+	return nil
+}
+
+// 投递协程：按序号读取未确认的记录，失败时按指数退避重试同一批
+func (s *SpoolSink) run() {
+	defer s.wg.Done()
+	defer func() { s.reader.close() }()
+	retry := s.cfg.RetryMin
+	var batch []SpoolRecord
+	for {
+		if len(batch) == 0 {
+			next := s.acked.Load() + 1
+			if next > s.written.Load() {
+				select {
+				case <-s.notify:
+					continue
+				case <-s.done:
+					return
+				}
+			}
+			var err error
+			batch, err = s.readBatch(next)
+			if err == nil && len(batch) == 0 {
+				err = fmt.Errorf("logx: spool: seq %d not found", next)
+			}
+			if err != nil {
+				s.setErr(err)
+				batch = nil
+				if !s.sleep(retry) {
+					return
+				}
+				retry = min(retry*2, s.cfg.RetryMax)
+				continue
+			}
+		}
+
+		// 重试期间也可能过期，每次投递前都检查
+		last := batch[len(batch)-1].Seq
+		batch = s.dropExpired(batch)
+		if len(batch) > 0 {
+			if err := s.deliverer.Deliver(batch); err != nil {
+				s.setErr(err)
+				if !s.sleep(retry) {
+					return
+				}
+				retry = min(retry*2, s.cfg.RetryMax)
+				continue
+			}
+		}
+		retry = s.cfg.RetryMin
+		s.setErr(nil)
+		if err := s.ack(last); err != nil {
+			s.setErr(err)
+		}
+		batch = nil
+	}
+}
+
+func (s *SpoolSink) sleep(d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-s.done:
+		return false
+	}
+}
+
+func (s *SpoolSink) setErr(err error) {
+	s.errMu.Lock()
+	s.lastErr = err
+	s.errMu.Unlock()
+}
+
+// 持久化确认位置后删除已确认的段
+func (s *SpoolSink) ack(seq uint64) error {
+	s.acked.Store(seq)
+	if err := writeFileAtomic(filepath.Join(s.cfg.Dir, "acked"), []byte(strconv.FormatUint(seq, 10))); err != nil {
+		return err
+	}
+	// 读取位置进入新的段后，之前的段都已确认
+	if s.reader != nil && s.reader.first != s.cleaned {
+		s.removeAcked()
+		s.cleaned = s.reader.first
+	}
+	return nil
+}
+
+// 从磁盘读取从 next 开始、已经完整写入的最多 BatchSize 条记录
+func (s *SpoolSink) readBatch(next uint64) ([]SpoolRecord, error) {
+	if s.reader == nil || s.reader.next != next {
+		if err := s.openReader(next); err != nil {
+			return nil, err
+		}
+	}
+	last := s.written.Load()
+	var batch []SpoolRecord
+	for s.reader.next <= last && len(batch) < s.cfg.BatchSize {
+		rec, err := s.reader.read()
+		if errors.Is(err, io.EOF) {
+			// 当前段已经读完，下一条在以它的序号命名的新段中
+			if err := s.reader.open(s, s.reader.next); err != nil {
+				return batch, err
+			}
+			continue
+		}
+		if err != nil {
+			// 写入失败后没能截掉的半条记录，下一条在以它的序号命名的新段中
+			if _, serr := os.Stat(s.segPath(s.reader.next)); serr == nil && s.reader.next > s.reader.first {
+				if err := s.reader.open(s, s.reader.next); err != nil {
+					return batch, err
+				}
+				continue
+			}
+			s.reader.close()
+			s.reader = nil
+			return batch, err
+		}
+		if rec.Seq < next {
+			continue
+		}
+		rec.ID = s.id + "-" + strconv.FormatUint(rec.Seq, 10)
+		batch = append(batch, rec)
+	}
+	return batch, nil
+}
+
+// 找到包含 next 的段并跳过之前的记录
+func (s *SpoolSink) openReader(next uint64) error {
+	segs, err := s.segments()
+	if err != nil {
+		return err
+	}
+	first := uint64(0)
+	for _, f := range segs {
+		if f <= next {
+			first = f
+		}
+	}
+	if first == 0 {
+		return fmt.Errorf("logx: spool: segment for seq %d not found", next)
+	}
+	s.reader.close()
+	s.reader = &spoolReader{}
+	if err := s.reader.open(s, first); err != nil {
+		return err
+	}
+	for s.reader.next < next {
+		if _, err := s.reader.read(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type spoolReader struct {
+	file  *os.File
+	r     *bufio.Reader
+	first uint64 // 当前段的第一条序号
+	next  uint64 // 下一条要读的序号
+}
+
+func (r *spoolReader) open(s *SpoolSink, first uint64) error {
+	file, err := os.Open(s.segPath(first))
+	if err != nil {
+		return err
+	}
+	if r.file != nil {
+		r.file.Close()
+	}
+	r.file, r.r, r.first, r.next = file, bufio.NewReader(file), first, first
+	return nil
+}
+
+// 调用方保证 r.next 已经完整写入，或者当前段已经写完
+func (r *spoolReader) read() (SpoolRecord, error) {
+	header := make([]byte, spoolHeaderSize)
+	if _, err := io.ReadFull(r.r, header); err != nil {
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			return SpoolRecord{}, errors.New("logx: spool: truncated record header")
+		}
+		return SpoolRecord{}, err
+	}
+	seq, n, sum := decodeSpoolHeader(header)
+	line := make([]byte, n)
+	if _, err := io.ReadFull(r.r, line); err != nil {
+		return SpoolRecord{}, errors.New("logx: spool: truncated record")
+	}
+	if crc32.ChecksumIEEE(line) != sum {
+		return SpoolRecord{}, fmt.Errorf("logx: spool: checksum mismatch at seq %d", seq)
+	}
+	r.next = seq + 1
+	return SpoolRecord{Seq: seq, Line: line}, nil
+}
+
+func (r *spoolReader) close() {
+	if r != nil && r.file != nil {
+		r.file.Close()
+		r.file = nil
+	}
+}
+
+// 去掉带有 ExpiresKey 字段且已经过期的记录，例如故障期间积压的告警
+func (s *SpoolSink) dropExpired(batch []SpoolRecord) []SpoolRecord {
+	kept := batch[:0]
+	now := time.Now()
+	for _, rec := range batch {
+		if bytes.Contains(rec.Line, []byte(ExpiresKey)) {
+			if entry, err := ParseLine(rec.Line); err == nil && entry.Expired(now) {
+				s.expired.Add(1)
+				continue
+			}
+		}
+		kept = append(kept, rec)
+	}
+	return kept
+}
+
+// Stats 返回磁盘队列的状态
+func (s *SpoolSink) Stats() SpoolStats {
+	written, acked := s.written.Load(), s.acked.Load()
+	s.errMu.Lock()
+	defer s.errMu.Unlock()
+	return SpoolStats{Written: written, Acked: acked, Pending: written - acked, Expired: s.expired.Load(), LastError: s.lastErr}
+}
+
+// Close 停止投递并关闭段文件，未确认的日志留在磁盘上，下次打开同一目录后继续投递
+func (s *SpoolSink) Close() error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+	s.closed = true
+	s.mu.Unlock()
+
+	close(s.done)
+	s.wg.Wait()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.seg == nil {
+		return nil
+	}
+	return s.seg.Close()
+}
+
+// 先写临时文件再重命名，避免崩溃时留下不完整的内容
+func writeFileAtomic(path string, data []byte) error {
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,76 @@
+package logx
+
+import (
+	"os"
+	"runtime/debug"
+)
+
+// 标准字段的键
+const (
+	HostKey    = "host"
+	PIDKey     = "pid"
+	ServiceKey = "service"
+	VersionKey = "version"
+)
+
+// WithStaticFields 为每条日志(包括日志记录器自身产生的)在最前面加上 fields，所有格式和输出目标都会带上，
+// 用于区分多实例部署的日志来源，例如 WithStaticFields(Hostname(), PID(), Service("billing"), BuildVersion())
+func WithStaticFields(fields ...Field) Option {
+	return func(l *Logger) {
+		l.staticFields = append(l.staticFields, fields...)
+	}
+}
+
+// Hostname 当前主机名，获取失败时为空
+func Hostname() Field {
+	host, _ := os.Hostname()
+	return Indexed(HostKey, host)
+}
+
+// PID 当前进程号
+func PID() Field {
+	return Field{Key: PIDKey, Value: os.Getpid()}
+}
+
+// Service 服务名称
+func Service(name string) Field {
+	return Indexed(ServiceKey, name)
+}
+
+// Version 版本号，例如构建时通过 -ldflags "-X main.version=..." 注入的值
+func Version(version string) Field {
+	return Field{Key: VersionKey, Value: version}
+}
+
+// BuildVersion 从构建信息中读取主模块的版本，没有版本时(例如 go run 或本地构建)使用 VCS 提交号
+func BuildVersion() Field {
+	version := "unknown"
+	if info, ok := debug.ReadBuildInfo(); ok {
+		if info.Main.Version != "" && info.Main.Version != "(devel)" {
+			version = info.Main.Version
+		} else {
+			for _, s := range info.Settings {
+				if s.Key == "vcs.revision" {
+					version = s.Value
+				}
+			}
+		}
+	}
+	return Version(version)
+}
+
+// 加上静态字段，返回的条目使用新的字段切片
+func (l *Logger) applyStaticFields(entry Entry) Entry {
+	fields := l.allocFields(len(l.staticFields) + len(entry.Fields))
+	if len(entry.hiddenStatic) == 0 {
+		fields = append(fields, l.staticFields...)
+	} else {
+		for _, f := range l.staticFields {
+			if !containsString(entry.hiddenStatic, f.Key) {
+				fields = append(fields, f)
+			}
+		}
+	}
+	entry.Fields = append(fields, entry.Fields...)
+	return entry
+}
@@ -0,0 +1,71 @@
+package logx
+
+import "sync"
+
+// 每个订阅者的缓冲条数，读取跟不上时新日志会被丢弃
+const subscriberBuffer = 256
+
+type subscriber struct {
+	ch       chan Entry
+	minLevel LogLevel
+	closed   bool
+}
+
+// Subscribe 订阅实时写入的日志，例如管理后台的 websocket 或调试界面。
+// 只推送不低于 minLevel 的日志；读取跟不上时直接丢弃，不会阻塞写入。
+// 调用 cancel 或 Close 后通道关闭，cancel 可以重复调用
+func (l *Logger) Subscribe(minLevel LogLevel) (<-chan Entry, func()) {
+	sub := &subscriber{ch: make(chan Entry, subscriberBuffer), minLevel: minLevel}
+	l.mu.Lock()
+	if l.subscribeClosed {
+		sub.close()
+	} else {
+		l.subscribers = append(l.subscribers, sub)
+	}
+	l.mu.Unlock()
+
+	var once sync.Once
+	cancel := func() {
+		once.Do(func() {
+			l.mu.Lock()
+			defer l.mu.Unlock()
+			for i, s := range l.subscribers {
+				if s == sub {
+					l.subscribers = append(l.subscribers[:i], l.subscribers[i+1:]...)
+					break
+				}
+			}
+			sub.close()
+		})
+	}
+	return sub.ch, cancel
+}
+
+func (s *subscriber) close() {
+	if !s.closed {
+		s.closed = true
+		close(s.ch)
+	}
+}
+
+// 调用方需持有 l.mu
+func (l *Logger) publish(entry Entry) {
+	for _, sub := range l.subscribers {
+		if entry.Level < sub.minLevel {
+			continue
+		}
+		select {
+		case sub.ch <- entry:
+		default:
+		}
+	}
+}
+
+// Close 时关闭所有订阅，调用方需持有 l.mu
+func (l *Logger) closeSubscribers() {
+	for _, sub := range l.subscribers {
+		sub.close()
+	}
+	l.subscribers = nil
+	l.subscribeClosed = true
+}
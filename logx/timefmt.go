@@ -0,0 +1,116 @@
+package logx
+
+import (
+	"strconv"
+	"time"
+)
+
+// 特殊的时间格式，输出为整数时间戳
+const (
+	TimeFormatEpochSeconds = "epoch_seconds"
+	TimeFormatEpochMillis  = "epoch_millis"
+	TimeFormatEpochNanos   = "epoch_nanos"
+)
+
+// 文本格式默认的时间前缀格式，与标准库 log.LstdFlags 一致
+const defaultTextTimeLayout = "2006/01/02 15:04:05"
+
+// WithTimeFormat 设置时间格式，可以是 time 包的布局(如 time.RFC3339Nano)或 TimeFormatEpochMillis 等
+func WithTimeFormat(layout string) Option {
+	return func(l *Logger) {
+		l.timeLayout = layout
+	}
+}
+
+// WithTimeLocation 设置输出时间的时区，默认本地时区
+func WithTimeLocation(loc *time.Location) Option {
+	return func(l *Logger) {
+		l.timeLoc = loc
+	}
+}
+
+// WithUTC 以UTC时间输出
+func WithUTC() Option {
+	return WithTimeLocation(time.UTC)
+}
+
+// WithClock 替换获取当前时间的函数，测试中可以得到确定的输出
+func WithClock(clock func() time.Time) Option {
+	return func(l *Logger) {
+		l.clock = clock
+	}
+}
+
+// TimeSkewKey WithTimeSkewField 默认的字段名
+const TimeSkewKey = "write_skew"
+
+// WithWriteTime 输出写入协程写入日志的时间，而不是调用日志方法的时间(默认)。
+// 队列积压时两者可能相差数秒，分析耗时请使用默认的调用时间
+func WithWriteTime() Option {
+	return func(l *Logger) {
+		l.writeTime = true
+	}
+}
+
+// WithTimeSkewField 为每条日志添加字段 key(为空时使用 TimeSkewKey)，值为写入时间与调用时间之差，
+// 用于观察队列积压造成的延迟
+func WithTimeSkewField(key string) Option {
+	return func(l *Logger) {
+		if key == "" {
+			key = TimeSkewKey
+		}
+		l.skewKey = key
+	}
+}
+
+// 在写入协程中记录写入时间
+func (l *Logger) stampWriteTime(entry Entry) Entry {
+	now := l.now()
+	if l.skewKey != "" && !entry.Time.IsZero() {
+		fields := make([]Field, 0, len(entry.Fields)+1)
+		fields = append(fields, entry.Fields...)
+		entry.Fields = append(fields, Field{Key: l.skewKey, Value: now.Sub(entry.Time)})
+	}
+	if l.writeTime {
+		entry.Time = now
+	}
+	return entry
+}
+
+func (l *Logger) now() time.Time {
+	if l.clock != nil {
+		return l.clock()
+	}
+	return time.Now()
+}
+
+// 按配置的时区和格式输出时间，defaultLayout 在没有设置 WithTimeFormat 时使用
+func (l *Logger) appendTime(buf []byte, t time.Time, defaultLayout string, quote bool) []byte {
+	if l.timeLoc != nil {
+		t = t.In(l.timeLoc)
+	}
+	layout := l.timeLayout
+	if layout == "" {
+		layout = defaultLayout
+	}
+	switch layout {
+	case TimeFormatEpochSeconds:
+		return strconv.AppendInt(buf, t.Unix(), 10)
+	case TimeFormatEpochMillis:
+		return strconv.AppendInt(buf, t.UnixMilli(), 10)
+	case TimeFormatEpochNanos:
+		return strconv.AppendInt(buf, t.UnixNano(), 10)
+	}
+	if quote {
+		// 时间布局中不应包含需要转义的字符
+		buf = append(buf, '"')
+		buf = t.AppendFormat(buf, layout)
+		return append(buf, '"')
+	}
+	return t.AppendFormat(buf, layout)
+}
+
+// 文本格式的时间前缀
+func (l *Logger) textTime(t time.Time) string {
+	return string(l.appendTime(nil, t, defaultTextTimeLayout, false))
+}
@@ -0,0 +1,120 @@
+package logx
+
+import (
+	"sort"
+	"strconv"
+	"unicode/utf8"
+)
+
+// WithMaxEntrySize 限制单条日志中消息和字段值的总字节数，超出时从最长的值开始截断，
+// 被截断的值末尾追加 "...[truncated, N bytes]"，N 为原始长度。字段名和数值等短值不截断，
+// 避免意外打印的大段内容撑爆切割统计或拖慢下游采集
+func WithMaxEntrySize(bytes int) Option {
+	return func(l *Logger) {
+		l.maxEntrySize = bytes
+	}
+}
+
+const truncatedSuffix = "...[truncated, "
+
+// 可截断的值：消息(index = -1)或转换为字符串后的字段值
+type truncPart struct {
+	index int
+	text  string
+}
+
+// 调用方保证 l.maxEntrySize > 0
+func (l *Logger) truncateEntry(entry Entry) Entry {
+	size := len(entry.Message)
+	for _, f := range entry.Fields {
+		size += len(f.Key) + valueSize(f.Value)
+	}
+	if size <= l.maxEntrySize {
+		return entry
+	}
+
+	parts := []truncPart{{index: -1, text: entry.Message}}
+	for i, f := range entry.Fields {
+		if !isScalar(f.Value) {
+			parts = append(parts, truncPart{index: i, text: textValue(f.Value)})
+		}
+	}
+
+	// 找到最大的单值上限 limit，使所有值截断到 limit 后总大小不超过限制
+	budget := l.maxEntrySize - (size - partsLen(parts))
+	sort.Slice(parts, func(i, j int) bool { return len(parts[i].text) < len(parts[j].text) })
+	limit := 0
+	if budget > 0 {
+		limit = budget
+		for i, p := range parts {
+			share := budget / (len(parts) - i)
+			if len(p.text) > share {
+				limit = share
+				break
+			}
+			budget -= len(p.text)
+		}
+	}
+
+	fields := append([]Field(nil), entry.Fields...)
+	truncated := false
+	for _, p := range parts {
+		if len(p.text) <= limit {
+			continue
+		}
+		text := truncateString(p.text, limit)
+		truncated = true
+		if p.index < 0 {
+			entry.Message = text
+		} else {
+			fields[p.index].Value = text
+		}
+	}
+	if truncated {
+		entry.Fields = fields
+		l.metrics.truncated.Add(1)
+	}
+	return entry
+}
+
+func partsLen(parts []truncPart) int {
+	n := 0
+	for _, p := range parts {
+		n += len(p.text)
+	}
+	return n
+}
+
+// 在不超过 limit 字节的 UTF-8 字符边界处截断并追加原始长度
+func truncateString(s string, limit int) string {
+	cut := limit
+	for cut > 0 && !utf8.RuneStart(s[cut]) {
+		cut--
+	}
+	out := make([]byte, 0, cut+len(truncatedSuffix)+16)
+	out = append(out, s[:cut]...)
+	out = append(out, truncatedSuffix...)
+	out = strconv.AppendInt(out, int64(len(s)), 10)
+	out = append(out, " bytes]"...)
+	return string(out)
+}
+
+// 数值等短值不参与截断
+func isScalar(v interface{}) bool {
+	switch v.(type) {
+	case nil, bool, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+		return true
+	}
+	return false
+}
+
+// 字符串直接取长度，数值按固定长度估算，避免没有超出限制时也要格式化
+func valueSize(v interface{}) int {
+	if s, ok := v.(string); ok {
+		return len(s)
+	}
+	if isScalar(v) {
+		return 8
+	}
+	return len(textValue(v))
+}
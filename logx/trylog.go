@@ -0,0 +1,29 @@
+package logx
+
+import "errors"
+
+// TryLog 等方法返回的日志去向，nil 表示已放入队列
+var (
+	ErrBelowLevel = errors.New("logx: below minimum level")
+	ErrSampled    = errors.New("logx: dropped by sampling")
+	ErrQueueFull  = errors.New("logx: queue full")
+)
+
+// TryLog 与 Info 等方法相同，但队列满时不等待，返回日志的去向：nil 表示已放入队列(之后仍可能被过滤或路由规则丢弃)，
+// ErrBelowLevel、ErrSampled 表示因等级或采样没有记录，ErrQueueFull 表示队列已满，ErrClosed 表示已经 Close
+// (不按 WithClosedPolicy 处理)，开启 WithWAL 时返回写入预写日志的错误。
+// 用于需要知道日志是否被接收的调用方，例如审计代码在失败时改走同步的写入方式。
+// 未被接收的日志不计入 Stats().Dropped
+func (l *Logger) TryLog(level LogLevel, msg string, fields ...Field) error {
+	return l.tryLog(level, msg, fields)
+}
+
+func (l *Logger) TryDebug(msg string, fields ...Field) error { return l.tryLog(DEBUG, msg, fields) }
+func (l *Logger) TryInfo(msg string, fields ...Field) error  { return l.tryLog(INFO, msg, fields) }
+func (l *Logger) TryWarn(msg string, fields ...Field) error  { return l.tryLog(WARN, msg, fields) }
+func (l *Logger) TryError(msg string, fields ...Field) error { return l.tryLog(ERROR, msg, fields) }
+
+// 与 Logger.log 的调用层数相同，见 callerSkipFromLogFunc
+func (l *Logger) tryLog(level LogLevel, msg string, fields []Field) error {
+	return l.logScoped(level, msg, nil, fields, true)
+}
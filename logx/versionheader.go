@@ -0,0 +1,158 @@
+package logx
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 版本文件头的行前缀，文本和JSON格式的解析都不会把它当作日志
+const versionHeaderPrefix = "#logx "
+
+const modulePath = "github.com/capyflow/opensource/logx"
+
+// FileHeader WithVersionHeader 写在每个日志文件开头的信息
+type FileHeader struct {
+	Version string    // logx 的版本，从构建信息中读取，本地构建时为 (devel)
+	Format  string    // 日志格式，text 或 json
+	Schema  int       // JSON输出的结构版本，见 WithSchemaVersion
+	Start   time.Time // 创建文件的时间
+	Host    string
+}
+
+// WithVersionHeader 每个新建的日志文件开头写入一行 "#logx version=... format=... schema=... start=... host=..."，
+// 在归档中找到的文件不依赖部署信息也能知道如何读取，用 ReadFileHeader 读出。
+// 写在 WithFileHeader 的内容之前，同样计入文件大小，审计模式和二进制格式不写
+func WithVersionHeader() Option {
+	return func(l *Logger) {
+		l.versionHeader = true
+	}
+}
+
+var loggerVersion = sync.OnceValue(func() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "(devel)"
+	}
+	if info.Main.Path == modulePath && info.Main.Version != "" {
+		return info.Main.Version
+	}
+	for _, dep := range info.Deps {
+		if dep.Path == modulePath {
+			if dep.Replace != nil && dep.Replace.Version != "" {
+				return dep.Replace.Version
+			}
+			return dep.Version
+		}
+	}
+	return "(devel)"
+})
+
+func (l *Logger) encodeVersionHeader() []byte {
+	host, _ := os.Hostname()
+	h := FileHeader{
+		Version: loggerVersion(),
+		Format:  l.format.String(),
+		Schema:  l.schemaVersion,
+		Start:   l.now(),
+		Host:    host,
+	}
+	return []byte(h.String() + "\n")
+}
+
+// String 编码为文件头的行，不含换行符
+func (h FileHeader) String() string {
+	var b strings.Builder
+	b.WriteString(strings.TrimSpace(versionHeaderPrefix))
+	for _, kv := range [...][2]string{
+		{"version", h.Version},
+		{"format", h.Format},
+		{"schema", strconv.Itoa(h.Schema)},
+		{"start", h.Start.Format(time.RFC3339Nano)},
+		{"host", h.Host},
+	} {
+		b.WriteString(" " + kv[0] + "=")
+		if quoted := strconv.Quote(kv[1]); kv[1] == "" || strings.Contains(kv[1], " ") || quoted[1:len(quoted)-1] != kv[1] {
+			b.WriteString(quoted)
+		} else {
+			b.WriteString(kv[1])
+		}
+	}
+	return b.String()
+}
+
+// ParseFileHeader 解析 FileHeader.String 的输出，不认识的键会被忽略，以便新版本增加信息
+func ParseFileHeader(line string) (FileHeader, error) {
+	var h FileHeader
+	rest, ok := strings.CutPrefix(strings.TrimRight(line, "\r\n"), versionHeaderPrefix)
+	if !ok {
+		return h, fmt.Errorf("logx: not a version header: %q", line)
+	}
+	for rest = strings.TrimLeft(rest, " "); rest != ""; rest = strings.TrimLeft(rest, " ") {
+		key, value, ok := strings.Cut(rest, "=")
+		if !ok || strings.Contains(key, " ") {
+			return h, fmt.Errorf("logx: bad version header: %q", line)
+		}
+		if strings.HasPrefix(value, `"`) {
+			quoted, err := strconv.QuotedPrefix(value)
+			if err != nil {
+				return h, fmt.Errorf("logx: bad version header: %q", line)
+			}
+			rest = value[len(quoted):]
+			value, _ = strconv.Unquote(quoted)
+		} else {
+			value, rest, _ = strings.Cut(value, " ")
+		}
+		switch key {
+		case "version":
+			h.Version = value
+		case "format":
+			h.Format = value
+		case "schema":
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				return h, fmt.Errorf("logx: bad schema in version header: %q", value)
+			}
+			h.Schema = n
+		case "start":
+			t, err := time.Parse(time.RFC3339Nano, value)
+			if err != nil {
+				return h, fmt.Errorf("logx: bad start in version header: %q", value)
+			}
+			h.Start = t
+		case "host":
+			h.Host = value
+		}
+	}
+	return h, nil
+}
+
+// ReadFileHeader 读取日志文件或归档(包括压缩、加密后的)开头的版本文件头，没有文件头时返回 nil。
+// 文件未加密时 keys 可以为 nil
+func ReadFileHeader(path string, keys KeyProvider) (*FileHeader, error) {
+	br, closeFile, err := openLogReader(path, keys)
+	if err != nil {
+		return nil, err
+	}
+	defer closeFile()
+	if head, _ := br.Peek(len(encryptMagic)); string(head) == encryptMagic {
+		return nil, fmt.Errorf("logx: %s is encrypted", path)
+	}
+	if head, _ := br.Peek(len(versionHeaderPrefix)); string(head) != versionHeaderPrefix {
+		return nil, nil
+	}
+	line, err := br.ReadString('\n')
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	h, err := ParseFileHeader(line)
+	if err != nil {
+		return nil, err
+	}
+	return &h, nil
+}
@@ -0,0 +1,260 @@
+package logx
+
+import (
+	"bytes"
+	"context"
+	"crypto/cipher"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// WithWAL 开启预写队列：调用日志方法时日志先以二进制格式追加写入 cfg.Dir 下的磁盘队列，
+// 写入协程从队列读取、写入文件和输出目标后再确认。进程在放入队列和写入文件之间崩溃时，
+// 下次用同一目录创建日志记录器会重新写入未确认的日志(可能重复最后一批)。
+// cfg 的含义与 NewSpoolSink 相同，需要断电也不丢失时开启 Sync。
+// 调用方需要等待磁盘写入，吞吐低于默认的内存队列；字段值经过二进制编码，类型可能变化(例如 int 变为 int64)，
+// 调用位置、字段的索引提示、To/Also 指定的输出目标和子日志记录器去掉的静态字段会一起保存。
+// 开启 WithRedaction 时先脱敏再写入队列；开启 WithEncryption 时每条记录用创建日志记录器时的当前密钥加密
+func WithWAL(cfg SpoolConfig) Option {
+	return func(l *Logger) {
+		l.walStarted = make(chan struct{})
+		wal, err := NewSpoolSink(walDeliverer{l}, cfg)
+		if err != nil {
+			l.optErr = err
+			return
+		}
+		l.wal = wal
+	}
+}
+
+// 调用方一侧写入预写队列
+func (l *Logger) walAppend(entry Entry) error {
+	if entry.belowLevel && !l.filter.keep(&entry) {
+		// 二进制格式不保存该标记，先在调用方判断
+		l.metrics.filtered.Add(1)
+		return ErrBelowLevel
+	}
+	if l.redactor != nil {
+		// 敏感信息不能以明文留在磁盘上，写入协程中的 redact 环节会再执行一次，结果不变
+		entry = l.redactor.apply(entry)
+	}
+	bufp := getBuffer()
+	*bufp = appendWALTargets(appendBinary((*bufp)[:0], entry), entry)
+	record := *bufp
+	var err error
+	if l.walCipher != nil {
+		sealedp := getBuffer()
+		defer putBuffer(sealedp)
+		*sealedp, err = l.walCipher.seal((*sealedp)[:0], record)
+		record = *sealedp
+	}
+	if err == nil {
+		err = l.wal.Write(&entry, record)
+	}
+	putBuffer(bufp)
+	if err != nil {
+		l.metrics.dropped.Add(1)
+		l.handleError(OpWrite, l.wal.cfg.Dir, err)
+	}
+	return err
+}
+
+type walDeliverer struct {
+	l *Logger
+}
+
+func (d walDeliverer) Deliver(records []SpoolRecord) error {
+	// 恢复的日志要等 StartWorker 之后才能写入；Close 时 done 已关闭，仍要写完队列
+	select {
+	case <-d.l.walStarted:
+	default:
+		select {
+		case <-d.l.walStarted:
+		case <-d.l.done:
+			return ErrClosed
+		}
+	}
+	batch := make([]Entry, 0, len(records))
+	for _, rec := range records {
+		line := rec.Line
+		// 未加密的记录以 uvarint 长度和 MessagePack 数组开头，不会与 encryptMagic 混淆
+		if bytes.HasPrefix(line, []byte(encryptMagic)) {
+			plain, err := d.l.openWALRecord(line)
+			if err != nil {
+				// 密钥缺失或记录损坏时重试也无法成功，跳过
+				d.l.handleError(OpWrite, d.l.wal.cfg.Dir, err)
+				continue
+			}
+			line = plain
+		}
+		_, n := binary.Uvarint(line)
+		if n <= 0 {
+			continue
+		}
+		dec := msgpackDecoder{buf: line[n:]}
+		entry := dec.entry()
+		dec.walTargets(&entry)
+		if dec.err != nil {
+			// 损坏的记录无法重试成功，跳过
+			d.l.handleError(OpWrite, d.l.wal.cfg.Dir, dec.err)
+			continue
+		}
+		batch = append(batch, entry)
+	}
+	if len(batch) > 0 {
+		d.l.writeBatch(batch)
+		if !d.l.batchFlush {
+			d.l.mu.Lock()
+			err := d.l.flushBuffer()
+			d.l.mu.Unlock()
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// 等待预写队列中的日志全部确认，ctx 结束时返回它的错误
+func (l *Logger) drainWAL(ctx context.Context) error {
+	target := l.wal.Stats().Written
+	for l.wal.Stats().Acked < target {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Millisecond):
+		}
+	}
+	return nil
+}
+
+// Close 时写完队列中的日志后关闭，写入持续失败时最多等待 walCloseTimeout，剩余的留在磁盘上
+const walCloseTimeout = 5 * time.Second
+
+func (l *Logger) closeWAL() {
+	ctx, cancel := context.WithTimeout(context.Background(), walCloseTimeout)
+	defer cancel()
+	select {
+	case <-l.walStarted:
+		l.drainWAL(ctx)
+	default:
+		// 没有调用 StartWorker，队列中的日志留到下次
+	}
+	if err := l.wal.Close(); err != nil && !errors.Is(err, os.ErrClosed) {
+		l.handleError(OpWrite, l.wal.cfg.Dir, err)
+	}
+}
+
+// 二进制格式之外再追加 To、Also 指定的输出目标、去掉的静态字段和字段的索引提示：
+// MessagePack 数组 [标记, routes, alsoSinks, hiddenStatic, hints]，标记的第0位为 targeted，第1位为 routed，
+// hints 按字段顺序排列，都没有时不追加。旧版本写入的记录没有 hints
+func appendWALTargets(buf []byte, entry Entry) []byte {
+	hinted := false
+	for _, f := range entry.Fields {
+		if f.Hint != HintDefault {
+			hinted = true
+			break
+		}
+	}
+	if !entry.targeted && !entry.routed && len(entry.hiddenStatic) == 0 && !hinted {
+		return buf
+	}
+	var flags int64
+	if entry.targeted {
+		flags |= 1
+	}
+	if entry.routed {
+		flags |= 2
+	}
+	buf = appendMsgpackInt(append(buf, 0x95), flags)
+	for _, list := range [][]string{entry.routes, entry.alsoSinks, entry.hiddenStatic} {
+		buf = appendMsgpackHeader(buf, len(list), 0x90, 0xdc)
+		for _, s := range list {
+			buf = appendMsgpackString(buf, s)
+		}
+	}
+	if !hinted {
+		return append(buf, 0x90)
+	}
+	buf = appendMsgpackHeader(buf, len(entry.Fields), 0x90, 0xdc)
+	for _, f := range entry.Fields {
+		buf = appendMsgpackInt(buf, int64(f.Hint))
+	}
+	return buf
+}
+
+func (d *msgpackDecoder) walTargets(entry *Entry) {
+	if d.err != nil || len(d.buf) == 0 {
+		return
+	}
+	values, ok := d.value().([]interface{})
+	if !ok || len(values) < 4 || len(values) > 5 {
+		d.fail()
+		return
+	}
+	flags, _ := values[0].(int64)
+	entry.targeted, entry.routed = flags&1 != 0, flags&2 != 0
+	entry.routes = walStrings(values[1])
+	entry.alsoSinks = walStrings(values[2])
+	entry.hiddenStatic = walStrings(values[3])
+	if len(values) == 5 {
+		hints, _ := values[4].([]interface{})
+		if len(hints) == len(entry.Fields) {
+			for i, h := range hints {
+				hint, _ := h.(int64)
+				entry.Fields[i].Hint = FieldHint(hint)
+			}
+		}
+	}
+}
+
+func walStrings(v interface{}) []string {
+	values, _ := v.([]interface{})
+	if len(values) == 0 {
+		return nil
+	}
+	out := make([]string, 0, len(values))
+	for _, item := range values {
+		if s, ok := item.(string); ok {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// 加密预写队列记录的密钥，记录为 encryptMagic + 与 WithEncryption 的文件相同格式的一条加密记录
+type walCipher struct {
+	id   string
+	aead cipher.AEAD
+}
+
+func newWALCipher(keys KeyProvider) (*walCipher, error) {
+	id, key, err := keys.CurrentKey()
+	if err == nil && len(id) > 255 {
+		err = fmt.Errorf("logx: encryption key id %q is too long", id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	return &walCipher{id: id, aead: aead}, nil
+}
+
+func (c *walCipher) seal(buf, record []byte) ([]byte, error) {
+	return appendEncrypted(append(buf, encryptMagic...), c.aead, c.id, record)
+}
+
+// 解密预写队列中的记录，按记录中的密钥 ID 查找密钥，密钥轮换后仍能恢复旧记录
+func (l *Logger) openWALRecord(record []byte) ([]byte, error) {
+	if l.encryption == nil {
+		return nil, fmt.Errorf("logx: encrypted WAL record but WithEncryption is not set")
+	}
+	return io.ReadAll(NewDecryptReader(bytes.NewReader(record), l.encryption))
+}
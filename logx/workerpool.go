@@ -0,0 +1,170 @@
+package logx
+
+import (
+	"errors"
+	"sync"
+)
+
+// 默认每次调度最多写入的条数
+const defaultPoolQuantum = 64
+
+// WorkerPoolConfig 共享写入协程池的配置
+type WorkerPoolConfig struct {
+	Workers int // 写入协程数，<=0 时为1
+	Quantum int // 每次调度一个日志记录器最多写入的条数，<=0 时为64，越小越公平，批量合并的效果越差
+}
+
+// WorkerPool 多个日志记录器共用的写入协程池，用于一个进程中有成千上万个按租户、模块划分的小日志记录器的场景：
+// 各日志记录器不再各自占用一个写入协程，有日志的记录器按先来先服务轮流调度，每次最多写入 Quantum 条后排到队尾，
+// 日志量大的记录器不会让其他记录器等待。同一个记录器同时只由一个协程写入，输出顺序不变
+type WorkerPool struct {
+	quantum int
+	mu      sync.Mutex
+	cond    *sync.Cond
+	ready   []*Logger // 有日志等待写入的记录器，每个最多出现一次
+	closed  bool
+	wg      sync.WaitGroup
+}
+
+// NewWorkerPool 创建并启动写入协程池
+func NewWorkerPool(cfg WorkerPoolConfig) *WorkerPool {
+	if cfg.Workers <= 0 {
+		cfg.Workers = 1
+	}
+	if cfg.Quantum <= 0 {
+		cfg.Quantum = defaultPoolQuantum
+	}
+	p := &WorkerPool{quantum: cfg.Quantum}
+	p.cond = sync.NewCond(&p.mu)
+	for i := 0; i < cfg.Workers; i++ {
+		p.wg.Add(1)
+		go p.run()
+	}
+	return p
+}
+
+// WithWorkerPool 由 p 写入日志，StartWorker 不再启动单独的写入协程。
+// 通常与 WithQueueSize 一起使用以减少每个记录器的内存占用。不能与 WithWAL、WithDedup、WithWorkers 同时使用
+func WithWorkerPool(p *WorkerPool) Option {
+	return func(l *Logger) {
+		l.pool = p
+	}
+}
+
+// WithQueueSize 设置日志队列的容量(向上取整为2的幂)，默认 2048
+func WithQueueSize(n int) Option {
+	return func(l *Logger) {
+		if n > 0 {
+			l.queue = newRingBuffer(n)
+		}
+	}
+}
+
+func (l *Logger) checkWorkerPool() error {
+	if l.pool == nil {
+		return nil
+	}
+	if l.wal != nil || l.dedup != nil || l.workers > 1 {
+		return errors.New("logx: WithWorkerPool does not support WithWAL, WithDedup or WithWorkers")
+	}
+	return nil
+}
+
+// 在 StartWorker 中调用，之后有新日志时加入调度
+func (l *Logger) attachPool() {
+	l.wg.Add(1) // 队列关闭且写完后由协程池释放
+	l.queue.schedule = func() {
+		if !l.scheduled.Load() && l.scheduled.CompareAndSwap(false, true) {
+			l.pool.push(l)
+		}
+	}
+	l.queue.schedule()
+}
+
+// Close 等待已调度的日志写完后停止写入协程，应在使用它的日志记录器都 Close 之后调用；
+// 之后仍有日志的记录器在调用方协程中同步写入
+func (p *WorkerPool) Close() {
+	p.mu.Lock()
+	p.closed = true
+	p.cond.Broadcast()
+	p.mu.Unlock()
+	p.wg.Wait()
+}
+
+func (p *WorkerPool) push(l *Logger) {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		var batch []Entry
+		for more := true; more; {
+			batch, more = p.serve(l, batch)
+		}
+		return
+	}
+	p.ready = append(p.ready, l)
+	p.cond.Signal()
+	p.mu.Unlock()
+}
+
+// 取出下一个记录器，协程池已关闭且没有等待的记录器时返回 nil
+func (p *WorkerPool) next() *Logger {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for len(p.ready) == 0 && !p.closed {
+		p.cond.Wait()
+	}
+	if len(p.ready) == 0 {
+		return nil
+	}
+	l := p.ready[0]
+	p.ready[0] = nil
+	p.ready = p.ready[1:]
+	return l
+}
+
+func (p *WorkerPool) run() {
+	defer p.wg.Done()
+	batch := make([]Entry, 0, p.quantum)
+	for {
+		l := p.next()
+		if l == nil {
+			return
+		}
+		var more bool
+		if batch, more = p.serve(l, batch); more {
+			p.mu.Lock()
+			p.ready = append(p.ready, l)
+			p.mu.Unlock()
+		}
+	}
+}
+
+// 写入 l 的最多 quantum 条日志，返回 true 表示还有日志，需要重新排队
+func (p *WorkerPool) serve(l *Logger, batch []Entry) ([]Entry, bool) {
+	batch = l.queue.popBatch(batch[:0], p.quantum)
+	if len(batch) > 0 {
+		l.writeBatch(batch)
+		l.processed.Add(uint64(len(batch)))
+	}
+	if l.queue.ready() {
+		return batch, true
+	}
+	if l.queue.closed.Load() {
+		if l.queue.inflight.Load() > 0 {
+			// 等仍在放入的日志完成
+			return batch, true
+		}
+		if l.queue.ready() {
+			return batch, true
+		}
+		// 写完了，scheduled 保持为 true，不会再被调度
+		l.wg.Done()
+		return batch, false
+	}
+	// 清除标记后再检查一次，避免错过清除之前放入的日志
+	l.scheduled.Store(false)
+	if (l.queue.ready() || l.queue.closed.Load()) && l.scheduled.CompareAndSwap(false, true) {
+		return batch, true
+	}
+	return batch, false
+}
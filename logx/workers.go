@@ -0,0 +1,82 @@
+package logx
+
+import "sync"
+
+// 一批日志至少有这么多条时才并行编码，条数太少时分发的开销比编码本身更大
+const minParallelBatch = 32
+
+// WithWorkers 使用 n 个协程并行编码日志，适合 JSON 编码占满一个核的场景。
+// 脱敏、高基数保护等有状态的处理和写入仍由一个协程按入队顺序完成，输出顺序与单协程时一致
+func WithWorkers(n int) Option {
+	return func(l *Logger) {
+		if n < 1 {
+			n = 1
+		}
+		l.workers = n
+	}
+}
+
+// 预先编码好的一行，bufp 来自缓冲池
+type encodedLine struct {
+	bufp      *[]byte
+	bodyStart int
+}
+
+type encodeJob struct {
+	entries []Entry
+	lines   []encodedLine
+	wg      *sync.WaitGroup
+}
+
+// 启动 workers-1 个编码协程，写入协程自己也负责编码一段
+func (l *Logger) startEncoders() {
+	if l.workers <= 1 {
+		return
+	}
+	l.encodeJobs = make(chan encodeJob, l.workers)
+	l.encodedLines = make([]encodedLine, workerBatchSize)
+	for i := 1; i < l.workers; i++ {
+		l.wg.Add(1)
+		go func() {
+			defer l.wg.Done()
+			for job := range l.encodeJobs {
+				l.encodeAll(job.entries, job.lines)
+				job.wg.Done()
+			}
+		}()
+	}
+}
+
+// 写入协程退出时调用
+func (l *Logger) stopEncoders() {
+	if l.encodeJobs != nil {
+		close(l.encodeJobs)
+	}
+}
+
+func (l *Logger) encodeAll(entries []Entry, lines []encodedLine) {
+	for i := range entries {
+		lines[i].bufp, lines[i].bodyStart = l.encode(entries[i])
+	}
+}
+
+// 按批内序号把日志分段交给编码协程，返回时 lines[i] 是 batch[i] 编码后的结果，
+// 之后由写入协程按序号依次提交，保证顺序
+func (l *Logger) encodeBatch(batch []Entry, lines []encodedLine) {
+	chunk := (len(batch) + l.workers - 1) / l.workers
+	var wg sync.WaitGroup
+	start := 0
+	for ; start+chunk < len(batch); start += chunk {
+		wg.Add(1)
+		l.encodeJobs <- encodeJob{entries: batch[start : start+chunk], lines: lines[start : start+chunk], wg: &wg}
+	}
+	l.encodeAll(batch[start:], lines[start:])
+	wg.Wait()
+}
+
+func releaseLines(lines []encodedLine) {
+	for i := range lines {
+		putBuffer(lines[i].bufp)
+		lines[i] = encodedLine{}
+	}
+}
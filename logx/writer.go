@@ -0,0 +1,67 @@
+package logx
+
+import (
+	"bytes"
+	"io"
+	"sync"
+)
+
+// 不完整的行超过该长度时不再等待换行，直接作为一条日志写入
+const maxPendingLine = 64 * 1024
+
+// Writer 返回一个 io.Writer，写入的内容按行拆分，每行作为一条 level 等级的日志，
+// 可用于 http.Server.ErrorLog(log.New(l.Writer(logx.ERROR), "", 0))、log.SetOutput 等只接受 io.Writer 的地方。
+// 末尾没有换行的内容会等到下一次写入；返回值同时实现了 io.Closer，Close 时写入剩余内容
+func (l *Logger) Writer(level LogLevel) io.Writer {
+	return &levelWriter{l: l, level: level}
+}
+
+type levelWriter struct {
+	l       *Logger
+	level   LogLevel
+	mu      sync.Mutex
+	pending []byte
+}
+
+func (w *levelWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	n := len(p)
+	for len(p) > 0 {
+		i := bytes.IndexByte(p, '\n')
+		if i < 0 {
+			w.pending = append(w.pending, p...)
+			if len(w.pending) >= maxPendingLine {
+				w.emit(w.pending)
+				w.pending = w.pending[:0]
+			}
+			break
+		}
+		line := p[:i]
+		if len(w.pending) > 0 {
+			line = append(w.pending, line...)
+			w.pending = w.pending[:0]
+		}
+		w.emit(line)
+		p = p[i+1:]
+	}
+	return n, nil
+}
+
+func (w *levelWriter) emit(line []byte) {
+	line = bytes.TrimSuffix(line, []byte{'\r'})
+	if len(line) == 0 {
+		return
+	}
+	w.l.log(w.level, string(line), nil)
+}
+
+func (w *levelWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if len(w.pending) > 0 {
+		w.emit(w.pending)
+		w.pending = nil
+	}
+	return nil
+}